@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"github.com/kubenoops-ai/google-drive-downloader/pkg/drive"
+)
+
+// Exit statuses, so scripts can tell outcomes apart
+const (
+	// exitOK is used when the run succeeded
+	exitOK = 0
+	// exitFailure is used for errors without a status of their own
+	exitFailure = 1
+	// exitNoMatches is used when a search matched no files
+	exitNoMatches = 2
+	// exitPartial is used when some files failed to download
+	exitPartial = 3
+	// exitAuth is used when the credentials can't be loaded or are rejected
+	exitAuth = 4
+	// exitUsage is used for invalid flags and arguments
+	exitUsage = 5
+	// exitTimeout is used when -timeout expires
+	exitTimeout = 6
+	// exitTooFew is used when fewer files matched than -min-expected
+	exitTooFew = 7
+)
+
+// exitCode returns the exit status for a run that failed with err. Requests
+// rejected earlier in the run for lack of valid credentials don't count:
+// only the error the run failed with decides the status.
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, drive.ErrInvalidCredentials):
+		return exitAuth
+	case errors.Is(err, context.DeadlineExceeded):
+		return exitTimeout
+	case errors.Is(err, drive.ErrTooManyFailures):
+		return exitPartial
+	case errors.Is(err, drive.ErrInvalidQuery):
+		return exitUsage
+	case drive.IsAuthError(err):
+		return exitAuth
+	}
+	return exitFailure
+}
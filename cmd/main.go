@@ -1,141 +1,2194 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
-	"path/filepath"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/kubenoops-ai/google-drive-downloader/pkg/drive"
+	"github.com/kubenoops-ai/google-drive-downloader/pkg/hooks"
 	"github.com/kubenoops-ai/google-drive-downloader/pkg/transform"
 	"github.com/kubenoops-ai/google-drive-downloader/pkg/utils"
 )
 
 func main() {
+	os.Exit(run())
+}
+
+// run parses the flags and runs the mode they select, returning the exit
+// status
+func run() (code int) {
+	started := time.Now()
+
 	var (
 		credentials string
-		folderID    string
+		useADC      bool
+		folderIDs   stringList
+		withShared  bool
 		pattern     string
+		patternFile string
+		matchTarget string
+		folderRegex string
 		maxDepth    int
 		dryRun      bool
 		outputDir   string
+		seenBloom   string
+		excludeSeen bool
+		rebuildBF   bool
+		finishInc   bool
 		verbose     bool
+		quiet       bool
+		httpTrace   string
+		summaryOut  string
+		changesTok  string
+		traceBody   bool
 		maxResults  int
+		minExpect   int
+		countOnly   bool
 		pathPattern string
 		pathFormat  string
+		pathPatFile string
+		pathFmtFile string
+		rulesFile   string
+		unmatchDir  string
+		checkRules  bool
+		coverage    bool
+		pathReplace stringList
+		routeRules  stringList
+		verifyOnly  bool
+		verifyJobs  int
+		exportJobs  int
+		smartSched  bool
+		shards      int
+		byExt       bool
+		printSchema bool
+		listFormats bool
+		formatsJSON bool
+		debugPar    string
+		crawlDump   string
+		crawlLoad   string
+		prefixDrive bool
+		stream      bool
+		tarOut      string
+		sinkSpec    string
+		writeMeta   bool
+		folderDesc  bool
+		writeXattrs bool
+		redact      string
+		symlinkDups bool
+		hardlinkDup bool
+		runTimeout  time.Duration
+		createdAft  string
+		since       string
+		createdBef  string
+		manifestOut string
+		apiRetries  int
+		retryBudget int
+		pageSize    int
+		prefetch    bool
+		onPathErr   string
+		maxConns    int
+		rps         float64
+		maxIdle     int
+		fileModeArg string
+		dirModeArg  string
+		categories  stringList
+		typeNames   stringList
+		catFile     string
+		dryRunDiff  bool
+		mtimeTol    time.Duration
+		skipExports bool
+		tagRev      bool
+		extensions  string
+		orderBy     string
+		dlOrder     string
+		onCollision string
+		caseFS      string
+		maxPerExt   int
+		perFolder   int
+		maxFolders  int
+		revisions   string
+		matchFolder bool
+		leafOnly    bool
+		trashAfter  bool
+		trashAck    bool
+		requireRO   bool
+		owners      stringList
+		ownedByMe   bool
+		modifiedBy  stringList
+		labelSpecs  stringList
+		collapseAt  int
+		compress    string
+		showVersion bool
+		selfTest    bool
+		variants    stringList
+		auditShare  bool
+		internalDom stringList
+		execCmd     string
+		execTimeout time.Duration
+		maxErrors   int
+		nativeLink  bool
+		verifySum   bool
+		sumRetries  int
+		query       string
 	)
 
 	flag.StringVar(&credentials, "credentials", "credentials.json", "Path to credentials file")
-	flag.StringVar(&folderID, "folder-id", "", "Folder ID to start search from (optional)")
+	flag.BoolVar(&useADC, "use-adc", false, "Use Application Default Credentials, such as workload identity on Google Cloud or 'gcloud auth application-default login', instead of a credentials file")
+	flag.Var(&folderIDs, "folder-id", "Folder ID to start search from (optional, repeatable)")
+	flag.BoolVar(&withShared, "shared-with-me", false, "Search files and folders shared directly with the account instead of its root folder (combines with -folder-id)")
 	flag.StringVar(&pattern, "pattern", "", "Regex pattern to match files")
+	flag.StringVar(&patternFile, "pattern-file", "", "File holding the -pattern regex, as an alternative to passing it inline")
+	flag.StringVar(&matchTarget, "match-target", drive.MatchName, "What -pattern and -ext are matched against: name (the file name) or path (the path from the folder searched, e.g. 'Zoom Recordings/.*/x\\.TRANSCRIPT')")
+	flag.StringVar(&folderRegex, "folder-pattern", "", "Regex pattern a folder name in each file's path must match, such as the meeting folder a recording is in; filters the files found, every folder is still searched (optional)")
+	flag.StringVar(&extensions, "ext", "", "Comma-separated list of file extensions to match (e.g. 'TRANSCRIPT,mp4,m4a')")
+	flag.Var(&typeNames, "type", "Only match files of this type, filtered by Drive: "+strings.Join(drive.FileTypeNames(), ", ")+" (repeatable)")
+	flag.Var(&categories, "category", "Only match files in this category, e.g. video, audio, image or document (repeatable)")
+	flag.StringVar(&catFile, "categories-file", "", "JSON file defining or overriding categories for -category (optional)")
+	flag.BoolVar(&matchFolder, "match-folders", false, "Also include folders whose names match the pattern in the results")
+	flag.BoolVar(&leafOnly, "leaf-only", false, "Only include files in folders that have no subfolders")
+	flag.StringVar(&query, "query", "", "Drive query ANDed with the folder listing query, e.g. \"mimeType='application/pdf'\" (optional)")
+	flag.StringVar(&createdAft, "created-after", "", "Only match files created after this date or RFC 3339 time (optional)")
+	flag.StringVar(&createdBef, "created-before", "", "Only match files created before this date or RFC 3339 time (optional)")
+	flag.StringVar(&since, "since", "", "Only match files modified within this long before now, e.g. 24h, 7d or 2w (optional)")
+	flag.Var(&owners, "owner", "Only match files owned by this email address (repeatable)")
+	flag.BoolVar(&ownedByMe, "owned-by-me", false, "Only match files owned by the account the credentials belong to, leaving out files shared with it")
+	flag.Var(&modifiedBy, "last-modified-by", "Only match files last modified by this email address (repeatable)")
+	flag.Var(&labelSpecs, "label", "Only match files with this Drive label, given as labelId or labelId.fieldId=value (repeatable, Google Workspace only)")
 	flag.IntVar(&maxDepth, "max-depth", -1, "Maximum depth to search (-1 for unlimited)")
 	flag.BoolVar(&dryRun, "dry-run", false, "Only list files, don't download")
-	flag.StringVar(&outputDir, "output-dir", "output", "Directory to save downloaded files")
+	flag.BoolVar(&dryRunDiff, "dry-run-diff", false, "Compare matching files with the output directory and list which would be new, updated or unchanged, without downloading")
+	flag.DurationVar(&mtimeTol, "mtime-tolerance", drive.DefaultModTimeTolerance, "How much older than the Drive file a local copy may be and still count as unchanged by -dry-run-diff when compared by modification time")
+	flag.StringVar(&manifestOut, "manifest-only", "", "Write a CSV manifest (id,path,webContentLink,md5,size) of matching files to this file ('-' for standard output) instead of downloading")
+	flag.IntVar(&apiRetries, "retries", drive.DefaultRetries, "Retry Drive requests that fail with rate limiting, server or network errors up to this many times")
+	flag.IntVar(&retryBudget, "retry-budget", 0, "Maximum number of retries across the whole run; once used up, failing requests are not retried (0 for no limit)")
+	flag.IntVar(&pageSize, "page-size", drive.MaxPageSize, "Number of files requested per page when listing a folder (1-1000)")
+	flag.BoolVar(&prefetch, "prefetch-metadata-batch", false, "Look up the parent folders of files found outside the searched folders in batched queries instead of one request per folder")
+	flag.StringVar(&onPathErr, "on-path-error", drive.PathErrorShallow, "What to do with a file found outside the searched folders whose folders can't be looked up: shallow (save it under its name alone), skip or fail")
+	flag.Float64Var(&rps, "rps", 0, "Maximum Drive API requests per second, shared by listing and downloads (0 for no limit)")
+	flag.IntVar(&maxConns, "max-conns-per-host", drive.DefaultMaxConnsPerHost, "Maximum simultaneous connections to each Google host (0 for no limit)")
+	flag.StringVar(&fileModeArg, "file-mode", "", "Octal permissions for downloaded files, e.g. 0640 (default 0666, less the umask)")
+	flag.StringVar(&dirModeArg, "dir-mode", "", "Octal permissions for directories created for downloads, e.g. 0750 (default 0755, less the umask)")
+	flag.IntVar(&maxIdle, "max-idle-conns-per-host", drive.DefaultMaxIdleConnsPerHost, "Maximum idle connections kept open for reuse per Google host")
+	flag.DurationVar(&runTimeout, "timeout", 0, "Abort listing and downloads once the whole run exceeds this duration, e.g. 30m (0 for no limit)")
+	flag.BoolVar(&stream, "stream", false, "Start downloading files as soon as they are found instead of after listing; results are not sorted")
+	flag.StringVar(&tarOut, "tar", "", "Write downloaded files into a single tar archive at this path ('-' for standard output) instead of the output directory")
+	flag.StringVar(&sinkSpec, "sink", "", "Save downloaded files to this location instead of the output directory: gs://bucket/prefix, s3://bucket/prefix or file:///dir")
+	flag.StringVar(&revisions, "revisions", "", "Also download past revisions of each file: all, latest or N most recent (optional)")
+	flag.Var(&variants, "variant", "Output to produce for each file: "+strings.Join(drive.VariantNames(), ", ")+" (repeatable, default original)")
+	flag.IntVar(&exportJobs, "export-concurrency", 0, "Export pdf-export variants on this many workers in the background while downloads continue (0 exports each file before the next)")
+	flag.BoolVar(&smartSched, "smart-schedule", false, "Have -export-concurrency workers export the largest queued file first, so the run isn't left waiting on one large export at the end")
+	flag.BoolVar(&skipExports, "skip-unchanged-exports", false, "Don't export a pdf-export variant again when the Google Docs editors file hasn't changed since its local export")
+	flag.BoolVar(&tagRev, "tag-revision", false, "Append the head revision of Google Docs editors files to the names of their exports, e.g. notes@rev123.pdf")
+	flag.StringVar(&compress, "compress", "", "Compress downloaded files: gzip (optional)")
+	flag.StringVar(&outputDir, "output-dir", "output", "Directory to save downloaded files; may be a template such as 'downloads/{{.Owner}}'")
+	flag.StringVar(&seenBloom, "seen-bloom", "", "Bloom filter file of the IDs of files downloaded by earlier runs, which are skipped; created if missing (optional)")
+	flag.BoolVar(&excludeSeen, "exclude-seen", false, "Leave the files in the -seen-bloom filter out while crawling, before matching them, instead of skipping them at download time")
+	flag.BoolVar(&rebuildBF, "rebuild-bloom", false, "Rebuild the -seen-bloom filter from the -write-metadata sidecars in the output directory and exit")
+	flag.BoolVar(&finishInc, "finish-incomplete", false, "Finish the downloads a crashed run left as .part files in the output directory, without listing Drive, and exit")
+	flag.IntVar(&collapseAt, "collapse-after", 0, "Join output directories beyond this nesting depth into a single directory name (0 to keep all)")
+	flag.BoolVar(&byExt, "by-extension", false, "Save each file under a directory named after its extension, e.g. mp4/ or TRANSCRIPT/, with files lacking one under no-ext/")
+	flag.BoolVar(&prefixDrive, "prefix-drive-id", false, "Save each file under a top-level directory named after its shared drive ('My Drive' outside shared drives)")
+	flag.IntVar(&shards, "shard-by-hash", 0, "Spread files over N subdirectories named after a hash of the file name; N must be 16, 256, 4096, ... (0 to disable)")
+	flag.StringVar(&execCmd, "exec", "", "Command to run after each download, templated with file fields (e.g. 'ffmpeg -i {{.Path}} {{.Path}}.mp3')")
+	flag.DurationVar(&execTimeout, "exec-timeout", 5*time.Minute, "Maximum run time of each -exec command (0 for no limit)")
+	flag.IntVar(&maxErrors, "max-errors", 0, "Abort the run once this many files have failed (0 for no limit)")
+	flag.BoolVar(&nativeLink, "native-as-link", false, "Save Google Docs, Sheets, Slides and other Google-native files as JSON stubs (.gdoc, .gsheet, ...) linking to them in Drive instead of failing to download them")
+	flag.StringVar(&onCollision, "on-collision", drive.CollisionOverwrite, "What to do when several files map to the same output path: overwrite, skip, rename or fail")
+	flag.StringVar(&caseFS, "case-insensitive-fs", "auto", "Whether output paths differing only in case collide: true, false or auto to check the output directory's filesystem")
+	flag.BoolVar(&symlinkDups, "symlink-duplicates", false, "Save files whose content was already downloaded in this run as symlinks to the first copy")
+	flag.BoolVar(&hardlinkDup, "hardlink-duplicates", false, "Save files whose content was already downloaded in this run as hardlinks to the first copy")
+	flag.BoolVar(&writeMeta, "write-metadata", false, "Write each file's Drive metadata to <path>.meta.json next to the download")
+	flag.BoolVar(&writeXattrs, "xattr", false, "Record each file's Drive ID and MD5 as the user.drive.id and user.drive.md5 extended attributes of the download (Linux and macOS)")
+	flag.BoolVar(&folderDesc, "export-folder-descriptions", false, "Save the description of the Drive folder holding downloaded files to .folder-description.txt in their output directory")
+	flag.StringVar(&redact, "redact-fields", "", "Comma-separated metadata fields to clear from -write-metadata sidecars, e.g. 'owners,permissions'")
+	flag.BoolVar(&verifySum, "verify-checksum", false, "Verify each download against the MD5 checksum reported by Drive")
+	flag.IntVar(&sumRetries, "retry-on-checksum-mismatch", 0, "Download a file again up to N times when its checksum does not match (requires -verify-checksum or -trash-after-download, which verifies checksums)")
+	flag.BoolVar(&verifyOnly, "verify-only", false, "Check existing downloads in the output directory against Drive checksums without downloading")
+	flag.IntVar(&verifyJobs, "verify-workers", 4, "Number of files hashed at once by -verify-only, and by -verify-checksum in the background while downloads continue")
+	flag.BoolVar(&trashAfter, "trash-after-download", false, "Move each file to the Drive trash after it is downloaded and its checksum verified")
+	flag.BoolVar(&trashAck, "i-understand-this-trashes-files", false, "Confirm that -trash-after-download should trash files in Drive")
+	flag.BoolVar(&requireRO, "require-readonly", false, "Abort if the scopes granted to the access token allow writing to Drive, such as user credentials authorized for full drive access, instead of only warning")
 	flag.BoolVar(&verbose, "verbose", false, "Enable verbose logging")
-	flag.IntVar(&maxResults, "max", 0, "Maximum number of files to return (0 for unlimited)")
+	flag.BoolVar(&quiet, "quiet", false, "Don't show the search heartbeat or download progress bars")
+	flag.StringVar(&httpTrace, "http-trace", "", "Log the method, URL, status and latency of every Drive API request to this file ('-' for standard error), with credentials redacted")
+	flag.BoolVar(&traceBody, "http-trace-body", false, "Also log request and response bodies, up to 64 KiB each, in the -http-trace log")
+	flag.StringVar(&summaryOut, "summary-json", "", "Write a JSON summary of the download run (counts, bytes, elapsed time, API requests and retries) to this file at the end")
+	flag.IntVar(&maxResults, "max", 0, "Maximum number of files to return, the first in -order-by order (0 for unlimited)")
+	flag.BoolVar(&countOnly, "count-only", false, "Print only the number of matching files, without listing or downloading them, and exit")
+	flag.IntVar(&minExpect, "min-expected", 0, "Fail without downloading when fewer than this many files match, to catch broken patterns or lost access (0 to disable)")
+	flag.IntVar(&maxPerExt, "max-per-ext", 0, "Maximum number of files to return per file extension (0 for unlimited)")
+	flag.IntVar(&perFolder, "per-folder-limit", 0, "Maximum number of files to return per folder, keeping the first ones in sort order (0 for unlimited)")
+	flag.IntVar(&maxFolders, "max-folders", 0, "Stop searching new folders once this many have been listed, returning the files found so far (0 for unlimited)")
+	flag.StringVar(&orderBy, "order-by", "modified", "Sort results by modified, created, name, size or path, with an optional :asc or :desc suffix")
+	flag.StringVar(&dlOrder, "download-order", "", "Order to download files in, independent of -order-by: smallest, largest, oldest, newest or path (default listing order)")
 	flag.StringVar(&pathPattern, "path-pattern", "", "Regex pattern with named groups to transform output paths (e.g. 'Zoom Recordings/(?P<date>[^/]+)/.*\\.TRANSCRIPT')")
 	flag.StringVar(&pathFormat, "path-format", "", "Format string for transformed paths using named groups (e.g. '${date}.TRANSCRIPT')")
+	flag.StringVar(&pathPatFile, "path-pattern-file", "", "File holding the -path-pattern regex, as an alternative to passing it inline")
+	flag.StringVar(&pathFmtFile, "path-format-file", "", "File holding the -path-format string, as an alternative to passing it inline")
+	flag.Var(&pathReplace, "path-replace", "Rewrite matches within output paths, sed-style, as 'pattern=>replacement' using $1 or ${name} (repeatable, applied in order)")
+	flag.Var(&routeRules, "route", "Save files whose path matches a glob under a subdirectory of the output directory, as 'glob=>subdir', e.g. '*.mp4=>videos' (repeatable, first match wins)")
+	flag.BoolVar(&checkRules, "validate-rules", false, "List every path rule matching each file, flagging files whose matching rules disagree, and exit without downloading")
+	flag.BoolVar(&coverage, "transform-coverage", false, "Report how many matching files the path rules transform and sample those no rule matches, and exit without downloading")
+	flag.StringVar(&rulesFile, "rules-file", "", "File of 'pattern=>format' path rules, one per line; '-' reads standard input")
+	flag.StringVar(&unmatchDir, "unmatched-dir", "", "Save files that match no path rule under this subdirectory of the output directory, keeping their original path beneath it (optional)")
+
+	flag.BoolVar(&auditShare, "audit-sharing", false, "Report matched files shared publicly or outside the internal domains instead of downloading")
+	flag.Var(&internalDom, "internal-domain", "Domain treated as internal by -audit-sharing (repeatable, defaults to each file owner's domain)")
+	flag.BoolVar(&showVersion, "version", false, "Print version information and exit")
+	flag.BoolVar(&selfTest, "self-test", false, "Run offline checks of pattern matching and path transformation and exit")
+	flag.BoolVar(&printSchema, "print-schema", false, "Print the JSON Schema of file records and exit")
+	flag.StringVar(&changesTok, "changes-token", "", "File keeping a Drive change token: the first run downloads every match and saves it, later runs download only files changed since")
+	flag.StringVar(&crawlDump, "crawl-dump", "", "Crawl the whole folder tree, ignoring the pattern and filters, save it to this JSON file and exit")
+	flag.StringVar(&crawlLoad, "crawl-load", "", "Match files against a tree saved by -crawl-dump instead of Drive, without any API calls; implies -dry-run")
+	flag.BoolVar(&listFormats, "list-export-formats", false, "Print the formats each Google Docs editors file type can be exported to, and those Drive converts to them on upload, and exit")
+	flag.BoolVar(&formatsJSON, "formats-json", false, "Print -list-export-formats as JSON instead of a table")
+	flag.StringVar(&debugPar, "debug-parents", "", "Print the chain of parent folders Drive reports for this file ID, as used to compute its path, and exit")
+
+	// Report invalid flags with exitUsage rather than the flag package's 2
+	flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
+	if err := flag.CommandLine.Parse(os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	// Messages go to standard error when standard output is kept for the
+	// archive of -tar -, the manifest of -manifest-only - or the count of
+	// -count-only
+	out := os.Stdout
+	if tarOut == "-" || manifestOut == "-" || countOnly {
+		out = os.Stderr
+	}
+
+	// Only flags naming files and directories; patterns, formats and
+	// templates may hold a literal $
+	if err := utils.ExpandEnvFlags(flag.CommandLine, "credentials", "output-dir", "categories-file", "rules-file", "manifest-only",
+		"tar", "http-trace", "summary-json", "changes-token", "crawl-dump", "crawl-load", "seen-bloom",
+		"pattern-file", "path-pattern-file", "path-format-file"); err != nil {
+		fmt.Fprintf(out, "Error: %v\n", err)
+		flag.Usage()
+		return exitUsage
+	}
+	for _, f := range []struct {
+		value            *string
+		inline, fileFlag string
+		path             string
+	}{
+		{&pattern, "pattern", "pattern-file", patternFile},
+		{&pathPattern, "path-pattern", "path-pattern-file", pathPatFile},
+		{&pathFormat, "path-format", "path-format-file", pathFmtFile},
+	} {
+		if err := readFlagFile(f.value, f.inline, f.fileFlag, f.path); err != nil {
+			fmt.Fprintf(out, "Error: %v\n", err)
+			flag.Usage()
+			return exitUsage
+		}
+	}
+	if useADC {
+		if flagPassed("credentials") {
+			fmt.Fprintln(out, "Error: -use-adc and -credentials cannot be combined")
+			flag.Usage()
+			return exitUsage
+		}
+		// An empty path tells the drive package to find default credentials
+		credentials = ""
+	}
+
+	if showVersion {
+		printVersion()
+		return exitOK
+	}
+	if selfTest {
+		if !runSelfTest() {
+			fmt.Fprintln(out, "Self-test failed")
+			return exitFailure
+		}
+		fmt.Fprintln(out, "Self-test passed")
+		return exitOK
+	}
+	if printSchema {
+		schema, err := drive.FileInfoSchema()
+		if err != nil {
+			fmt.Fprintf(out, "Error generating schema: %v\n", err)
+			return exitFailure
+		}
+		fmt.Println(string(schema))
+		return exitOK
+	}
+	if rebuildBF {
+		if seenBloom == "" || strings.Contains(outputDir, "{{") {
+			fmt.Fprintln(out, "Error: -rebuild-bloom needs -seen-bloom and a plain -output-dir")
+			flag.Usage()
+			return exitUsage
+		}
+		seen, err := drive.RebuildBloomFilter(outputDir)
+		if err == nil {
+			err = seen.Save(seenBloom)
+		}
+		if err != nil {
+			fmt.Fprintf(out, "Error: %v\n", err)
+			return exitFailure
+		}
+		fmt.Fprintf(out, "Rebuilt %s with %d file IDs from the metadata sidecars in %s\n", seenBloom, seen.Count(), outputDir)
+		return exitOK
+	}
+	svcConfig, err := parseServiceFlags(serviceConfig{
+		credentials: credentials,
+		verbose:     verbose,
+		timeout:     runTimeout,
+		retries:     apiRetries,
+		retryBudget: retryBudget,
+		maxConns:    maxConns,
+		maxIdle:     maxIdle,
+		rps:         rps,
+		httpTrace:   httpTrace,
+		traceBody:   traceBody,
+	}, fileModeArg, dirModeArg)
+	if err != nil {
+		fmt.Fprintf(out, "Error: %v\n", err)
+		flag.Usage()
+		return exitUsage
+	}
+	if debugPar != "" {
+		driveService, err := newDriveService(out, credentials, verbose, drive.ReadonlyScope)
+		if err != nil {
+			return exitCode(err)
+		}
+		chain, err := driveService.ParentChain(debugPar)
+		if err != nil {
+			fmt.Fprintf(out, "Error: %v\n", err)
+			return exitCode(err)
+		}
+		printParentChain(out, chain)
+		return exitOK
+	}
+	if finishInc {
+		return finishIncomplete(out, svcConfig, outputDir, verifySum)
+	}
+	if listFormats {
+		driveService, err := newDriveService(out, credentials, verbose, drive.ReadonlyScope)
+		if err != nil {
+			return exitCode(err)
+		}
+		formats, err := driveService.Formats()
+		if err != nil {
+			fmt.Fprintf(out, "Error: %v\n", err)
+			return exitCode(err)
+		}
+		if err := printFormats(os.Stdout, formats, formatsJSON); err != nil {
+			fmt.Fprintf(out, "Error: %v\n", err)
+			return exitFailure
+		}
+		return exitOK
+	}
+
+	var extList []string
+	for _, ext := range strings.Split(extensions, ",") {
+		if ext = strings.TrimSpace(ext); ext != "" {
+			extList = append(extList, ext)
+		}
+	}
+
+	if pattern == "" && len(extList) == 0 && crawlDump == "" {
+		fmt.Fprintln(out, "Error: pattern or ext is required")
+		flag.Usage()
+		return exitUsage
+	}
+
+	sortOrder, err := drive.ParseSortOrder(orderBy)
+	if err != nil {
+		fmt.Fprintf(out, "Error: %v\n", err)
+		flag.Usage()
+		return exitUsage
+	}
+
+	if err := drive.ValidatePageSize(pageSize); err != nil {
+		fmt.Fprintf(out, "Error: %v\n", err)
+		flag.Usage()
+		return exitUsage
+	}
+	var redactList []string
+	for _, field := range strings.Split(redact, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			redactList = append(redactList, field)
+		}
+	}
+	if err := drive.ValidateRedactFields(redactList); err != nil {
+		fmt.Fprintf(out, "Error: %v\n", err)
+		flag.Usage()
+		return exitUsage
+	}
+	if len(redactList) > 0 && !writeMeta {
+		fmt.Fprintln(out, "Warning: -redact-fields only applies to sidecars written with -write-metadata")
+	}
+
+	if verifyJobs < 1 {
+		fmt.Fprintln(out, "Error: verify-workers must be at least 1")
+		flag.Usage()
+		return exitUsage
+	}
+	if exportJobs < 0 {
+		fmt.Fprintln(out, "Error: export-concurrency cannot be negative")
+		flag.Usage()
+		return exitUsage
+	}
+	if formatsJSON && !listFormats {
+		fmt.Fprintln(out, "Error: -formats-json requires -list-export-formats")
+		flag.Usage()
+		return exitUsage
+	}
+	if smartSched && exportJobs == 0 {
+		fmt.Fprintln(out, "Error: -smart-schedule requires -export-concurrency")
+		flag.Usage()
+		return exitUsage
+	}
+
+	if maxErrors < 0 {
+		fmt.Fprintln(out, "Error: max-errors must not be negative")
+		flag.Usage()
+		return exitUsage
+	}
+	if minExpect < 0 {
+		fmt.Fprintln(out, "Error: min-expected must not be negative")
+		flag.Usage()
+		return exitUsage
+	}
+	if minExpect > 0 && changesTok != "" {
+		fmt.Fprintln(out, "Error: -min-expected cannot be combined with -changes-token, as few files may change between runs")
+		flag.Usage()
+		return exitUsage
+	}
+	if maxFolders < 0 {
+		fmt.Fprintln(out, "Error: max-folders must not be negative")
+		flag.Usage()
+		return exitUsage
+	}
+	if mtimeTol < 0 {
+		fmt.Fprintln(out, "Error: mtime-tolerance must not be negative")
+		flag.Usage()
+		return exitUsage
+	}
+
+	if collapseAt < 0 {
+		fmt.Fprintln(out, "Error: collapse-after must not be negative")
+		flag.Usage()
+		return exitUsage
+	}
+
+	if stream && (dryRun || dryRunDiff || auditShare || verifyOnly || revisions != "" || manifestOut != "") {
+		fmt.Fprintln(out, "Error: -stream cannot be combined with -dry-run, -dry-run-diff, -audit-sharing, -verify-only, -revisions or -manifest-only")
+		flag.Usage()
+		return exitUsage
+	}
+	if summaryOut != "" && (dryRun || dryRunDiff || verifyOnly || auditShare || checkRules || coverage || manifestOut != "" || crawlDump != "" || crawlLoad != "") {
+		fmt.Fprintln(out, "Error: -summary-json summarizes downloads and cannot be combined with -dry-run, -dry-run-diff, -verify-only, "+
+			"-audit-sharing, -validate-rules, -transform-coverage, -manifest-only, -crawl-dump or -crawl-load")
+		flag.Usage()
+		return exitUsage
+	}
+	if changesTok != "" && (stream || dryRun || dryRunDiff || verifyOnly || auditShare || checkRules || coverage || manifestOut != "" ||
+		crawlDump != "" || crawlLoad != "" || tarOut != "" || revisions != "" || maxResults > 0 || maxPerExt > 0 || perFolder > 0 || maxFolders > 0 || query != "" || withShared || leafOnly) {
+		fmt.Fprintln(out, "Error: -changes-token cannot be combined with -stream, -dry-run, -dry-run-diff, -verify-only, -audit-sharing, -validate-rules, "+
+			"-transform-coverage, -manifest-only, -crawl-dump, -crawl-load, -tar, -revisions, -max, -max-per-ext, -per-folder-limit, -max-folders, -query, -shared-with-me or -leaf-only")
+		flag.Usage()
+		return exitUsage
+	}
+	if countOnly && (stream || dryRunDiff || verifyOnly || auditShare || checkRules || coverage || manifestOut != "" || crawlDump != "" || crawlLoad != "" ||
+		tarOut != "" || sinkSpec != "" || revisions != "" || changesTok != "" || summaryOut != "") {
+		fmt.Fprintln(out, "Error: -count-only cannot be combined with -stream, -dry-run-diff, -verify-only, -audit-sharing, -validate-rules, "+
+			"-transform-coverage, -manifest-only, -crawl-dump, -crawl-load, -tar, -sink, -revisions, -changes-token or -summary-json")
+		flag.Usage()
+		return exitUsage
+	}
+	if excludeSeen && seenBloom == "" {
+		fmt.Fprintln(out, "Error: -exclude-seen needs -seen-bloom")
+		flag.Usage()
+		return exitUsage
+	}
+	if crawlDump != "" && crawlLoad != "" {
+		fmt.Fprintln(out, "Error: -crawl-dump and -crawl-load cannot be combined")
+		flag.Usage()
+		return exitUsage
+	}
+	if crawlLoad != "" {
+		if stream || revisions != "" || prefixDrive || query != "" || len(labelSpecs) > 0 || trashAfter || maxFolders > 0 || ownedByMe {
+			fmt.Fprintln(out, "Error: -crawl-load works offline and cannot be combined with -stream, -revisions, -prefix-drive-id, -query, -label, -trash-after-download, -max-folders or -owned-by-me")
+			flag.Usage()
+			return exitUsage
+		}
+		if !dryRun && !dryRunDiff && !verifyOnly && !auditShare && !checkRules && !coverage && manifestOut == "" {
+			fmt.Fprintln(out, "Note: -crawl-load doesn't download anything; showing a dry run")
+			dryRun = true
+		}
+	}
+	if err := drive.ValidatePathErrorPolicy(onPathErr); err != nil {
+		fmt.Fprintf(out, "Error: %v\n", err)
+		flag.Usage()
+		return exitUsage
+	}
+	if err := drive.ValidateCollisionStrategy(onCollision); err != nil {
+		fmt.Fprintf(out, "Error: %v\n", err)
+		flag.Usage()
+		return exitUsage
+	}
+	if caseFS != "auto" && caseFS != "true" && caseFS != "false" {
+		fmt.Fprintf(out, "Error: invalid -case-insensitive-fs value %q (expected auto, true or false)\n", caseFS)
+		flag.Usage()
+		return exitUsage
+	}
+	if stream && onCollision != drive.CollisionOverwrite {
+		fmt.Fprintln(out, "Error: -on-collision needs the full list of files and cannot be combined with -stream")
+		flag.Usage()
+		return exitUsage
+	}
+	if stream && (orderBy != "modified" || dlOrder != "") {
+		fmt.Fprintln(out, "Warning: -order-by and -download-order have no effect with -stream; files are downloaded in the order they are found")
+	}
+
+	var downloadOrder *drive.SortOrder
+	if dlOrder != "" {
+		order, err := drive.ParseDownloadOrder(dlOrder)
+		if err != nil {
+			fmt.Fprintf(out, "Error: %v\n", err)
+			flag.Usage()
+			return exitUsage
+		}
+		downloadOrder = &order
+	}
+
+	var linkDups string
+	switch {
+	case symlinkDups && hardlinkDup:
+		fmt.Fprintln(out, "Error: -symlink-duplicates and -hardlink-duplicates cannot be combined")
+		flag.Usage()
+		return exitUsage
+	case symlinkDups:
+		linkDups = "symlink"
+	case hardlinkDup:
+		linkDups = "hardlink"
+	}
+
+	if tarOut != "" && (dryRunDiff || verifyOnly || auditShare || manifestOut != "" || revisions != "" || trashAfter ||
+		compress != "" || len(variants) > 0 || writeMeta || folderDesc || writeXattrs || execCmd != "" || linkDups != "" || sumRetries > 0) {
+		fmt.Fprintln(out, "Error: -tar cannot be combined with -dry-run-diff, -verify-only, -audit-sharing, -manifest-only, -revisions, -trash-after-download, "+
+			"-compress, -variant, -write-metadata, -export-folder-descriptions, -xattr, -exec, -symlink-duplicates, -hardlink-duplicates or -retry-on-checksum-mismatch")
+		flag.Usage()
+		return exitUsage
+	}
+	if sinkSpec != "" && (tarOut != "" || dryRunDiff || verifyOnly || auditShare || manifestOut != "" || revisions != "" || trashAfter ||
+		compress != "" || len(variants) > 0 || writeMeta || folderDesc || writeXattrs || execCmd != "" || linkDups != "" || sumRetries > 0) {
+		fmt.Fprintln(out, "Error: -sink cannot be combined with -tar, -dry-run-diff, -verify-only, -audit-sharing, -manifest-only, -revisions, -trash-after-download, "+
+			"-compress, -variant, -write-metadata, -export-folder-descriptions, -xattr, -exec, -symlink-duplicates, -hardlink-duplicates or -retry-on-checksum-mismatch")
+		flag.Usage()
+		return exitUsage
+	}
+	if err := transform.ValidateShards(shards); err != nil {
+		fmt.Fprintf(out, "Error: %v\n", err)
+		flag.Usage()
+		return exitUsage
+	}
+
+	if err := drive.ValidateCompression(compress); err != nil {
+		fmt.Fprintf(out, "Error: %v\n", err)
+		flag.Usage()
+		return exitUsage
+	}
+
+	if err := drive.ValidateVariants(variants); err != nil {
+		fmt.Fprintf(out, "Error: %v\n", err)
+		flag.Usage()
+		return exitUsage
+	}
+
+	if sumRetries < 0 || (sumRetries > 0 && !verifySum && !trashAfter) {
+		fmt.Fprintln(out, "Error: retry-on-checksum-mismatch must not be negative and requires -verify-checksum or -trash-after-download")
+		flag.Usage()
+		return exitUsage
+	}
+
+	if trashAfter && !trashAck {
+		fmt.Fprintln(out, "Error: -trash-after-download moves files to the Drive trash; pass -i-understand-this-trashes-files to confirm")
+		flag.Usage()
+		return exitUsage
+	}
+
+	if requireRO && trashAfter {
+		fmt.Fprintln(out, "Error: -require-readonly cannot be combined with -trash-after-download, which needs write access")
+		flag.Usage()
+		return exitUsage
+	}
+
+	revisionLimit := -1
+	if revisions != "" {
+		revisionLimit, err = drive.ParseRevisionLimit(revisions)
+		if err != nil {
+			fmt.Fprintf(out, "Error: %v\n", err)
+			flag.Usage()
+			return exitUsage
+		}
+	}
+
+	var createdAfter, createdBefore time.Time
+	if createdAft != "" {
+		createdAfter, err = drive.ParseTimeBound(createdAft)
+		if err != nil {
+			fmt.Fprintf(out, "Error: created-after: %v\n", err)
+			flag.Usage()
+			return exitUsage
+		}
+	}
+	if createdBef != "" {
+		createdBefore, err = drive.ParseTimeBound(createdBef)
+		if err != nil {
+			fmt.Fprintf(out, "Error: created-before: %v\n", err)
+			flag.Usage()
+			return exitUsage
+		}
+	}
+	var modifiedAfter time.Time
+	if since != "" {
+		modifiedAfter, err = drive.ParseSince(since, time.Now())
+		if err != nil {
+			fmt.Fprintf(out, "Error: since: %v\n", err)
+			flag.Usage()
+			return exitUsage
+		}
+	}
+
+	knownCats := drive.DefaultCategories
+	if catFile != "" {
+		knownCats, err = drive.LoadCategories(catFile)
+		if err != nil {
+			fmt.Fprintf(out, "Error: %v\n", err)
+			return exitUsage
+		}
+	}
+	fileCats, err := drive.ResolveCategories(knownCats, categories)
+	if err != nil {
+		fmt.Fprintf(out, "Error: %v\n", err)
+		flag.Usage()
+		return exitUsage
+	}
+	if err := drive.ValidateMatchTarget(matchTarget); err != nil {
+		fmt.Fprintf(out, "Error: %v\n", err)
+		flag.Usage()
+		return exitUsage
+	}
+	if err := drive.ValidateFileTypes(typeNames); err != nil {
+		fmt.Fprintf(out, "Error: %v\n", err)
+		flag.Usage()
+		return exitUsage
+	}
 
-	flag.Parse()
+	var labels []drive.LabelFilter
+	for _, spec := range labelSpecs {
+		label, err := drive.ParseLabelFilter(spec)
+		if err != nil {
+			fmt.Fprintf(out, "Error: %v\n", err)
+			flag.Usage()
+			return exitUsage
+		}
+		labels = append(labels, label)
+	}
 
-	if pattern == "" {
-		fmt.Println("Error: pattern is required")
+	if err := drive.ValidateQuery(query); err != nil {
+		fmt.Fprintf(out, "Error: %v\n", err)
 		flag.Usage()
-		os.Exit(1)
+		return exitUsage
+	}
+
+	var commandHook *hooks.CommandHook
+	if execCmd != "" {
+		commandHook, err = hooks.NewCommandHook(execCmd, execTimeout)
+		if err != nil {
+			fmt.Fprintf(out, "Error: invalid exec command: %v\n", err)
+			flag.Usage()
+			return exitUsage
+		}
 	}
 
 	// Validate path transformation flags
 	if (pathPattern == "") != (pathFormat == "") {
-		fmt.Println("Error: both path-pattern and path-format must be provided together")
+		fmt.Fprintln(out, "Error: both path-pattern and path-format must be provided together")
+		flag.Usage()
+		return exitUsage
+	}
+
+	outputTmpl, err := drive.ParseOutputDir(outputDir)
+	if err != nil {
+		fmt.Fprintf(out, "Error: %v\n", err)
 		flag.Usage()
-		os.Exit(1)
+		return exitUsage
 	}
 
-	var pathTransformer *transform.PathTransformer
+	var rules []transform.RulePair
 	if pathPattern != "" {
-		var err error
-		pathTransformer, err = transform.NewPathTransformer(pathPattern, pathFormat)
+		rules = append(rules, transform.RulePair{Pattern: pathPattern, Format: pathFormat})
+	}
+	if rulesFile != "" {
+		fileRules, err := readRules(rulesFile)
+		if err != nil {
+			fmt.Fprintf(out, "Error reading path rules: %v\n", err)
+			return exitUsage
+		}
+		rules = append(rules, fileRules...)
+	}
+
+	var pathTransformer *transform.ChainTransformer
+	if len(rules) > 0 {
+		pathTransformer, err = transform.NewChainTransformer(rules)
+		if err != nil {
+			fmt.Fprintf(out, "Error creating path transformer: %v\n", err)
+			return exitUsage
+		}
+	}
+	if unmatchDir != "" {
+		if pathTransformer == nil {
+			fmt.Fprintln(out, "Error: -unmatched-dir needs path rules from -path-pattern/-path-format or -rules-file")
+			flag.Usage()
+			return exitUsage
+		}
+		if err := pathTransformer.SetUnmatchedDir(unmatchDir); err != nil {
+			fmt.Fprintf(out, "Error: invalid -unmatched-dir: %v\n", err)
+			flag.Usage()
+			return exitUsage
+		}
+	}
+
+	if checkRules && pathTransformer == nil {
+		fmt.Fprintln(out, "Error: -validate-rules needs path rules from -path-pattern/-path-format or -rules-file")
+		flag.Usage()
+		return exitUsage
+	}
+	if checkRules && stream {
+		fmt.Fprintln(out, "Error: -validate-rules cannot be combined with -stream")
+		flag.Usage()
+		return exitUsage
+	}
+	if coverage && pathTransformer == nil {
+		fmt.Fprintln(out, "Error: -transform-coverage needs path rules from -path-pattern/-path-format or -rules-file")
+		flag.Usage()
+		return exitUsage
+	}
+	if coverage && (stream || checkRules) {
+		fmt.Fprintln(out, "Error: -transform-coverage cannot be combined with -stream or -validate-rules")
+		flag.Usage()
+		return exitUsage
+	}
+
+	var replacers []*transform.RegexReplacer
+	for _, rule := range pathReplace {
+		replacer, err := transform.ParseReplacement(rule)
+		if err != nil {
+			fmt.Fprintf(out, "Error: invalid path-replace rule: %v\n", err)
+			flag.Usage()
+			return exitUsage
+		}
+		replacers = append(replacers, replacer)
+	}
+
+	var routes []transform.Route
+	for _, rule := range routeRules {
+		route, err := transform.ParseRoute(rule)
 		if err != nil {
-			fmt.Printf("Error creating path transformer: %v\n", err)
-			os.Exit(1)
+			fmt.Fprintf(out, "Error: invalid route: %v\n", err)
+			flag.Usage()
+			return exitUsage
 		}
+		routes = append(routes, route)
 	}
 
 	config := utils.Config{
 		Credentials: credentials,
-		FolderID:    folderID,
+		FolderIDs:   folderIDs,
 		Pattern:     pattern,
+		Extensions:  extList,
+		OrderBy:     sortOrder,
 		MaxDepth:    maxDepth,
 		DryRun:      dryRun,
 		OutputDir:   outputDir,
 		Verbose:     verbose,
 	}
 
-	driveService, err := drive.NewDriveService(config.Credentials, config.Verbose)
-	if err != nil {
-		fmt.Printf("Error creating Drive service: %v\n", err)
-		os.Exit(1)
+	// Only ask for write access when a feature needs it
+	scope := drive.ReadonlyScope
+	if trashAfter {
+		scope = drive.FullScope
+	}
+	var driveService *drive.DriveService
+	if crawlLoad != "" {
+		if driveService, err = drive.NewOfflineDriveService(config.Verbose); err != nil {
+			fmt.Fprintf(out, "Error: %v\n", err)
+			return exitFailure
+		}
+		driveService.WithOutput(out)
+	} else if driveService, err = newDriveService(out, config.Credentials, config.Verbose, scope); err != nil {
+		return exitCode(err)
+	}
+
+	// Progress output is only worth it when someone is watching
+	showProgress := !config.Verbose && !quiet
+	if showProgress && isTerminal(out) {
+		driveService.WithProgress(progressBar(out))
 	}
 
-	files, err := driveService.ListFiles(config.FolderID, config.Pattern, config.MaxDepth, maxResults)
+	ctx, closeService, err := svcConfig.configure(driveService)
 	if err != nil {
-		fmt.Printf("Error listing files: %v\n", err)
-		os.Exit(1)
+		fmt.Fprintf(out, "Error: %v\n", err)
+		return exitFailure
 	}
+	defer closeService()
 
-	fmt.Printf("\nFound %d matching files:\n", len(files))
-	for _, file := range files {
-		fmt.Printf("- %s (Modified: %s)\n", file.Path, file.ModifiedTime)
+	if !trashAfter && crawlLoad == "" {
+		if err := checkReadonly(out, driveService, requireRO); err != nil {
+			fmt.Fprintf(out, "Error: -require-readonly: %v\n", err)
+			return exitAuth
+		}
 	}
 
-	if config.DryRun {
-		fmt.Println("\nFound files:")
-		for _, file := range files {
-			fmt.Printf("- %s (Modified: %s)\n", file.Path, file.ModifiedTime)
+	listOpts := drive.ListOptions{
+		FolderIDs:       config.FolderIDs,
+		Pattern:         config.Pattern,
+		MatchTarget:     matchTarget,
+		FolderPattern:   folderRegex,
+		Extensions:      config.Extensions,
+		MaxDepth:        config.MaxDepth,
+		MaxResults:      maxResults,
+		OrderBy:         config.OrderBy,
+		MaxPerExtension: maxPerExt,
+		MaxPerFolder:    perFolder,
+		MaxFolders:      maxFolders,
+		MatchFolders:    matchFolder,
+		LeafOnly:        leafOnly,
+		Owners:          owners,
+		OwnedByMe:       ownedByMe,
+		LastModifiedBy:  modifiedBy,
+		Labels:          labels,
+		Query:           query,
+		CreatedAfter:    createdAfter,
+		ModifiedAfter:   modifiedAfter,
+		CreatedBefore:   createdBefore,
+		Categories:      fileCats,
+		Types:           typeNames,
+		PageSize:        pageSize,
+		PrefetchPaths:   prefetch,
+		OnPathError:     onPathErr,
+		SharedWithMe:    withShared,
+	}
+	var seen *drive.BloomFilter
+	if seenBloom != "" && crawlDump == "" {
+		if seen, err = loadSeen(out, seenBloom); err != nil {
+			fmt.Fprintf(out, "Error: %v\n", err)
+			return exitFailure
 		}
+	}
+	if excludeSeen {
+		listOpts.Exclude = seen
+	}
 
-		fmt.Println("\nDownload preview:")
-		for _, file := range files {
-			fmt.Printf("\n📄 Original file: %s\n", file.Path)
-			if pathTransformer != nil {
-				fmt.Printf("   🔍 Applying pattern: %q\n", pathPattern)
-				fmt.Printf("   📝 Using format: %q\n", pathFormat)
-				newPath, err := pathTransformer.Transform(file.Path)
-				if err != nil {
-					fmt.Printf("   ❌ Transformation failed: %v\n", err)
-					fmt.Printf("   📁 Will be saved as: %s\n", filepath.Join(config.OutputDir, file.Path))
-				} else {
-					fmt.Printf("   ✅ Transformed to: %q\n", newPath)
-					fmt.Printf("   📁 Will be saved as: %s\n", filepath.Join(config.OutputDir, newPath))
-				}
-			} else {
-				fmt.Printf("   📁 Will be saved as: %s\n", filepath.Join(config.OutputDir, file.Path))
+	r := &runner{
+		out:           out,
+		ctx:           ctx,
+		timeout:       runTimeout,
+		driveService:  driveService,
+		summary:       &summaryWriter{path: summaryOut, started: started, driveService: driveService, out: out},
+		listOpts:      listOpts,
+		transformer:   pathTransformer,
+		onCollision:   onCollision,
+		downloadOrder: downloadOrder,
+		seenPath:      seenBloom,
+		minExpect:     minExpect,
+		maxFolders:    maxFolders,
+	}
+	if crawlDump != "" {
+		return r.dumpCrawl(crawlDump, showProgress)
+	}
+	if countOnly {
+		return r.countMatches(os.Stdout, showProgress)
+	}
+
+	r.downloadOpts = drive.DownloadOptions{
+		OutputDir:               config.OutputDir,
+		OutputDirTemplate:       outputTmpl,
+		VerifyChecksum:          verifySum,
+		ChecksumRetries:         sumRetries,
+		TrashAfterDownload:      trashAfter,
+		Compress:                compress,
+		Variants:                variants,
+		WriteMetadata:           writeMeta,
+		WriteFolderDescriptions: folderDesc,
+		WriteXattrs:             writeXattrs,
+		RedactFields:            redactList,
+		LinkDuplicates:          linkDups,
+		MaxErrors:               maxErrors,
+		VerifyWorkers:           verifyJobs,
+		ExportWorkers:           exportJobs,
+		SmartSchedule:           smartSched,
+		NativeAsLink:            nativeLink,
+		ModTimeTolerance:        mtimeTol,
+		SkipUnchangedExports:    skipExports,
+		TagRevision:             tagRev,
+		CaseInsensitiveFS:       caseInsensitiveFS(out, caseFS, config.OutputDir, tarOut != "" || sinkSpec != ""),
+	}
+	r.downloadOpts.Seen = seen
+	if commandHook != nil {
+		r.downloadOpts.AfterDownload = func(file drive.FileInfo, localPath string) error {
+			output, err := commandHook.Run(ctx, file, localPath)
+			if config.Verbose && len(output) > 0 {
+				fmt.Fprintf(out, "  Command output for %s:\n%s", file.Path, output)
 			}
+			return err
 		}
-		fmt.Println("\nDry run completed. No files were downloaded.")
-		return
 	}
-
-	if pathTransformer != nil {
-		fmt.Println("\nTransforming file paths before downloading:")
-		// Transform file paths before downloading
-		for i := range files {
-			fmt.Printf("\n🔍 Processing file %d/%d:\n", i+1, len(files))
-			fmt.Printf("   Input path: %q\n", files[i].Path)
-			fmt.Printf("   Using pattern: %q\n", pathPattern)
-			fmt.Printf("   Using format: %q\n", pathFormat)
-			newPath, err := pathTransformer.Transform(files[i].Path)
+	// place applies the path options that follow path transformation
+	r.place = func(file drive.FileInfo) drive.FileInfo {
+		for _, replacer := range replacers {
+			newPath, err := replacer.Transform(file.Path)
 			if err != nil {
-				fmt.Printf("   ❌ Warning: Could not transform path: %v\n", err)
+				fmt.Fprintf(out, "⚠️ Could not apply path-replace %s: %v\n", replacer, err)
 				continue
 			}
-			fmt.Printf("   ✅ Successfully transformed to: %q\n", newPath)
-			files[i].Path = newPath
+			file.Path = newPath
+		}
+		routeDir := transform.RouteDir(routes, file.Path)
+		if collapseAt > 0 {
+			file.Path = transform.CollapsePath(file.Path, collapseAt)
+		}
+		file.Path = transform.ShardPath(file.Path, shards)
+		if prefixDrive {
+			file.Path = driveService.PrefixDriveName(file)
+		}
+		if byExt {
+			file.Path = transform.ExtensionPath(file.Path)
+		}
+		if routeDir != "" {
+			file.Path = routeDir + "/" + file.Path
+		}
+		return file
+	}
+
+	if tarOut != "" && !config.DryRun {
+		r.downloadOpts.Archive, r.closeTar, err = openTar(out, tarOut, os.Stdout)
+		if err != nil {
+			fmt.Fprintf(out, "Error: %v\n", err)
+			return exitFailure
+		}
+	}
+
+	if sinkSpec != "" && !config.DryRun {
+		if r.downloadOpts.Sink, err = drive.ParseSink(ctx, sinkSpec, config.Credentials); err != nil {
+			fmt.Fprintf(out, "Error: %v\n", err)
+			return exitCode(err)
+		}
+	}
+
+	if stream {
+		return r.streamDownloads()
+	}
+
+	// With a saved change token, only files changed since are downloaded.
+	// Without one, the full run below saves a token taken before it starts.
+	var startToken string
+	if changesTok != "" {
+		_, err := drive.ReadChangeToken(changesTok)
+		if err == nil {
+			return r.syncChanges(changesTok)
+		}
+		if !errors.Is(err, drive.ErrNoChangeToken) {
+			fmt.Fprintf(out, "Error: %v\n", err)
+			return exitCode(err)
+		}
+		fmt.Fprintf(out, "No change token in %s yet; downloading every matching file first\n", changesTok)
+		if startToken, err = driveService.StartPageToken(); err != nil {
+			fmt.Fprintf(out, "Error: %v\n", err)
+			return exitCode(err)
+		}
+	}
+
+	var files []drive.FileInfo
+	if crawlLoad != "" {
+		files, err = loadCrawl(out, driveService, crawlLoad, listOpts)
+	} else {
+		stopHeartbeat := startHeartbeat(driveService, showProgress)
+		files, err = driveService.ListFiles(listOpts)
+		stopHeartbeat()
+		if r.timedOut(nil) {
+			return exitTimeout
+		}
+		warnFolderLimit(out, driveService, maxFolders)
+	}
+	if err != nil {
+		r.summary.write(nil, runFailed)
+		fmt.Fprintf(out, "Error listing files: %v\n", err)
+		return exitCode(err)
+	}
+	r.summary.matched = len(files)
+	if tooFew(out, len(files), minExpect) {
+		r.summary.write(nil, runFailed)
+		return exitTooFew
+	}
+
+	if len(files) == 0 {
+		// Exit with exitNoMatches once the run is otherwise complete, so a
+		// manifest or change token is still written and other failures
+		// take precedence
+		defer func() {
+			if code == exitOK {
+				code = exitNoMatches
+			}
+		}()
+	}
+
+	if checkRules {
+		if !validateRules(out, pathTransformer, files) {
+			return exitFailure
 		}
+		return exitOK
 	}
 
-	if err := driveService.DownloadFiles(files, config.OutputDir); err != nil {
-		fmt.Printf("Error downloading files: %v\n", err)
-		os.Exit(1)
+	if coverage {
+		printTransformCoverage(out, pathTransformer, files)
+		return exitOK
+	}
+
+	if manifestOut != "" {
+		for i := range files {
+			files[i] = r.transformPath(files[i])
+		}
+		if err := writeManifest(out, manifestOut, files); err != nil {
+			fmt.Fprintf(out, "Error: %v\n", err)
+			return exitFailure
+		}
+		return exitOK
+	}
+
+	fmt.Fprintf(out, "\nFound %d matching files:\n", len(files))
+	for _, file := range files {
+		fmt.Fprintf(out, "- %s%s (Modified: %s%s)\n", file.Path, folderSuffix(file), file.ModifiedTime, ownerSuffix(file))
+	}
+	if sortOrder.Field == "size" || (downloadOrder != nil && downloadOrder.Field == "size") {
+		warnUnsized(out, files)
+	}
+
+	if auditShare {
+		printSharingAudit(out, files, internalDom)
+		return exitOK
+	}
+
+	if config.DryRun {
+		r.previewDownloads(files, tarOut != "")
+		return exitOK
+	}
+
+	files = r.transformPaths(files)
+	if files, err = r.resolveCollisions(files); err != nil {
+		return exitFailure
+	}
+
+	if dryRunDiff {
+		return r.diffLocal(files)
+	}
+
+	if verifyOnly {
+		return r.verifyLocal(files)
+	}
+
+	return r.downloadFiles(files, revisionLimit, changesTok, startToken)
+}
+
+// runner holds what the listing and download modes share once the flags are
+// parsed and the Drive service is set up
+type runner struct {
+	// out receives messages, leaving standard output to data such as the
+	// archive of -tar -
+	out          io.Writer
+	ctx          context.Context
+	timeout      time.Duration
+	driveService *drive.DriveService
+	summary      *summaryWriter
+
+	listOpts      drive.ListOptions
+	downloadOpts  drive.DownloadOptions
+	transformer   *transform.ChainTransformer
+	onCollision   string
+	downloadOrder *drive.SortOrder
+	closeTar      func() error
+
+	// place applies the path options that follow path transformation
+	place func(drive.FileInfo) drive.FileInfo
+
+	seenPath   string
+	minExpect  int
+	maxFolders int
+}
+
+// timedOut reports whether the -timeout deadline has passed, after
+// summarizing what completed. report is nil if the deadline passed while
+// listing. The run summary, if any, is written first.
+func (r *runner) timedOut(report *drive.DownloadReport) bool {
+	if !errors.Is(r.ctx.Err(), context.DeadlineExceeded) {
+		return false
+	}
+
+	r.summary.write(report, runTimedOut)
+	fmt.Fprintf(r.out, "\n⏱️ Run timed out after %v\n", r.timeout)
+	if report == nil {
+		fmt.Fprintln(r.out, "Listing did not finish; no files were downloaded.")
+		return true
+	}
+	fmt.Fprintf(r.out, "Downloaded %d files before the timeout:\n", len(report.Downloaded))
+	for _, file := range report.Downloaded {
+		fmt.Fprintf(r.out, "- %s\n", file.Path)
+	}
+	printSummary(r.out, report)
+	printFailures(r.out, report)
+	return true
+}
+
+// transformPath applies the path rules, if any, and then the path options
+// to file. A file no rule transforms keeps its original path.
+func (r *runner) transformPath(file drive.FileInfo) drive.FileInfo {
+	if r.transformer != nil {
+		if newPath, err := r.transformer.Transform(file.Path); err == nil {
+			file.Path = newPath
+		}
+	}
+	return r.place(file)
+}
+
+// streamDownloads downloads files as the crawl finds them for -stream
+func (r *runner) streamDownloads() int {
+	done := make(chan struct{})
+	found, errc := r.driveService.WalkFiles(r.listOpts, done)
+
+	placed := make(chan drive.FileInfo)
+	go func() {
+		defer close(placed)
+		for file := range found {
+			if r.transformer != nil {
+				newPath, err := r.transformer.Transform(file.Path)
+				if err != nil {
+					fmt.Fprintf(r.out, "⚠️ Could not transform path: %v\n", err)
+				} else {
+					file.Path = newPath
+				}
+			}
+			select {
+			case placed <- r.place(file):
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	report, err := r.driveService.DownloadStream(placed, r.downloadOpts)
+	close(done)
+	walkErr := <-errc
+	if r.finishTar(err) {
+		return exitFailure
+	}
+	warnFolderLimit(r.out, r.driveService, r.maxFolders)
+	r.summary.matched = int(r.driveService.CrawlStats().Matches)
+	if r.timedOut(report) {
+		return exitTimeout
+	}
+	if walkErr != nil {
+		r.summary.write(report, runFailed)
+		fmt.Fprintf(r.out, "Error listing files: %v\n", walkErr)
+		return exitCode(walkErr)
+	}
+	if code := r.finishDownloads(report, err); code != exitOK {
+		return code
+	}
+	saveSeen(r.out, r.downloadOpts.Seen, r.seenPath)
+	if tooFew(r.out, r.summary.matched, r.minExpect) {
+		return exitTooFew
+	}
+	if r.summary.matched == 0 {
+		return exitNoMatches
+	}
+	return exitOK
+}
+
+// syncChanges downloads the files changed since the change token saved in
+// tokenPath, in batches, advancing the token past each batch that
+// downloaded without failures
+func (r *runner) syncChanges(tokenPath string) int {
+	report := &drive.DownloadReport{}
+	err := r.driveService.SyncChanges(tokenPath, r.listOpts, func(files []drive.FileInfo) error {
+		r.summary.matched += len(files)
+		for i := range files {
+			files[i] = r.transformPath(files[i])
+		}
+		files, err := r.driveService.ResolveCollisions(files, r.downloadOpts, r.onCollision)
+		if err != nil {
+			return err
+		}
+		if r.downloadOrder != nil {
+			drive.SortFiles(files, *r.downloadOrder)
+		}
+		batch, err := r.driveService.DownloadFiles(files, r.downloadOpts)
+		addReport(report, batch)
+		if err == nil && len(batch.Failed) > 0 {
+			err = fmt.Errorf("%d files failed", len(batch.Failed))
+		}
+		if err == nil {
+			// Saved before the token is advanced past these files
+			saveSeen(r.out, r.downloadOpts.Seen, r.seenPath)
+		}
+		return err
+	})
+	if r.timedOut(report) {
+		return exitTimeout
+	}
+	if err != nil {
+		printSummary(r.out, report)
+		printFailures(r.out, report)
+		r.summary.write(report, runFailed)
+		fmt.Fprintf(r.out, "Error syncing changes: %v\n", err)
+		fmt.Fprintln(r.out, "The change token was not advanced past the files that failed; the next run tries them again.")
+		if len(report.Failed) > 0 {
+			return exitPartial
+		}
+		return exitCode(err)
+	}
+	fmt.Fprintf(r.out, "\nDownloaded %d changed files\n", len(report.Downloaded))
+	return r.finishDownloads(report, nil)
+}
+
+// previewDownloads prints where -dry-run would save each file. archive is
+// set when files go to a -tar archive instead of the output directory.
+func (r *runner) previewDownloads(files []drive.FileInfo, archive bool) {
+	fmt.Fprintln(r.out, "\nFound files:")
+	for _, file := range files {
+		fmt.Fprintf(r.out, "- %s%s (Modified: %s)\n", file.Path, folderSuffix(file), file.ModifiedTime)
+	}
+
+	fmt.Fprintln(r.out, "\nDownload preview:")
+	var placed []drive.FileInfo
+	for _, file := range files {
+		fmt.Fprintf(r.out, "\n📄 Original file: %s\n", file.Path)
+		savePath := file.Path
+		if r.transformer != nil {
+			for _, rule := range r.transformer.Rules() {
+				fmt.Fprintf(r.out, "   🔍 Applying pattern: %q\n", rule.Pattern)
+				fmt.Fprintf(r.out, "   📝 Using format: %q\n", rule.Format)
+			}
+			newPath, err := r.transformer.Transform(file.Path)
+			if err != nil {
+				fmt.Fprintf(r.out, "   ❌ Transformation failed: %v\n", err)
+			} else {
+				fmt.Fprintf(r.out, "   ✅ Transformed to: %q\n", newPath)
+				savePath = newPath
+			}
+		}
+		file.Path = savePath
+		file = r.place(file)
+		placed = append(placed, file)
+		if archive {
+			fmt.Fprintf(r.out, "   📦 Will be added to the tar archive as: %s\n", file.Path)
+			continue
+		}
+		outPath, err := r.downloadOpts.OutputPath(file)
+		if err != nil {
+			fmt.Fprintf(r.out, "   ❌ %v\n", err)
+			continue
+		}
+		fmt.Fprintf(r.out, "   📁 Will be saved as: %s\n", outPath)
+		if r.downloadOpts.WriteMetadata {
+			metaPath, _ := r.downloadOpts.MetadataPath(file) // same base directory as outPath
+			fmt.Fprintf(r.out, "   🧾 Metadata will be saved as: %s\n", metaPath)
+		}
+	}
+	if collisions, err := drive.FindCollisions(placed, r.downloadOpts); err == nil {
+		printCollisions(r.out, collisions, r.onCollision)
+	}
+	fmt.Fprintln(r.out, "\nDry run completed. No files were downloaded.")
+}
+
+// transformPaths applies the path rules to files, printing each step, and
+// then the path options
+func (r *runner) transformPaths(files []drive.FileInfo) []drive.FileInfo {
+	if r.transformer != nil {
+		fmt.Fprintln(r.out, "\nTransforming file paths before downloading:")
+		for i := range files {
+			fmt.Fprintf(r.out, "\n🔍 Processing file %d/%d:\n", i+1, len(files))
+			fmt.Fprintf(r.out, "   Input path: %q\n", files[i].Path)
+			for _, rule := range r.transformer.Rules() {
+				fmt.Fprintf(r.out, "   Using pattern: %q\n", rule.Pattern)
+				fmt.Fprintf(r.out, "   Using format: %q\n", rule.Format)
+			}
+			newPath, err := r.transformer.Transform(files[i].Path)
+			if err != nil {
+				fmt.Fprintf(r.out, "   ❌ Warning: Could not transform path: %v\n", err)
+				continue
+			}
+			fmt.Fprintf(r.out, "   ✅ Successfully transformed to: %q\n", newPath)
+			files[i].Path = newPath
+		}
+	}
+
+	for i := range files {
+		files[i] = r.place(files[i])
+	}
+	return files
+}
+
+// resolveCollisions applies -on-collision to files, listing the collisions
+// left to overwrite each other. Its error has already been printed.
+func (r *runner) resolveCollisions(files []drive.FileInfo) ([]drive.FileInfo, error) {
+	if r.onCollision == drive.CollisionOverwrite {
+		if collisions, err := drive.FindCollisions(files, r.downloadOpts); err == nil {
+			printCollisions(r.out, collisions, r.onCollision)
+		}
+	}
+	files, err := r.driveService.ResolveCollisions(files, r.downloadOpts, r.onCollision)
+	if err != nil {
+		r.summary.write(nil, runFailed)
+		fmt.Fprintf(r.out, "Error: %v\n", err)
+		fmt.Fprintln(r.out, "Adjust the path transformation or pass -on-collision skip or rename.")
+		return nil, err
+	}
+	return files, nil
+}
+
+// diffLocal compares files with the output directory for -dry-run-diff
+func (r *runner) diffLocal(files []drive.FileInfo) int {
+	diffs, err := r.driveService.DiffLocal(files, r.downloadOpts)
+	if err != nil {
+		fmt.Fprintf(r.out, "Error comparing files: %v\n", err)
+		return exitCode(err)
+	}
+	printLocalDiff(r.out, diffs)
+	return exitOK
+}
+
+// verifyLocal checks earlier downloads of files for -verify-only
+func (r *runner) verifyLocal(files []drive.FileInfo) int {
+	report, err := r.driveService.VerifyLocal(files, r.downloadOpts)
+	if err != nil {
+		fmt.Fprintf(r.out, "Error verifying files: %v\n", err)
+		return exitCode(err)
+	}
+	if !printVerifyReport(r.out, report) {
+		return exitFailure
+	}
+	return exitOK
+}
+
+// downloadFiles downloads files, and for -revisions up to revisionLimit of
+// their past revisions. Once none failed, it saves the -seen-bloom filter and
+// startToken, if any, to tokenPath for -changes-token.
+func (r *runner) downloadFiles(files []drive.FileInfo, revisionLimit int, tokenPath, startToken string) int {
+	if r.downloadOrder != nil {
+		drive.SortFiles(files, *r.downloadOrder)
+	}
+	report, err := r.driveService.DownloadFiles(files, r.downloadOpts)
+	if r.finishTar(err) {
+		return exitFailure
+	}
+	if r.timedOut(report) {
+		return exitTimeout
+	}
+	printSummary(r.out, report)
+	if err != nil {
+		r.summary.write(report, runFailed)
+		printFailures(r.out, report)
+		fmt.Fprintf(r.out, "Error downloading files: %v\n", err)
+		return exitCode(err)
+	}
+
+	if revisionLimit >= 0 {
+		for _, file := range files {
+			baseDir, err := r.downloadOpts.BaseDir(file)
+			if err == nil {
+				err = r.driveService.DownloadRevisions(file, baseDir, revisionLimit)
+			}
+			if r.timedOut(report) {
+				return exitTimeout
+			}
+			if err != nil {
+				r.summary.write(report, runFailed)
+				fmt.Fprintf(r.out, "Error downloading revisions of %s: %v\n", file.Path, err)
+				return exitCode(err)
+			}
+		}
+	}
+
+	if printFailures(r.out, report) {
+		r.summary.write(report, runFailed)
+		return exitPartial
+	}
+	saveSeen(r.out, r.downloadOpts.Seen, r.seenPath)
+	if startToken != "" {
+		if err := drive.WriteChangeToken(tokenPath, startToken); err != nil {
+			fmt.Fprintf(r.out, "Error: %v\n", err)
+			return exitFailure
+		}
+		fmt.Fprintf(r.out, "\nSaved the change token to %s; later runs will only download files changed since this one started\n", tokenPath)
+	}
+	r.summary.write(report, runCompleted)
+	return exitOK
+}
+
+// warnUnsized warns about the files Drive reports no size for, which are
+// sorted last when sorting by size
+func warnUnsized(out io.Writer, files []drive.FileInfo) {
+	unsized := 0
+	for _, file := range files {
+		if !file.IsFolder && !file.HasSize() {
+			unsized++
+		}
+	}
+	if unsized > 0 {
+		fmt.Fprintf(out, "⚠️ Drive reports no size for %d files, such as Google Docs; they are sorted last by size\n", unsized)
+	}
+}
+
+// readFlagFile sets value from the file at path, for the fileFlag form of
+// the inline flag, such as -pattern-file for -pattern. A trailing newline is
+// trimmed; everything else is used as written, without shell escaping.
+func readFlagFile(value *string, inline, fileFlag, path string) error {
+	if path == "" {
+		return nil
+	}
+	if flagPassed(inline) {
+		return fmt.Errorf("-%s and -%s cannot be combined", inline, fileFlag)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("unable to read -%s: %v", fileFlag, err)
+	}
+	*value = strings.TrimSuffix(strings.TrimSuffix(string(data), "\n"), "\r")
+	return nil
+}
+
+// flagPassed reports whether the named flag was given on the command line
+func flagPassed(name string) bool {
+	passed := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			passed = true
+		}
+	})
+	return passed
+}
+
+// newDriveService creates the Drive service, printing the error, with a hint
+// if the credentials can't be used
+func newDriveService(out io.Writer, credentials string, verbose bool, scope string) (*drive.DriveService, error) {
+	driveService, err := drive.NewDriveServiceWithScope(credentials, verbose, scope)
+	if err != nil {
+		if errors.Is(err, drive.ErrInvalidCredentials) {
+			fmt.Fprintf(out, "Error loading credentials: %v\n", err)
+			fmt.Fprintln(out, "See the Authentication section of the README for how to create a credentials file.")
+			return nil, err
+		}
+		fmt.Fprintf(out, "Error creating Drive service: %v\n", err)
+		return nil, err
+	}
+	return driveService.WithOutput(out), nil
+}
+
+// serviceConfig holds the flags that set up the Drive service of the modes
+// that download files
+type serviceConfig struct {
+	credentials string
+	verbose     bool
+	timeout     time.Duration
+	retries     int
+	retryBudget int
+	maxConns    int
+	maxIdle     int
+	rps         float64
+	fileMode    os.FileMode
+	dirMode     os.FileMode
+	httpTrace   string
+	traceBody   bool
+}
+
+// parseServiceFlags checks the flags of c and parses -file-mode and
+// -dir-mode into it
+func parseServiceFlags(c serviceConfig, fileModeArg, dirModeArg string) (serviceConfig, error) {
+	if c.retries < 0 {
+		return c, errors.New("retries must not be negative")
+	}
+	if c.retryBudget < 0 {
+		return c, errors.New("retry-budget must not be negative")
+	}
+	if c.rps < 0 {
+		return c, errors.New("rps must not be negative")
+	}
+	if c.maxConns < 0 || c.maxIdle < 1 {
+		return c, errors.New("max-conns-per-host must not be negative and max-idle-conns-per-host must be at least 1")
+	}
+	if c.traceBody && c.httpTrace == "" {
+		return c, errors.New("-http-trace-body requires -http-trace")
+	}
+	if fileModeArg != "" {
+		var err error
+		if c.fileMode, err = drive.ParseFileMode(fileModeArg); err != nil {
+			return c, fmt.Errorf("-file-mode: %v", err)
+		}
+	}
+	if dirModeArg != "" {
+		var err error
+		if c.dirMode, err = drive.ParseFileMode(dirModeArg); err != nil {
+			return c, fmt.Errorf("-dir-mode: %v", err)
+		}
+		if c.dirMode&0700 != 0700 {
+			return c, errors.New("-dir-mode must give the owner read, write and execute permission (0700) so files can be saved in the directories")
+		}
+	}
+	return c, nil
+}
+
+// configure applies the -timeout, retry, connection, rate limit, file mode
+// and HTTP trace flags to driveService. It returns the context of the run
+// and a function to call once the run is over.
+func (c serviceConfig) configure(driveService *drive.DriveService) (context.Context, func(), error) {
+	var trace *os.File
+	if c.httpTrace != "" {
+		var err error
+		if trace, err = openTrace(c.httpTrace); err != nil {
+			return nil, nil, err
+		}
+		driveService.WithHTTPTrace(trace, c.traceBody)
+	}
+
+	ctx, cancel := context.Background(), func() {}
+	if c.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+	}
+	driveService.WithContext(ctx).
+		WithRetries(c.retries).
+		WithRetryBudget(c.retryBudget).
+		WithConnLimits(c.maxConns, c.maxIdle).
+		WithRateLimit(c.rps).
+		WithFileModes(c.fileMode, c.dirMode)
+	return ctx, func() {
+		cancel()
+		if trace != nil && trace != os.Stderr {
+			trace.Close()
+		}
+	}, nil
+}
+
+// finishIncomplete resumes the downloads left in ".part" files under
+// outputDir for -finish-incomplete
+func finishIncomplete(out io.Writer, svc serviceConfig, outputDir string, verifySum bool) int {
+	if strings.Contains(outputDir, "{{") {
+		fmt.Fprintln(out, "Error: -finish-incomplete needs a plain -output-dir")
+		flag.Usage()
+		return exitUsage
+	}
+	downloads, unknown, err := drive.FindIncomplete(outputDir)
+	if err != nil {
+		fmt.Fprintf(out, "Error: %v\n", err)
+		return exitFailure
+	}
+	if len(unknown) > 0 {
+		fmt.Fprintf(out, "⚠️ %d .part files can't be traced back to a Drive file and were left alone:\n", len(unknown))
+		for _, path := range unknown {
+			fmt.Fprintf(out, "- %s\n", path)
+		}
+	}
+	if len(downloads) == 0 {
+		fmt.Fprintf(out, "No incomplete downloads found in %s\n", outputDir)
+		return exitOK
+	}
+
+	driveService, err := newDriveService(out, svc.credentials, svc.verbose, drive.ReadonlyScope)
+	if err != nil {
+		return exitCode(err)
+	}
+	_, closeService, err := svc.configure(driveService)
+	if err != nil {
+		fmt.Fprintf(out, "Error: %v\n", err)
+		return exitFailure
+	}
+	defer closeService()
+	report, err := driveService.FinishIncomplete(downloads, drive.DownloadOptions{VerifyChecksum: verifySum})
+	if err != nil {
+		fmt.Fprintf(out, "Error: %v\n", err)
+		return exitCode(err)
+	}
+	fmt.Fprintf(out, "\nFinished %d of %d incomplete downloads\n", len(report.Downloaded), len(downloads))
+	if printFailures(out, report) {
+		return exitPartial
+	}
+	return exitOK
+}
+
+// countMatches prints the number of matching files to count for
+// -count-only. Files are counted as the crawl streams them rather than
+// collected, so memory use stays flat however many match.
+func (r *runner) countMatches(count io.Writer, heartbeat bool) int {
+	stopHeartbeat := startHeartbeat(r.driveService, heartbeat)
+	found, errc := r.driveService.WalkFiles(r.listOpts, nil)
+	matched := 0
+	for range found {
+		matched++
+	}
+	err := <-errc
+	stopHeartbeat()
+	if err != nil {
+		fmt.Fprintf(r.out, "Error listing files: %v\n", err)
+		return exitCode(err)
+	}
+	fmt.Fprintln(count, matched)
+	switch {
+	case tooFew(r.out, matched, r.minExpect):
+		return exitTooFew
+	case matched == 0:
+		return exitNoMatches
+	case r.timedOut(nil):
+		return exitTimeout
+	}
+	return exitOK
+}
+
+// warnFolderLimit warns when -max-folders cut the search short
+func warnFolderLimit(out io.Writer, driveService *drive.DriveService, maxFolders int) {
+	if driveService.CrawlStats().FolderLimitReached {
+		fmt.Fprintf(out, "⚠️ Reached max folders (%d), not searching any more folders; results may be incomplete\n", maxFolders)
+	}
+}
+
+// dumpCrawl saves the whole tree under the folders searched to path for
+// -crawl-dump
+func (r *runner) dumpCrawl(path string, heartbeat bool) int {
+	stopHeartbeat := startHeartbeat(r.driveService, heartbeat)
+	cache, err := r.driveService.CrawlTree(r.listOpts)
+	stopHeartbeat()
+	if err == nil {
+		err = drive.WriteCrawlCache(path, cache)
+	}
+	if err != nil {
+		fmt.Fprintf(r.out, "Error crawling files: %v\n", err)
+		return exitCode(err)
+	}
+	fmt.Fprintf(r.out, "Saved %d files and folders to %s\n", len(cache.Files), path)
+	fmt.Fprintln(r.out, "Search it with -crawl-load; run -crawl-dump again to pick up changes made in Drive.")
+	if r.timedOut(nil) {
+		return exitTimeout
+	}
+	return exitOK
+}
+
+// loadCrawl matches the files of a -crawl-load cache, warning that they may
+// no longer reflect Drive
+func loadCrawl(out io.Writer, driveService *drive.DriveService, path string, opts drive.ListOptions) ([]drive.FileInfo, error) {
+	cache, err := drive.ReadCrawlCache(path)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(out, "⚠️ Using the file tree cached in %s at %s (%s ago). Files added, changed, moved or deleted in Drive since then are not reflected.\n",
+		path, cache.CrawledAt.Local().Format(time.RFC3339), time.Since(cache.CrawledAt).Round(time.Second))
+	if len(opts.FolderIDs) > 0 || opts.SharedWithMe {
+		fmt.Fprintln(out, "⚠️ -folder-id and -shared-with-me are ignored with -crawl-load; the cache covers the folders it was crawled from")
+	}
+	return driveService.FilterFiles(cache.Files, opts)
+}
+
+// validateRules prints, for -validate-rules, every rule matching each file,
+// and reports whether no file has matching rules that disagree
+func validateRules(out io.Writer, chain *transform.ChainTransformer, files []drive.FileInfo) bool {
+	ambiguous, unmatched := 0, 0
+	fmt.Fprintf(out, "\nChecking %d rules against %d files:\n", len(chain.Rules()), len(files))
+	for _, file := range files {
+		matches := chain.MatchAll(file.Path)
+		switch {
+		case len(matches) == 0:
+			unmatched++
+			fmt.Fprintf(out, "\n❓ %s: no rule matches; the path can't be transformed\n", file.Path)
+			continue
+		case transform.Ambiguous(matches):
+			ambiguous++
+			fmt.Fprintf(out, "\n⚠️ %s: %d rules match and disagree; rule %d wins\n", file.Path, len(matches), matches[0].Index+1)
+		case len(matches) > 1:
+			fmt.Fprintf(out, "\n✅ %s: %d rules match with the same result\n", file.Path, len(matches))
+		default:
+			fmt.Fprintf(out, "\n✅ %s\n", file.Path)
+		}
+		for _, m := range matches {
+			if m.Err != nil {
+				fmt.Fprintf(out, "   rule %d (%s): ❌ %v\n", m.Index+1, m.Rule, m.Err)
+			} else {
+				fmt.Fprintf(out, "   rule %d (%s): %s\n", m.Index+1, m.Rule, m.Result)
+			}
+		}
+	}
+
+	fmt.Fprintf(out, "\n%d files checked: %d ambiguous, %d unmatched\n", len(files), ambiguous, unmatched)
+	return ambiguous == 0
+}
+
+// coverageSamples is how many unmatched paths -transform-coverage lists
+const coverageSamples = 20
+
+// printTransformCoverage prints, for -transform-coverage, how many files the
+// path rules transform, and a sample of those kept under their original path
+func printTransformCoverage(out io.Writer, chain *transform.ChainTransformer, files []drive.FileInfo) {
+	paths := make([]string, len(files))
+	for i, file := range files {
+		paths[i] = file.Path
+	}
+	cov := chain.Coverage(paths, coverageSamples)
+
+	percent := func(n int) float64 {
+		if cov.Total == 0 {
+			return 0
+		}
+		return 100 * float64(n) / float64(cov.Total)
+	}
+	fmt.Fprintf(out, "\n🧮 Transform coverage of %d files:\n", cov.Total)
+	fmt.Fprintf(out, "   ✅ Transformed: %d (%.1f%%)\n", cov.Transformed, percent(cov.Transformed))
+	fmt.Fprintf(out, "   ❌ Failed:      %d (%.1f%%)\n", cov.Failed, percent(cov.Failed))
+	fmt.Fprintf(out, "   ❓ Unmatched:   %d (%.1f%%)\n", cov.Unmatched, percent(cov.Unmatched))
+	fmt.Fprintln(out, "\nFiles transformed per rule:")
+	for i, rule := range chain.Rules() {
+		fmt.Fprintf(out, "   rule %d (%s): %d\n", i+1, rule, cov.PerRule[i])
+	}
+	if dir := chain.UnmatchedDir(); dir != "" && cov.Unmatched > 0 {
+		fmt.Fprintf(out, "\nUnmatched files are saved under %s/ when downloaded; failed files keep their original path.\n", dir)
+	} else if cov.Failed+cov.Unmatched > 0 {
+		fmt.Fprintln(out, "\nFailed and unmatched files keep their original path when downloaded.")
+	}
+	if len(cov.Samples) > 0 {
+		fmt.Fprintf(out, "\nUnmatched files (%d of %d):\n", len(cov.Samples), cov.Unmatched)
+		for _, path := range cov.Samples {
+			fmt.Fprintf(out, "- %s\n", path)
+		}
+	}
+}
+
+// printFormats prints the -list-export-formats tables, or JSON with asJSON
+func printFormats(out io.Writer, formats *drive.Formats, asJSON bool) error {
+	if asJSON {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(formats)
+	}
+	for i, table := range []struct {
+		title       string
+		conversions []drive.FormatConversion
+	}{{"Export formats", formats.Export}, {"Import formats", formats.Import}} {
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		fmt.Fprintf(out, "%s:\n", table.title)
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "SOURCE\tTARGETS")
+		for _, conversion := range table.conversions {
+			fmt.Fprintf(w, "%s\t%s\n", conversion.Source, strings.Join(conversion.Targets, ", "))
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkReadonly warns when the credentials grant write access to Drive that
+// the run does not need. When required is set, it returns an error instead.
+func checkReadonly(out io.Writer, driveService *drive.DriveService, required bool) error {
+	scopes, err := driveService.GrantedScopes()
+	if err != nil {
+		if required {
+			return fmt.Errorf("unable to check credentials scopes: %v", err)
+		}
+		fmt.Fprintf(out, "⚠️ Unable to check credentials scopes: %v\n", err)
+		return nil
+	}
+
+	write := drive.WriteScopes(scopes)
+	if len(write) == 0 {
+		return nil
+	}
+	if required {
+		return fmt.Errorf("the credentials grant write access: %s", strings.Join(write, ", "))
+	}
+	fmt.Fprintf(out, "⚠️ The credentials grant write access this run does not need: %s\n", strings.Join(write, ", "))
+	fmt.Fprintf(out, "   Consider credentials limited to %s\n", drive.ReadonlyScope)
+	return nil
+}
+
+// openTar starts the -tar archive, writing it to stdout when path is "-".
+// The returned function finishes the archive and closes its file.
+func openTar(out io.Writer, path string, stdout io.Writer) (*drive.TarArchive, func() error, error) {
+	if path == "-" {
+		archive := drive.NewTarArchive(stdout)
+		return archive, archive.Close, nil
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to create tar archive: %v", err)
+	}
+	archive := drive.NewTarArchive(f)
+	return archive, func() error {
+		if err := archive.Close(); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return fmt.Errorf("unable to write tar archive: %v", err)
+		}
+		fmt.Fprintf(out, "Wrote tar archive to %s\n", path)
+		return nil
+	}, nil
+}
+
+// finishTar finishes the -tar archive, if any, and reports whether the run
+// fails because the archive cannot be written. That is left to downloadErr
+// when it already stops the run and is reported later.
+func (r *runner) finishTar(downloadErr error) bool {
+	if r.closeTar == nil {
+		return false
+	}
+	if err := r.closeTar(); err != nil {
+		fmt.Fprintf(r.out, "Error: %v\n", err)
+		return downloadErr == nil
+	}
+	return false
+}
+
+// printParentChain prints, for -debug-parents, each link of a parent chain
+// from the file up, and the path it adds up to
+func printParentChain(out io.Writer, chain []drive.ParentLink) {
+	fmt.Fprintf(out, "Parent chain of %s, from the file up:\n", chain[0].ID)
+	for i, link := range chain {
+		if link.Err != nil {
+			fmt.Fprintf(out, "%d. ❌ %s: %v\n   The chain stops here; the path starts below this folder\n", i, link.ID, link.Err)
+			continue
+		}
+		fmt.Fprintf(out, "%d. %q (ID: %s, drive: %s)\n", i, link.Name, link.ID, orNone(link.DriveID))
+		switch len(link.Parents) {
+		case 0:
+			fmt.Fprintln(out, "   No parents: this is the top of the chain")
+		case 1:
+			fmt.Fprintf(out, "   Parent: %s\n", link.Parents[0])
+		default:
+			fmt.Fprintf(out, "   Parents: %s (only the first is followed)\n", strings.Join(link.Parents, ", "))
+		}
+	}
+	fmt.Fprintf(out, "\nPath from this chain, before any rewriting: %s\n", drive.ChainPath(chain))
+}
+
+// orNone returns s, or "none" when it is empty
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
+
+// loadSeen reads the -seen-bloom filter, starting a new one if the file
+// doesn't exist yet
+func loadSeen(out io.Writer, path string) (*drive.BloomFilter, error) {
+	seen, err := drive.LoadBloomFilter(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		fmt.Fprintf(out, "No bloom filter in %s yet; starting a new one\n", path)
+		return drive.NewBloomFilter(drive.DefaultBloomCapacity, drive.DefaultBloomFalsePositive), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if seen.Full() {
+		fmt.Fprintf(out, "⚠️ %s holds more file IDs than it was sized for, so new files are more likely to be skipped by mistake; "+
+			"consider starting a new filter with -rebuild-bloom\n", path)
+	}
+	return seen, nil
+}
+
+// saveSeen saves the -seen-bloom filter after a download run in which no
+// file failed; a failed run leaves it as it was. Failing to save is only a
+// warning: the next run downloads this run's files again.
+func saveSeen(out io.Writer, seen *drive.BloomFilter, path string) {
+	if seen == nil {
+		return
+	}
+	if err := seen.Save(path); err != nil {
+		fmt.Fprintf(out, "⚠️ %v; the next run will download the files of this run again\n", err)
+	}
+}
+
+// finishDownloads reports the outcome of a download run, returning an error
+// status if anything failed
+func (r *runner) finishDownloads(report *drive.DownloadReport, err error) int {
+	printSummary(r.out, report)
+	if err != nil {
+		r.summary.write(report, runFailed)
+		printFailures(r.out, report)
+		fmt.Fprintf(r.out, "Error downloading files: %v\n", err)
+		return exitCode(err)
+	}
+	if printFailures(r.out, report) {
+		r.summary.write(report, runFailed)
+		return exitPartial
+	}
+	r.summary.write(report, runCompleted)
+	return exitOK
+}
+
+// tooFew reports whether fewer than minExpect files matched, which usually
+// means a pattern or permission broke rather than that there is nothing to
+// download
+func tooFew(out io.Writer, matched, minExpect int) bool {
+	if matched >= minExpect {
+		return false
+	}
+	fmt.Fprintf(out, "❌ Only %d files matched, fewer than the %d expected by -min-expected; check the pattern and the access of the credentials\n", matched, minExpect)
+	return true
+}
+
+// addReport adds the files of report to total
+func addReport(total, report *drive.DownloadReport) {
+	total.Downloaded = append(total.Downloaded, report.Downloaded...)
+	total.Trashed = append(total.Trashed, report.Trashed...)
+	total.Failed = append(total.Failed, report.Failed...)
+	total.Linked = append(total.Linked, report.Linked...)
+	total.Warnings = append(total.Warnings, report.Warnings...)
+	total.Downloads = addStats(total.Downloads, report.Downloads)
+	total.Exports = addStats(total.Exports, report.Exports)
+	total.Verification = addStats(total.Verification, report.Verification)
+}
+
+// addStats adds the work of a pool in one run to that of earlier runs
+func addStats(total, stats drive.PoolStats) drive.PoolStats {
+	return drive.PoolStats{
+		Workers: max(total.Workers, stats.Workers),
+		Tasks:   total.Tasks + stats.Tasks,
+		Busy:    total.Busy + stats.Busy,
+		Bytes:   total.Bytes + stats.Bytes,
+	}
+}
+
+// printSummary lists the files linked to duplicates and those moved to the
+// Drive trash, and the work of each pool when exports or verification had
+// their own
+func printSummary(out io.Writer, report *drive.DownloadReport) {
+	if report.Exports.Workers > 0 || report.Verification.Workers > 0 {
+		fmt.Fprintln(out, "\nWorkers:")
+		for _, pool := range []struct {
+			name  string
+			stats drive.PoolStats
+		}{{"downloads", report.Downloads}, {"exports", report.Exports}, {"verification", report.Verification}} {
+			if pool.stats.Workers == 0 {
+				continue
+			}
+			fmt.Fprintf(out, "- %s: %d tasks on %d workers, busy for %s\n",
+				pool.name, pool.stats.Tasks, pool.stats.Workers, pool.stats.Busy.Round(time.Millisecond))
+		}
+		if stats := report.Verification; stats.Workers > 0 {
+			fmt.Fprintf(out, "Hashed %s at %s/s per worker\n", formatBytes(stats.Bytes), formatBytes(int64(stats.Throughput())))
+		}
+	}
+	if len(report.Linked) > 0 {
+		fmt.Fprintf(out, "\nLinked %d files to identical downloads instead of downloading them again\n", len(report.Linked))
+	}
+	if len(report.Trashed) > 0 {
+		fmt.Fprintf(out, "\nMoved %d files to the Drive trash:\n", len(report.Trashed))
+		for _, file := range report.Trashed {
+			fmt.Fprintf(out, "- %s (ID: %s)\n", file.Path, file.ID)
+		}
+	}
+}
+
+// printFailures lists warnings and the files that failed, and reports
+// whether any file failed
+func printFailures(out io.Writer, report *drive.DownloadReport) bool {
+	if len(report.Warnings) > 0 {
+		fmt.Fprintf(out, "\n⚠️ %d warnings:\n", len(report.Warnings))
+		for _, warning := range report.Warnings {
+			fmt.Fprintf(out, "- %s: %v\n", warning.File.Path, warning.Err)
+		}
+	}
+	if len(report.Failed) > 0 {
+		fmt.Fprintf(out, "\n%d files failed:\n", len(report.Failed))
+		for _, failure := range report.Failed {
+			fmt.Fprintf(out, "- %s: %v\n", failure.File.Path, failure.Err)
+		}
+	}
+	return len(report.Failed) > 0
+}
+
+// printCollisions lists the output paths several files map to and what
+// -on-collision does about them
+func printCollisions(out io.Writer, collisions []drive.Collision, strategy string) {
+	if len(collisions) == 0 {
+		return
+	}
+	fmt.Fprintf(out, "\n⚠️ %d output paths are shared by several files (-on-collision %s):\n", len(collisions), strategy)
+	for _, c := range collisions {
+		fmt.Fprintf(out, "- %s\n", c.LocalPath)
+		for _, file := range c.Files {
+			fmt.Fprintf(out, "    %s (ID: %s)\n", file.Path, file.ID)
+		}
+	}
+}
+
+// printLocalDiff prints each file's -dry-run-diff state followed by counts
+func printLocalDiff(out io.Writer, diffs []drive.LocalDiff) {
+	counts := make(map[drive.LocalState]int)
+	fmt.Fprintln(out)
+	for _, diff := range diffs {
+		counts[diff.State]++
+		fmt.Fprintf(out, "%-9s %s\n", diff.State, diff.LocalPath)
+	}
+	fmt.Fprintf(out, "\n%d new, %d to update, %d unchanged. No files were downloaded.\n",
+		counts[drive.StateNew], counts[drive.StateUpdate], counts[drive.StateUnchanged])
+}
+
+// printVerifyReport prints the outcome of -verify-only and reports whether
+// every checked file matched
+func printVerifyReport(out io.Writer, report *drive.VerifyReport) bool {
+	fmt.Fprintf(out, "\nVerified %d files against Drive checksums\n", len(report.Verified))
+	fmt.Fprintf(out, "Hashed %s in %v (%s/s)\n", formatBytes(report.Bytes), report.Elapsed.Round(time.Millisecond), formatBytes(int64(report.Throughput())))
+	if len(report.Unchecked) > 0 {
+		fmt.Fprintf(out, "\n%d files have no Drive checksum and were not checked:\n", len(report.Unchecked))
+		for _, file := range report.Unchecked {
+			fmt.Fprintf(out, "- %s\n", file.Path)
+		}
+	}
+	if len(report.Missing) > 0 {
+		fmt.Fprintf(out, "\n❌ %d files are missing locally:\n", len(report.Missing))
+		for _, file := range report.Missing {
+			fmt.Fprintf(out, "- %s\n", file.Path)
+		}
+	}
+	if len(report.Mismatched) > 0 {
+		fmt.Fprintf(out, "\n❌ %d files do not match:\n", len(report.Mismatched))
+		for _, failure := range report.Mismatched {
+			fmt.Fprintf(out, "- %s: %v\n", failure.File.Path, failure.Err)
+		}
+	}
+	return len(report.Missing) == 0 && len(report.Mismatched) == 0
+}
+
+// caseInsensitiveFS resolves -case-insensitive-fs. With auto, the output
+// directory is checked, unless files go to a tar archive or -sink; when the check
+// fails, the usual default of the OS is assumed.
+func caseInsensitiveFS(out io.Writer, value, outputDir string, archive bool) bool {
+	if value != "auto" {
+		return value == "true"
+	}
+	if archive {
+		return false
+	}
+	insensitive, err := drive.DetectCaseInsensitiveFS(outputDir)
+	if err != nil {
+		insensitive = runtime.GOOS == "darwin" || runtime.GOOS == "windows"
+		assumed := "case-sensitive"
+		if insensitive {
+			assumed = "case-insensitive"
+		}
+		fmt.Fprintf(out, "⚠️ %v; assuming file names are %s\n", err, assumed)
+	}
+	return insensitive
+}
+
+// openTrace opens the -http-trace log, standard error when path is "-"
+func openTrace(path string) (*os.File, error) {
+	if path == "-" {
+		return os.Stderr, nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create HTTP trace: %v", err)
+	}
+	return f, nil
+}
+
+// writeManifest writes the CSV manifest of files to path, or to standard
+// output when path is "-"
+func writeManifest(out io.Writer, path string, files []drive.FileInfo) error {
+	if path == "-" {
+		return drive.WriteManifest(os.Stdout, files)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("unable to create manifest: %v", err)
+	}
+	if err := drive.WriteManifest(f, files); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("unable to write manifest: %v", err)
+	}
+	fmt.Fprintf(out, "Wrote manifest of %d files to %s\n", len(files), path)
+	return nil
+}
+
+// readRules reads path transformation rules from a file, or from standard
+// input when path is "-"
+func readRules(path string) ([]transform.RulePair, error) {
+	if path == "-" {
+		return transform.ParseRules(os.Stdin)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return transform.ParseRules(f)
+}
+
+// folderSuffix marks folder entries in file listings
+func folderSuffix(file drive.FileInfo) string {
+	if file.IsFolder {
+		return "/ [folder]"
+	}
+	return ""
+}
+
+// ownerSuffix adds the file owners to file listings
+func ownerSuffix(file drive.FileInfo) string {
+	if len(file.Owners) == 0 {
+		return ""
+	}
+	return ", Owner: " + strings.Join(file.Owners, ", ")
+}
+
+// stringList is a flag.Value collecting every occurrence of a repeatable flag
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// printSharingAudit prints the risky sharing grants found on the files
+func printSharingAudit(out io.Writer, files []drive.FileInfo, internalDomains []string) {
+	findings := drive.AuditSharing(files, internalDomains)
+	fmt.Fprintf(out, "\nSharing audit: %d risky grants found\n", len(findings))
+
+	lastPath := ""
+	for _, finding := range findings {
+		if finding.File.Path != lastPath {
+			fmt.Fprintf(out, "\n⚠️ %s (ID: %s)\n", finding.File.Path, finding.File.ID)
+			lastPath = finding.File.Path
+		}
+		fmt.Fprintf(out, "   - %s (role: %s)\n", finding.Reason, finding.Permission.Role)
 	}
+	fmt.Fprintln(out, "\nAudit completed. No files were downloaded.")
 }
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kubenoops-ai/google-drive-downloader/pkg/drive"
+)
+
+const progressBarWidth = 30
+
+// heartbeatInterval is how often the crawl heartbeat is printed
+const heartbeatInterval = 3 * time.Second
+
+// isTerminal reports whether f is attached to a terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// progressBar returns a ProgressFunc rendering a single-line progress bar
+// on out for the file being downloaded
+func progressBar(out io.Writer) drive.ProgressFunc {
+	return func(file drive.FileInfo, bytesDone, bytesTotal int64) {
+		if bytesTotal <= 0 {
+			fmt.Fprintf(out, "\r   %s", formatBytes(bytesDone))
+			return
+		}
+
+		filled := min(max(int(bytesDone*progressBarWidth/bytesTotal), 0), progressBarWidth)
+		bar := strings.Repeat("#", filled) + strings.Repeat(".", progressBarWidth-filled)
+		fmt.Fprintf(out, "\r   [%s] %3d%% (%s / %s)", bar, bytesDone*100/bytesTotal, formatBytes(bytesDone), formatBytes(bytesTotal))
+		if bytesDone >= bytesTotal {
+			fmt.Fprintln(out)
+		}
+	}
+}
+
+// formatBytes formats a byte count using binary units
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// startHeartbeat prints the crawl statistics of driveService to standard
+// error every heartbeatInterval, so a long crawl doesn't look hung. It does
+// nothing unless enabled and standard error is a terminal. The returned
+// function stops it and clears the line.
+func startHeartbeat(driveService *drive.DriveService, enabled bool) (stop func()) {
+	if !enabled || !isTerminal(os.Stderr) {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(heartbeatInterval)
+		defer ticker.Stop()
+		printed := false
+		for {
+			select {
+			case <-ticker.C:
+				stats := driveService.CrawlStats()
+				fmt.Fprintf(os.Stderr, "\r\033[K   Searching... %d folders visited, %d files examined, %d matches",
+					stats.Folders, stats.Files, stats.Matches)
+				printed = true
+			case <-done:
+				if printed {
+					fmt.Fprint(os.Stderr, "\r\033[K")
+				}
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
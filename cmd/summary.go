@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/kubenoops-ai/google-drive-downloader/pkg/drive"
+)
+
+// Outcomes of a run recorded by -summary-json
+const (
+	runCompleted = "completed"
+	runFailed    = "failed"
+	runTimedOut  = "timed_out"
+)
+
+// runSummary is the JSON object -summary-json writes at the end of a run
+type runSummary struct {
+	Status         string    `json:"status"`
+	StartedAt      time.Time `json:"startedAt"`
+	FinishedAt     time.Time `json:"finishedAt"`
+	ElapsedSeconds float64   `json:"elapsedSeconds"`
+
+	FoldersCrawled int64 `json:"foldersCrawled"`
+	FilesExamined  int64 `json:"filesExamined"`
+	Matched        int   `json:"matched"`
+	Downloaded     int   `json:"downloaded"`
+	Linked         int   `json:"linked"`
+	Skipped        int   `json:"skipped"`
+	Failed         int   `json:"failed"`
+	Warnings       int   `json:"warnings"`
+	Trashed        int   `json:"trashed"`
+
+	// BytesDownloaded is the Drive size of the files downloaded, leaving
+	// out those linked to a duplicate and UnsizedDownloaded, those Drive
+	// reports no size for
+	BytesDownloaded   int64 `json:"bytesDownloaded"`
+	UnsizedDownloaded int   `json:"unsizedDownloaded"`
+
+	APIRequests     int64 `json:"apiRequests"`
+	Retries         int64 `json:"retries"`
+	PeakConcurrency int64 `json:"peakConcurrency"`
+}
+
+// summaryWriter collects what -summary-json needs over a download run
+type summaryWriter struct {
+	path         string
+	started      time.Time
+	driveService *drive.DriveService
+	// out receives the warning when the summary can't be written
+	out io.Writer
+
+	// matched is the number of files the run set out to download
+	matched int
+}
+
+// write saves the summary of a run that ended with status. report is nil
+// when the run ended before downloading. Without -summary-json it does
+// nothing, and failing to write is only a warning, so the exit status still
+// reflects the downloads.
+func (s *summaryWriter) write(report *drive.DownloadReport, status string) {
+	if s == nil || s.path == "" {
+		return
+	}
+
+	finished := time.Now()
+	crawl := s.driveService.CrawlStats()
+	api := s.driveService.APIStats()
+	summary := runSummary{
+		Status:          status,
+		StartedAt:       s.started.UTC(),
+		FinishedAt:      finished.UTC(),
+		ElapsedSeconds:  finished.Sub(s.started).Seconds(),
+		FoldersCrawled:  crawl.Folders,
+		FilesExamined:   crawl.Files,
+		Matched:         s.matched,
+		APIRequests:     api.Requests,
+		Retries:         api.Retries,
+		PeakConcurrency: api.PeakConcurrency,
+	}
+	if report != nil {
+		summary.Downloaded = len(report.Downloaded)
+		summary.Linked = len(report.Linked)
+		summary.Failed = len(report.Failed)
+		summary.Warnings = len(report.Warnings)
+		summary.Trashed = len(report.Trashed)
+		summary.BytesDownloaded, summary.UnsizedDownloaded = downloadedBytes(report)
+	}
+	summary.Skipped = max(summary.Matched-summary.Downloaded-summary.Failed, 0)
+
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err == nil {
+		err = os.WriteFile(s.path, append(data, '\n'), 0644)
+	}
+	if err != nil {
+		fmt.Fprintf(s.out, "⚠️ Unable to write run summary: %v\n", err)
+	}
+}
+
+// downloadedBytes adds up the size of the files a run downloaded, leaving
+// out those saved as links to a duplicate, and counts the files it left out
+// as Drive reports no size for them
+func downloadedBytes(report *drive.DownloadReport) (total int64, unsized int) {
+	linked := make(map[string]bool)
+	for _, file := range report.Linked {
+		linked[file.ID] = true
+	}
+	for _, file := range report.Downloaded {
+		switch {
+		case linked[file.ID]:
+		case !file.HasSize():
+			unsized++
+		default:
+			total += file.Size
+		}
+	}
+	return total, unsized
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/kubenoops-ai/google-drive-downloader/pkg/drive"
+	"github.com/kubenoops-ai/google-drive-downloader/pkg/transform"
+)
+
+// version is the build version, set with -ldflags "-X main.version=v1.2.3"
+var version = "dev"
+
+// driveClientVersion returns the version of the Google API client the binary was built with
+func driveClientVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == "google.golang.org/api" {
+			return dep.Version
+		}
+	}
+	return "unknown"
+}
+
+func printVersion() {
+	fmt.Printf("google-drive-downloader %s\n", version)
+	fmt.Printf("Drive API client: google.golang.org/api %s (drive/v3)\n", driveClientVersion())
+	fmt.Printf("Go: %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+}
+
+// runSelfTest exercises the matching and transformation features against
+// built-in samples without touching the network, returning false on failure
+func runSelfTest() bool {
+	const samplePath = "Zoom Recordings/apr-10-2025-17-27-28-AI_TEAM_OFFICE_ROOM-2/audio_transcript.TRANSCRIPT"
+
+	checks := []struct {
+		name string
+		run  func() error
+	}{
+		{"regex pattern", func() error {
+			if !regexp.MustCompile(`.*\.TRANSCRIPT$`).MatchString(samplePath) {
+				return fmt.Errorf("sample path did not match")
+			}
+			return nil
+		}},
+		{"extension pattern", func() error {
+			pattern, err := drive.ExtensionPattern([]string{"transcript", ".mp4"})
+			if err != nil {
+				return err
+			}
+			if !regexp.MustCompile(pattern).MatchString(samplePath) {
+				return fmt.Errorf("sample path did not match %s", pattern)
+			}
+			return nil
+		}},
+		{"path transformation", func() error {
+			t, err := transform.NewPathTransformer(
+				`(?P<date>[^-/]+-[^-]+-[^-]+-[^-]+-[^-]+-[^-]+)-(?P<room>[^/]+)/.*\.TRANSCRIPT$`,
+				"${date}-${room}.TRANSCRIPT",
+			)
+			if err != nil {
+				return err
+			}
+			got, err := t.Transform(samplePath)
+			if err != nil {
+				return err
+			}
+			if want := "apr-10-2025-17-27-28-AI_TEAM_OFFICE_ROOM-2.TRANSCRIPT"; got != want {
+				return fmt.Errorf("got %q, want %q", got, want)
+			}
+			return nil
+		}},
+		{"sort order", func() error {
+			_, err := drive.ParseSortOrder("modified:desc")
+			return err
+		}},
+	}
+
+	ok := true
+	for _, check := range checks {
+		if err := check.run(); err != nil {
+			fmt.Printf("❌ %s: %v\n", check.name, err)
+			ok = false
+			continue
+		}
+		fmt.Printf("✅ %s\n", check.name)
+	}
+	return ok
+}
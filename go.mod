@@ -4,7 +4,11 @@ go 1.23.0
 
 toolchain go1.23.8
 
-require google.golang.org/api v0.228.0
+require (
+	golang.org/x/oauth2 v0.29.0
+	golang.org/x/sys v0.32.0
+	google.golang.org/api v0.228.0
+)
 
 require (
 	cloud.google.com/go/auth v0.15.0 // indirect
@@ -24,8 +28,6 @@ require (
 	go.opentelemetry.io/otel/trace v1.35.0 // indirect
 	golang.org/x/crypto v0.37.0 // indirect
 	golang.org/x/net v0.39.0 // indirect
-	golang.org/x/oauth2 v0.29.0 // indirect
-	golang.org/x/sys v0.32.0 // indirect
 	golang.org/x/text v0.24.0 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20250409194420-de1ac958c67a // indirect
 	google.golang.org/grpc v1.71.1 // indirect
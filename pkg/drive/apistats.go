@@ -0,0 +1,85 @@
+package drive
+
+import (
+	"errors"
+	"net/http"
+	"sync/atomic"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/googleapi"
+)
+
+// APIStats counts the requests the service has sent to Google so far
+type APIStats struct {
+	// Requests is the number of HTTP requests sent, retries included
+	Requests int64
+	// Retries is the number of times a failed request was retried
+	Retries int64
+	// PeakConcurrency is the most requests awaiting a response at once
+	PeakConcurrency int64
+	// AuthFailures is the number of requests that failed for lack of valid
+	// credentials: those rejected as unauthorized, and those no access
+	// token could be obtained for
+	AuthFailures int64
+}
+
+// apiCounters backs APIStats
+type apiCounters struct {
+	requests atomic.Int64
+	retries  atomic.Int64
+	inFlight atomic.Int64
+	peak     atomic.Int64
+	authFail atomic.Int64
+}
+
+// APIStats returns the counts so far. It is safe to call while requests are
+// being made.
+func (d *DriveService) APIStats() APIStats {
+	return APIStats{
+		Requests:        d.api.requests.Load(),
+		Retries:         d.api.retries.Load(),
+		PeakConcurrency: d.api.peak.Load(),
+		AuthFailures:    d.api.authFail.Load(),
+	}
+}
+
+// countingTransport counts the requests sent through it into counters
+type countingTransport struct {
+	next     http.RoundTripper
+	counters *apiCounters
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.counters.requests.Add(1)
+	n := t.counters.inFlight.Add(1)
+	defer t.counters.inFlight.Add(-1)
+	for peak := t.counters.peak.Load(); n > peak && !t.counters.peak.CompareAndSwap(peak, n); {
+		peak = t.counters.peak.Load()
+	}
+	return t.next.RoundTrip(req)
+}
+
+// authTransport counts the requests sent through it that fail for lack of
+// valid credentials. It wraps the authenticated transport, as obtaining an
+// access token happens there.
+type authTransport struct {
+	next     http.RoundTripper
+	counters *apiCounters
+}
+
+// IsAuthError reports whether err is a request rejected as unauthorized, or
+// one no access token could be obtained for
+func IsAuthError(err error) bool {
+	var apiErr *googleapi.Error
+	var tokenErr *oauth2.RetrieveError
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusUnauthorized || errors.As(err, &tokenErr)
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	var tokenErr *oauth2.RetrieveError
+	if errors.As(err, &tokenErr) || err == nil && resp.StatusCode == http.StatusUnauthorized {
+		t.counters.authFail.Add(1)
+	}
+	return resp, err
+}
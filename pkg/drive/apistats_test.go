@@ -0,0 +1,130 @@
+package drive
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+)
+
+func TestAPIStats(t *testing.T) {
+	defer func(delay time.Duration) { retryBaseDelay = delay }(retryBaseDelay)
+	retryBaseDelay = time.Millisecond
+
+	fake := newFakeDrive()
+	fake.addFile("a", "a.txt", "root", "2025-04-01T00:00:00Z", "a")
+	fake.failures["files/root"] = []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	fake.url = srv.URL
+
+	d := &DriveService{}
+	client := &http.Client{Transport: &countingTransport{next: http.DefaultTransport, counters: &d.api}}
+	service, err := drive.NewService(context.Background(), option.WithEndpoint(srv.URL+"/"), option.WithHTTPClient(client))
+	if err != nil {
+		t.Fatalf("failed to create Drive service: %v", err)
+	}
+	d.service, d.client = service, client
+
+	if _, err := d.ListFiles(ListOptions{MaxDepth: -1}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Two failed root lookups, the one that succeeds and the folder listing
+	stats := d.APIStats()
+	if stats.Requests != 4 || stats.Retries != 2 || stats.PeakConcurrency != 1 {
+		t.Errorf("stats = %+v, want 4 requests, 2 retries and a peak concurrency of 1", stats)
+	}
+}
+
+func TestCountingTransportPeakConcurrency(t *testing.T) {
+	const concurrent = 3
+	var arrived sync.WaitGroup
+	arrived.Add(concurrent)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Hold every request until all of them are in flight
+		arrived.Done()
+		arrived.Wait()
+	}))
+	defer srv.Close()
+
+	var counters apiCounters
+	client := &http.Client{Transport: &countingTransport{next: http.DefaultTransport, counters: &counters}}
+	var done sync.WaitGroup
+	for i := 0; i < concurrent; i++ {
+		done.Add(1)
+		go func() {
+			defer done.Done()
+			resp, err := client.Get(srv.URL)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			resp.Body.Close()
+		}()
+	}
+	done.Wait()
+
+	if got := counters.peak.Load(); got != concurrent {
+		t.Errorf("peak = %d, want %d", got, concurrent)
+	}
+	if got := counters.inFlight.Load(); got != 0 {
+		t.Errorf("in flight = %d after every request finished, want 0", got)
+	}
+}
+
+// failingTokenSource fails like a token endpoint rejecting the credentials
+type failingTokenSource struct{}
+
+func (failingTokenSource) Token() (*oauth2.Token, error) {
+	return nil, &oauth2.RetrieveError{Response: &http.Response{StatusCode: http.StatusBadRequest}}
+}
+
+func TestAuthTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/denied" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+
+	d := &DriveService{}
+	get := func(next http.RoundTripper, path string) {
+		client := &http.Client{Transport: &authTransport{next: next, counters: &d.api}}
+		if resp, err := client.Get(srv.URL + path); err == nil {
+			resp.Body.Close()
+		}
+	}
+	get(http.DefaultTransport, "/ok")
+	if got := d.APIStats().AuthFailures; got != 0 {
+		t.Errorf("AuthFailures = %d after a successful request, want 0", got)
+	}
+	get(http.DefaultTransport, "/denied")
+	get(&oauth2.Transport{Base: http.DefaultTransport, Source: failingTokenSource{}}, "/ok")
+	if got := d.APIStats().AuthFailures; got != 2 {
+		t.Errorf("AuthFailures = %d, want 2", got)
+	}
+}
+
+func TestIsAuthError(t *testing.T) {
+	fake := newFakeDrive()
+	fake.addFolder("f1", "room-1", "root")
+	fake.failures["files"] = []int{http.StatusUnauthorized}
+	d := newTestService(t, fake)
+
+	_, err := d.ListFiles(ListOptions{FolderIDs: []string{"f1"}, MaxDepth: -1})
+	if !IsAuthError(err) {
+		t.Errorf("IsAuthError(%v) = false for a listing rejected as unauthorized", err)
+	}
+
+	fake.failures["files"] = []int{http.StatusForbidden}
+	_, err = d.ListFiles(ListOptions{FolderIDs: []string{"f1"}, MaxDepth: -1})
+	if err == nil || IsAuthError(err) {
+		t.Errorf("IsAuthError(%v) = true, want false for a forbidden listing", err)
+	}
+}
@@ -0,0 +1,129 @@
+package drive
+
+import (
+	"archive/tar"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// TarArchive collects downloaded files into a single tar stream instead of
+// saving them to the output directory
+type TarArchive struct {
+	tw *tar.Writer
+}
+
+// errTarEntry reports a failure writing to the archive, after which it can't
+// take any more entries
+var errTarEntry = errors.New("unable to write tar entry")
+
+// NewTarArchive returns an archive writing to w. Close must be called once
+// every file has been added.
+func NewTarArchive(w io.Writer) *TarArchive {
+	return &TarArchive{tw: tar.NewWriter(w)}
+}
+
+// Close writes the end of the archive. It does not close the underlying writer.
+func (a *TarArchive) Close() error {
+	if err := a.tw.Close(); err != nil {
+		return fmt.Errorf("unable to finish tar archive: %v", err)
+	}
+	return nil
+}
+
+// archiveFile downloads a file straight into the archive, named after its
+// path and stamped with its modification time. Files whose size Drive doesn't
+// send up front are spooled to a temporary file, as tar needs each entry's
+// size before its content.
+func (d *DriveService) archiveFile(fileInfo FileInfo, archive *TarArchive, opts DownloadOptions) error {
+	if fileInfo.IsFolder {
+		return fmt.Errorf("%s is a folder; folder entries from -match-folders can be listed but not downloaded", fileInfo.Path)
+	}
+
+	d.log("📥 Adding to archive: %s", fileInfo.Path)
+	if opts.savesLinkStub(fileInfo) {
+		return d.archiveLinkStub(fileInfo, archive)
+	}
+
+	resp, err := d.service.Files.Get(fileInfo.ID).Context(d.requestContext()).Download()
+	if err != nil {
+		return fmt.Errorf("unable to download file: %v", err)
+	}
+	defer resp.Body.Close()
+
+	size := resp.ContentLength
+	var body io.Reader = resp.Body
+	if size < 0 {
+		d.log("  Size unknown, spooling to a temporary file...")
+		spool, err := os.CreateTemp("", "gdrive-tar-*")
+		if err != nil {
+			return fmt.Errorf("unable to create temporary file: %v", err)
+		}
+		defer os.Remove(spool.Name())
+		defer spool.Close()
+		if size, err = io.Copy(spool, resp.Body); err != nil {
+			return fmt.Errorf("unable to download file: %v", err)
+		}
+		if _, err := spool.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("unable to read temporary file: %v", err)
+		}
+		body = spool
+	}
+
+	hash := md5.New()
+	body = io.TeeReader(body, hash)
+	var progress *progressReader
+	if d.progress != nil {
+		progress = &progressReader{r: body, fn: d.progress, file: fileInfo, total: size}
+		body = progress
+	}
+
+	header := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     fileInfo.Path,
+		Size:     size,
+		Mode:     0644,
+		ModTime:  fileInfo.ModifiedAt,
+	}
+	if err := archive.tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("%w: %v", errTarEntry, err)
+	}
+	if _, err := io.Copy(archive.tw, body); err != nil {
+		return fmt.Errorf("%w: %v", errTarEntry, err)
+	}
+	if progress != nil {
+		progress.finish()
+	}
+
+	if err := d.checkChecksum(fileInfo, hash, opts); err != nil {
+		return err
+	}
+
+	d.log("✅ Successfully archived: %s", fileInfo.Path)
+	return nil
+}
+
+// archiveLinkStub adds a link stub for a Google-native file to the archive
+func (d *DriveService) archiveLinkStub(fileInfo FileInfo, archive *TarArchive) error {
+	data, err := linkStubContent(fileInfo)
+	if err != nil {
+		return err
+	}
+	header := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     fileInfo.Path + linkStubExtension(fileInfo.MimeType),
+		Size:     int64(len(data)),
+		Mode:     0644,
+		ModTime:  fileInfo.ModifiedAt,
+	}
+	if err := archive.tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("%w: %v", errTarEntry, err)
+	}
+	if _, err := archive.tw.Write(data); err != nil {
+		return fmt.Errorf("%w: %v", errTarEntry, err)
+	}
+	d.log("✅ Successfully archived link: %s", header.Name)
+	return nil
+}
@@ -0,0 +1,60 @@
+package drive
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestDownloadFilesTarArchive(t *testing.T) {
+	fake := newFakeDrive()
+	fake.addFile("a", "a.txt", "root", "2025-04-01T00:00:00Z", "hello")
+	fake.addFile("b", "b.txt", "root", "2025-04-02T00:00:00Z", "world!")
+	d := newTestService(t, fake)
+
+	var buf bytes.Buffer
+	archive := NewTarArchive(&buf)
+	outputDir := t.TempDir()
+	files := []FileInfo{
+		{ID: "a", Name: "a.txt", Path: "2025/a.txt", ModifiedAt: rfc3339("2025-04-01T00:00:00Z")},
+		{ID: "b", Name: "b.txt", Path: "b.txt", ModifiedAt: rfc3339("2025-04-02T00:00:00Z"), MD5: md5Hex("world!")},
+	}
+
+	report, err := d.DownloadFiles(files, DownloadOptions{OutputDir: outputDir, Archive: archive, VerifyChecksum: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(report.Downloaded) != 2 {
+		t.Errorf("downloaded %d files, want 2", len(report.Downloaded))
+	}
+
+	tr := tar.NewReader(&buf)
+	for _, want := range []struct{ name, content, modified string }{
+		{"2025/a.txt", "hello", "2025-04-01T00:00:00Z"},
+		{"b.txt", "world!", "2025-04-02T00:00:00Z"},
+	} {
+		header, err := tr.Next()
+		if err != nil {
+			t.Fatalf("reading entry %s: %v", want.name, err)
+		}
+		content, _ := io.ReadAll(tr)
+		if header.Name != want.name || string(content) != want.content {
+			t.Errorf("entry = %s %q, want %s %q", header.Name, content, want.name, want.content)
+		}
+		if !header.ModTime.Equal(rfc3339(want.modified)) {
+			t.Errorf("%s mtime = %v, want %s", header.Name, header.ModTime, want.modified)
+		}
+	}
+	if _, err := tr.Next(); err != io.EOF {
+		t.Errorf("expected end of archive, got %v", err)
+	}
+
+	if entries, _ := os.ReadDir(outputDir); len(entries) != 0 {
+		t.Errorf("expected nothing written to the output directory, found %d entries", len(entries))
+	}
+}
@@ -0,0 +1,87 @@
+package drive
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// Permission is a sharing grant on a file
+type Permission struct {
+	Type         string `json:"type"` // user, group, domain or anyone
+	Role         string `json:"role"`
+	EmailAddress string `json:"emailAddress,omitempty"`
+	Domain       string `json:"domain,omitempty"`
+}
+
+// SharingFinding is a risky grant found by AuditSharing
+type SharingFinding struct {
+	File       FileInfo
+	Permission Permission
+	Reason     string
+}
+
+func newPermissions(perms []*drive.Permission) []Permission {
+	var result []Permission
+	for _, p := range perms {
+		result = append(result, Permission{
+			Type:         p.Type,
+			Role:         p.Role,
+			EmailAddress: p.EmailAddress,
+			Domain:       p.Domain,
+		})
+	}
+	return result
+}
+
+// AuditSharing flags grants that make files public or share them outside the
+// internal domains. When no internal domains are given, the domains of each
+// file's owners are treated as internal.
+func AuditSharing(files []FileInfo, internalDomains []string) []SharingFinding {
+	var findings []SharingFinding
+	for _, file := range files {
+		internal := make(map[string]bool)
+		for _, domain := range internalDomains {
+			internal[strings.ToLower(domain)] = true
+		}
+		if len(internalDomains) == 0 {
+			for _, owner := range file.Owners {
+				internal[emailDomain(owner)] = true
+			}
+		}
+
+		for _, perm := range file.Permissions {
+			if reason := sharingRisk(perm, internal); reason != "" {
+				findings = append(findings, SharingFinding{File: file, Permission: perm, Reason: reason})
+			}
+		}
+	}
+	return findings
+}
+
+// sharingRisk explains why a permission is risky, or returns "" if it isn't
+func sharingRisk(perm Permission, internal map[string]bool) string {
+	switch perm.Type {
+	case "anyone":
+		return "shared with anyone who has the link"
+	case "domain":
+		if !internal[strings.ToLower(perm.Domain)] {
+			return fmt.Sprintf("shared with external domain %s", perm.Domain)
+		}
+	case "user", "group":
+		if domain := emailDomain(perm.EmailAddress); domain != "" && !internal[domain] {
+			return fmt.Sprintf("shared with external %s %s", perm.Type, perm.EmailAddress)
+		}
+	}
+	return ""
+}
+
+// emailDomain returns the lowercased domain part of an email address
+func emailDomain(email string) string {
+	_, domain, ok := strings.Cut(email, "@")
+	if !ok {
+		return ""
+	}
+	return strings.ToLower(domain)
+}
@@ -0,0 +1,52 @@
+package drive
+
+import "testing"
+
+func TestAuditSharing(t *testing.T) {
+	files := []FileInfo{
+		{
+			Path:   "public.mp4",
+			Owners: []string{"alice@example.com"},
+			Permissions: []Permission{
+				{Type: "user", Role: "owner", EmailAddress: "alice@example.com"},
+				{Type: "anyone", Role: "reader"},
+			},
+		},
+		{
+			Path:   "partner.TRANSCRIPT",
+			Owners: []string{"alice@example.com"},
+			Permissions: []Permission{
+				{Type: "user", Role: "writer", EmailAddress: "bob@Example.com"},
+				{Type: "user", Role: "reader", EmailAddress: "carol@partner.org"},
+				{Type: "domain", Role: "reader", Domain: "partner.org"},
+				{Type: "domain", Role: "reader", Domain: "example.com"},
+			},
+		},
+		{
+			Path:        "private.txt",
+			Owners:      []string{"alice@example.com"},
+			Permissions: []Permission{{Type: "group", Role: "reader", EmailAddress: "team@example.com"}},
+		},
+	}
+
+	findings := AuditSharing(files, nil)
+	want := []string{
+		"public.mp4: shared with anyone who has the link",
+		"partner.TRANSCRIPT: shared with external user carol@partner.org",
+		"partner.TRANSCRIPT: shared with external domain partner.org",
+	}
+	if len(findings) != len(want) {
+		t.Fatalf("got %d findings, want %d: %+v", len(findings), len(want), findings)
+	}
+	for i, f := range findings {
+		if got := f.File.Path + ": " + f.Reason; got != want[i] {
+			t.Errorf("finding %d = %q, want %q", i, got, want[i])
+		}
+	}
+
+	// Explicit internal domains replace the owners' domains
+	findings = AuditSharing(files[1:], []string{"example.com", "partner.org"})
+	if len(findings) != 0 {
+		t.Errorf("expected no findings with partner.org internal, got %+v", findings)
+	}
+}
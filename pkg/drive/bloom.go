@@ -0,0 +1,201 @@
+package drive
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Default sizing of a new BloomFilter: about 1.8 MB, with one false match
+// in a thousand until it holds this many IDs
+const (
+	DefaultBloomCapacity      = 1_000_000
+	DefaultBloomFalsePositive = 0.001
+)
+
+// bloomMagic starts every saved BloomFilter, followed by a format version
+const bloomMagic = "GDDBLOOM"
+
+// BloomFilter records the IDs of files downloaded by earlier runs in a fixed
+// amount of memory. Test never misses an ID that was added, but may report
+// IDs that weren't, more often once it holds more than its capacity. It is
+// safe for concurrent use, as by a crawl and the downloads it feeds.
+type BloomFilter struct {
+	mu       sync.RWMutex
+	bits     []uint64
+	hashes   uint32
+	count    uint64
+	capacity uint64
+}
+
+// bloomHeader is the fixed-size start of a saved BloomFilter
+type bloomHeader struct {
+	Magic    [8]byte
+	Version  uint8
+	Hashes   uint32
+	Words    uint64
+	Count    uint64
+	Capacity uint64
+}
+
+// NewBloomFilter returns an empty filter sized to hold capacity IDs with
+// the given rate of false matches
+func NewBloomFilter(capacity int, falsePositive float64) *BloomFilter {
+	capacity = max(capacity, 1)
+	bits := math.Ceil(-float64(capacity) * math.Log(falsePositive) / (math.Ln2 * math.Ln2))
+	hashes := max(uint32(math.Round(bits/float64(capacity)*math.Ln2)), 1)
+	return &BloomFilter{
+		bits:     make([]uint64, (int(bits)+63)/64),
+		hashes:   hashes,
+		capacity: uint64(capacity),
+	}
+}
+
+// positions returns the bits an ID maps to, by double hashing its SHA-256
+func (b *BloomFilter) positions(id string) []uint64 {
+	sum := sha256.Sum256([]byte(id))
+	h1 := binary.LittleEndian.Uint64(sum[0:8])
+	h2 := binary.LittleEndian.Uint64(sum[8:16]) | 1
+	n := uint64(len(b.bits)) * 64
+	positions := make([]uint64, b.hashes)
+	for i := range positions {
+		positions[i] = (h1 + uint64(i)*h2) % n
+	}
+	return positions
+}
+
+// Add records an ID
+func (b *BloomFilter) Add(id string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.test(id) {
+		return
+	}
+	for _, p := range b.positions(id) {
+		b.bits[p/64] |= 1 << (p % 64)
+	}
+	b.count++
+}
+
+// Test reports whether an ID was probably added
+func (b *BloomFilter) Test(id string) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.test(id)
+}
+
+func (b *BloomFilter) test(id string) bool {
+	for _, p := range b.positions(id) {
+		if b.bits[p/64]&(1<<(p%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Count returns how many distinct IDs were added, give or take the IDs
+// mistaken for ones already added
+func (b *BloomFilter) Count() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return int(b.count)
+}
+
+// Full reports whether the filter holds more IDs than it was sized for, so
+// false matches are more likely than intended
+func (b *BloomFilter) Full() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.count > b.capacity
+}
+
+// LoadBloomFilter reads a filter saved by Save. A missing file is returned
+// as an error wrapping fs.ErrNotExist.
+func LoadBloomFilter(path string) (*BloomFilter, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read bloom filter: %w", err)
+	}
+	r := bytes.NewReader(data)
+	var h bloomHeader
+	if err := binary.Read(r, binary.LittleEndian, &h); err != nil || string(h.Magic[:]) != bloomMagic {
+		return nil, fmt.Errorf("%s is not a bloom filter saved by -seen-bloom", path)
+	}
+	if h.Version != 1 || h.Hashes == 0 || h.Words == 0 || h.Words*8 != uint64(r.Len()) {
+		return nil, fmt.Errorf("%s is not a valid bloom filter", path)
+	}
+	b := &BloomFilter{bits: make([]uint64, h.Words), hashes: h.Hashes, count: h.Count, capacity: h.Capacity}
+	if err := binary.Read(r, binary.LittleEndian, b.bits); err != nil {
+		return nil, fmt.Errorf("unable to read bloom filter: %v", err)
+	}
+	return b, nil
+}
+
+// Save writes the filter to path, replacing an earlier copy only once it
+// is complete
+func (b *BloomFilter) Save(path string) error {
+	b.mu.RLock()
+	var buf bytes.Buffer
+	h := bloomHeader{Version: 1, Hashes: b.hashes, Words: uint64(len(b.bits)), Count: b.count, Capacity: b.capacity}
+	copy(h.Magic[:], bloomMagic)
+	binary.Write(&buf, binary.LittleEndian, &h)
+	binary.Write(&buf, binary.LittleEndian, b.bits)
+	b.mu.RUnlock()
+
+	partPath := path + partSuffix
+	if err := os.WriteFile(partPath, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("unable to write bloom filter: %v", err)
+	}
+	if err := os.Rename(partPath, path); err != nil {
+		os.Remove(partPath)
+		return fmt.Errorf("unable to write bloom filter: %v", err)
+	}
+	return nil
+}
+
+// RebuildBloomFilter returns a filter of the IDs recorded in the metadata
+// sidecars written by DownloadOptions.WriteMetadata under dir, sized for
+// twice as many IDs or the default capacity, whichever is larger
+func RebuildBloomFilter(dir string) (*BloomFilter, error) {
+	var ids []string
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || !strings.HasSuffix(path, metadataSuffix) {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var info FileInfo
+		if err := json.Unmarshal(data, &info); err != nil || info.ID == "" {
+			fmt.Printf("⚠️ Skipping %s: not a metadata sidecar\n", path)
+			return nil
+		}
+		ids = append(ids, info.ID)
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("output directory %s does not exist", dir)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read metadata sidecars: %v", err)
+	}
+
+	b := NewBloomFilter(max(2*len(ids), DefaultBloomCapacity), DefaultBloomFalsePositive)
+	for _, id := range ids {
+		b.Add(id)
+	}
+	return b, nil
+}
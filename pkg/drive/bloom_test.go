@@ -0,0 +1,157 @@
+package drive
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/api/drive/v3"
+)
+
+func TestBloomFilter(t *testing.T) {
+	b := NewBloomFilter(10000, 0.01)
+	for i := 0; i < 10000; i++ {
+		b.Add(fmt.Sprintf("added-%d", i))
+	}
+	for i := 0; i < 10000; i++ {
+		if !b.Test(fmt.Sprintf("added-%d", i)) {
+			t.Fatalf("added-%d was added but not found", i)
+		}
+	}
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		if b.Test(fmt.Sprintf("other-%d", i)) {
+			falsePositives++
+		}
+	}
+	// 1% expected; allow for chance
+	if falsePositives > 200 {
+		t.Errorf("%d false positives in 10000, want about 100", falsePositives)
+	}
+	if b.Full() {
+		t.Error("filter holding its capacity reported as full")
+	}
+
+	path := filepath.Join(t.TempDir(), "seen.bloom")
+	if _, err := LoadBloomFilter(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("LoadBloomFilter of a missing file = %v, want fs.ErrNotExist", err)
+	}
+	if err := b.Save(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	loaded, err := LoadBloomFilter(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !loaded.Test("added-42") || loaded.Count() != b.Count() {
+		t.Errorf("loaded filter lost IDs: count %d, want %d", loaded.Count(), b.Count())
+	}
+
+	os.WriteFile(path, []byte("not a filter"), 0644)
+	if _, err := LoadBloomFilter(path); err == nil || !strings.Contains(err.Error(), "not a bloom filter") {
+		t.Errorf("LoadBloomFilter of another file = %v", err)
+	}
+}
+
+func TestDownloadFilesSeen(t *testing.T) {
+	fake := newFakeDrive()
+	fake.addFile("a", "a.txt", "root", "2025-04-01T00:00:00Z", "a")
+	fake.addFile("b", "b.txt", "root", "2025-04-01T00:00:00Z", "b")
+	d := newTestService(t, fake)
+
+	files := []FileInfo{{ID: "a", Name: "a.txt", Path: "a.txt"}, {ID: "b", Name: "b.txt", Path: "b.txt"}}
+	outputDir := t.TempDir()
+	seen := NewBloomFilter(100, 0.001)
+	seen.Add("a")
+	report, err := d.DownloadFiles(files, DownloadOptions{OutputDir: outputDir, Seen: seen, WriteMetadata: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := strings.Join(paths(report.Downloaded), ","); got != "b.txt" {
+		t.Errorf("downloaded %s, want only b.txt", got)
+	}
+	if !seen.Test("b") {
+		t.Error("downloaded file was not added to the filter")
+	}
+
+	rebuilt, err := RebuildBloomFilter(outputDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !rebuilt.Test("b") || rebuilt.Count() != 1 {
+		t.Errorf("rebuilt filter holds %d IDs, want b only", rebuilt.Count())
+	}
+}
+
+func TestListFilesExclude(t *testing.T) {
+	fake := newFakeDrive()
+	fake.addFile("a", "a.txt", "root", "2025-04-01T00:00:00Z", "a")
+	fake.addFile("b", "b.txt", "root", "2025-04-01T00:00:00Z", "b")
+	fake.addFile("c", "c.txt", "root", "2025-04-01T00:00:00Z", "c")
+	d := newTestService(t, fake)
+
+	seen := NewBloomFilter(100, 0.001)
+	seen.Add("b")
+	opts := ListOptions{Pattern: `\.txt$`, FolderIDs: []string{"root"}, MaxDepth: -1, MaxResults: 2, Exclude: seen, OrderBy: SortOrder{Field: "path"}}
+	files, err := d.ListFiles(opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Excluded files don't use up MaxResults
+	if got, want := paths(files), []string{"a.txt", "c.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ListFiles() = %v, want %v", got, want)
+	}
+
+	cached := []FileInfo{{ID: "a", Name: "a.txt", Path: "a.txt"}, {ID: "b", Name: "b.txt", Path: "b.txt"}}
+	files, err = d.FilterFiles(cached, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := paths(files), []string{"a.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FilterFiles() = %v, want %v", got, want)
+	}
+}
+
+// BenchmarkMatchFileExclude measures the matching work Exclude saves on a
+// large listing whose files were all downloaded before
+func BenchmarkMatchFileExclude(b *testing.B) {
+	files := make([]*drive.File, 100000)
+	for i := range files {
+		files[i] = &drive.File{
+			Id:           fmt.Sprintf("id-%d", i),
+			Name:         fmt.Sprintf("meeting-%d.TRANSCRIPT", i),
+			MimeType:     "text/plain",
+			ModifiedTime: "2025-04-01T00:00:00Z",
+			CreatedTime:  "2025-03-01T00:00:00Z",
+			Size:         1024,
+		}
+	}
+	seen := NewBloomFilter(len(files), DefaultBloomFalsePositive)
+	for _, f := range files {
+		seen.Add(f.Id)
+	}
+
+	for _, bench := range []struct {
+		name    string
+		exclude *BloomFilter
+	}{{"match", nil}, {"exclude", seen}} {
+		b.Run(bench.name, func(b *testing.B) {
+			d := &DriveService{}
+			opts := ListOptions{Pattern: `(?i)meeting-\d+\.transcript$`, FolderIDs: []string{"root"}, MaxDepth: -1, Exclude: bench.exclude}
+			for i := 0; i < b.N; i++ {
+				c, _, err := d.newCrawl(opts)
+				if err != nil {
+					b.Fatalf("unexpected error: %v", err)
+				}
+				for _, f := range files {
+					d.matchFile(c, f, "Zoom/2025/"+f.Name, 2)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package drive
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DetectCaseInsensitiveFS reports whether file names differing only in case
+// refer to the same file in dir, or in its nearest existing parent when dir
+// doesn't exist yet. It creates and removes a small probe file there.
+func DetectCaseInsensitiveFS(dir string) (bool, error) {
+	dir = filepath.Clean(dir)
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return false, fmt.Errorf("unable to check the filesystem of %s: %v", dir, err)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return false, fmt.Errorf("unable to check the filesystem of %s: no existing parent", dir)
+		}
+		dir = parent
+	}
+
+	probe, err := os.CreateTemp(dir, ".case-probe-*")
+	if err != nil {
+		return false, fmt.Errorf("unable to check the filesystem of %s: %v", dir, err)
+	}
+	probe.Close()
+	defer os.Remove(probe.Name())
+
+	lower, err := os.Stat(probe.Name())
+	if err != nil {
+		return false, fmt.Errorf("unable to check the filesystem of %s: %v", dir, err)
+	}
+	upper, err := os.Stat(filepath.Join(dir, strings.ToUpper(filepath.Base(probe.Name()))))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("unable to check the filesystem of %s: %v", dir, err)
+	}
+	return os.SameFile(lower, upper), nil
+}
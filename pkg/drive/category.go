@@ -0,0 +1,125 @@
+package drive
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Category is a named group of file types matched by extension or MIME type
+type Category struct {
+	Extensions []string `json:"extensions"`
+	MimeTypes  []string `json:"mimeTypes,omitempty"`
+}
+
+// DefaultCategories are the categories available to -category
+var DefaultCategories = map[string]Category{
+	"video": {
+		Extensions: []string{"mp4", "mov", "m4v", "webm", "mkv", "avi"},
+		MimeTypes:  []string{"application/vnd.google-apps.video"},
+	},
+	"audio": {
+		Extensions: []string{"m4a", "mp3", "wav", "ogg", "flac", "aac"},
+		MimeTypes:  []string{"application/vnd.google-apps.audio"},
+	},
+	"image": {
+		Extensions: []string{"jpg", "jpeg", "png", "gif", "webp", "heic", "svg"},
+		MimeTypes:  []string{"application/vnd.google-apps.photo", "application/vnd.google-apps.drawing"},
+	},
+	"document": {
+		Extensions: []string{"pdf", "doc", "docx", "odt", "rtf", "txt", "md"},
+		MimeTypes:  []string{"application/vnd.google-apps.document"},
+	},
+	"spreadsheet": {
+		Extensions: []string{"xls", "xlsx", "ods", "csv", "tsv"},
+		MimeTypes:  []string{"application/vnd.google-apps.spreadsheet"},
+	},
+	"presentation": {
+		Extensions: []string{"ppt", "pptx", "odp", "key"},
+		MimeTypes:  []string{"application/vnd.google-apps.presentation"},
+	},
+	"archive": {
+		Extensions: []string{"zip", "tar", "gz", "tgz", "bz2", "xz", "7z", "rar"},
+	},
+	"transcript": {
+		Extensions: []string{"transcript", "vtt", "srt"},
+	},
+}
+
+// LoadCategories reads a JSON object of categories from path, such as
+// {"video": {"extensions": ["mp4", "mov"]}}, and returns them on top of the
+// defaults. A category in the file replaces the default of the same name.
+func LoadCategories(path string) (map[string]Category, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read categories file: %v", err)
+	}
+
+	var overrides map[string]Category
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return nil, fmt.Errorf("invalid categories file %s: %v", path, err)
+	}
+
+	categories := make(map[string]Category, len(DefaultCategories)+len(overrides))
+	for name, category := range DefaultCategories {
+		categories[name] = category
+	}
+	for name, category := range overrides {
+		if len(category.Extensions) == 0 && len(category.MimeTypes) == 0 {
+			return nil, fmt.Errorf("invalid categories file %s: category %q has no extensions or MIME types", path, name)
+		}
+		categories[strings.ToLower(name)] = category
+	}
+	return categories, nil
+}
+
+// ResolveCategories looks up each named category in categories
+func ResolveCategories(categories map[string]Category, names []string) ([]Category, error) {
+	var resolved []Category
+	for _, name := range names {
+		category, ok := categories[strings.ToLower(name)]
+		if !ok {
+			var known []string
+			for k := range categories {
+				known = append(known, k)
+			}
+			sort.Strings(known)
+			return nil, fmt.Errorf("unknown category %q (expected one of %s)", name, strings.Join(known, ", "))
+		}
+		resolved = append(resolved, category)
+	}
+	return resolved, nil
+}
+
+// Matches reports whether a file belongs to the category
+func (c Category) Matches(fileInfo FileInfo) bool {
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(fileInfo.Name)), ".")
+	for _, e := range c.Extensions {
+		if ext != "" && strings.EqualFold(strings.TrimPrefix(e, "."), ext) {
+			return true
+		}
+	}
+	for _, mimeType := range c.MimeTypes {
+		if fileInfo.MimeType == mimeType {
+			return true
+		}
+	}
+	return false
+}
+
+// inAnyCategory reports whether a file belongs to one of the categories, or
+// whether no categories were given
+func inAnyCategory(fileInfo FileInfo, categories []Category) bool {
+	if len(categories) == 0 {
+		return true
+	}
+	for _, category := range categories {
+		if category.Matches(fileInfo) {
+			return true
+		}
+	}
+	return false
+}
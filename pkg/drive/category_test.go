@@ -0,0 +1,72 @@
+package drive
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCategoryMatches(t *testing.T) {
+	video := DefaultCategories["video"]
+	tests := []struct {
+		file FileInfo
+		want bool
+	}{
+		{file: FileInfo{Name: "call.MP4"}, want: true},
+		{file: FileInfo{Name: "clip.mov"}, want: true},
+		{file: FileInfo{Name: "Recording", MimeType: "application/vnd.google-apps.video"}, want: true},
+		{file: FileInfo{Name: "call.m4a"}, want: false},
+		{file: FileInfo{Name: "mp4"}, want: false},
+	}
+	for _, tt := range tests {
+		if got := video.Matches(tt.file); got != tt.want {
+			t.Errorf("video.Matches(%+v) = %v, want %v", tt.file, got, tt.want)
+		}
+	}
+
+	categories, err := ResolveCategories(DefaultCategories, []string{"Video", "audio"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !inAnyCategory(FileInfo{Name: "call.m4a"}, categories) || inAnyCategory(FileInfo{Name: "notes.txt"}, categories) {
+		t.Error("inAnyCategory did not match video or audio files only")
+	}
+	if !inAnyCategory(FileInfo{Name: "notes.txt"}, nil) {
+		t.Error("files must match when no categories are given")
+	}
+	if _, err := ResolveCategories(DefaultCategories, []string{"films"}); err == nil {
+		t.Error("expected error for unknown category")
+	}
+}
+
+func TestLoadCategories(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "categories.json")
+	content := `{"video": {"extensions": ["mp4"]}, "Meetings": {"extensions": ["TRANSCRIPT", "m4a"]}}`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	categories, err := LoadCategories(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if categories["video"].Matches(FileInfo{Name: "clip.mov"}) {
+		t.Error("overridden video category still matches .mov")
+	}
+	if !categories["meetings"].Matches(FileInfo{Name: "call.transcript"}) {
+		t.Error("custom meetings category does not match .transcript")
+	}
+	if _, ok := categories["audio"]; !ok {
+		t.Error("default categories missing after loading overrides")
+	}
+	if _, ok := DefaultCategories["meetings"]; ok {
+		t.Error("LoadCategories modified DefaultCategories")
+	}
+
+	if err := os.WriteFile(path, []byte(`{"empty": {}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadCategories(path); err == nil {
+		t.Error("expected error for empty category")
+	}
+}
@@ -0,0 +1,215 @@
+package drive
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"strings"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+)
+
+// ErrNoChangeToken is returned by ReadChangeToken when no token has been
+// saved yet, so a full search is needed first
+var ErrNoChangeToken = errors.New("no change token saved")
+
+// changeFields lists the fields requested for each page of changes
+const changeFields = "nextPageToken, newStartPageToken, changes(fileId, removed, file(" + fileDetailFields + "))"
+
+// StartPageToken returns the token from which SyncChanges picks up changes
+// made from now on. Take it before a full search, so nothing changed during
+// the search is missed.
+func (d *DriveService) StartPageToken() (string, error) {
+	var r *drive.StartPageToken
+	err := d.retryDo("Getting the change token", func() (err error) {
+		r, err = d.service.Changes.GetStartPageToken().
+			SupportsAllDrives(true).
+			Context(d.requestContext()).
+			Do()
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("unable to get the change token: %w", err)
+	}
+	return r.StartPageToken, nil
+}
+
+// ReadChangeToken loads a token saved by WriteChangeToken
+func ReadChangeToken(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", ErrNoChangeToken
+	}
+	if err != nil {
+		return "", fmt.Errorf("unable to read change token: %v", err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("change token file %s is empty", path)
+	}
+	return token, nil
+}
+
+// WriteChangeToken saves a change token, replacing the previous one only
+// once the new one is completely written
+func WriteChangeToken(path, token string) error {
+	partPath := path + partSuffix
+	if err := os.WriteFile(partPath, []byte(token+"\n"), 0644); err != nil {
+		return fmt.Errorf("unable to write change token: %v", err)
+	}
+	if err := os.Rename(partPath, path); err != nil {
+		os.Remove(partPath)
+		return fmt.Errorf("unable to write change token: %v", err)
+	}
+	return nil
+}
+
+// SyncChanges finds the files changed since the token saved at tokenPath
+// that match opts, one page of changes at a time, and calls process with
+// the matches of each page. The saved token is only advanced past a page
+// once process has succeeded for it, so after a failure or crash the next
+// run sees that page again instead of skipping its files. Pages without
+// matches are still saved past.
+//
+// Changed files are placed like ListFiles would place them under
+// opts.FolderIDs, or the root folder when empty; files outside them are
+// ignored, as are folders, removed and trashed files. opts.Query,
+// opts.SharedWithMe and the limits and order of opts are not supported.
+func (d *DriveService) SyncChanges(tokenPath string, opts ListOptions, process func(files []FileInfo) error) error {
+	if opts.Query != "" || opts.SharedWithMe {
+		return fmt.Errorf("a Drive query and files shared with the account can't be searched for changes")
+	}
+	token, err := ReadChangeToken(tokenPath)
+	if err != nil {
+		return err
+	}
+	c, folderIDs, err := d.newCrawl(opts)
+	if err != nil {
+		return err
+	}
+	roots := make(map[string]bool)
+	for _, id := range folderIDs {
+		roots[id] = true
+	}
+	folders := make(map[string]*drive.File)
+
+	for {
+		r, err := d.listChanges(token, opts)
+		if err != nil {
+			return err
+		}
+		c.files, c.seen = nil, make(map[string]bool)
+		for _, change := range r.Changes {
+			if err := d.matchChange(c, change, roots, folders); err != nil {
+				return err
+			}
+		}
+		d.log("🔄 %d changes, %d matching files", len(r.Changes), len(c.files))
+
+		if err := process(c.files); err != nil {
+			return err
+		}
+		next := r.NextPageToken
+		if next == "" {
+			next = r.NewStartPageToken
+		}
+		if err := WriteChangeToken(tokenPath, next); err != nil {
+			return err
+		}
+		if r.NextPageToken == "" {
+			return nil
+		}
+		token = next
+	}
+}
+
+// listChanges fetches the page of changes at token
+func (d *DriveService) listChanges(token string, opts ListOptions) (*drive.ChangeList, error) {
+	call := d.service.Changes.List(token).
+		Fields(changeFields).
+		IncludeItemsFromAllDrives(true).
+		SupportsAllDrives(true).
+		PageSize(opts.pageSize())
+	if len(opts.Labels) > 0 {
+		call = call.IncludeLabels(labelIDs(opts.Labels))
+	}
+	var r *drive.ChangeList
+	err := d.retryDo("Listing changes", func() (err error) {
+		r, err = call.Context(d.requestContext()).Do()
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list changes: %w", err)
+	}
+	return r, nil
+}
+
+// matchChange adds the file of a change to the crawl's results if it is
+// under one of roots and passes every filter
+func (d *DriveService) matchChange(c *crawl, change *drive.Change, roots map[string]bool, folders map[string]*drive.File) error {
+	f := change.File
+	if change.Removed || f == nil || f.Trashed || f.MimeType == folderMimeType {
+		return nil
+	}
+	path, depth, err := d.changePath(f, roots, folders)
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		d.log("  ⏭️ Skipping changed file outside the searched folders: %s", f.Name)
+		return nil
+	}
+	if c.opts.MaxDepth != -1 && depth > c.opts.MaxDepth {
+		return nil
+	}
+	if !ofAnyType(f.MimeType, c.opts.Types) {
+		return nil
+	}
+	d.counters.files.Add(1)
+	d.matchFile(c, f, d.cleanPath(path), depth)
+	return nil
+}
+
+// changePath returns the path of a changed file below the root it is under,
+// and the depth of its folder, by walking up its parents, or "" if it is not
+// under any root. Folders looked up are cached in folders. A folder that
+// can't be looked up fails the page rather than dropping the file for good.
+func (d *DriveService) changePath(f *drive.File, roots map[string]bool, folders map[string]*drive.File) (string, int, error) {
+	names := []string{f.Name}
+	parents := f.Parents
+	for len(parents) > 0 {
+		id := parents[0]
+		if roots[id] {
+			for i, j := 0, len(names)-1; i < j; i, j = i+1, j-1 {
+				names[i], names[j] = names[j], names[i]
+			}
+			return joinPath(names...), len(names) - 1, nil
+		}
+		folder, ok := folders[id]
+		if !ok {
+			err := d.retryDo("Looking up a folder", func() (err error) {
+				folder, err = d.service.Files.Get(id).
+					Fields("id, name, parents").
+					SupportsAllDrives(true).
+					Context(d.requestContext()).
+					Do()
+				return err
+			})
+			var apiErr *googleapi.Error
+			if errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+				// A parent the account can't see is outside every root
+				return "", 0, nil
+			}
+			if err != nil {
+				return "", 0, fmt.Errorf("unable to look up folder %s of %s: %v", id, f.Name, err)
+			}
+			folders[id] = folder
+		}
+		names = append(names, folder.Name)
+		parents = folder.Parents
+	}
+	return "", 0, nil
+}
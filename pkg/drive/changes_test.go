@@ -0,0 +1,158 @@
+package drive
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSyncChanges(t *testing.T) {
+	defer func(delay time.Duration) { retryBaseDelay = delay }(retryBaseDelay)
+	retryBaseDelay = time.Millisecond
+
+	fake := newFakeDrive()
+	fake.addFolder("f1", "recordings", "root")
+	fake.addFolder("f1a", "apr", "f1")
+	fake.addFolder("f2", "other", "root")
+	d := newTestService(t, fake)
+
+	tokenPath := filepath.Join(t.TempDir(), "changes.token")
+	process := func([]FileInfo) error { return nil }
+	opts := ListOptions{FolderIDs: []string{"f1"}, Pattern: "TRANSCRIPT", MaxDepth: -1, PageSize: 2}
+	if err := d.SyncChanges(tokenPath, opts, process); !errors.Is(err, ErrNoChangeToken) {
+		t.Fatalf("SyncChanges() without a token error = %v, want ErrNoChangeToken", err)
+	}
+
+	start, err := d.StartPageToken()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := WriteChangeToken(tokenPath, start); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	fake.addFile("a", "a.TRANSCRIPT", "f1", "2025-04-01T00:00:00Z", "a")
+	fake.addFile("b", "b.TRANSCRIPT", "f2", "2025-04-01T00:00:00Z", "b")
+	fake.addFile("c", "c.TRANSCRIPT", "f1a", "2025-04-01T00:00:00Z", "c")
+	fake.addFile("d", "d.txt", "f1", "2025-04-01T00:00:00Z", "d")
+	fake.changes = []string{"a", "b", "c", "d", "gone"}
+	fake.failures["changes"] = []int{http.StatusServiceUnavailable}
+
+	// The second page fails to download, so the token stays after the first
+	var pages [][]string
+	failure := errors.New("download failed")
+	err = d.SyncChanges(tokenPath, opts, func(files []FileInfo) error {
+		pages = append(pages, paths(files))
+		if len(pages) == 2 {
+			return failure
+		}
+		return nil
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("SyncChanges() error = %v, want the processing failure", err)
+	}
+	if want := [][]string{{"a.TRANSCRIPT"}, {"apr/c.TRANSCRIPT"}}; !reflect.DeepEqual(pages, want) {
+		t.Errorf("pages = %v, want %v", pages, want)
+	}
+	if token, _ := ReadChangeToken(tokenPath); token != "2" {
+		t.Errorf("token after the failure = %q, want %q", token, "2")
+	}
+
+	// The next run processes the failed page again and then catches up
+	pages = nil
+	err = d.SyncChanges(tokenPath, opts, func(files []FileInfo) error {
+		pages = append(pages, paths(files))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := [][]string{{"apr/c.TRANSCRIPT"}, nil}; !reflect.DeepEqual(pages, want) {
+		t.Errorf("pages = %v, want %v", pages, want)
+	}
+	if token, _ := ReadChangeToken(tokenPath); token != "5" {
+		t.Errorf("token after catching up = %q, want %q", token, "5")
+	}
+}
+
+func TestSyncChangesCrashAfterRename(t *testing.T) {
+	fake := newFakeDrive()
+	d := newTestService(t, fake)
+	dir := t.TempDir()
+	tokenPath, seenPath, outputDir := filepath.Join(dir, "changes.token"), filepath.Join(dir, "seen.bloom"), filepath.Join(dir, "out")
+	start, err := d.StartPageToken()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := WriteChangeToken(tokenPath, start); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := NewBloomFilter(10, 0.01).Save(seenPath); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fake.addFile("a", "a.txt", "root", "2025-04-01T00:00:00Z", "hello")
+	fake.changes = []string{"a"}
+
+	// A run saves the filter once its downloads succeeded, and the token
+	// is only advanced after that
+	run := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("crashed: %v", r)
+			}
+		}()
+		seen, err := LoadBloomFilter(seenPath)
+		if err != nil {
+			return err
+		}
+		opts := DownloadOptions{OutputDir: outputDir, VerifyChecksum: true, Seen: seen}
+		return d.SyncChanges(tokenPath, ListOptions{MaxDepth: -1}, func(files []FileInfo) error {
+			report, err := d.DownloadFiles(files, opts)
+			if err == nil && len(report.Failed) > 0 {
+				err = report.Failed[0].Err
+			}
+			if err != nil {
+				return err
+			}
+			return seen.Save(seenPath)
+		})
+	}
+
+	// The first run crashes once the corrupt download is in place, before
+	// it is checked
+	fake.corrupt["a"] = 1
+	defer func(f func(string)) { afterRename = f }(afterRename)
+	afterRename = func(string) { panic("crash") }
+	if err := run(); err == nil {
+		t.Fatal("expected the run to crash")
+	}
+	if content, _ := os.ReadFile(filepath.Join(outputDir, "a.txt")); string(content) == "hello" {
+		t.Fatal("expected the corrupt download to be left in place by the crash")
+	}
+	if seen, _ := LoadBloomFilter(seenPath); seen.Test("a") {
+		t.Error("a was saved as downloaded before it was verified")
+	}
+	if token, _ := ReadChangeToken(tokenPath); token != start {
+		t.Errorf("token after the crash = %q, want %q", token, start)
+	}
+
+	// The next run downloads and verifies it again rather than skipping it
+	afterRename = func(string) {}
+	if err := run(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if content, _ := os.ReadFile(filepath.Join(outputDir, "a.txt")); string(content) != "hello" {
+		t.Errorf("content = %q, want hello", content)
+	}
+	if seen, _ := LoadBloomFilter(seenPath); !seen.Test("a") {
+		t.Error("a was not saved as downloaded once verified")
+	}
+	if token, _ := ReadChangeToken(tokenPath); token != "1" {
+		t.Errorf("token after the next run = %q, want %q", token, "1")
+	}
+}
@@ -0,0 +1,200 @@
+package drive
+
+import (
+	"errors"
+	"fmt"
+	pathpkg "path"
+	"strings"
+)
+
+// ErrPathCollision is returned by ResolveCollisions with the "fail" strategy
+// when several files would be saved to the same local path
+var ErrPathCollision = errors.New("output path collision")
+
+// Collision strategies for ResolveCollisions
+const (
+	CollisionOverwrite = "overwrite"
+	CollisionSkip      = "skip"
+	CollisionRename    = "rename"
+	CollisionFail      = "fail"
+)
+
+// ValidateCollisionStrategy checks that an -on-collision value is supported
+func ValidateCollisionStrategy(strategy string) error {
+	switch strategy {
+	case CollisionOverwrite, CollisionSkip, CollisionRename, CollisionFail:
+		return nil
+	}
+	return fmt.Errorf("invalid collision strategy %q (expected overwrite, skip, rename or fail)", strategy)
+}
+
+// Collision is a local path that several files would be saved to
+type Collision struct {
+	LocalPath string
+	Files     []FileInfo
+}
+
+// FindCollisions returns the local paths, computed with opts as DownloadFiles
+// would, that more than one file maps to, in the order they are first seen.
+// The paths of exported variants count too, so a Google Doc exported to
+// notes.pdf collides with a notes.pdf next to it. With
+// opts.CaseInsensitiveFS, paths differing only in case collide, and each
+// collision is reported under the path of its first file.
+func FindCollisions(files []FileInfo, opts DownloadOptions) ([]Collision, error) {
+	byPath := make(map[string][]FileInfo)
+	firstPath := make(map[string]string)
+	var order []string
+	for _, file := range files {
+		if file.IsFolder {
+			continue
+		}
+		localPaths, err := opts.localPaths(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, localPath := range localPaths {
+			key := opts.collisionKey(localPath)
+			if _, ok := byPath[key]; !ok {
+				order = append(order, key)
+				firstPath[key] = localPath
+			}
+			byPath[key] = append(byPath[key], file)
+		}
+	}
+
+	var collisions []Collision
+	for _, key := range order {
+		if len(byPath[key]) > 1 {
+			collisions = append(collisions, Collision{LocalPath: firstPath[key], Files: byPath[key]})
+		}
+	}
+	return collisions, nil
+}
+
+// localPaths returns the local paths a file is saved to: its output path,
+// and those of exported variants, except with TagRevision where they are
+// named after revisions that aren't known until they are exported
+func (o DownloadOptions) localPaths(file FileInfo) ([]string, error) {
+	outPath, err := o.OutputPath(file)
+	if err != nil {
+		return nil, err
+	}
+	paths := []string{outPath}
+	if o.TagRevision || !isGoogleNative(file.MimeType) {
+		return paths, nil
+	}
+	baseDir, err := o.BaseDir(file)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range o.Variants {
+		if export := variants[name].export; export != "" {
+			paths = append(paths, localPath(baseDir, file.Path)+export)
+		}
+	}
+	return paths, nil
+}
+
+// collisionKey returns what two local paths have in common when they refer
+// to the same file on disk
+func (o DownloadOptions) collisionKey(localPath string) string {
+	if o.CaseInsensitiveFS {
+		return strings.ToLower(localPath)
+	}
+	return localPath
+}
+
+// ResolveCollisions applies a collision strategy to the files. The first file
+// for each local path always keeps it; later ones are left to overwrite it,
+// skipped, renamed, or make the call fail with ErrPathCollision listing every
+// collision. Renamed files get a " (2)", " (3)", ... counter before the
+// extension, except Google Docs editors files, which get their ID instead so
+// their exports keep the same name from one run to the next. Skipped and
+// renamed files are reported to the writer set by WithOutput.
+func (d *DriveService) ResolveCollisions(files []FileInfo, opts DownloadOptions, strategy string) ([]FileInfo, error) {
+	if strategy == CollisionOverwrite {
+		return files, nil
+	}
+	collisions, err := FindCollisions(files, opts)
+	if err != nil || len(collisions) == 0 {
+		return files, err
+	}
+
+	if strategy == CollisionFail {
+		var lines []string
+		for _, c := range collisions {
+			var paths []string
+			for _, file := range c.Files {
+				paths = append(paths, file.Path+" (ID: "+file.ID+")")
+			}
+			lines = append(lines, fmt.Sprintf("%s <- %s", c.LocalPath, strings.Join(paths, ", ")))
+		}
+		return nil, fmt.Errorf("%w: %d local paths are shared by several files:\n%s", ErrPathCollision, len(collisions), strings.Join(lines, "\n"))
+	}
+
+	taken := make(map[string]bool)
+	var resolved []FileInfo
+	for _, file := range files {
+		if file.IsFolder {
+			resolved = append(resolved, file)
+			continue
+		}
+		localPaths, err := opts.localPaths(file)
+		if err != nil {
+			return nil, err
+		}
+		clash := takenPath(taken, localPaths, opts)
+		if clash == "" {
+			markTaken(taken, localPaths, opts)
+			resolved = append(resolved, file)
+			continue
+		}
+		if strategy == CollisionSkip {
+			d.printf("⚠️ Skipping %s (ID: %s): %s is already taken\n", file.Path, file.ID, clash)
+			continue
+		}
+
+		original := file.Path
+		for n := 2; clash != ""; n++ {
+			if isGoogleNative(file.MimeType) && n == 2 {
+				file.Path = original + " (" + file.ID + ")"
+			} else {
+				file.Path = numberedPath(original, n)
+			}
+			if localPaths, err = opts.localPaths(file); err != nil {
+				return nil, err
+			}
+			clash = takenPath(taken, localPaths, opts)
+		}
+		markTaken(taken, localPaths, opts)
+		d.printf("⚠️ Renaming %s (ID: %s) to %s to avoid a collision\n", original, file.ID, file.Path)
+		resolved = append(resolved, file)
+	}
+	return resolved, nil
+}
+
+// takenPath returns the first of paths already taken, or "" if none is
+func takenPath(taken map[string]bool, paths []string, opts DownloadOptions) string {
+	for _, path := range paths {
+		if taken[opts.collisionKey(path)] {
+			return path
+		}
+	}
+	return ""
+}
+
+// markTaken records paths as taken
+func markTaken(taken map[string]bool, paths []string, opts DownloadOptions) {
+	for _, path := range paths {
+		taken[opts.collisionKey(path)] = true
+	}
+}
+
+// numberedPath inserts " (n)" before the extension of the path's last element
+func numberedPath(path string, n int) string {
+	ext := pathpkg.Ext(path)
+	if ext == path[strings.LastIndex(path, "/")+1:] {
+		ext = "" // dotfile such as .env
+	}
+	return fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(path, ext), n, ext)
+}
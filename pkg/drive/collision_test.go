@@ -0,0 +1,153 @@
+package drive
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResolveCollisions(t *testing.T) {
+	d := &DriveService{}
+	files := []FileInfo{
+		{ID: "1", Name: "a.txt", Path: "out/a.txt"},
+		{ID: "2", Name: "a.txt", Path: "out/a.txt"},
+		{ID: "3", Name: "b.txt", Path: "out/b.txt"},
+		{ID: "4", Name: "a.txt", Path: "out/a.txt"},
+		{ID: "5", Name: ".env", Path: "out/.env"},
+		{ID: "6", Name: ".env", Path: "out/.env"},
+	}
+	opts := DownloadOptions{OutputDir: "downloads"}
+
+	collisions, err := FindCollisions(files, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(collisions) != 2 || len(collisions[0].Files) != 3 || len(collisions[1].Files) != 2 {
+		t.Errorf("FindCollisions() = %v, want a.txt shared by 3 files and .env by 2", collisions)
+	}
+
+	tests := map[string][]string{
+		CollisionOverwrite: {"out/a.txt", "out/a.txt", "out/b.txt", "out/a.txt", "out/.env", "out/.env"},
+		CollisionSkip:      {"out/a.txt", "out/b.txt", "out/.env"},
+		CollisionRename:    {"out/a.txt", "out/a (2).txt", "out/b.txt", "out/a (3).txt", "out/.env", "out/.env (2)"},
+	}
+	for strategy, want := range tests {
+		resolved, err := d.ResolveCollisions(files, opts, strategy)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", strategy, err)
+			continue
+		}
+		if got := paths(resolved); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: ResolveCollisions() = %v, want %v", strategy, got, want)
+		}
+	}
+
+	_, err = d.ResolveCollisions(files, opts, CollisionFail)
+	if !errors.Is(err, ErrPathCollision) || !strings.Contains(err.Error(), "out/a.txt (ID: 4)") {
+		t.Errorf("fail: error = %v, want ErrPathCollision listing the files", err)
+	}
+	if _, err := d.ResolveCollisions(files[2:3], opts, CollisionFail); err != nil {
+		t.Errorf("fail without collisions: unexpected error: %v", err)
+	}
+}
+
+func TestResolveCollisionsNativeExports(t *testing.T) {
+	const docType = "application/vnd.google-apps.document"
+	fake := newFakeDrive()
+	fake.addFile("doc1", "notes", "root", "2025-04-01T00:00:00Z", "first notes")
+	fake.addFile("doc2", "notes", "root", "2025-04-01T00:00:00Z", "second notes")
+	fake.addFile("pdf", "notes.pdf", "root", "2025-04-01T00:00:00Z", "a real PDF")
+	d := newTestService(t, fake)
+
+	files := []FileInfo{
+		{ID: "pdf", Name: "notes.pdf", Path: "notes.pdf", MimeType: "application/pdf"},
+		{ID: "doc1", Name: "notes", Path: "notes", MimeType: docType},
+		{ID: "doc2", Name: "notes", Path: "notes", MimeType: docType},
+	}
+	outputDir := t.TempDir()
+	opts := DownloadOptions{OutputDir: outputDir, Variants: []string{"original", "pdf-export"}, NativeAsLink: true}
+
+	// The PDF export of each Doc lands on the real PDF
+	collisions, err := FindCollisions(files, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(collisions) != 2 || len(collisions[0].Files) != 3 {
+		t.Errorf("FindCollisions() = %v, want notes.pdf shared by 3 files and notes.gdoc by 2", collisions)
+	}
+
+	resolved, err := d.ResolveCollisions(files, opts, CollisionRename)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := paths(resolved), []string{"notes.pdf", "notes (doc1)", "notes (doc2)"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ResolveCollisions() = %v, want %v", got, want)
+	}
+
+	if _, err := d.DownloadFiles(resolved, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]string{
+		"notes.pdf":        "a real PDF",
+		"notes (doc1).pdf": "exported application/pdf: first notes",
+		"notes (doc2).pdf": "exported application/pdf: second notes",
+	}
+	for name, content := range want {
+		got, err := os.ReadFile(filepath.Join(outputDir, name))
+		if err != nil {
+			t.Errorf("expected %s: %v", name, err)
+		} else if string(got) != content {
+			t.Errorf("%s = %q, want %q", name, got, content)
+		}
+	}
+}
+
+func TestResolveCollisionsCaseInsensitive(t *testing.T) {
+	d := &DriveService{}
+	files := []FileInfo{
+		{ID: "1", Name: "Foo.TRANSCRIPT", Path: "Foo.TRANSCRIPT"},
+		{ID: "2", Name: "foo.TRANSCRIPT", Path: "foo.TRANSCRIPT"},
+	}
+
+	collisions, err := FindCollisions(files, DownloadOptions{OutputDir: "out"})
+	if err != nil || len(collisions) != 0 {
+		t.Errorf("FindCollisions() = %v, %v, want no collisions on a case-sensitive filesystem", collisions, err)
+	}
+
+	opts := DownloadOptions{OutputDir: "out", CaseInsensitiveFS: true}
+	collisions, err = FindCollisions(files, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(collisions) != 1 || collisions[0].LocalPath != filepath.Join("out", "Foo.TRANSCRIPT") || len(collisions[0].Files) != 2 {
+		t.Errorf("FindCollisions() = %v, want Foo.TRANSCRIPT shared by both files", collisions)
+	}
+
+	resolved, err := d.ResolveCollisions(files, opts, CollisionRename)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := paths(resolved); !reflect.DeepEqual(got, []string{"Foo.TRANSCRIPT", "foo (2).TRANSCRIPT"}) {
+		t.Errorf("ResolveCollisions() = %v, want the second file renamed", got)
+	}
+}
+
+func TestDetectCaseInsensitiveFS(t *testing.T) {
+	dir := t.TempDir()
+	want, err := DetectCaseInsensitiveFS(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Missing directories are checked through their nearest existing parent
+	got, err := DetectCaseInsensitiveFS(filepath.Join(dir, "not", "yet"))
+	if err != nil || got != want {
+		t.Errorf("DetectCaseInsensitiveFS(missing) = %v, %v, want %v", got, err, want)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("probe files left behind: %v", entries)
+	}
+}
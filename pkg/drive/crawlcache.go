@@ -0,0 +1,201 @@
+package drive
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	pathpkg "path"
+	"strings"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+)
+
+// CrawlCache is a snapshot of every file and folder under the crawled
+// folders, so searches can be repeated against it without calling Drive
+type CrawlCache struct {
+	CrawledAt    time.Time  `json:"crawledAt"`
+	FolderIDs    []string   `json:"folderIds,omitempty"`
+	SharedWithMe bool       `json:"sharedWithMe,omitempty"`
+	Files        []FileInfo `json:"files"`
+}
+
+// ErrOffline is returned by Drive requests made through a service created by
+// NewOfflineDriveService
+var ErrOffline = errors.New("Drive is not available offline")
+
+// CrawlTree lists every file and folder under opts.FolderIDs (the root
+// folder when empty) and, with opts.SharedWithMe, those shared with the
+// account. All other filters and limits of opts are ignored.
+func (d *DriveService) CrawlTree(opts ListOptions) (*CrawlCache, error) {
+	crawledAt := time.Now().UTC()
+	files, err := d.ListFiles(ListOptions{
+		FolderIDs:    opts.FolderIDs,
+		SharedWithMe: opts.SharedWithMe,
+		PageSize:     opts.PageSize,
+		MaxDepth:     -1,
+		MatchFolders: true,
+		OrderBy:      SortOrder{Field: "path"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &CrawlCache{
+		CrawledAt:    crawledAt,
+		FolderIDs:    opts.FolderIDs,
+		SharedWithMe: opts.SharedWithMe,
+		Files:        files,
+	}, nil
+}
+
+// WriteCrawlCache saves a crawl cache as JSON
+func WriteCrawlCache(path string, cache *CrawlCache) error {
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to encode crawl cache: %v", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("unable to write crawl cache: %v", err)
+	}
+	return nil
+}
+
+// ReadCrawlCache loads a crawl cache saved by WriteCrawlCache
+func ReadCrawlCache(path string) (*CrawlCache, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read crawl cache: %v", err)
+	}
+	var cache CrawlCache
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return nil, fmt.Errorf("invalid crawl cache %s: %v", path, err)
+	}
+	return &cache, nil
+}
+
+// FilterFiles searches files, such as those of a CrawlCache, the way
+// ListFiles searches Drive, without making any requests. Depth is taken from
+// each file's path. opts.Query, opts.Labels and opts.OwnedByMe can't be
+// evaluated offline and are rejected; FolderIDs, SharedWithMe, PageSize,
+// MaxFolders and PrefetchPaths are ignored. LeafOnly judges folders by the
+// folders among files, so files at the top level are skipped if any folder
+// is there too.
+func (d *DriveService) FilterFiles(files []FileInfo, opts ListOptions) ([]FileInfo, error) {
+	if opts.Query != "" {
+		return nil, fmt.Errorf("a Drive query can't be evaluated against cached files")
+	}
+	if len(opts.Labels) > 0 {
+		return nil, fmt.Errorf("labels aren't recorded in cached files")
+	}
+	if opts.OwnedByMe {
+		return nil, fmt.Errorf("which files the account owns isn't recorded in cached files")
+	}
+	if err := ValidateFileTypes(opts.Types); err != nil {
+		return nil, err
+	}
+	if err := ValidateMatchTarget(opts.MatchTarget); err != nil {
+		return nil, err
+	}
+	m, err := d.compileMatcher(opts)
+	if err != nil {
+		return nil, err
+	}
+	folderPattern, err := d.compileFolderPattern(opts.FolderPattern)
+	if err != nil {
+		return nil, err
+	}
+
+	var branches map[string]bool
+	if opts.LeafOnly {
+		branches = branchFolders(files)
+	}
+
+	var matched []FileInfo
+	for _, f := range files {
+		if opts.Exclude != nil && opts.Exclude.Test(f.ID) {
+			continue
+		}
+		if opts.MaxDepth != -1 && strings.Count(f.Path, "/") > opts.MaxDepth {
+			continue
+		}
+		if !m.MatchString(opts.matchSubject(f.Name, f.Path)) {
+			continue
+		}
+		if f.IsFolder {
+			if opts.MatchFolders {
+				matched = append(matched, f)
+			}
+			continue
+		}
+		if opts.LeafOnly && branches[pathpkg.Dir(f.Path)] {
+			continue
+		}
+		if !underMatchingFolder(folderPattern, f.Path) {
+			continue
+		}
+		if len(opts.Owners) > 0 && !containsFold(f.Owners, opts.Owners) {
+			continue
+		}
+		if len(opts.LastModifiedBy) > 0 && (f.LastModifiedBy == "" || !containsFold([]string{f.LastModifiedBy}, opts.LastModifiedBy)) {
+			continue
+		}
+		if !inAnyCategory(f, opts.Categories) || !ofAnyType(f.MimeType, opts.Types) || !opts.createdInRange(f.CreatedAt) || !opts.modifiedInRange(f.ModifiedAt) {
+			continue
+		}
+		d.log("✅ Found matching file: %s (Modified: %s)", f.Path, f.ModifiedTime)
+		matched = append(matched, f)
+	}
+
+	matched = d.sortAndLimit(matched, opts)
+	d.log("\nSearch completed. Found %d matching files in %d cached items.", len(matched), len(files))
+	return matched, nil
+}
+
+// branchFolders returns the paths of the folders among files that hold
+// another of them, "." standing for the crawled folders themselves
+func branchFolders(files []FileInfo) map[string]bool {
+	branches := make(map[string]bool)
+	for _, f := range files {
+		if f.IsFolder {
+			branches[pathpkg.Dir(f.Path)] = true
+		}
+	}
+	return branches
+}
+
+// containsFold reports whether any of values equals one of wanted, compared
+// case-insensitively
+func containsFold(values, wanted []string) bool {
+	for _, v := range values {
+		for _, w := range wanted {
+			if strings.EqualFold(v, w) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// NewOfflineDriveService returns a service for working with files already
+// listed, such as from a CrawlCache, without credentials. Methods that only
+// read local files, like FilterFiles, DiffLocal and VerifyLocal, work as
+// usual; any request to Drive fails with ErrOffline.
+func NewOfflineDriveService(verbose bool) (*DriveService, error) {
+	client := &http.Client{Transport: offlineTransport{}}
+	srv, err := drive.NewService(context.Background(), option.WithHTTPClient(client))
+	if err != nil {
+		return nil, fmt.Errorf("unable to create Drive service: %v", err)
+	}
+	return &DriveService{service: srv, client: client, verbose: verbose}, nil
+}
+
+// offlineTransport fails every request with ErrOffline
+type offlineTransport struct{}
+
+func (offlineTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, ErrOffline
+}
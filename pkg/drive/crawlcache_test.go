@@ -0,0 +1,71 @@
+package drive
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCrawlCacheRoundTrip(t *testing.T) {
+	fake := newFakeDrive()
+	fake.addFolder("f1", "room-1", "root")
+	fake.addFolder("f1a", "apr", "f1")
+	fake.addFile("a", "a.TRANSCRIPT", "f1a", "2025-04-03T00:00:00Z", "a")
+	fake.addFile("b", "b.txt", "f1", "2025-04-02T00:00:00Z", "b")
+	fake.addFile("c", "c.TRANSCRIPT", "root", "2025-04-01T00:00:00Z", "c")
+	d := newTestService(t, fake)
+
+	// The pattern and filters are ignored so later searches see everything
+	cache, err := d.CrawlTree(ListOptions{Pattern: "nothing matches this", MaxDepth: 0})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "tree.json")
+	if err := WriteCrawlCache(path, cache); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	loaded, err := ReadCrawlCache(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"c.TRANSCRIPT", "room-1", "room-1/apr", "room-1/apr/a.TRANSCRIPT", "room-1/b.txt"}
+	if got := paths(loaded.Files); !reflect.DeepEqual(got, want) {
+		t.Fatalf("cached paths = %v, want %v", got, want)
+	}
+	if loaded.CrawledAt.IsZero() {
+		t.Error("expected the crawl time to be recorded")
+	}
+
+	offline, err := NewOfflineDriveService(false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tests := []struct {
+		opts ListOptions
+		want []string
+	}{
+		{ListOptions{Pattern: "TRANSCRIPT", MaxDepth: -1, OrderBy: SortOrder{Field: "path"}}, []string{"c.TRANSCRIPT", "room-1/apr/a.TRANSCRIPT"}},
+		{ListOptions{Pattern: "TRANSCRIPT", MaxDepth: 1}, []string{"c.TRANSCRIPT"}},
+		{ListOptions{Pattern: "^r", MaxDepth: -1, MatchFolders: true}, []string{"room-1"}},
+		{ListOptions{Extensions: []string{"txt"}, MaxDepth: -1}, []string{"room-1/b.txt"}},
+		{ListOptions{Pattern: "^room-1/apr/", MatchTarget: MatchPath, MaxDepth: -1}, []string{"room-1/apr/a.TRANSCRIPT"}},
+		{ListOptions{MaxDepth: -1, LeafOnly: true}, []string{"room-1/apr/a.TRANSCRIPT"}},
+	}
+	for _, tt := range tests {
+		files, err := offline.FilterFiles(loaded.Files, tt.opts)
+		if err != nil {
+			t.Fatalf("FilterFiles(%+v): unexpected error: %v", tt.opts, err)
+		}
+		if got := paths(files); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("FilterFiles(%+v) = %v, want %v", tt.opts, got, tt.want)
+		}
+	}
+
+	if _, err := offline.FilterFiles(loaded.Files, ListOptions{Query: "starred = true"}); err == nil {
+		t.Error("expected a Drive query to be rejected")
+	}
+	if _, err := offline.ListFiles(ListOptions{Pattern: "x", MaxDepth: -1}); !errors.Is(err, ErrOffline) {
+		t.Errorf("ListFiles() error = %v, want ErrOffline", err)
+	}
+}
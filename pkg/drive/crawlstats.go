@@ -0,0 +1,39 @@
+package drive
+
+import (
+	"sync/atomic"
+)
+
+// CrawlStats counts the work done by the service's crawls so far
+type CrawlStats struct {
+	// Folders is the number of folders listed
+	Folders int64
+	// Files is the number of files checked against the filters
+	Files int64
+	// Matches is the number of files and folders that passed them
+	Matches int64
+	// FolderLimitReached is set once ListOptions.MaxFolders stopped a crawl
+	// from listing more folders, so its results may be incomplete
+	FolderLimitReached bool
+}
+
+// crawlCounters backs CrawlStats; crawls update it while others read it
+type crawlCounters struct {
+	folders atomic.Int64
+	files   atomic.Int64
+	matches atomic.Int64
+
+	folderLimit atomic.Bool
+}
+
+// CrawlStats returns the counts so far. It is safe to call while a crawl
+// is running, such as to report progress.
+func (d *DriveService) CrawlStats() CrawlStats {
+	return CrawlStats{
+		Folders: d.counters.folders.Load(),
+		Files:   d.counters.files.Load(),
+		Matches: d.counters.matches.Load(),
+
+		FolderLimitReached: d.counters.folderLimit.Load(),
+	}
+}
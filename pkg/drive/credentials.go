@@ -0,0 +1,82 @@
+package drive
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/oauth2/google"
+)
+
+// ErrInvalidCredentials is returned when the credentials file is missing or
+// is not a usable Google credentials JSON
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// supportedCredentialTypes lists the "type" values accepted in a credentials file
+var supportedCredentialTypes = map[string]bool{
+	"service_account":                  true,
+	"authorized_user":                  true,
+	"external_account":                 true,
+	"external_account_authorized_user": true,
+	"impersonated_service_account":     true,
+}
+
+// acceptedCredentials describes the credentials files that are accepted,
+// naming each of supportedCredentialTypes
+const acceptedCredentials = `expected a service account key ("service_account"), an "authorized_user" JSON from 'gcloud auth application-default login', ` +
+	`an external account JSON for workload or workforce identity federation ("external_account" or "external_account_authorized_user"), ` +
+	`or an "impersonated_service_account" JSON from 'gcloud auth application-default login --impersonate-service-account'; ` +
+	`OAuth client secrets are not accepted`
+
+// validateCredentialsFile checks that the file exists and looks like a
+// credentials JSON the Drive client can use
+func validateCredentialsFile(credentialsFile string) error {
+	data, err := os.ReadFile(credentialsFile)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("%w: file %s not found; download a service account key from the Google Cloud console or pass its path with -credentials", ErrInvalidCredentials, credentialsFile)
+		}
+		return fmt.Errorf("%w: unable to read %s: %v", ErrInvalidCredentials, credentialsFile, err)
+	}
+
+	var creds struct {
+		Type      string          `json:"type"`
+		Installed json.RawMessage `json:"installed"`
+		Web       json.RawMessage `json:"web"`
+	}
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return fmt.Errorf("%w: %s is not valid JSON (%v); %s", ErrInvalidCredentials, credentialsFile, err, acceptedCredentials)
+	}
+
+	if creds.Installed != nil || creds.Web != nil {
+		return fmt.Errorf("%w: %s is an OAuth client secret, not user credentials; run 'gcloud auth application-default login' or use a service account key instead", ErrInvalidCredentials, credentialsFile)
+	}
+	if !supportedCredentialTypes[creds.Type] {
+		if creds.Type == "" {
+			return fmt.Errorf("%w: %s has no credential type; %s", ErrInvalidCredentials, credentialsFile, acceptedCredentials)
+		}
+		return fmt.Errorf("%w: %s has unsupported credential type %q; %s", ErrInvalidCredentials, credentialsFile, creds.Type, acceptedCredentials)
+	}
+	return nil
+}
+
+// adcHint tells how to set up Application Default Credentials
+const adcHint = "run 'gcloud auth application-default login', set GOOGLE_APPLICATION_CREDENTIALS " +
+	"to a credentials file, or run on Google Cloud with a service account attached"
+
+// defaultCredentials finds Application Default Credentials, from the file
+// named by GOOGLE_APPLICATION_CREDENTIALS, gcloud's application default
+// login or the metadata server of Google Cloud, and checks they yield a
+// token so a missing or expired login is reported before any work starts
+func defaultCredentials(ctx context.Context, scope string) (*google.Credentials, error) {
+	creds, err := google.FindDefaultCredentials(ctx, scope)
+	if err != nil {
+		return nil, fmt.Errorf("%w: no Application Default Credentials found (%v); %s", ErrInvalidCredentials, err, adcHint)
+	}
+	if _, err := creds.TokenSource.Token(); err != nil {
+		return nil, fmt.Errorf("%w: Application Default Credentials can't get a token (%v); %s", ErrInvalidCredentials, err, adcHint)
+	}
+	return creds, nil
+}
@@ -0,0 +1,103 @@
+package drive
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewDriveServiceInvalidCredentials(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     *string
+		errContains string
+	}{
+		{
+			name:        "missing file",
+			errContains: "not found",
+		},
+		{
+			name:        "not json",
+			content:     strPtr("this is not json"),
+			errContains: "not valid JSON",
+		},
+		{
+			name:        "empty object",
+			content:     strPtr("{}"),
+			errContains: "no credential type",
+		},
+		{
+			name:        "unsupported type",
+			content:     strPtr(`{"type": "api_key"}`),
+			errContains: `unsupported credential type "api_key"; expected a service account key ("service_account"), an "authorized_user" JSON`,
+		},
+		{
+			name:        "oauth client secret",
+			content:     strPtr(`{"installed": {"client_id": "id", "client_secret": "secret"}}`),
+			errContains: "OAuth client secret",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "credentials.json")
+			if tt.content != nil {
+				if err := os.WriteFile(path, []byte(*tt.content), 0600); err != nil {
+					t.Fatalf("failed to write credentials: %v", err)
+				}
+			}
+
+			_, err := NewDriveService(path, false)
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if !errors.Is(err, ErrInvalidCredentials) {
+				t.Errorf("error = %v, want ErrInvalidCredentials", err)
+			}
+			if !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("error = %v, want error containing %v", err, tt.errContains)
+			}
+		})
+	}
+}
+
+func TestValidateCredentialsFileAcceptedTypes(t *testing.T) {
+	for _, credType := range []string{"service_account", "authorized_user", "external_account", "external_account_authorized_user", "impersonated_service_account"} {
+		t.Run(credType, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "credentials.json")
+			if err := os.WriteFile(path, []byte(`{"type": "`+credType+`"}`), 0600); err != nil {
+				t.Fatalf("failed to write credentials: %v", err)
+			}
+			if err := validateCredentialsFile(path); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestAcceptedCredentialsNamesEveryType(t *testing.T) {
+	for credType := range supportedCredentialTypes {
+		if !strings.Contains(acceptedCredentials, `"`+credType+`"`) {
+			t.Errorf("acceptedCredentials does not name %q", credType)
+		}
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func TestNewDriveServiceDefaultCredentialsMissing(t *testing.T) {
+	// Point ADC at a file that doesn't exist so no other source is tried
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", filepath.Join(t.TempDir(), "missing.json"))
+
+	_, err := NewDriveServiceWithScope("", false, ReadonlyScope)
+	if !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("error = %v, want ErrInvalidCredentials", err)
+	}
+	if !strings.Contains(err.Error(), "gcloud auth application-default login") {
+		t.Errorf("error %q doesn't say how to set up default credentials", err)
+	}
+}
@@ -0,0 +1,122 @@
+package drive
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ValidateLinkMode checks a LinkDuplicates value
+func ValidateLinkMode(mode string) error {
+	switch mode {
+	case "", "symlink", "hardlink":
+		return nil
+	default:
+		return fmt.Errorf("unsupported duplicate link mode %q (expected symlink or hardlink)", mode)
+	}
+}
+
+// canonicalCopy is the first local copy of some content downloaded in a run
+type canonicalCopy struct {
+	path       string
+	compressed bool
+}
+
+// recordCanonical remembers a freshly downloaded file as the copy later
+// duplicates link to
+func (r *downloadRun) recordCanonical(file FileInfo) {
+	if r.opts.LinkDuplicates == "" || !file.HasChecksum() {
+		return
+	}
+	if _, ok := r.canonical[file.MD5]; ok {
+		return
+	}
+	outPath, err := r.opts.OutputPath(file)
+	if err != nil {
+		return
+	}
+	r.canonical[file.MD5] = canonicalCopy{path: outPath, compressed: r.opts.compresses(file)}
+}
+
+// linkDuplicate saves file as a link to an identical file downloaded earlier
+// in the run, and reports whether it did. Files are only linked to a copy
+// stored the same way, so a plain file never points at a gzipped one.
+func (r *downloadRun) linkDuplicate(file FileInfo) (bool, error) {
+	if r.opts.LinkDuplicates == "" || !file.HasChecksum() {
+		return false, nil
+	}
+	original, ok := r.canonical[file.MD5]
+	if !ok || original.compressed != r.opts.compresses(file) {
+		return false, nil
+	}
+
+	outPath, err := r.opts.OutputPath(file)
+	if err != nil {
+		return false, err
+	}
+	if outPath == original.path {
+		return false, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(outPath), r.d.dirPerm()); err != nil {
+		return false, fmt.Errorf("unable to create output directory: %v", err)
+	}
+	// Replace a copy left by an earlier run
+	if err := os.Remove(outPath); err != nil && !os.IsNotExist(err) {
+		return false, fmt.Errorf("unable to replace %s: %v", outPath, err)
+	}
+
+	r.d.log("🔗 %s has the same content as %s, creating a %s", file.Path, original.path, r.opts.LinkDuplicates)
+	if err := createLink(r.opts.LinkDuplicates, original.path, outPath); err != nil {
+		r.d.printf("⚠️ Unable to create %s for %s, copying instead: %v\n", r.opts.LinkDuplicates, file.Path, err)
+		r.report.Warnings = append(r.report.Warnings, FileFailure{File: file, Err: fmt.Errorf("copied instead of linked: %v", err)})
+		if err := copyFile(original.path, outPath, r.d.filePerm()); err != nil {
+			return false, fmt.Errorf("unable to copy %s: %v", original.path, err)
+		}
+		return true, nil
+	}
+	r.report.Linked = append(r.report.Linked, file)
+	return true, nil
+}
+
+// createLink creates a symlink or hardlink at linkPath pointing to target.
+// Symlinks are relative, so the output directory can be moved as a whole.
+func createLink(mode, target, linkPath string) error {
+	if mode == "hardlink" {
+		return os.Link(target, linkPath)
+	}
+
+	absTarget, err := filepath.Abs(target)
+	if err != nil {
+		return err
+	}
+	absLink, err := filepath.Abs(linkPath)
+	if err != nil {
+		return err
+	}
+	relTarget, err := filepath.Rel(filepath.Dir(absLink), absTarget)
+	if err != nil {
+		return err
+	}
+	return os.Symlink(relTarget, linkPath)
+}
+
+// copyFile copies src to a new file dst with permissions perm, for platforms
+// or filesystems without links
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_RDWR|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
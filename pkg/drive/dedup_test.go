@@ -0,0 +1,60 @@
+package drive
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDownloadFilesLinkDuplicates(t *testing.T) {
+	for _, mode := range []string{"symlink", "hardlink"} {
+		t.Run(mode, func(t *testing.T) {
+			fake := newFakeDrive()
+			fake.addFile("a", "a.txt", "root", "2025-04-01T00:00:00Z", "same")
+			fake.addFile("b", "b.txt", "root", "2025-04-01T00:00:00Z", "same")
+			fake.addFile("c", "c.txt", "root", "2025-04-01T00:00:00Z", "different")
+			d := newTestService(t, fake)
+
+			outputDir := t.TempDir()
+			files := []FileInfo{
+				{ID: "a", Name: "a.txt", Path: "one/a.txt", MD5: md5Hex("same")},
+				{ID: "b", Name: "b.txt", Path: "two/b.txt", MD5: md5Hex("same")},
+				{ID: "c", Name: "c.txt", Path: "two/c.txt", MD5: md5Hex("different")},
+			}
+			report, err := d.DownloadFiles(files, DownloadOptions{OutputDir: outputDir, LinkDuplicates: mode})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(report.Downloaded) != 3 {
+				t.Errorf("downloaded %d files, want 3", len(report.Downloaded))
+			}
+			if len(report.Linked) != 1 || report.Linked[0].ID != "b" {
+				t.Fatalf("linked = %v, want only b", report.Linked)
+			}
+
+			linkPath := filepath.Join(outputDir, "two", "b.txt")
+			info, err := os.Lstat(linkPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if isSymlink := info.Mode()&os.ModeSymlink != 0; isSymlink != (mode == "symlink") {
+				t.Errorf("symlink = %v for mode %s", isSymlink, mode)
+			}
+			content, err := os.ReadFile(linkPath)
+			if err != nil || string(content) != "same" {
+				t.Errorf("linked content = %q, %v", content, err)
+			}
+		})
+	}
+}
+
+func TestValidateLinkMode(t *testing.T) {
+	for _, mode := range []string{"", "symlink", "hardlink"} {
+		if err := ValidateLinkMode(mode); err != nil {
+			t.Errorf("ValidateLinkMode(%q) unexpected error: %v", mode, err)
+		}
+	}
+	if err := ValidateLinkMode("reflink"); err == nil {
+		t.Error("expected error for reflink")
+	}
+}
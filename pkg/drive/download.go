@@ -0,0 +1,822 @@
+package drive
+
+import (
+	"compress/gzip"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// ErrChecksumMismatch is returned when a downloaded file's MD5 does not match
+// the checksum reported by Drive
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// ErrTooManyFailures is returned once a download run reaches
+// DownloadOptions.MaxErrors failed files
+var ErrTooManyFailures = errors.New("too many failed files")
+
+// DownloadOptions controls how DownloadFiles saves files
+type DownloadOptions struct {
+	OutputDir string
+
+	// OutputDirTemplate, if set, replaces OutputDir with a directory
+	// rendered from each file's metadata
+	OutputDirTemplate *OutputDirTemplate
+
+	// Archive, if set, receives every file as a tar entry named after its
+	// path; nothing is written to the output directory
+	Archive *TarArchive
+
+	// Sink, if set, receives every file, such as for uploading to object
+	// storage; nothing is written to the output directory
+	Sink Sink
+
+	// Seen, if set, holds the IDs of files downloaded by earlier runs, which
+	// are skipped; the IDs of files downloaded by this run are added to it
+	// once the file has been moved into place, its checksum verified and
+	// all of its variants saved. A run that crashes before saving Seen
+	// leaves the files it downloaded to be downloaded and verified again.
+	Seen *BloomFilter
+
+	// VerifyChecksum compares each download against Drive's md5Checksum
+	VerifyChecksum bool
+
+	// ExportWorkers, if above 0, runs exported variants such as pdf-export
+	// on this many workers in the background, so slow exports don't hold up
+	// downloads. Otherwise each file's variants are saved before moving on.
+	ExportWorkers int
+
+	// SmartSchedule has the export workers take the export of the largest
+	// queued file first instead of the oldest, so large files start early
+	// and small ones fill in around them, shortening the wait for the last
+	// exports. Drive reports the size of Google Docs editors files, which
+	// stands in for how long their export takes.
+	SmartSchedule bool
+
+	// VerifyWorkers is how many local copies VerifyLocal hashes at once
+	// (0 or 1 to hash them one at a time). With VerifyChecksum, if above 0,
+	// it is also how many downloads are checked at once in the background
+	// while the next ones download; each is moved into place only once it
+	// matches. Otherwise each download is hashed as it is written.
+	VerifyWorkers int
+
+	// ChecksumRetries is how many times a file is downloaded again after a
+	// checksum mismatch before it is reported as failed
+	ChecksumRetries int
+
+	// TrashAfterDownload moves each file to the Drive trash once it has been
+	// downloaded and its checksum verified
+	TrashAfterDownload bool
+
+	// Compress is the compression applied to saved files: "" for none or "gzip"
+	Compress string
+
+	// Variants names the outputs produced for each file; only the original
+	// content is downloaded when empty
+	Variants []string
+
+	// LinkDuplicates is how a file whose content was already downloaded in
+	// this run is saved: "" to download it again, "symlink" or "hardlink"
+	LinkDuplicates string
+
+	// WriteMetadata saves each file's Drive metadata as JSON next to it
+	WriteMetadata bool
+
+	// WriteFolderDescriptions saves the description of the Drive folder
+	// holding each downloaded file as .folder-description.txt in the
+	// directory the file is saved to
+	WriteFolderDescriptions bool
+
+	// WriteXattrs records each file's Drive ID and MD5 as the extended
+	// attributes XattrID and XattrMD5 of its local copy (Linux and macOS)
+	WriteXattrs bool
+
+	// RedactFields names the JSON fields of FileInfo, such as "owners",
+	// cleared from metadata sidecars
+	RedactFields []string
+
+	// AfterDownload, if set, is called with the local path of each downloaded
+	// file. An error marks the file as failed without stopping the run.
+	AfterDownload func(fileInfo FileInfo, localPath string) error
+
+	// MaxErrors stops the run with ErrTooManyFailures once this many files
+	// have failed (0 for no limit)
+	MaxErrors int
+
+	// NativeAsLink saves Google Docs editors files, which can't be downloaded
+	// as they are, as small JSON stubs linking to the file in Drive, named
+	// with an extension such as .gdoc
+	NativeAsLink bool
+
+	// CaseInsensitiveFS makes FindCollisions and ResolveCollisions treat
+	// output paths differing only in case as the same path, as they are on
+	// the default filesystems of macOS and Windows
+	CaseInsensitiveFS bool
+
+	// SkipUnchangedExports skips exported variants, such as pdf-export,
+	// whose local copy was exported from the current version of the file
+	SkipUnchangedExports bool
+
+	// TagRevision appends the file's head revision to the names of exported
+	// variants, as in notes@rev123.pdf, so each version gets its own export
+	TagRevision bool
+
+	// ModTimeTolerance is how much older than the Drive file a local copy
+	// may be and still count as unchanged when they are compared by
+	// modification time, as by DiffLocal and SkipUnchangedExports
+	ModTimeTolerance time.Duration
+}
+
+// alreadyCompressed lists extensions of formats that gain nothing from gzip
+var alreadyCompressed = map[string]bool{
+	".mp4": true, ".m4a": true, ".mov": true, ".webm": true, ".mp3": true,
+	".jpg": true, ".jpeg": true, ".png": true, ".gif": true,
+	".zip": true, ".gz": true, ".tgz": true, ".bz2": true, ".xz": true, ".7z": true,
+}
+
+// ValidateCompression checks that a -compress value is supported
+func ValidateCompression(compress string) error {
+	if compress != "" && compress != "gzip" {
+		return fmt.Errorf("unsupported compression %q (expected gzip)", compress)
+	}
+	return nil
+}
+
+// compresses reports whether the file is saved compressed
+func (o DownloadOptions) compresses(fileInfo FileInfo) bool {
+	return o.Compress == "gzip" &&
+		!isGoogleNative(fileInfo.MimeType) &&
+		!alreadyCompressed[strings.ToLower(filepath.Ext(fileInfo.Name))]
+}
+
+// BaseDir returns the directory the file's path is joined onto
+func (o DownloadOptions) BaseDir(fileInfo FileInfo) (string, error) {
+	if o.OutputDirTemplate == nil {
+		return o.OutputDir, nil
+	}
+	return o.OutputDirTemplate.Render(fileInfo)
+}
+
+// localPath converts a "/"-separated Drive path into a local path under baseDir
+func localPath(baseDir, drivePath string) string {
+	return filepath.Join(baseDir, filepath.FromSlash(drivePath))
+}
+
+// OutputPath returns the local path the file is saved to
+func (o DownloadOptions) OutputPath(fileInfo FileInfo) (string, error) {
+	baseDir, err := o.BaseDir(fileInfo)
+	if err != nil {
+		return "", err
+	}
+	outPath := localPath(baseDir, fileInfo.Path)
+	if o.compresses(fileInfo) {
+		outPath += ".gz"
+	}
+	if o.savesLinkStub(fileInfo) {
+		outPath += linkStubExtension(fileInfo.MimeType)
+	}
+	return outPath, nil
+}
+
+// DownloadReport records the outcome of DownloadFiles
+type DownloadReport struct {
+	Downloaded []FileInfo
+	Trashed    []FileInfo
+	Failed     []FileFailure
+
+	// Linked lists the downloads saved as links to an identical file
+	// downloaded earlier in the run; they are also listed in Downloaded
+	Linked []FileInfo
+
+	// Warnings records problems that did not stop a file from downloading
+	Warnings []FileFailure
+
+	// Downloads is the work of the download loop, Exports that of the
+	// export workers, used only with DownloadOptions.ExportWorkers, and
+	// Verification that of the workers checking downloads in the background
+	Downloads    PoolStats
+	Exports      PoolStats
+	Verification PoolStats
+}
+
+// FileFailure records a file that could not be fully processed
+type FileFailure struct {
+	File FileInfo
+	Err  error
+}
+
+func (d *DriveService) DownloadFile(fileInfo FileInfo, opts DownloadOptions) error {
+	if fileInfo.IsFolder {
+		return fmt.Errorf("%s is a folder; folder entries from -match-folders can be listed but not downloaded", fileInfo.Path)
+	}
+
+	d.log("📥 Starting download of: %s", fileInfo.Path)
+
+	outPath, err := opts.OutputPath(fileInfo)
+	if err != nil {
+		return err
+	}
+	if opts.savesLinkStub(fileInfo) {
+		return d.writeLinkStub(fileInfo, outPath)
+	}
+
+	hash := md5.New()
+	recordPath, err := d.downloadPart(fileInfo, outPath, hash, opts)
+	if recordPath != "" {
+		defer os.Remove(recordPath)
+	}
+	if err != nil {
+		return err
+	}
+	if err := d.movePart(outPath); err != nil {
+		return err
+	}
+
+	if err := d.checkChecksum(fileInfo, hash, opts); err != nil {
+		return err
+	}
+
+	d.log("✅ Successfully downloaded: %s", fileInfo.Path)
+	return nil
+}
+
+// downloadPart downloads a file to the ".part" file of outPath, hashing its
+// content into sum, if not nil, as it arrives. It returns the path of the
+// record left next to the part for FinishIncomplete, or "" if it couldn't
+// be written.
+func (d *DriveService) downloadPart(fileInfo FileInfo, outPath string, sum io.Writer, opts DownloadOptions) (string, error) {
+	d.log("  Downloading file from Drive...")
+	resp, err := d.service.Files.Get(fileInfo.ID).Context(d.requestContext()).Download()
+	if err != nil {
+		return "", fmt.Errorf("unable to download file: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var body io.Reader = resp.Body
+	if sum != nil {
+		body = io.TeeReader(body, sum)
+	}
+	var progress *progressReader
+	if d.progress != nil {
+		total := resp.ContentLength
+		if fileInfo.HasSize() {
+			total = fileInfo.Size
+		}
+		progress = &progressReader{r: body, fn: d.progress, file: fileInfo, total: total}
+		body = progress
+	}
+
+	// A crash leaves the record next to the ".part" file for FinishIncomplete
+	recordPath, err := d.writePartRecord(outPath, fileInfo, opts.compresses(fileInfo))
+	if err != nil {
+		d.log("  ⚠️ %v", err)
+	}
+	if err := d.writePart(outPath, body, opts.compresses(fileInfo)); err != nil {
+		return recordPath, err
+	}
+	if progress != nil {
+		progress.finish()
+	}
+	return recordPath, nil
+}
+
+// downloadVerified downloads a file, downloading it again up to
+// opts.ChecksumRetries times if its checksum does not match. These attempts
+// are counted separately from retries of failed API calls.
+func (d *DriveService) downloadVerified(fileInfo FileInfo, opts DownloadOptions) error {
+	for attempt := 0; ; attempt++ {
+		err := d.DownloadFile(fileInfo, opts)
+		if !errors.Is(err, ErrChecksumMismatch) || attempt >= opts.ChecksumRetries {
+			return err
+		}
+		d.printf("⚠️ %s: %v; downloading again (retry %d/%d)\n", fileInfo.Path, err, attempt+1, opts.ChecksumRetries)
+	}
+}
+
+// checkChecksum compares the MD5 of a download, hashed into sum as it was
+// written, with Drive's checksum when opts.VerifyChecksum is set. Files
+// without a checksum, such as Google-native ones, can't be checked and pass.
+func (d *DriveService) checkChecksum(fileInfo FileInfo, sum hash.Hash, opts DownloadOptions) error {
+	if !opts.VerifyChecksum {
+		return nil
+	}
+	if !fileInfo.HasChecksum() {
+		d.log("  No checksum to verify against: %s", fileInfo.Path)
+		return nil
+	}
+	if got := hex.EncodeToString(sum.Sum(nil)); got != fileInfo.MD5 {
+		return fmt.Errorf("%w: got %s, Drive reports %s", ErrChecksumMismatch, got, fileInfo.MD5)
+	}
+	d.log("  Checksum verified: %s", fileInfo.MD5)
+	return nil
+}
+
+// partSuffix names the temporary file a download is written to before it is
+// renamed into place
+const partSuffix = ".part"
+
+// writeFile creates outPath, along with any missing parent directories, and
+// copies body into it, gzip-compressing it when compress is set. The content
+// is written to a ".part" file first and renamed over outPath only once
+// complete, so an interrupted download never leaves a truncated file under
+// the final name nor destroys an earlier copy.
+func (d *DriveService) writeFile(outPath string, body io.Reader, compress bool) error {
+	if err := d.writePart(outPath, body, compress); err != nil {
+		return err
+	}
+	return d.movePart(outPath)
+}
+
+// writePart writes body to the ".part" file of outPath, as writeFile does,
+// leaving it there. A failed write leaves no ".part" file.
+func (d *DriveService) writePart(outPath string, body io.Reader, compress bool) error {
+	d.log("  Creating directory: %s", filepath.Dir(outPath))
+	if err := os.MkdirAll(filepath.Dir(outPath), d.dirPerm()); err != nil {
+		return fmt.Errorf("unable to create output directory: %v", err)
+	}
+
+	partPath := outPath + partSuffix
+	d.log("  Creating output file: %s", partPath)
+	// A part left by a crash would keep its permissions when truncated
+	if err := os.Remove(partPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("unable to replace %s: %v", partPath, err)
+	}
+	outFile, err := os.OpenFile(partPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, d.filePerm())
+	if err != nil {
+		return fmt.Errorf("unable to create output file: %v", err)
+	}
+
+	d.log("  Copying file contents...")
+	if err := copyContent(outFile, body, compress); err != nil {
+		outFile.Close()
+		os.Remove(partPath)
+		return fmt.Errorf("unable to save file: %v", err)
+	}
+	if err := outFile.Close(); err != nil {
+		os.Remove(partPath)
+		return fmt.Errorf("unable to save file: %v", err)
+	}
+	return nil
+}
+
+// movePart renames the ".part" file of outPath over outPath
+func (d *DriveService) movePart(outPath string) error {
+	partPath := outPath + partSuffix
+	d.log("  Moving into place: %s", outPath)
+	if err := os.Rename(partPath, outPath); err != nil {
+		os.Remove(partPath)
+		return fmt.Errorf("unable to save file: %v", err)
+	}
+	afterRename(outPath)
+	return nil
+}
+
+// afterRename is called once a download is moved into place, before it is
+// checked and added to DownloadOptions.Seen; tests crash the run there
+var afterRename = func(outPath string) {}
+
+// copyContent copies body to w, gzip-compressing it when compress is set
+func copyContent(w io.Writer, body io.Reader, compress bool) error {
+	if !compress {
+		_, err := io.Copy(w, body)
+		return err
+	}
+	gz := gzip.NewWriter(w)
+	if _, err := io.Copy(gz, body); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func (d *DriveService) DownloadFiles(files []FileInfo, opts DownloadOptions) (*DownloadReport, error) {
+	d.log("\n📥 Starting download of %d files...", len(files))
+	run := d.newDownloadRun(opts)
+	for _, file := range files {
+		if err := run.download(file); err != nil {
+			return run.stop(err)
+		}
+	}
+	return run.finish()
+}
+
+// DownloadStream downloads files as they arrive on the channel, such as from
+// WalkFiles, until it is closed. It stops without draining the channel if the
+// run is cancelled or DownloadOptions.MaxErrors files fail.
+func (d *DriveService) DownloadStream(files <-chan FileInfo, opts DownloadOptions) (*DownloadReport, error) {
+	d.log("\n📥 Downloading files as they are found...")
+	run := d.newDownloadRun(opts)
+	for file := range files {
+		if err := run.download(file); err != nil {
+			return run.stop(err)
+		}
+	}
+	return run.finish()
+}
+
+// downloadRun holds the state of a single DownloadFiles or DownloadStream call
+type downloadRun struct {
+	d           *DriveService
+	opts        DownloadOptions
+	report      *DownloadReport
+	createdDirs map[string]bool
+
+	// canonical maps each MD5 to the first local copy with that content
+	canonical map[string]canonicalCopy
+
+	// noXattrs is set once extended attributes turn out to be unsupported,
+	// so the run warns only once
+	noXattrs bool
+
+	// exports runs exported variants when opts.ExportWorkers is set, and
+	// exporting counts the exports of each file yet to be collected from it.
+	// A file is only added to opts.Seen once all of them succeeded.
+	exports   *exportPool
+	exporting map[string]int
+
+	// failedIDs holds the IDs of the files that failed, which are never
+	// added to opts.Seen
+	failedIDs map[string]bool
+
+	// verifier checks downloads in the background; see
+	// DownloadOptions.VerifyWorkers
+	verifier *verifyPool
+
+	// describedDirs holds the directories whose folder description was
+	// saved, and descriptions the descriptions looked up by folder ID
+	describedDirs map[string]bool
+	descriptions  map[string]string
+}
+
+func (d *DriveService) newDownloadRun(opts DownloadOptions) *downloadRun {
+	if opts.TrashAfterDownload {
+		// Never trash a file whose download could not be verified
+		opts.VerifyChecksum = true
+	}
+	run := &downloadRun{
+		d:           d,
+		opts:        opts,
+		report:      &DownloadReport{Downloads: PoolStats{Workers: 1}},
+		createdDirs: make(map[string]bool),
+		canonical:   make(map[string]canonicalCopy),
+		failedIDs:   make(map[string]bool),
+
+		describedDirs: make(map[string]bool),
+		descriptions:  make(map[string]string),
+	}
+	if opts.ExportWorkers > 0 {
+		run.exports = newExportPool(d, opts)
+		run.exporting = make(map[string]int)
+	}
+	if opts.VerifyChecksum && opts.VerifyWorkers > 0 && opts.Archive == nil && opts.Sink == nil {
+		run.verifier = newVerifyPool(d, opts)
+	}
+	return run
+}
+
+// download processes a single file. Failures that stop the run are returned;
+// others are recorded in the report.
+func (r *downloadRun) download(file FileInfo) (err error) {
+	d, opts, report := r.d, r.opts, r.report
+	if err := d.requestContext().Err(); err != nil {
+		return err
+	}
+	if r.exports != nil {
+		if err := r.collectExports(); err != nil {
+			return err
+		}
+	}
+	if r.verifier != nil {
+		if err := r.collectVerified(false); err != nil {
+			return err
+		}
+	}
+	// A download checked in the background is only added to Seen once
+	// it has been verified
+	queued := false
+	if opts.Seen != nil {
+		if opts.Seen.Test(file.ID) {
+			r.d.printf("Skipping %s: already downloaded by an earlier run\n", file.Path)
+			return nil
+		}
+		defer func() {
+			if err == nil && !queued {
+				r.markSeen(file)
+			}
+		}()
+	}
+	start := time.Now()
+	defer func() {
+		report.Downloads.Tasks++
+		report.Downloads.Busy += time.Since(start)
+	}()
+
+	r.d.printf("Downloading: %s\n", file.Path) // Always show this regardless of verbose mode
+	if opts.Archive != nil {
+		if err := d.archiveFile(file, opts.Archive, opts); err != nil {
+			err = fmt.Errorf("error downloading %s: %w", file.Path, err)
+			if errors.Is(err, errTarEntry) {
+				// The archive can't take any more entries
+				return err
+			}
+			return r.failDownload(file, err)
+		}
+		report.Downloaded = append(report.Downloaded, file)
+		return nil
+	}
+	if opts.Sink != nil {
+		if err := d.sinkFile(file, opts.Sink, opts); err != nil {
+			return r.failDownload(file, fmt.Errorf("error downloading %s: %w", file.Path, err))
+		}
+		report.Downloaded = append(report.Downloaded, file)
+		return nil
+	}
+
+	baseDir, err := opts.BaseDir(file)
+	if err != nil {
+		return r.failDownload(file, err)
+	}
+	if !r.createdDirs[baseDir] {
+		d.log("  Creating output directory: %s", baseDir)
+		if err := os.MkdirAll(baseDir, d.dirPerm()); err != nil {
+			return r.failDownload(file, fmt.Errorf("unable to create output directory: %v", err))
+		}
+		r.createdDirs[baseDir] = true
+	}
+
+	if !opts.includesOriginal() {
+		if err := r.downloadVariants(file); err != nil {
+			return r.failDownload(file, err)
+		}
+		if opts.WriteFolderDescriptions {
+			r.writeFolderDescription(file)
+		}
+		return nil
+	}
+
+	linked, err := r.linkDuplicate(file)
+	if err != nil {
+		return r.failDownload(file, err)
+	}
+	if !linked && r.verifier != nil && file.HasChecksum() {
+		queued = true
+		if err := r.queueDownload(file, 0); err != nil {
+			return r.failDownload(file, err)
+		}
+		return nil
+	}
+	if !linked {
+		if err := d.downloadVerified(file, opts); err != nil {
+			return r.failDownload(file, fmt.Errorf("error downloading %s: %w", file.Path, err))
+		}
+		r.recordCanonical(file)
+	}
+	return r.complete(file, linked)
+}
+
+// complete records a downloaded file and saves what goes with it: its
+// variants, metadata, folder description and extended attributes, before
+// running the post-download step and trashing it in Drive
+func (r *downloadRun) complete(file FileInfo, linked bool) error {
+	d, opts, report := r.d, r.opts, r.report
+	report.Downloaded = append(report.Downloaded, file)
+
+	if err := r.downloadVariants(file); err != nil {
+		return r.failDownload(file, err)
+	}
+
+	if opts.WriteMetadata {
+		if err := d.writeMetadata(file, opts); err != nil {
+			r.d.printf("⚠️ Unable to write metadata for %s: %v\n", file.Path, err)
+			report.Warnings = append(report.Warnings, FileFailure{File: file, Err: err})
+		}
+	}
+
+	if opts.WriteFolderDescriptions {
+		r.writeFolderDescription(file)
+	}
+
+	if opts.WriteXattrs && !linked {
+		r.writeXattrs(file)
+	}
+
+	if opts.AfterDownload != nil {
+		outPath, err := opts.OutputPath(file)
+		if err == nil {
+			err = opts.AfterDownload(file, outPath)
+		}
+		if err != nil {
+			r.d.printf("❌ Post-download step failed for %s: %v\n", file.Path, err)
+			return r.fail(file, err)
+		}
+	}
+
+	if opts.TrashAfterDownload {
+		if !file.HasChecksum() {
+			r.d.printf("⚠️ Not trashing %s: Drive reports no checksum to verify the download against\n", file.Path)
+			return nil
+		}
+		if err := d.trashFile(file); err != nil {
+			return r.failDownload(file, fmt.Errorf("error trashing %s: %v", file.Path, err))
+		}
+		report.Trashed = append(report.Trashed, file)
+	}
+	return nil
+}
+
+// writeXattrs sets the extended attributes of a downloaded file. Failing to
+// do so is only a warning, given once for the run when they are unsupported.
+func (r *downloadRun) writeXattrs(file FileInfo) {
+	if r.noXattrs {
+		return
+	}
+	err := r.d.writeXattrs(file, r.opts)
+	if errors.Is(err, ErrXattrUnsupported) {
+		r.d.printf("⚠️ Not setting extended attributes: %v\n", err)
+		r.noXattrs = true
+		return
+	}
+	if err != nil {
+		r.d.printf("⚠️ Unable to set extended attributes for %s: %v\n", file.Path, err)
+		r.report.Warnings = append(r.report.Warnings, FileFailure{File: file, Err: err})
+	}
+}
+
+// fail records a failed file, stopping the run once MaxErrors is reached
+func (r *downloadRun) fail(file FileInfo, err error) error {
+	r.report.Failed = append(r.report.Failed, FileFailure{File: file, Err: err})
+	r.failedIDs[file.ID] = true
+	if r.opts.MaxErrors > 0 && len(r.report.Failed) >= r.opts.MaxErrors {
+		return fmt.Errorf("%w: %d files failed, stopping", ErrTooManyFailures, len(r.report.Failed))
+	}
+	return nil
+}
+
+// failDownload records a file that could not be downloaded and moves on to
+// the next, unless the run was cancelled, which stops it with err
+func (r *downloadRun) failDownload(file FileInfo, err error) error {
+	if r.d.requestContext().Err() != nil {
+		return err
+	}
+	r.d.printf("❌ %v\n", err)
+	return r.fail(file, err)
+}
+
+// collectExports records the failures of the exports finished so far,
+// adding files to opts.Seen once all of their exports succeeded
+func (r *downloadRun) collectExports() error {
+	for _, job := range r.exports.collect() {
+		id := job.file.ID
+		if r.exporting[id]--; r.exporting[id] == 0 {
+			delete(r.exporting, id)
+		}
+		if job.err != nil {
+			if err := r.failDownload(job.file, job.err); err != nil {
+				return err
+			}
+			continue
+		}
+		r.markSeen(job.file)
+	}
+	return nil
+}
+
+// markSeen adds a completed file to opts.Seen, unless it failed or exports
+// of it are still running, in which case collectExports adds it once they
+// succeed
+func (r *downloadRun) markSeen(file FileInfo) {
+	if r.opts.Seen != nil && r.exporting[file.ID] == 0 && !r.failedIDs[file.ID] {
+		r.opts.Seen.Add(file.ID)
+	}
+}
+
+// downloadVariants saves every non-original variant requested for the file,
+// handing exported ones to the export workers when there are any
+func (r *downloadRun) downloadVariants(file FileInfo) error {
+	for _, name := range r.opts.Variants {
+		if name == OriginalVariant {
+			continue
+		}
+		if r.exports != nil && variants[name].export != "" {
+			r.exports.add(file, name)
+			r.exporting[file.ID]++
+			continue
+		}
+		if err := r.d.DownloadVariant(file, name, r.opts); err != nil {
+			return fmt.Errorf("error downloading %s of %s: %w", name, file.Path, err)
+		}
+	}
+	return nil
+}
+
+// queueDownload downloads a file to its ".part" file and queues it for the
+// verification workers. attempt counts the earlier downloads of the file
+// that failed their checksum.
+func (r *downloadRun) queueDownload(file FileInfo, attempt int) error {
+	outPath, err := r.opts.OutputPath(file)
+	if err != nil {
+		return err
+	}
+	r.d.log("📥 Starting download of: %s", file.Path)
+	recordPath, err := r.d.downloadPart(file, outPath, nil, r.opts)
+	if err != nil {
+		if recordPath != "" {
+			os.Remove(recordPath)
+		}
+		return fmt.Errorf("error downloading %s: %w", file.Path, err)
+	}
+	r.verifier.add(verifyJob{file: file, outPath: outPath, recordPath: recordPath, attempt: attempt})
+	return nil
+}
+
+// collectVerified completes the downloads the verification workers have
+// checked, downloading those that didn't match again up to
+// opts.ChecksumRetries times. With wait set, it returns only once every
+// download has been checked and completed.
+func (r *downloadRun) collectVerified(wait bool) error {
+	for {
+		jobs := r.verifier.collect(wait)
+		if len(jobs) == 0 {
+			return nil
+		}
+		for _, job := range jobs {
+			if err := r.verified(job); err != nil {
+				return err
+			}
+		}
+		if !wait {
+			return nil
+		}
+	}
+}
+
+// verified completes a download checked by the verification workers
+func (r *downloadRun) verified(job verifyJob) error {
+	file, opts := job.file, r.opts
+	if errors.Is(job.err, ErrChecksumMismatch) && job.attempt < opts.ChecksumRetries {
+		r.d.printf("⚠️ %s: %v; downloading again (retry %d/%d)\n", file.Path, job.err, job.attempt+1, opts.ChecksumRetries)
+		if err := r.queueDownload(file, job.attempt+1); err != nil {
+			return r.failDownload(file, err)
+		}
+		return nil
+	}
+	if job.err != nil {
+		return r.failDownload(file, fmt.Errorf("error downloading %s: %w", file.Path, job.err))
+	}
+	r.d.log("✅ Successfully downloaded: %s", file.Path)
+	r.recordCanonical(file)
+	if err := r.complete(file, false); err != nil {
+		return err
+	}
+	r.markSeen(file)
+	return nil
+}
+
+// stop ends a run that failed with err once the exports and checks already
+// under way are done
+func (r *downloadRun) stop(err error) (*DownloadReport, error) {
+	if r.verifier != nil {
+		r.report.Verification = r.verifier.stop()
+	}
+	if r.exports != nil {
+		r.report.Exports = r.exports.wait()
+	}
+	return r.report, err
+}
+
+func (r *downloadRun) finish() (*DownloadReport, error) {
+	if r.verifier != nil {
+		if err := r.collectVerified(true); err != nil {
+			return r.stop(err)
+		}
+		r.report.Verification = r.verifier.stop()
+	}
+	if r.exports != nil {
+		r.report.Exports = r.exports.wait()
+		if err := r.collectExports(); err != nil {
+			return r.report, err
+		}
+	}
+	if len(r.report.Failed) == 0 {
+		r.d.log("✅ All files downloaded successfully!")
+	}
+	return r.report, nil
+}
+
+// trashFile moves a file to the Drive trash
+func (d *DriveService) trashFile(fileInfo FileInfo) error {
+	d.log("🗑️ Moving to trash: %s", fileInfo.Path)
+	_, err := d.service.Files.Update(fileInfo.ID, &drive.File{Trashed: true}).
+		SupportsAllDrives(true).
+		Context(d.requestContext()).
+		Do()
+	return err
+}
@@ -0,0 +1,397 @@
+package drive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestDownloadFilesTrashAfterDownload(t *testing.T) {
+	fake := newFakeDrive()
+	fake.addFile("a", "a.txt", "root", "2025-04-01T00:00:00Z", "hello")
+	fake.addFile("b", "b.txt", "root", "2025-04-01T00:00:00Z", "world")
+	d := newTestService(t, fake)
+
+	outputDir := t.TempDir()
+	files := []FileInfo{
+		{ID: "a", Name: "a.txt", Path: "a.txt", MD5: fake.files["a"].Md5Checksum},
+		{ID: "b", Name: "b.txt", Path: "b.txt"},
+	}
+
+	report, err := d.DownloadFiles(files, DownloadOptions{OutputDir: outputDir, TrashAfterDownload: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(report.Downloaded) != 2 {
+		t.Errorf("downloaded %d files, want 2", len(report.Downloaded))
+	}
+	if len(report.Trashed) != 1 || report.Trashed[0].ID != "a" {
+		t.Errorf("trashed = %v, want only a", report.Trashed)
+	}
+	if !fake.files["a"].Trashed {
+		t.Error("expected verified file to be trashed")
+	}
+	if fake.files["b"].Trashed {
+		t.Error("expected file without checksum not to be trashed")
+	}
+
+	content, err := os.ReadFile(filepath.Join(outputDir, "a.txt"))
+	if err != nil || string(content) != "hello" {
+		t.Errorf("downloaded content = %q, %v", content, err)
+	}
+}
+
+func TestDownloadFilesChecksumMismatchDoesNotTrash(t *testing.T) {
+	fake := newFakeDrive()
+	fake.addFile("a", "a.txt", "root", "2025-04-01T00:00:00Z", "hello")
+	d := newTestService(t, fake)
+
+	files := []FileInfo{{ID: "a", Name: "a.txt", Path: "a.txt", MD5: md5Hex("something else")}}
+	report, err := d.DownloadFiles(files, DownloadOptions{OutputDir: t.TempDir(), TrashAfterDownload: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(report.Failed) != 1 || !errors.Is(report.Failed[0].Err, ErrChecksumMismatch) {
+		t.Fatalf("failed = %v, want a.txt with ErrChecksumMismatch", report.Failed)
+	}
+	if len(report.Trashed) != 0 || fake.files["a"].Trashed {
+		t.Error("expected file with mismatched checksum not to be trashed")
+	}
+}
+
+func TestDownloadFileReportsProgress(t *testing.T) {
+	fake := newFakeDrive()
+	fake.addFile("a", "a.txt", "root", "2025-04-01T00:00:00Z", "hello world")
+	d := newTestService(t, fake)
+
+	var calls int
+	var lastDone, lastTotal int64
+	d.WithProgress(func(file FileInfo, bytesDone, bytesTotal int64) {
+		calls++
+		lastDone, lastTotal = bytesDone, bytesTotal
+	})
+
+	file := FileInfo{ID: "a", Name: "a.txt", Path: "a.txt", Size: 11}
+	if err := d.DownloadFile(file, DownloadOptions{OutputDir: t.TempDir()}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls == 0 {
+		t.Fatal("expected progress to be reported")
+	}
+	if lastDone != 11 || lastTotal != 11 {
+		t.Errorf("final progress = %d/%d, want 11/11", lastDone, lastTotal)
+	}
+}
+
+func TestDownloadFilesWithOutput(t *testing.T) {
+	fake := newFakeDrive()
+	fake.addFile("a", "a.txt", "root", "2025-04-01T00:00:00Z", "hello world")
+	d := newTestService(t, fake)
+
+	var out bytes.Buffer
+	d.WithOutput(&out)
+	files := []FileInfo{{ID: "a", Name: "a.txt", Path: "a.txt", Size: 11}}
+	if _, err := d.DownloadFiles(files, DownloadOptions{OutputDir: t.TempDir()}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), "Downloading: a.txt") {
+		t.Errorf("output = %q, want the download reported", out.String())
+	}
+}
+
+func TestDownloadFileProgressLongerThanListed(t *testing.T) {
+	// The file grew after it was listed
+	fake := newFakeDrive()
+	fake.addFile("a", "a.txt", "root", "2025-04-01T00:00:00Z", "hello world")
+	d := newTestService(t, fake)
+
+	d.WithProgress(func(file FileInfo, bytesDone, bytesTotal int64) {
+		if bytesDone > bytesTotal {
+			t.Errorf("progress %d/%d: more done than the total", bytesDone, bytesTotal)
+		}
+	})
+	file := FileInfo{ID: "a", Name: "a.txt", Path: "a.txt", Size: 5}
+	if err := d.DownloadFile(file, DownloadOptions{OutputDir: t.TempDir()}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestDownloadFileCompress(t *testing.T) {
+	fake := newFakeDrive()
+	fake.addFile("a", "a.TRANSCRIPT", "root", "2025-04-01T00:00:00Z", "hello transcript")
+	fake.addFile("b", "b.mp4", "root", "2025-04-01T00:00:00Z", "video")
+	d := newTestService(t, fake)
+
+	opts := DownloadOptions{OutputDir: t.TempDir(), Compress: "gzip", VerifyChecksum: true}
+	transcript := FileInfo{ID: "a", Name: "a.TRANSCRIPT", Path: "a.TRANSCRIPT", MD5: fake.files["a"].Md5Checksum}
+	video := FileInfo{ID: "b", Name: "b.mp4", Path: "b.mp4", MD5: fake.files["b"].Md5Checksum}
+
+	// The checksum is verified against the uncompressed content
+	for _, file := range []FileInfo{transcript, video} {
+		if err := d.DownloadFile(file, opts); err != nil {
+			t.Fatalf("DownloadFile(%s) unexpected error: %v", file.Name, err)
+		}
+	}
+
+	f, err := os.Open(filepath.Join(opts.OutputDir, "a.TRANSCRIPT.gz"))
+	if err != nil {
+		t.Fatalf("expected compressed output: %v", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("invalid gzip output: %v", err)
+	}
+	content, err := io.ReadAll(gz)
+	if err != nil || string(content) != "hello transcript" {
+		t.Errorf("decompressed content = %q, %v", content, err)
+	}
+
+	if _, err := os.Stat(filepath.Join(opts.OutputDir, "b.mp4")); err != nil {
+		t.Errorf("expected already-compressed type to be saved as is: %v", err)
+	}
+}
+
+func TestDownloadFilesRetryOnChecksumMismatch(t *testing.T) {
+	fake := newFakeDrive()
+	fake.addFile("a", "a.txt", "root", "2025-04-01T00:00:00Z", "hello")
+	d := newTestService(t, fake)
+	files := []FileInfo{{ID: "a", Name: "a.txt", Path: "a.txt", MD5: fake.files["a"].Md5Checksum}}
+
+	fake.corrupt["a"] = 2
+	report, err := d.DownloadFiles(files, DownloadOptions{OutputDir: t.TempDir(), VerifyChecksum: true, ChecksumRetries: 2})
+	if err != nil || len(report.Failed) != 0 {
+		t.Errorf("expected success after retries, got %v, failed %v", err, report.Failed)
+	}
+
+	fake.corrupt["a"] = 2
+	report, err = d.DownloadFiles(files, DownloadOptions{OutputDir: t.TempDir(), VerifyChecksum: true, ChecksumRetries: 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(report.Failed) != 1 || !errors.Is(report.Failed[0].Err, ErrChecksumMismatch) {
+		t.Errorf("failed = %v, want ErrChecksumMismatch once retries are exhausted", report.Failed)
+	}
+	if fake.corrupt["a"] != 0 {
+		t.Errorf("expected 2 download attempts, %d corrupt responses left", fake.corrupt["a"])
+	}
+}
+
+func TestDownloadFilesStopsWhenContextDone(t *testing.T) {
+	fake := newFakeDrive()
+	fake.addFile("a", "a.txt", "root", "2025-04-01T00:00:00Z", "hello")
+	d := newTestService(t, fake)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	d.WithContext(ctx)
+
+	files := []FileInfo{{ID: "a", Name: "a.txt", Path: "a.txt"}}
+	report, err := d.DownloadFiles(files, DownloadOptions{OutputDir: t.TempDir()})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want context.Canceled", err)
+	}
+	if len(report.Downloaded) != 0 {
+		t.Errorf("downloaded %v after cancellation", report.Downloaded)
+	}
+
+	if _, err := d.ListFiles(ListOptions{FolderIDs: []string{"root"}, MaxDepth: -1}); err == nil {
+		t.Error("expected ListFiles to fail after cancellation")
+	}
+}
+
+func TestLocalPath(t *testing.T) {
+	// Drive paths always use "/"; only local paths use the OS separator,
+	// such as a backslash on Windows
+	got := localPath("out", "Zoom Recordings/2025/a.mp4")
+	if want := filepath.Join("out", "Zoom Recordings", "2025", "a.mp4"); got != want {
+		t.Errorf("localPath() = %q, want %q", got, want)
+	}
+
+	opts := DownloadOptions{OutputDir: "out", Compress: "gzip"}
+	got, err := opts.OutputPath(FileInfo{Name: "a.txt", Path: "notes/a.txt"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := filepath.Join("out", "notes", "a.txt.gz"); got != want {
+		t.Errorf("OutputPath() = %q, want %q", got, want)
+	}
+}
+
+func TestDownloadFilesMaxErrors(t *testing.T) {
+	fake := newFakeDrive()
+	var files []FileInfo
+	for _, id := range []string{"a", "b", "c"} {
+		fake.addFile(id, id+".txt", "root", "2025-04-01T00:00:00Z", id)
+		files = append(files, FileInfo{ID: id, Name: id + ".txt", Path: id + ".txt"})
+	}
+	d := newTestService(t, fake)
+
+	opts := DownloadOptions{
+		OutputDir:     t.TempDir(),
+		AfterDownload: func(FileInfo, string) error { return errors.New("hook failed") },
+	}
+	report, err := d.DownloadFiles(files, opts)
+	if err != nil || len(report.Failed) != 3 {
+		t.Fatalf("without a limit: failed %d files, error %v; want 3 failures and no error", len(report.Failed), err)
+	}
+
+	opts.MaxErrors = 2
+	report, err = d.DownloadFiles(files, opts)
+	if !errors.Is(err, ErrTooManyFailures) {
+		t.Errorf("error = %v, want ErrTooManyFailures", err)
+	}
+	if len(report.Failed) != 2 || len(report.Downloaded) != 2 {
+		t.Errorf("failed %d and downloaded %d files, want the run to stop after 2", len(report.Failed), len(report.Downloaded))
+	}
+}
+
+func TestDownloadFilesMaxErrorsCountsFailedDownloads(t *testing.T) {
+	fake := newFakeDrive()
+	var files []FileInfo
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		fake.addFile(id, id+".txt", "root", "2025-04-01T00:00:00Z", id)
+		files = append(files, FileInfo{ID: id, Name: id + ".txt", Path: id + ".txt"})
+	}
+	d := newTestService(t, fake)
+	forbid := func() {
+		for _, file := range files {
+			fake.failures["files/"+file.ID] = []int{http.StatusForbidden}
+		}
+	}
+
+	forbid()
+	report, err := d.DownloadFiles(files, DownloadOptions{OutputDir: t.TempDir()})
+	if err != nil || len(report.Failed) != 5 {
+		t.Fatalf("without a limit: failed %d files, error %v; want all 5 to fail and no error", len(report.Failed), err)
+	}
+
+	forbid()
+	report, err = d.DownloadFiles(files, DownloadOptions{OutputDir: t.TempDir(), MaxErrors: 3})
+	if !errors.Is(err, ErrTooManyFailures) {
+		t.Errorf("error = %v, want ErrTooManyFailures", err)
+	}
+	if len(report.Failed) != 3 {
+		t.Errorf("failed %d files, want the run to stop after 3", len(report.Failed))
+	}
+	if codes := fake.failures["files/e"]; len(codes) != 1 {
+		t.Error("the run went on to e.txt after 3 failures")
+	}
+}
+
+func TestDownloadFilesNativeAsLink(t *testing.T) {
+	fake := newFakeDrive()
+	fake.addFile("a", "a.txt", "root", "2025-04-01T00:00:00Z", "hello")
+	d := newTestService(t, fake)
+
+	outputDir := t.TempDir()
+	files := []FileInfo{
+		{ID: "a", Name: "a.txt", Path: "a.txt"},
+		{ID: "doc", Name: "Notes", Path: "Notes", MimeType: "application/vnd.google-apps.document", WebViewLink: "https://docs.google.com/document/d/doc/edit"},
+	}
+	report, err := d.DownloadFiles(files, DownloadOptions{OutputDir: outputDir, NativeAsLink: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(report.Downloaded) != 2 {
+		t.Errorf("downloaded %d files, want 2", len(report.Downloaded))
+	}
+
+	data, err := os.ReadFile(filepath.Join(outputDir, "Notes.gdoc"))
+	if err != nil {
+		t.Fatalf("link stub not written: %v", err)
+	}
+	var stub linkStub
+	if err := json.Unmarshal(data, &stub); err != nil {
+		t.Fatalf("invalid link stub %q: %v", data, err)
+	}
+	if stub.URL != files[1].WebViewLink || stub.DocID != "doc" {
+		t.Errorf("link stub = %+v", stub)
+	}
+	if content, err := os.ReadFile(filepath.Join(outputDir, "a.txt")); err != nil || string(content) != "hello" {
+		t.Errorf("downloaded content = %q, %v", content, err)
+	}
+}
+
+func TestWriteFileIsAtomic(t *testing.T) {
+	d := &DriveService{}
+	outPath := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(outPath, []byte("earlier copy"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A download cut off part way leaves the earlier copy alone
+	body := io.MultiReader(strings.NewReader("partial"), iotest.ErrReader(errors.New("connection reset")))
+	if err := d.writeFile(outPath, body, false); err == nil {
+		t.Fatal("expected an error for an interrupted download")
+	}
+	if content, _ := os.ReadFile(outPath); string(content) != "earlier copy" {
+		t.Errorf("content after failed download = %q, want the earlier copy", content)
+	}
+	if _, err := os.Stat(outPath + partSuffix); !os.IsNotExist(err) {
+		t.Errorf("expected the .part file to be removed, got %v", err)
+	}
+
+	// A .part file left by a crashed run is replaced
+	if err := os.WriteFile(outPath+partSuffix, []byte("stale"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.writeFile(outPath, strings.NewReader("hello"), false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if content, _ := os.ReadFile(outPath); string(content) != "hello" {
+		t.Errorf("content = %q, want hello", content)
+	}
+	if _, err := os.Stat(outPath + partSuffix); !os.IsNotExist(err) {
+		t.Errorf("expected no .part file after a successful download, got %v", err)
+	}
+}
+
+func TestDownloadFilesFolderDescriptions(t *testing.T) {
+	fake := newFakeDrive()
+	fake.addFolder("proj", "Project", "root")
+	fake.files["proj"].Description = "Client: ACME\nOwner: ops"
+	fake.addFolder("misc", "Misc", "root")
+	fake.addFile("a", "a.txt", "proj", "2025-04-01T00:00:00Z", "a")
+	fake.addFile("b", "b.txt", "proj", "2025-04-01T00:00:00Z", "b")
+	fake.addFile("c", "c.txt", "misc", "2025-04-01T00:00:00Z", "c")
+	d := newTestService(t, fake)
+
+	files, err := d.ListFiles(ListOptions{MaxDepth: -1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	outputDir := t.TempDir()
+	gets := fake.fileGets
+	report, err := d.DownloadFiles(files, DownloadOptions{OutputDir: outputDir, WriteFolderDescriptions: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(report.Warnings) != 0 {
+		t.Errorf("unexpected warnings: %v", report.Warnings)
+	}
+
+	got, err := os.ReadFile(filepath.Join(outputDir, "Project", ".folder-description.txt"))
+	if err != nil {
+		t.Fatalf("expected the description of Project: %v", err)
+	}
+	if string(got) != "Client: ACME\nOwner: ops" {
+		t.Errorf("description = %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "Misc", ".folder-description.txt")); err == nil {
+		t.Error("expected no description for a folder without one")
+	}
+	// Three downloads and one description lookup per folder
+	if n := fake.fileGets - gets; n != 5 {
+		t.Errorf("made %d file requests, want 5", n)
+	}
+}
@@ -0,0 +1,44 @@
+package drive
+
+import (
+	"strings"
+)
+
+// myDriveDir is the top-level directory used by PrefixDriveName for files
+// outside any shared drive
+const myDriveDir = "My Drive"
+
+// driveName returns the name of a shared drive, looking it up only once per
+// drive. The ID is used when the name cannot be resolved.
+func (d *DriveService) driveName(driveID string) string {
+	if name, ok := d.driveNames[driveID]; ok {
+		return name
+	}
+	if d.driveNames == nil {
+		d.driveNames = make(map[string]string)
+	}
+
+	name := driveID
+	sharedDrive, err := d.service.Drives.Get(driveID).Fields("id, name").Context(d.requestContext()).Do()
+	if err != nil {
+		d.printf("⚠️ Unable to resolve the name of shared drive %s, using its ID: %v\n", driveID, err)
+	} else if sharedDrive.Name != "" {
+		name = sharedDrive.Name
+	}
+	d.log("  Shared drive %s is %q", driveID, name)
+
+	d.driveNames[driveID] = name
+	return name
+}
+
+// PrefixDriveName returns the file's path below a top-level directory named
+// after the shared drive it belongs to, or "My Drive" for files outside any
+// shared drive, so files from different drives cannot collide
+func (d *DriveService) PrefixDriveName(fileInfo FileInfo) string {
+	dir := myDriveDir
+	if fileInfo.DriveID != "" {
+		// Drive names may contain path separators
+		dir = strings.ReplaceAll(d.driveName(fileInfo.DriveID), "/", "_")
+	}
+	return joinPath(dir, fileInfo.Path)
+}
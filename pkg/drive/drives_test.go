@@ -0,0 +1,32 @@
+package drive
+
+import (
+	"testing"
+)
+
+func TestPrefixDriveName(t *testing.T) {
+	fake := newFakeDrive()
+	fake.drives["d1"] = "Sales/EMEA"
+	fake.drives["d2"] = "Engineering"
+	d := newTestService(t, fake)
+
+	tests := []struct {
+		file FileInfo
+		want string
+	}{
+		{file: FileInfo{Path: "reports/q1.pdf", DriveID: "d1"}, want: "Sales_EMEA/reports/q1.pdf"},
+		{file: FileInfo{Path: "reports/q1.pdf", DriveID: "d2"}, want: "Engineering/reports/q1.pdf"},
+		{file: FileInfo{Path: "reports/q2.pdf", DriveID: "d1"}, want: "Sales_EMEA/reports/q2.pdf"},
+		{file: FileInfo{Path: "reports/q1.pdf"}, want: "My Drive/reports/q1.pdf"},
+		{file: FileInfo{Path: "a.txt", DriveID: "gone"}, want: "gone/a.txt"},
+	}
+	for _, tt := range tests {
+		if got := d.PrefixDriveName(tt.file); got != tt.want {
+			t.Errorf("PrefixDriveName(%+v) = %q, want %q", tt.file, got, tt.want)
+		}
+	}
+
+	if fake.driveGets != 3 {
+		t.Errorf("looked up shared drives %d times, want 3 (one per drive)", fake.driveGets)
+	}
+}
@@ -0,0 +1,139 @@
+package drive
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// PoolStats records the work done by the workers of a download run
+type PoolStats struct {
+	Workers int
+	// Tasks is how many files, or exports of a file, the workers processed
+	Tasks int
+	// Busy is the time spent on them, summed over the workers
+	Busy time.Duration
+	// Bytes is the content the workers read, counted only for verification
+	Bytes int64
+}
+
+// Throughput returns the bytes processed per second of work of a single
+// worker
+func (s PoolStats) Throughput() float64 {
+	if s.Busy <= 0 {
+		return 0
+	}
+	return float64(s.Bytes) / s.Busy.Seconds()
+}
+
+// exportPool runs exported variants, such as pdf-export, in the background
+// so slow exports don't hold up downloads. Exports are queued without limit
+// and run on up to DownloadOptions.ExportWorkers at once, in the order they
+// were queued or, with DownloadOptions.SmartSchedule, largest file first.
+// Finished exports are collected by the download loop, which records their
+// failures.
+type exportPool struct {
+	// run exports the named variant of a file
+	run          func(file FileInfo, name string) error
+	workers      int
+	largestFirst bool
+	wg           sync.WaitGroup
+
+	mu    sync.Mutex
+	queue []exportJob
+	// running is how many workers are taking exports off the queue
+	running int
+	done    []exportJob
+	stats   PoolStats
+}
+
+// exportJob is an export waiting for a worker
+type exportJob struct {
+	file FileInfo
+	name string
+	err  error
+}
+
+func newExportPool(d *DriveService, opts DownloadOptions) *exportPool {
+	return &exportPool{
+		run: func(file FileInfo, name string) error {
+			return d.DownloadVariant(file, name, opts)
+		},
+		workers:      opts.ExportWorkers,
+		largestFirst: opts.SmartSchedule,
+		stats:        PoolStats{Workers: opts.ExportWorkers},
+	}
+}
+
+// add queues the named variant of a file for export, starting a worker if
+// fewer than the limit are running
+func (p *exportPool) add(file FileInfo, name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.queue = append(p.queue, exportJob{file: file, name: name})
+	if p.running < p.workers {
+		p.running++
+		p.wg.Add(1)
+		go p.work()
+	}
+}
+
+// work runs queued exports until the queue is empty
+func (p *exportPool) work() {
+	defer p.wg.Done()
+	for {
+		job, ok := p.next()
+		if !ok {
+			return
+		}
+
+		start := time.Now()
+		if err := p.run(job.file, job.name); err != nil {
+			job.err = fmt.Errorf("error downloading %s of %s: %w", job.name, job.file.Path, err)
+		}
+		p.mu.Lock()
+		p.stats.Tasks++
+		p.stats.Busy += time.Since(start)
+		p.done = append(p.done, job)
+		p.mu.Unlock()
+	}
+}
+
+// next takes the export to run next off the queue. Once there is none to
+// run, the worker is counted out and false is returned.
+func (p *exportPool) next() (exportJob, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.queue) == 0 {
+		p.running--
+		return exportJob{}, false
+	}
+	i := 0
+	if p.largestFirst {
+		// Starting large files early leaves the small ones to fill in at the
+		// end, rather than one worker finishing a large file on its own
+		for j, job := range p.queue {
+			if job.file.Size > p.queue[i].file.Size {
+				i = j
+			}
+		}
+	}
+	job := p.queue[i]
+	p.queue = append(p.queue[:i], p.queue[i+1:]...)
+	return job, true
+}
+
+// collect takes the exports finished so far off the pool
+func (p *exportPool) collect() []exportJob {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	done := p.done
+	p.done = nil
+	return done
+}
+
+// wait waits for every queued export to finish
+func (p *exportPool) wait() PoolStats {
+	p.wg.Wait()
+	return p.stats
+}
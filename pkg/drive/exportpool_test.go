@@ -0,0 +1,121 @@
+package drive
+
+import (
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestExportPoolSmartScheduleOrder(t *testing.T) {
+	// One worker, held on the first export until the rest are queued
+	p := newExportPool(&DriveService{}, DownloadOptions{ExportWorkers: 1, SmartSchedule: true})
+	started, gate := make(chan struct{}), make(chan struct{})
+	var order []string
+	p.run = func(file FileInfo, name string) error {
+		order = append(order, file.ID)
+		if file.ID == "first" {
+			close(started)
+			<-gate
+		}
+		return nil
+	}
+
+	p.add(FileInfo{ID: "first", Size: 1}, "pdf-export")
+	<-started
+	for _, f := range []FileInfo{{ID: "s", Size: 10}, {ID: "l", Size: 300}, {ID: "m", Size: 20}, {ID: "l2", Size: 300}} {
+		p.add(f, "pdf-export")
+	}
+	close(gate)
+	p.wait()
+	// Largest first, in queue order among files of the same size
+	if want := []string{"first", "l", "l2", "m", "s"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("export order = %v, want %v", order, want)
+	}
+}
+
+func TestExportPoolSmartScheduleMakespan(t *testing.T) {
+	// Six small files queued before a large one: exported in queue order,
+	// the large one starts last and runs on its own at the end
+	files := []FileInfo{
+		{ID: "a", Size: 1}, {ID: "b", Size: 1}, {ID: "c", Size: 1},
+		{ID: "d", Size: 1}, {ID: "e", Size: 1}, {ID: "f", Size: 1},
+		{ID: "big", Size: 6},
+	}
+	makespan := func(smart bool) int64 {
+		p := newExportPool(&DriveService{}, DownloadOptions{ExportWorkers: 2, SmartSchedule: smart})
+		clock := newSimClock(2, len(files))
+		p.run = clock.run
+		for _, f := range files {
+			p.add(f, "pdf-export")
+		}
+		// Time only moves once everything is queued, as when downloads
+		// queue exports faster than they complete
+		end := clock.drive()
+		p.wait()
+		return end
+	}
+
+	// In queue order: 3 rounds of small files, then the large one alone, 9
+	// units. Largest first: the large one starts with the first or second
+	// export and the small ones fill in beside it, 6 or 7 units.
+	if naive := makespan(false); naive != 9 {
+		t.Errorf("queue-order makespan %d units, want 9", naive)
+	}
+	if smart := makespan(true); smart > 7 {
+		t.Errorf("largest-first makespan %d units, want 6 or 7", smart)
+	}
+}
+
+// simClock runs exports on a simulated clock, each taking as many units as
+// its file's size. Time moves on to the end of the next export only once
+// every worker with an export to run is waiting for its export to end.
+type simClock struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	now  int64
+	// ends holds the end of each export under way
+	ends    []int64
+	workers int
+	// left is how many exports have yet to end
+	left int
+}
+
+func newSimClock(workers, exports int) *simClock {
+	c := &simClock{workers: workers, left: exports}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// run stands in for an export, returning once the clock reaches its end
+func (c *simClock) run(file FileInfo, name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	end := c.now + file.Size
+	c.ends = append(c.ends, end)
+	c.cond.Broadcast()
+	for c.now < end {
+		c.cond.Wait()
+	}
+	i := slices.Index(c.ends, end)
+	c.ends = slices.Delete(c.ends, i, i+1)
+	c.left--
+	c.cond.Broadcast()
+	return nil
+}
+
+// drive advances the clock until every export has ended, returning the
+// time the last one ended at
+func (c *simClock) drive() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.left > 0 {
+		if len(c.ends) < min(c.workers, c.left) || slices.Min(c.ends) <= c.now {
+			c.cond.Wait()
+			continue
+		}
+		c.now = slices.Min(c.ends)
+		c.cond.Broadcast()
+	}
+	return c.now
+}
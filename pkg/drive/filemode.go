@@ -0,0 +1,49 @@
+package drive
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// Permissions downloads are created with unless WithFileModes says
+// otherwise. Both are reduced by the process umask.
+const (
+	DefaultFileMode os.FileMode = 0666
+	DefaultDirMode  os.FileMode = 0755
+)
+
+// ParseFileMode parses an octal permission value such as "0640" or "750".
+// No permissions at all, "0", is rejected: nobody could read the files.
+func ParseFileMode(s string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || mode == 0 || mode > 0777 {
+		return 0, fmt.Errorf("invalid permissions %q (expected octal digits from 1 up to 0777, e.g. 0640)", s)
+	}
+	return os.FileMode(mode), nil
+}
+
+// WithFileModes sets the permissions downloaded files and the directories
+// holding them are created with (0 for the default). Existing directories
+// are left as they are.
+func (d *DriveService) WithFileModes(fileMode, dirMode os.FileMode) *DriveService {
+	d.fileMode = fileMode
+	d.dirMode = dirMode
+	return d
+}
+
+// filePerm returns the permissions new files are created with
+func (d *DriveService) filePerm() os.FileMode {
+	if d.fileMode == 0 {
+		return DefaultFileMode
+	}
+	return d.fileMode
+}
+
+// dirPerm returns the permissions new directories are created with
+func (d *DriveService) dirPerm() os.FileMode {
+	if d.dirMode == 0 {
+		return DefaultDirMode
+	}
+	return d.dirMode
+}
@@ -0,0 +1,20 @@
+package drive
+
+import (
+	"os"
+	"testing"
+)
+
+func TestParseFileMode(t *testing.T) {
+	for input, want := range map[string]os.FileMode{"0640": 0640, "750": 0750, "0400": 0400} {
+		got, err := ParseFileMode(input)
+		if err != nil || got != want {
+			t.Errorf("ParseFileMode(%q) = %o, %v, want %o", input, got, err, want)
+		}
+	}
+	for _, input := range []string{"", "0", "000", "0800", "1777", "rw-r-----", "-1"} {
+		if _, err := ParseFileMode(input); err == nil {
+			t.Errorf("ParseFileMode(%q) succeeded, want error", input)
+		}
+	}
+}
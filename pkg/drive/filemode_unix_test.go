@@ -0,0 +1,74 @@
+//go:build unix
+
+package drive
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestDownloadFileModes(t *testing.T) {
+	old := syscall.Umask(0)
+	defer syscall.Umask(old)
+
+	fake := newFakeDrive()
+	fake.addFile("a", "a.txt", "root", "2025-04-01T00:00:00Z", "hello")
+	d := newTestService(t, fake).WithFileModes(0640, 0750)
+
+	outputDir := filepath.Join(t.TempDir(), "out")
+	// A part left by a crash doesn't pass its permissions on
+	os.MkdirAll(filepath.Join(outputDir, "notes"), 0750)
+	os.WriteFile(filepath.Join(outputDir, "notes", "a.txt"+partSuffix), []byte("stale"), 0600)
+	files := []FileInfo{{ID: "a", Name: "a.txt", Path: "notes/a.txt"}}
+	if _, err := d.DownloadFiles(files, DownloadOptions{OutputDir: outputDir}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for path, want := range map[string]os.FileMode{
+		outputDir:                               0750,
+		filepath.Join(outputDir, "notes"):       0750,
+		filepath.Join(outputDir, "notes/a.txt"): 0640,
+	} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := info.Mode().Perm(); got != want {
+			t.Errorf("%s mode = %o, want %o", path, got, want)
+		}
+	}
+}
+
+func TestSinkFileModes(t *testing.T) {
+	old := syscall.Umask(0)
+	defer syscall.Umask(old)
+
+	fake := newFakeDrive()
+	fake.addFile("a", "a.txt", "root", "2025-04-01T00:00:00Z", "hello")
+	d := newTestService(t, fake).WithFileModes(0640, 0750)
+
+	sinkDir := t.TempDir()
+	// A part left by a crash doesn't pass its permissions on
+	os.MkdirAll(filepath.Join(sinkDir, "notes"), 0750)
+	os.WriteFile(filepath.Join(sinkDir, "notes", "a.txt"+partSuffix), []byte("stale"), 0600)
+
+	files := []FileInfo{{ID: "a", Name: "a.txt", Path: "notes/b/a.txt"}, {ID: "a", Name: "a.txt", Path: "notes/a.txt"}}
+	if _, err := d.DownloadFiles(files, DownloadOptions{OutputDir: t.TempDir(), Sink: LocalSink{Dir: sinkDir}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for path, want := range map[string]os.FileMode{
+		filepath.Join(sinkDir, "notes/b"):       0750,
+		filepath.Join(sinkDir, "notes/b/a.txt"): 0640,
+		filepath.Join(sinkDir, "notes/a.txt"):   0640,
+	} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := info.Mode().Perm(); got != want {
+			t.Errorf("%s mode = %o, want %o", path, got, want)
+		}
+	}
+}
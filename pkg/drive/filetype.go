@@ -0,0 +1,99 @@
+package drive
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// fileType is a kind of file selected server-side by MIME type
+type fileType struct {
+	// mimeTypes are matched exactly and prefixes with "contains"
+	mimeTypes []string
+	prefixes  []string
+}
+
+// fileTypes are the types available to ListOptions.Types
+var fileTypes = map[string]fileType{
+	"document": {mimeTypes: []string{
+		"application/vnd.google-apps.document",
+		"application/msword",
+		"application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+		"application/vnd.oasis.opendocument.text",
+		"application/rtf",
+	}},
+	"spreadsheet": {mimeTypes: []string{
+		"application/vnd.google-apps.spreadsheet",
+		"application/vnd.ms-excel",
+		"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		"application/vnd.oasis.opendocument.spreadsheet",
+		"text/csv",
+	}},
+	"presentation": {mimeTypes: []string{
+		"application/vnd.google-apps.presentation",
+		"application/vnd.ms-powerpoint",
+		"application/vnd.openxmlformats-officedocument.presentationml.presentation",
+		"application/vnd.oasis.opendocument.presentation",
+	}},
+	"pdf":   {mimeTypes: []string{"application/pdf"}},
+	"video": {mimeTypes: []string{"application/vnd.google-apps.video"}, prefixes: []string{"video/"}},
+	"audio": {mimeTypes: []string{"application/vnd.google-apps.audio"}, prefixes: []string{"audio/"}},
+	"image": {mimeTypes: []string{"application/vnd.google-apps.photo", "application/vnd.google-apps.drawing"}, prefixes: []string{"image/"}},
+}
+
+// FileTypeNames returns the names accepted in ListOptions.Types, sorted
+func FileTypeNames() []string {
+	var names []string
+	for name := range fileTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ValidateFileTypes checks that every name is a known file type
+func ValidateFileTypes(names []string) error {
+	for _, name := range names {
+		if _, ok := fileTypes[strings.ToLower(name)]; !ok {
+			return fmt.Errorf("unknown file type %q (expected one of %s)", name, strings.Join(FileTypeNames(), ", "))
+		}
+	}
+	return nil
+}
+
+// typesQuery builds a Drive query clause matching files of any of the types
+func typesQuery(names []string) string {
+	var clauses []string
+	for _, name := range names {
+		t := fileTypes[strings.ToLower(name)]
+		for _, mimeType := range t.mimeTypes {
+			clauses = append(clauses, fmt.Sprintf("mimeType = '%s'", mimeType))
+		}
+		for _, prefix := range t.prefixes {
+			clauses = append(clauses, fmt.Sprintf("mimeType contains '%s'", prefix))
+		}
+	}
+	return "(" + strings.Join(clauses, " or ") + ")"
+}
+
+// ofAnyType reports whether a MIME type belongs to one of the types, or
+// whether no types were given
+func ofAnyType(mimeType string, names []string) bool {
+	if len(names) == 0 {
+		return true
+	}
+	for _, name := range names {
+		t := fileTypes[strings.ToLower(name)]
+		for _, m := range t.mimeTypes {
+			if mimeType == m {
+				return true
+			}
+		}
+		for _, prefix := range t.prefixes {
+			if strings.Contains(mimeType, prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package drive
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTypesQuery(t *testing.T) {
+	got := typesQuery([]string{"pdf", "Video"})
+	want := "(mimeType = 'application/pdf' or mimeType = 'application/vnd.google-apps.video' or mimeType contains 'video/')"
+	if got != want {
+		t.Errorf("typesQuery() = %q, want %q", got, want)
+	}
+
+	opts := ListOptions{Query: "starred = true", Types: []string{"pdf"}}
+	if got, want := opts.fileQuery(), "(starred = true) and (mimeType = 'application/pdf')"; got != want {
+		t.Errorf("fileQuery() = %q, want %q", got, want)
+	}
+}
+
+func TestValidateFileTypes(t *testing.T) {
+	if err := ValidateFileTypes([]string{"document", "IMAGE"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	err := ValidateFileTypes([]string{"movie"})
+	if err == nil || !strings.Contains(err.Error(), "audio, document, image, pdf, presentation, spreadsheet, video") {
+		t.Errorf("ValidateFileTypes(movie) error = %v, want the list of types", err)
+	}
+}
+
+func TestOfAnyType(t *testing.T) {
+	if !ofAnyType("video/mp4", []string{"pdf", "video"}) || !ofAnyType("application/pdf", []string{"pdf"}) {
+		t.Error("expected types to match")
+	}
+	if ofAnyType("audio/mpeg", []string{"video"}) {
+		t.Error("expected audio not to match video")
+	}
+	if !ofAnyType("text/plain", nil) {
+		t.Error("expected every file to match when no types are given")
+	}
+}
@@ -0,0 +1,416 @@
+package drive
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	pathpkg "path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+)
+
+// matcher matches a file name only if every one of its patterns matches
+type matcher []*regexp.Regexp
+
+func (m matcher) MatchString(name string) bool {
+	for _, re := range m {
+		if !re.MatchString(name) {
+			return false
+		}
+	}
+	return true
+}
+
+// compileMatcher builds the matcher for the name pattern and extensions of opts
+func (d *DriveService) compileMatcher(opts ListOptions) (matcher, error) {
+	var m matcher
+	if opts.Pattern != "" {
+		regex, err := regexp.Compile(opts.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex pattern: %v", err)
+		}
+		m = append(m, regex)
+		d.log("Starting search with pattern: %s", opts.Pattern)
+	}
+	if len(opts.Extensions) > 0 {
+		extPattern, err := ExtensionPattern(opts.Extensions)
+		if err != nil {
+			return nil, err
+		}
+		m = append(m, regexp.MustCompile(extPattern))
+		d.log("Matching extensions: %s", strings.Join(opts.Extensions, ", "))
+	}
+	return m, nil
+}
+
+// What the name pattern and extensions of a search are matched against
+const (
+	// MatchName matches them against each file's name
+	MatchName = "name"
+
+	// MatchPath matches them against each file's path relative to the
+	// folder crawled, such as "Zoom Recordings/2025-04-01/call.TRANSCRIPT"
+	MatchPath = "path"
+)
+
+// ValidateMatchTarget checks a -match-target value
+func ValidateMatchTarget(target string) error {
+	switch target {
+	case "", MatchName, MatchPath:
+		return nil
+	}
+	return fmt.Errorf("invalid match target %q (expected %s or %s)", target, MatchName, MatchPath)
+}
+
+// matchSubject returns the text the pattern of opts is matched against for
+// a file with the given name and path
+func (o ListOptions) matchSubject(name, path string) string {
+	if o.MatchTarget == MatchPath {
+		return path
+	}
+	return name
+}
+
+// compileFolderPattern compiles the FolderPattern of a search, returning nil
+// when it has none
+func (d *DriveService) compileFolderPattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	regex, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid folder pattern: %v", err)
+	}
+	d.log("Matching folder names: %s", pattern)
+	return regex, nil
+}
+
+// underMatchingFolder reports whether any folder in a file's path matches
+// folderPattern, or true when there is no folder pattern
+func underMatchingFolder(folderPattern *regexp.Regexp, path string) bool {
+	if folderPattern == nil {
+		return true
+	}
+	dir := pathpkg.Dir(path)
+	if dir == "." {
+		return false
+	}
+	for _, name := range strings.Split(dir, "/") {
+		if folderPattern.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// ExtensionPattern builds a case-insensitive regex matching names that end
+// with any of the given extensions. A leading dot on an extension is optional.
+func ExtensionPattern(extensions []string) (string, error) {
+	var quoted []string
+	for _, ext := range extensions {
+		ext = strings.TrimPrefix(strings.TrimSpace(ext), ".")
+		if ext == "" {
+			continue
+		}
+		quoted = append(quoted, regexp.QuoteMeta(ext))
+	}
+	if len(quoted) == 0 {
+		return "", fmt.Errorf("no valid extensions provided")
+	}
+	return `(?i)\.(?:` + strings.Join(quoted, "|") + `)$`, nil
+}
+
+// LimitPerExtension keeps at most n files for each file extension, preserving
+// the order of files so the first ones in the current sort order win.
+// Extensions are compared case-insensitively; files without one share a group.
+func LimitPerExtension(files []FileInfo, n int) []FileInfo {
+	if n <= 0 {
+		return files
+	}
+
+	counts := make(map[string]int)
+	var limited []FileInfo
+	for _, f := range files {
+		ext := strings.ToLower(filepath.Ext(f.Name))
+		if counts[ext] >= n {
+			continue
+		}
+		counts[ext]++
+		limited = append(limited, f)
+	}
+	return limited
+}
+
+// LimitPerFolder keeps at most n files for each folder, grouping files by
+// the folder of their path and preserving the order of files so the first
+// ones in the current sort order win.
+func LimitPerFolder(files []FileInfo, n int) []FileInfo {
+	if n <= 0 {
+		return files
+	}
+
+	counts := make(map[string]int)
+	var limited []FileInfo
+	for _, f := range files {
+		dir := pathpkg.Dir(f.Path)
+		if counts[dir] >= n {
+			continue
+		}
+		counts[dir]++
+		limited = append(limited, f)
+	}
+	return limited
+}
+
+// ownerEmails returns the email addresses of the given owners
+func ownerEmails(owners []*drive.User) []string {
+	var emails []string
+	for _, owner := range owners {
+		if owner.EmailAddress != "" {
+			emails = append(emails, owner.EmailAddress)
+		}
+	}
+	return emails
+}
+
+// ownedByAny reports whether any of the owners has one of the wanted email
+// addresses, compared case-insensitively
+func ownedByAny(owners []*drive.User, wanted []string) bool {
+	for _, owner := range owners {
+		for _, email := range wanted {
+			if strings.EqualFold(owner.EmailAddress, email) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// modifierEmail returns the email address of a file's last modifier, or ""
+// when Drive doesn't report one, as for some anonymous or deleted users
+func modifierEmail(user *drive.User) string {
+	if user == nil {
+		return ""
+	}
+	return user.EmailAddress
+}
+
+// modifiedByAny reports whether the last modifier has one of the wanted email
+// addresses, compared case-insensitively
+func modifiedByAny(user *drive.User, wanted []string) bool {
+	email := modifierEmail(user)
+	if email == "" {
+		return false
+	}
+	for _, w := range wanted {
+		if strings.EqualFold(email, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// orUnknown returns s, or "unknown" when it is empty
+func orUnknown(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return s
+}
+
+// ownersQuery builds a Drive query clause matching files owned by any of the emails
+func ownersQuery(emails []string) string {
+	var clauses []string
+	for _, email := range emails {
+		clauses = append(clauses, fmt.Sprintf("'%s' in owners", escapeQuery(email)))
+	}
+	return "(" + strings.Join(clauses, " or ") + ")"
+}
+
+// escapeQuery escapes a value for use inside a single-quoted Drive query string
+func escapeQuery(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	return strings.ReplaceAll(value, `'`, `\'`)
+}
+
+// ErrInvalidQuery is returned when Drive rejects the query built from
+// ListOptions.Query and the other filters
+var ErrInvalidQuery = errors.New("invalid query")
+
+// ValidateQuery catches obviously malformed Drive queries, such as
+// unbalanced quotes or parentheses, before they are sent to the API
+func ValidateQuery(query string) error {
+	depth := 0
+	var quote rune
+	escaped := false
+	for _, r := range query {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+			if depth < 0 {
+				return fmt.Errorf("invalid query %q: unexpected )", query)
+			}
+		}
+	}
+
+	if quote != 0 {
+		return fmt.Errorf("invalid query %q: unterminated %c quote", query, quote)
+	}
+	if depth != 0 {
+		return fmt.Errorf("invalid query %q: unbalanced parentheses", query)
+	}
+	fields := strings.Fields(strings.ToLower(query))
+	if len(fields) == 0 {
+		return nil
+	}
+	if first := fields[0]; first == "and" || first == "or" {
+		return fmt.Errorf("invalid query %q: dangling %s", query, first)
+	}
+	if last := fields[len(fields)-1]; last == "and" || last == "or" || last == "not" {
+		return fmt.Errorf("invalid query %q: dangling %s", query, last)
+	}
+	return nil
+}
+
+// ParseTimeBound parses a -created-after or -created-before value, given as
+// an RFC 3339 timestamp or a date (midnight UTC)
+func ParseTimeBound(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q (expected a date like 2025-04-01 or an RFC 3339 timestamp)", value)
+	}
+	return t, nil
+}
+
+// ParseSince parses a -since value, a Go duration such as 36h or a number of
+// days or weeks such as 7d or 2w, and returns the time that long before now
+func ParseSince(value string, now time.Time) (time.Time, error) {
+	var unit time.Duration
+	switch {
+	case strings.HasSuffix(value, "d"):
+		unit = 24 * time.Hour
+	case strings.HasSuffix(value, "w"):
+		unit = 7 * 24 * time.Hour
+	}
+	var d time.Duration
+	var err error
+	if unit != 0 {
+		var n int
+		n, err = strconv.Atoi(value[:len(value)-1])
+		d = time.Duration(n) * unit
+	} else {
+		d, err = time.ParseDuration(value)
+	}
+	if err != nil || d <= 0 {
+		return time.Time{}, fmt.Errorf("invalid duration %q (expected a positive duration like 24h, 7d or 2w)", value)
+	}
+	return now.Add(-d), nil
+}
+
+// checkQuery makes sure Drive accepts a custom query, combined with the
+// other filters of opts, with a single one-file search before a crawl relies
+// on it, so a bad field or operator fails fast with Drive's explanation
+// rather than partway through the crawl
+func (d *DriveService) checkQuery(opts ListOptions) error {
+	if opts.Query == "" {
+		return nil
+	}
+	query := opts.fileQuery()
+	err := d.retryDo("Checking the query", func() error {
+		_, err := d.service.Files.List().
+			Q(query).
+			Fields("files(id)").
+			IncludeItemsFromAllDrives(true).
+			SupportsAllDrives(true).
+			PageSize(1).
+			Context(d.requestContext()).
+			Do()
+		return err
+	})
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusBadRequest {
+		return fmt.Errorf("%w: Drive rejected %q: %s", ErrInvalidQuery, query, apiErr.Message)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to check the query: %w", err)
+	}
+	return nil
+}
+
+// fileQuery returns the Drive query that matching files must satisfy, on top
+// of the folder they are listed from
+func (o ListOptions) fileQuery() string {
+	var clauses []string
+	if o.Query != "" {
+		clauses = append(clauses, o.Query)
+	}
+	if !o.CreatedAfter.IsZero() {
+		clauses = append(clauses, fmt.Sprintf("createdTime > '%s'", o.CreatedAfter.UTC().Format(time.RFC3339)))
+	}
+	if !o.CreatedBefore.IsZero() {
+		clauses = append(clauses, fmt.Sprintf("createdTime < '%s'", o.CreatedBefore.UTC().Format(time.RFC3339)))
+	}
+	if !o.ModifiedAfter.IsZero() {
+		clauses = append(clauses, fmt.Sprintf("modifiedTime > '%s'", o.ModifiedAfter.UTC().Format(time.RFC3339)))
+	}
+	if o.OwnedByMe {
+		clauses = append(clauses, "'me' in owners")
+	}
+	if len(o.Types) > 0 {
+		clauses = append(clauses, typesQuery(o.Types))
+	}
+	for _, label := range o.Labels {
+		clauses = append(clauses, label.query())
+	}
+	if len(clauses) > 1 && o.Query != "" {
+		clauses[0] = "(" + o.Query + ")"
+	}
+	return strings.Join(clauses, " and ")
+}
+
+// createdInRange reports whether a file created at createdAt passes the
+// CreatedAfter and CreatedBefore bounds. Files without a known creation time
+// only pass when no bound is set.
+func (o ListOptions) createdInRange(createdAt time.Time) bool {
+	if o.CreatedAfter.IsZero() && o.CreatedBefore.IsZero() {
+		return true
+	}
+	if createdAt.IsZero() {
+		return false
+	}
+	if !o.CreatedAfter.IsZero() && !createdAt.After(o.CreatedAfter) {
+		return false
+	}
+	if !o.CreatedBefore.IsZero() && !createdAt.Before(o.CreatedBefore) {
+		return false
+	}
+	return true
+}
+
+// modifiedInRange reports whether a file modified at modifiedAt passes the
+// ModifiedAfter bound. Files without a known modification time only pass
+// when it isn't set.
+func (o ListOptions) modifiedInRange(modifiedAt time.Time) bool {
+	return o.ModifiedAfter.IsZero() || modifiedAt.After(o.ModifiedAfter)
+}
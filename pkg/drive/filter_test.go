@@ -0,0 +1,293 @@
+package drive
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+)
+
+func TestExtensionPattern(t *testing.T) {
+	tests := []struct {
+		name       string
+		extensions []string
+		input      string
+		want       bool
+		wantErr    bool
+	}{
+		{
+			name:       "exact extension",
+			extensions: []string{"TRANSCRIPT"},
+			input:      "audio_transcript.TRANSCRIPT",
+			want:       true,
+		},
+		{
+			name:       "case insensitive",
+			extensions: []string{"mp4"},
+			input:      "recording.MP4",
+			want:       true,
+		},
+		{
+			name:       "leading dot is optional",
+			extensions: []string{".m4a", "mp4"},
+			input:      "audio.m4a",
+			want:       true,
+		},
+		{
+			name:       "suffix without dot does not match",
+			extensions: []string{"mp4"},
+			input:      "notmp4",
+			want:       false,
+		},
+		{
+			name:       "extension in the middle does not match",
+			extensions: []string{"mp4"},
+			input:      "video.mp4.txt",
+			want:       false,
+		},
+		{
+			name:       "special characters are literal",
+			extensions: []string{"c++"},
+			input:      "main.c++",
+			want:       true,
+		},
+		{
+			name:       "only empty extensions",
+			extensions: []string{"", " . "},
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pattern, err := ExtensionPattern(tt.extensions)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := regexp.MustCompile(pattern).MatchString(tt.input); got != tt.want {
+				t.Errorf("match(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatcher(t *testing.T) {
+	m := matcher{
+		regexp.MustCompile(`^meeting`),
+		regexp.MustCompile(`(?i)\.(?:mp4)$`),
+	}
+	if !m.MatchString("meeting.mp4") {
+		t.Error("expected name matching all patterns to match")
+	}
+	if m.MatchString("meeting.txt") {
+		t.Error("expected name failing one pattern not to match")
+	}
+	if !(matcher{}).MatchString("anything") {
+		t.Error("expected empty matcher to match everything")
+	}
+}
+
+func TestLimitPerExtension(t *testing.T) {
+	files := []FileInfo{
+		{ID: "1", Name: "a.mp4"},
+		{ID: "2", Name: "b.TRANSCRIPT"},
+		{ID: "3", Name: "c.MP4"},
+		{ID: "4", Name: "d.mp4"},
+		{ID: "5", Name: "README"},
+		{ID: "6", Name: "e.transcript"},
+		{ID: "7", Name: "LICENSE"},
+		{ID: "8", Name: "f.transcript"},
+	}
+
+	var got []string
+	for _, f := range LimitPerExtension(files, 2) {
+		got = append(got, f.ID)
+	}
+	want := []string{"1", "2", "3", "5", "6", "7"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LimitPerExtension() = %v, want %v", got, want)
+	}
+
+	if len(LimitPerExtension(files, 0)) != len(files) {
+		t.Error("expected n <= 0 to keep all files")
+	}
+}
+
+func TestOwnedByAny(t *testing.T) {
+	owners := []*drive.User{{EmailAddress: "alice@example.com"}, {EmailAddress: "bob@example.com"}}
+
+	if !ownedByAny(owners, []string{"carol@example.com", "Bob@Example.com"}) {
+		t.Error("expected case-insensitive match on second owner")
+	}
+	if ownedByAny(owners, []string{"carol@example.com"}) {
+		t.Error("expected no match for unrelated owner")
+	}
+	if ownedByAny(nil, []string{"alice@example.com"}) {
+		t.Error("expected no match for file without owners")
+	}
+}
+
+func TestModifiedByAny(t *testing.T) {
+	user := &drive.User{EmailAddress: "alice@example.com"}
+
+	if !modifiedByAny(user, []string{"bob@example.com", "Alice@Example.com"}) {
+		t.Error("expected case-insensitive match")
+	}
+	if modifiedByAny(user, []string{"bob@example.com"}) {
+		t.Error("expected no match for another user")
+	}
+	if modifiedByAny(nil, []string{"alice@example.com"}) || modifiedByAny(&drive.User{}, []string{""}) {
+		t.Error("expected no match for file without a last modifier")
+	}
+}
+
+func TestOwnersQuery(t *testing.T) {
+	got := ownersQuery([]string{"alice@example.com", "o'brien@example.com"})
+	want := `('alice@example.com' in owners or 'o\'brien@example.com' in owners)`
+	if got != want {
+		t.Errorf("ownersQuery() = %s, want %s", got, want)
+	}
+}
+
+func TestValidateQuery(t *testing.T) {
+	tests := []struct {
+		query   string
+		wantErr bool
+	}{
+		{query: ""},
+		{query: "mimeType='application/pdf' and modifiedTime > '2024-01-01'"},
+		{query: "name contains 'it\\'s' and (starred = true or trashed = false)"},
+		{query: "not name contains 'draft'"},
+		{query: "name contains 'draft", wantErr: true},
+		{query: "(starred = true", wantErr: true},
+		{query: "starred = true)", wantErr: true},
+		{query: "starred = true and", wantErr: true},
+		{query: "or starred = true", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			err := ValidateQuery(tt.query)
+			if tt.wantErr && err == nil {
+				t.Error("expected error, got nil")
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestCreatedTimeFilters(t *testing.T) {
+	after, err := ParseTimeBound("2025-02-01")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	before, err := ParseTimeBound("2025-03-01T12:00:00+02:00")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := ParseTimeBound("last week"); err == nil {
+		t.Error("expected error for unparsable time")
+	}
+
+	opts := ListOptions{Query: "starred = true", CreatedAfter: after, CreatedBefore: before}
+	want := "(starred = true) and createdTime > '2025-02-01T00:00:00Z' and createdTime < '2025-03-01T10:00:00Z'"
+	if got := opts.fileQuery(); got != want {
+		t.Errorf("fileQuery() = %q, want %q", got, want)
+	}
+	if got := (ListOptions{Query: "starred = true"}).fileQuery(); got != "starred = true" {
+		t.Errorf("fileQuery() = %q, want the query unchanged", got)
+	}
+
+	tests := []struct {
+		created string
+		want    bool
+	}{
+		{created: "2025-01-15T00:00:00Z", want: false},
+		{created: "2025-02-01T00:00:00Z", want: false},
+		{created: "2025-02-15T00:00:00Z", want: true},
+		{created: "2025-03-01T11:00:00Z", want: false},
+		{created: "", want: false},
+	}
+	for _, tt := range tests {
+		var createdAt time.Time
+		if tt.created != "" {
+			createdAt = rfc3339(tt.created)
+		}
+		if got := opts.createdInRange(createdAt); got != tt.want {
+			t.Errorf("createdInRange(%q) = %v, want %v", tt.created, got, tt.want)
+		}
+	}
+	if !(ListOptions{}).createdInRange(time.Time{}) {
+		t.Error("files without a creation time must pass when no bound is set")
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	now := rfc3339("2025-04-15T12:00:00Z")
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "24h", want: "2025-04-14T12:00:00Z"},
+		{value: "90m", want: "2025-04-15T10:30:00Z"},
+		{value: "7d", want: "2025-04-08T12:00:00Z"},
+		{value: "2w", want: "2025-04-01T12:00:00Z"},
+	}
+	for _, tt := range tests {
+		got, err := ParseSince(tt.value, now)
+		if err != nil {
+			t.Errorf("ParseSince(%q): unexpected error: %v", tt.value, err)
+			continue
+		}
+		if !got.Equal(rfc3339(tt.want)) {
+			t.Errorf("ParseSince(%q) = %s, want %s", tt.value, got.Format(time.RFC3339), tt.want)
+		}
+	}
+	for _, value := range []string{"", "d", "7", "-2d", "0h", "1.5d", "yesterday"} {
+		if _, err := ParseSince(value, now); err == nil {
+			t.Errorf("ParseSince(%q): expected error", value)
+		}
+	}
+
+	opts := ListOptions{ModifiedAfter: rfc3339("2025-04-08T12:00:00Z")}
+	if got, want := opts.fileQuery(), "modifiedTime > '2025-04-08T12:00:00Z'"; got != want {
+		t.Errorf("fileQuery() = %q, want %q", got, want)
+	}
+	if opts.modifiedInRange(rfc3339("2025-04-08T12:00:00Z")) || !opts.modifiedInRange(rfc3339("2025-04-09T00:00:00Z")) {
+		t.Error("modifiedInRange must only pass files modified after the bound")
+	}
+}
+
+func TestListFilesRejectedQuery(t *testing.T) {
+	fake := newFakeDrive()
+	fake.addFile("a", "a.txt", "root", "2025-04-01T00:00:00Z", "a")
+	d := newTestService(t, fake)
+
+	fake.failures["files"] = []int{http.StatusBadRequest}
+	_, err := d.ListFiles(ListOptions{FolderIDs: []string{"root"}, Query: "colour = 'red'", MaxDepth: -1})
+	if !errors.Is(err, ErrInvalidQuery) || !strings.Contains(err.Error(), `"colour = 'red'"`) {
+		t.Errorf("error = %v, want ErrInvalidQuery naming the query", err)
+	}
+
+	// Once Drive accepts it, the crawl goes ahead
+	files, err := d.ListFiles(ListOptions{FolderIDs: []string{"root"}, Query: "starred = false", MaxDepth: -1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(files) != 1 {
+		t.Errorf("found %d files, want 1", len(files))
+	}
+}
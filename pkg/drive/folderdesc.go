@@ -0,0 +1,66 @@
+package drive
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// folderDescriptionFile names the file a folder's description is saved to
+const folderDescriptionFile = ".folder-description.txt"
+
+// writeFolderDescription saves the description of the Drive folder holding
+// a downloaded file into the local directory the file was saved to, once per
+// directory. Folders without a description are skipped, and failing to save
+// one is only a warning.
+func (r *downloadRun) writeFolderDescription(file FileInfo) {
+	if file.ParentID == "" {
+		return
+	}
+	outPath, err := r.opts.OutputPath(file)
+	if err != nil {
+		return
+	}
+	dir := filepath.Dir(outPath)
+	if r.describedDirs[dir] {
+		return
+	}
+	r.describedDirs[dir] = true
+
+	description, ok := r.descriptions[file.ParentID]
+	if !ok {
+		description, err = r.d.folderDescription(file.ParentID)
+		if err == nil {
+			r.descriptions[file.ParentID] = description
+		}
+	}
+	if err == nil && description != "" {
+		path := filepath.Join(dir, folderDescriptionFile)
+		r.d.log("  Writing folder description: %s", path)
+		err = r.d.writeFile(path, strings.NewReader(description), false)
+	}
+	if err != nil {
+		r.d.printf("⚠️ Unable to save the folder description for %s: %v\n", file.Path, err)
+		r.report.Warnings = append(r.report.Warnings, FileFailure{File: file, Err: err})
+	}
+}
+
+// folderDescription fetches the description of a folder
+func (d *DriveService) folderDescription(id string) (string, error) {
+	var description string
+	err := d.retryDo("Looking up a folder description", func() error {
+		folder, err := d.service.Files.Get(id).
+			Fields("description").
+			SupportsAllDrives(true).
+			Context(d.requestContext()).
+			Do()
+		if err == nil {
+			description = folder.Description
+		}
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("unable to look up the folder description: %v", err)
+	}
+	return description, nil
+}
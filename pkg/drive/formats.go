@@ -0,0 +1,51 @@
+package drive
+
+import (
+	"fmt"
+	"sort"
+)
+
+// FormatConversion lists the MIME types Drive converts a type to
+type FormatConversion struct {
+	Source  string   `json:"source"`
+	Targets []string `json:"targets"`
+}
+
+// Formats lists the conversions Drive offers for Google Docs editors files
+type Formats struct {
+	// Export lists the types each Google Docs editors type exports to
+	Export []FormatConversion `json:"export"`
+	// Import lists the Google Docs editors types each type can be
+	// converted to when uploaded
+	Import []FormatConversion `json:"import"`
+}
+
+// Formats returns the export and import formats reported by Drive, each
+// sorted by source type
+func (d *DriveService) Formats() (*Formats, error) {
+	var exports, imports map[string][]string
+	err := d.retryDo("Looking up export formats", func() error {
+		about, err := d.service.About.Get().Fields("importFormats, exportFormats").Context(d.requestContext()).Do()
+		if err == nil {
+			exports, imports = about.ExportFormats, about.ImportFormats
+		}
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get export formats: %v", err)
+	}
+	return &Formats{Export: sortedConversions(exports), Import: sortedConversions(imports)}, nil
+}
+
+// sortedConversions converts a map of source types to target types into
+// conversions sorted by source and target
+func sortedConversions(formats map[string][]string) []FormatConversion {
+	result := []FormatConversion{}
+	for source, targets := range formats {
+		targets = append([]string(nil), targets...)
+		sort.Strings(targets)
+		result = append(result, FormatConversion{Source: source, Targets: targets})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Source < result[j].Source })
+	return result
+}
@@ -0,0 +1,28 @@
+package drive
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFormats(t *testing.T) {
+	d := newTestService(t, newFakeDrive())
+
+	formats, err := d.Formats()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := &Formats{
+		Export: []FormatConversion{
+			{Source: "application/vnd.google-apps.document", Targets: []string{"application/pdf", "text/plain"}},
+			{Source: "application/vnd.google-apps.spreadsheet", Targets: []string{"application/pdf", "text/csv"}},
+		},
+		Import: []FormatConversion{
+			{Source: "text/csv", Targets: []string{"application/vnd.google-apps.spreadsheet"}},
+			{Source: "text/plain", Targets: []string{"application/vnd.google-apps.document"}},
+		},
+	}
+	if !reflect.DeepEqual(formats, want) {
+		t.Errorf("Formats() = %+v, want %+v", formats, want)
+	}
+}
@@ -0,0 +1,88 @@
+package drive
+
+import (
+	"strings"
+)
+
+// CrawlHooks are called as ListFiles and WalkFiles crawl Drive. Any of them
+// may be nil. They run on the crawling goroutine, so a slow hook slows the
+// crawl down.
+type CrawlHooks struct {
+	// OnEnterFolder is called before a folder's contents are listed, with its
+	// path ("" for a crawl root) and depth
+	OnEnterFolder func(path string, depth int)
+
+	// OnLeaveFolder is called once a folder entered has been crawled, even if
+	// the crawl stopped or failed part way through it
+	OnLeaveFolder func(path string, depth int)
+
+	// OnMatch is called for each file, or folder with MatchFolders, that
+	// passes every filter and is kept, with the depth of the folder it was
+	// found in. Duplicates and files over a streaming crawl's limits are not
+	// reported.
+	OnMatch func(file FileInfo, depth int)
+}
+
+// WithCrawlHooks sets the hooks called while crawling, in addition to the
+// verbose log
+func (d *DriveService) WithCrawlHooks(hooks CrawlHooks) *DriveService {
+	d.hooks = hooks
+	return d
+}
+
+// logHooks write the verbose crawl log
+func (d *DriveService) logHooks() CrawlHooks {
+	return CrawlHooks{
+		OnEnterFolder: func(path string, depth int) {
+			d.log("%s📂 Entering directory: %s (depth: %d)", strings.Repeat("  ", depth), path, depth)
+		},
+		OnLeaveFolder: func(path string, depth int) {
+			d.log("%s📂 Leaving directory: %s", strings.Repeat("  ", depth), path)
+		},
+		OnMatch: func(file FileInfo, depth int) {
+			kind := "file"
+			if file.IsFolder {
+				kind = "folder"
+			}
+			d.log("%s  ✅ Found matching %s: %s (Modified: %s)", strings.Repeat("  ", depth), kind, file.Path, file.ModifiedTime)
+		},
+	}
+}
+
+// crawlHooks returns the hooks to call, the verbose log first
+func (d *DriveService) crawlHooks() []CrawlHooks {
+	if d.verbose {
+		return []CrawlHooks{d.logHooks(), d.hooks}
+	}
+	return []CrawlHooks{d.hooks}
+}
+
+func (d *DriveService) enterFolder(path string, depth int) {
+	for _, h := range d.crawlHooks() {
+		if h.OnEnterFolder != nil {
+			h.OnEnterFolder(path, depth)
+		}
+	}
+}
+
+func (d *DriveService) leaveFolder(path string, depth int) {
+	for _, h := range d.crawlHooks() {
+		if h.OnLeaveFolder != nil {
+			h.OnLeaveFolder(path, depth)
+		}
+	}
+}
+
+// match adds a file found at depth to the crawl's results and, if it was
+// kept, reports it
+func (d *DriveService) match(c *crawl, info FileInfo, depth int) {
+	if !c.add(info) {
+		return
+	}
+	for _, h := range d.crawlHooks() {
+		if h.OnMatch != nil {
+			h.OnMatch(info, depth)
+		}
+	}
+	d.counters.matches.Add(1)
+}
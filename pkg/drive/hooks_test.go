@@ -0,0 +1,78 @@
+package drive
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestCrawlHooks(t *testing.T) {
+	fake := newFakeDrive()
+	fake.addFolder("f1", "room-1", "root")
+	fake.addFile("a", "a.TRANSCRIPT", "f1", "2025-04-01T00:00:00Z", "a")
+	fake.addFile("b", "b.txt", "f1", "2025-04-01T00:00:00Z", "b")
+	fake.addFile("c", "c.TRANSCRIPT", "root", "2025-04-01T00:00:00Z", "c")
+	d := newTestService(t, fake)
+
+	var events []string
+	d.WithCrawlHooks(CrawlHooks{
+		OnEnterFolder: func(path string, depth int) { events = append(events, fmt.Sprintf("enter %q %d", path, depth)) },
+		OnLeaveFolder: func(path string, depth int) { events = append(events, fmt.Sprintf("leave %q %d", path, depth)) },
+		OnMatch:       func(file FileInfo, depth int) { events = append(events, fmt.Sprintf("match %s %d", file.Path, depth)) },
+	})
+
+	if _, err := d.ListFiles(ListOptions{Pattern: "TRANSCRIPT", MaxDepth: -1}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{
+		`enter "" 0`,
+		"match c.TRANSCRIPT 0",
+		`enter "room-1" 1`,
+		"match room-1/a.TRANSCRIPT 1",
+		`leave "room-1" 1`,
+		`leave "" 0`,
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("events = %q, want %q", events, want)
+	}
+	if got, want := d.CrawlStats(), (CrawlStats{Folders: 2, Files: 3, Matches: 2}); got != want {
+		t.Errorf("CrawlStats() = %+v, want %+v", got, want)
+	}
+
+	// Unset hooks are skipped
+	d.WithCrawlHooks(CrawlHooks{})
+	if _, err := d.ListFiles(ListOptions{Pattern: "TRANSCRIPT", MaxDepth: -1}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestCrawlHooksSkipDroppedFiles(t *testing.T) {
+	fake := newFakeDrive()
+	fake.addFolder("f1", "room-1", "root")
+	fake.addFile("a", "a.TRANSCRIPT", "f1", "2025-04-01T00:00:00Z", "a")
+	fake.addFile("b", "b.TRANSCRIPT", "f1", "2025-04-01T00:00:00Z", "b")
+	d := newTestService(t, fake)
+
+	var matched []string
+	d.WithCrawlHooks(CrawlHooks{
+		OnMatch: func(file FileInfo, depth int) { matched = append(matched, file.Path) },
+	})
+
+	// Only the first file is kept for its extension
+	done := make(chan struct{})
+	defer close(done)
+	files, errc := d.WalkFiles(ListOptions{FolderIDs: []string{"f1"}, Pattern: "TRANSCRIPT", MaxDepth: -1, MaxPerExtension: 1}, done)
+	var streamed []string
+	for file := range files {
+		streamed = append(streamed, file.Path)
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(streamed) != 1 || !reflect.DeepEqual(matched, streamed) {
+		t.Errorf("matched %q, want only the streamed %q", matched, streamed)
+	}
+	if got := d.CrawlStats().Matches; got != 1 {
+		t.Errorf("CrawlStats().Matches = %d, want 1", got)
+	}
+}
@@ -0,0 +1,76 @@
+package drive
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrIteratorDone is returned by FileIterator.Next once every file has been
+// returned, or the iterator was closed
+var ErrIteratorDone = errors.New("no more files")
+
+// FileIterator steps through matching files as they are found, as an
+// alternative to the channels of WalkFiles:
+//
+//	it := d.Iterate(opts)
+//	defer it.Close()
+//	for {
+//		file, err := it.Next()
+//		if errors.Is(err, drive.ErrIteratorDone) {
+//			break
+//		}
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+//
+// The same ordering and limits as WalkFiles apply. A FileIterator must not
+// be used from several goroutines at once.
+type FileIterator struct {
+	files <-chan FileInfo
+	errc  <-chan error
+	done  chan struct{}
+	once  sync.Once
+	err   error
+}
+
+// Iterate starts a crawl for the files matching opts and returns an
+// iterator over them. The crawl only runs ahead of Next by a single file.
+// Close must be called unless Next has returned an error.
+func (d *DriveService) Iterate(opts ListOptions) *FileIterator {
+	done := make(chan struct{})
+	files, errc := d.WalkFiles(opts, done)
+	return &FileIterator{files: files, errc: errc, done: done}
+}
+
+// Next returns the next matching file. It returns ErrIteratorDone after the
+// last file, or the error that stopped the crawl; either is returned again
+// by every later call.
+func (it *FileIterator) Next() (FileInfo, error) {
+	if it.err != nil {
+		return FileInfo{}, it.err
+	}
+	if file, ok := <-it.files; ok {
+		return file, nil
+	}
+	it.err = <-it.errc
+	if it.err == nil {
+		it.err = ErrIteratorDone
+	}
+	return FileInfo{}, it.err
+}
+
+// Close stops the crawl and waits for it to end. It is safe to call more
+// than once, and after Next has returned an error.
+func (it *FileIterator) Close() {
+	it.once.Do(func() {
+		close(it.done)
+		for range it.files {
+		}
+		<-it.errc
+		if it.err == nil {
+			it.err = ErrIteratorDone
+		}
+	})
+}
@@ -0,0 +1,53 @@
+package drive
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestFileIterator(t *testing.T) {
+	fake := newFakeDrive()
+	fake.addFolder("f1", "sub", "root")
+	fake.addFile("a", "a.txt", "root", "2025-04-03T00:00:00Z", "a")
+	fake.addFile("b", "b.txt", "f1", "2025-04-02T00:00:00Z", "b")
+	fake.addFile("c", "c.txt", "f1", "2025-04-01T00:00:00Z", "c")
+	d := newTestService(t, fake)
+
+	it := d.Iterate(ListOptions{FolderIDs: []string{"root"}, MaxDepth: -1})
+	var ids []string
+	for {
+		file, err := it.Next()
+		if errors.Is(err, ErrIteratorDone) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		ids = append(ids, file.ID)
+	}
+	it.Close()
+	if got := strings.Join(ids, ","); got != "a,b,c" {
+		t.Errorf("iterated %s, want a,b,c", got)
+	}
+
+	// Closing early stops the crawl, after which Next is done
+	it = d.Iterate(ListOptions{FolderIDs: []string{"root"}, MaxDepth: -1})
+	if _, err := it.Next(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	it.Close()
+	it.Close()
+	if _, err := it.Next(); !errors.Is(err, ErrIteratorDone) {
+		t.Errorf("Next after Close = %v, want ErrIteratorDone", err)
+	}
+
+	// Crawl failures are returned by Next
+	fake.failures["files"] = []int{http.StatusNotFound}
+	it = d.Iterate(ListOptions{FolderIDs: []string{"root"}, MaxDepth: -1})
+	defer it.Close()
+	if _, err := it.Next(); err == nil || errors.Is(err, ErrIteratorDone) {
+		t.Errorf("Next = %v, want the crawl error", err)
+	}
+}
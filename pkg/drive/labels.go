@@ -0,0 +1,187 @@
+package drive
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+)
+
+// ErrLabelsUnsupported is returned when a search filters on Drive labels
+// but Drive rejects label queries, as for accounts outside Google Workspace
+var ErrLabelsUnsupported = errors.New("labels not supported")
+
+// LabelFilter selects files carrying a Drive label, and when FieldID is set,
+// whose field of that label holds Value
+type LabelFilter struct {
+	LabelID string
+	FieldID string
+	Value   string
+}
+
+// FileLabel is a Drive label applied to a file, with the values of its
+// fields by field ID
+type FileLabel struct {
+	ID     string              `json:"id"`
+	Fields map[string][]string `json:"fields,omitempty"`
+}
+
+// labelIDPattern matches label and field IDs, which Drive generates
+var labelIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ParseLabelFilter parses a -label value: a label ID, or
+// labelId.fieldId=value to also require a field value
+func ParseLabelFilter(spec string) (LabelFilter, error) {
+	ids, value, hasValue := strings.Cut(spec, "=")
+	labelID, fieldID, hasField := strings.Cut(ids, ".")
+	if hasValue != hasField {
+		return LabelFilter{}, fmt.Errorf("invalid label %q (expected labelId or labelId.fieldId=value)", spec)
+	}
+	if !labelIDPattern.MatchString(labelID) || (hasField && !labelIDPattern.MatchString(fieldID)) {
+		return LabelFilter{}, fmt.Errorf("invalid label %q: label and field IDs may only contain letters, digits, '-' and '_'", spec)
+	}
+	return LabelFilter{LabelID: labelID, FieldID: fieldID, Value: value}, nil
+}
+
+func (l LabelFilter) String() string {
+	if l.FieldID == "" {
+		return l.LabelID
+	}
+	return l.LabelID + "." + l.FieldID + "=" + l.Value
+}
+
+// query returns the Drive query clause matching files the filter selects
+func (l LabelFilter) query() string {
+	if l.FieldID == "" {
+		return fmt.Sprintf("'labels/%s' in labels", l.LabelID)
+	}
+	return fmt.Sprintf("labels/%s.%s = '%s'", l.LabelID, l.FieldID, escapeQuery(l.Value))
+}
+
+// matches reports whether a file with the given labels is selected
+func (l LabelFilter) matches(labels []FileLabel) bool {
+	for _, label := range labels {
+		if label.ID != l.LabelID {
+			continue
+		}
+		if l.FieldID == "" {
+			return true
+		}
+		for _, value := range label.Fields[l.FieldID] {
+			if value == l.Value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasLabels reports whether a file with the given labels passes every filter
+func hasLabels(labels []FileLabel, filters []LabelFilter) bool {
+	for _, filter := range filters {
+		if !filter.matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// labelIDs returns the distinct label IDs of the filters, for includeLabels
+func labelIDs(filters []LabelFilter) string {
+	var ids []string
+	seen := make(map[string]bool)
+	for _, filter := range filters {
+		if !seen[filter.LabelID] {
+			seen[filter.LabelID] = true
+			ids = append(ids, filter.LabelID)
+		}
+	}
+	return strings.Join(ids, ",")
+}
+
+// newFileLabels converts the labels Drive returns for a file, with every
+// field value as a string
+func newFileLabels(info *drive.FileLabelInfo) []FileLabel {
+	if info == nil {
+		return nil
+	}
+	var labels []FileLabel
+	for _, l := range info.Labels {
+		label := FileLabel{ID: l.Id}
+		for id, field := range l.Fields {
+			var values []string
+			values = append(values, field.Text...)
+			values = append(values, field.Selection...)
+			values = append(values, field.DateString...)
+			for _, n := range field.Integer {
+				values = append(values, strconv.FormatInt(n, 10))
+			}
+			for _, user := range field.User {
+				values = append(values, user.EmailAddress)
+			}
+			if label.Fields == nil {
+				label.Fields = make(map[string][]string)
+			}
+			label.Fields[id] = values
+		}
+		labels = append(labels, label)
+	}
+	return labels
+}
+
+// formatLabels describes labels for the verbose log
+func formatLabels(labels []FileLabel) string {
+	var parts []string
+	for _, label := range labels {
+		var fields []string
+		for id, values := range label.Fields {
+			fields = append(fields, id+"="+strings.Join(values, "|"))
+		}
+		sort.Strings(fields)
+		if len(fields) == 0 {
+			parts = append(parts, label.ID)
+			continue
+		}
+		parts = append(parts, label.ID+"("+strings.Join(fields, ", ")+")")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// checkLabels makes sure Drive accepts the label filters of opts before a
+// crawl relies on them, so an account without labels gets a clear error
+// rather than a failure deep in the crawl
+func (d *DriveService) checkLabels(opts ListOptions) error {
+	if len(opts.Labels) == 0 {
+		return nil
+	}
+	var clauses []string
+	for _, filter := range opts.Labels {
+		clauses = append(clauses, filter.query())
+	}
+	err := d.retryDo("Checking label support", func() error {
+		_, err := d.service.Files.List().
+			Q(strings.Join(clauses, " and ")).
+			Fields("files(id)").
+			IncludeLabels(labelIDs(opts.Labels)).
+			IncludeItemsFromAllDrives(true).
+			SupportsAllDrives(true).
+			PageSize(1).
+			Context(d.requestContext()).
+			Do()
+		return err
+	})
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && (apiErr.Code == http.StatusBadRequest || apiErr.Code == http.StatusForbidden) {
+		return fmt.Errorf("%w: Drive rejected the label search (%v); labels need a Google Workspace edition with Drive labels, and the label and field IDs must exist", ErrLabelsUnsupported, err)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to check label support: %w", err)
+	}
+	return nil
+}
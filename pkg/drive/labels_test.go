@@ -0,0 +1,93 @@
+package drive
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/api/drive/v3"
+)
+
+func TestParseLabelFilter(t *testing.T) {
+	tests := map[string]LabelFilter{
+		"abc123":            {LabelID: "abc123"},
+		"abc123.f1=Meeting": {LabelID: "abc123", FieldID: "f1", Value: "Meeting"},
+		"abc123.f1=":        {LabelID: "abc123", FieldID: "f1"},
+		"abc123.f1=a=b c'd": {LabelID: "abc123", FieldID: "f1", Value: "a=b c'd"},
+	}
+	for spec, want := range tests {
+		got, err := ParseLabelFilter(spec)
+		if err != nil || got != want {
+			t.Errorf("ParseLabelFilter(%q) = %+v, %v, want %+v", spec, got, err, want)
+		}
+	}
+	for _, spec := range []string{"", "abc=value", "abc.f1", ".f1=x", "abc.=x", "a'b", "abc.f 1=x"} {
+		if _, err := ParseLabelFilter(spec); err == nil {
+			t.Errorf("ParseLabelFilter(%q) succeeded, want error", spec)
+		}
+	}
+}
+
+func TestLabelQuery(t *testing.T) {
+	opts := ListOptions{Labels: []LabelFilter{{LabelID: "abc"}, {LabelID: "abc", FieldID: "f1", Value: "o'brien"}}}
+	want := `'labels/abc' in labels and labels/abc.f1 = 'o\'brien'`
+	if got := opts.fileQuery(); got != want {
+		t.Errorf("fileQuery() = %q, want %q", got, want)
+	}
+	if got := labelIDs(opts.Labels); got != "abc" {
+		t.Errorf("labelIDs() = %q, want abc", got)
+	}
+}
+
+func TestNewFileLabels(t *testing.T) {
+	labels := newFileLabels(&drive.FileLabelInfo{Labels: []*drive.Label{{
+		Id: "abc",
+		Fields: map[string]drive.LabelField{
+			"kind":  {Selection: []string{"choice1"}},
+			"count": {Integer: []int64{3}},
+			"owner": {User: []*drive.User{{EmailAddress: "alice@example.com"}}},
+		},
+	}}})
+	want := []FileLabel{{ID: "abc", Fields: map[string][]string{
+		"kind":  {"choice1"},
+		"count": {"3"},
+		"owner": {"alice@example.com"},
+	}}}
+	if !reflect.DeepEqual(labels, want) {
+		t.Errorf("newFileLabels() = %+v, want %+v", labels, want)
+	}
+	if got := formatLabels(labels); got != "abc(count=3, kind=choice1, owner=alice@example.com)" {
+		t.Errorf("formatLabels() = %q", got)
+	}
+
+	if !hasLabels(labels, []LabelFilter{{LabelID: "abc"}, {LabelID: "abc", FieldID: "kind", Value: "choice1"}}) {
+		t.Error("expected labels to match")
+	}
+	if hasLabels(labels, []LabelFilter{{LabelID: "abc", FieldID: "kind", Value: "choice2"}}) || hasLabels(nil, []LabelFilter{{LabelID: "abc"}}) {
+		t.Error("expected labels not to match")
+	}
+}
+
+func TestListFilesLabels(t *testing.T) {
+	fake := newFakeDrive()
+	fake.addFile("a", "a.txt", "root", "2025-04-01T00:00:00Z", "a")
+	fake.addFile("b", "b.txt", "root", "2025-04-01T00:00:00Z", "b")
+	fake.files["a"].LabelInfo = &drive.FileLabelInfo{Labels: []*drive.Label{{Id: "abc"}}}
+	d := newTestService(t, fake)
+
+	files, err := d.ListFiles(ListOptions{FolderIDs: []string{"root"}, MaxDepth: -1, Labels: []LabelFilter{{LabelID: "abc"}}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := strings.Join(paths(files), ","); got != "a.txt" {
+		t.Errorf("ListFiles() = %v, want a.txt", got)
+	}
+
+	fake.failures["files"] = []int{http.StatusBadRequest}
+	_, err = d.ListFiles(ListOptions{FolderIDs: []string{"root"}, MaxDepth: -1, Labels: []LabelFilter{{LabelID: "abc"}}})
+	if !errors.Is(err, ErrLabelsUnsupported) {
+		t.Errorf("error = %v, want ErrLabelsUnsupported", err)
+	}
+}
@@ -0,0 +1,43 @@
+package drive
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// manifestHeader lists the columns written by WriteManifest
+var manifestHeader = []string{"id", "path", "webContentLink", "md5", "size"}
+
+// WriteManifest writes a CSV manifest of files to w, one row per file with
+// the columns in manifestHeader. Folders are skipped.
+func WriteManifest(w io.Writer, files []FileInfo) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(manifestHeader); err != nil {
+		return fmt.Errorf("unable to write manifest: %v", err)
+	}
+	for _, file := range files {
+		if file.IsFolder {
+			continue
+		}
+		row := []string{file.ID, file.Path, file.WebContentLink, file.MD5, manifestSize(file)}
+		if err := cw.Write(row); err != nil {
+			return fmt.Errorf("unable to write manifest: %v", err)
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("unable to write manifest: %v", err)
+	}
+	return nil
+}
+
+// manifestSize returns the size column of a file, empty when Drive reports
+// no size
+func manifestSize(file FileInfo) string {
+	if !file.HasSize() {
+		return ""
+	}
+	return strconv.FormatInt(file.Size, 10)
+}
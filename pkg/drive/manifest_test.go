@@ -0,0 +1,27 @@
+package drive
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteManifest(t *testing.T) {
+	files := []FileInfo{
+		{ID: "a", Path: "notes/a, b.txt", WebContentLink: "https://drive.google.com/uc?id=a&export=download", MD5: "abc", Size: 12},
+		{ID: "f", Path: "notes", IsFolder: true},
+		{ID: "d", Path: "Doc", MimeType: "application/vnd.google-apps.document"},
+	}
+
+	var b strings.Builder
+	if err := WriteManifest(&b, files); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := `id,path,webContentLink,md5,size
+a,"notes/a, b.txt",https://drive.google.com/uc?id=a&export=download,abc,12
+d,Doc,,,
+`
+	if b.String() != want {
+		t.Errorf("WriteManifest() =\n%s\nwant\n%s", b.String(), want)
+	}
+}
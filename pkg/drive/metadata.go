@@ -0,0 +1,36 @@
+package drive
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// metadataSuffix is appended to a file's local path to name its sidecar
+const metadataSuffix = ".meta.json"
+
+// MetadataPath returns the path of the metadata sidecar written next to a
+// downloaded file
+func (o DownloadOptions) MetadataPath(fileInfo FileInfo) (string, error) {
+	baseDir, err := o.BaseDir(fileInfo)
+	if err != nil {
+		return "", err
+	}
+	return localPath(baseDir, fileInfo.Path) + metadataSuffix, nil
+}
+
+// writeMetadata saves the file's Drive metadata as indented JSON in its
+// sidecar, leaving out opts.RedactFields
+func (d *DriveService) writeMetadata(fileInfo FileInfo, opts DownloadOptions) error {
+	metaPath, err := opts.MetadataPath(fileInfo)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(fileInfo.Redact(opts.RedactFields), "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to encode metadata: %v", err)
+	}
+	d.log("  Writing metadata: %s", metaPath)
+	return d.writeFile(metaPath, bytes.NewReader(append(data, '\n')), false)
+}
@@ -0,0 +1,69 @@
+package drive
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDownloadFilesWriteMetadata(t *testing.T) {
+	fake := newFakeDrive()
+	fake.addFile("a", "a.txt", "root", "2025-04-01T00:00:00Z", "hello")
+	d := newTestService(t, fake)
+
+	outputDir := t.TempDir()
+	file := FileInfo{
+		ID:          "a",
+		Name:        "a.txt",
+		Path:        "notes/a.txt",
+		MD5:         md5Hex("hello"),
+		Owners:      []string{"alice@example.com"},
+		Permissions: []Permission{{Type: "anyone", Role: "reader"}},
+		WebViewLink: "https://drive.google.com/file/d/a/view",
+	}
+	report, err := d.DownloadFiles([]FileInfo{file}, DownloadOptions{OutputDir: outputDir, WriteMetadata: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(report.Warnings) != 0 {
+		t.Errorf("unexpected warnings: %v", report.Warnings)
+	}
+
+	data, err := os.ReadFile(filepath.Join(outputDir, "notes", "a.txt.meta.json"))
+	if err != nil {
+		t.Fatalf("sidecar not written: %v", err)
+	}
+	var got FileInfo
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("sidecar is not valid JSON: %v", err)
+	}
+	if got.ID != file.ID || got.MD5 != file.MD5 || got.WebViewLink != file.WebViewLink ||
+		len(got.Owners) != 1 || len(got.Permissions) != 1 {
+		t.Errorf("sidecar = %+v, want %+v", got, file)
+	}
+}
+
+func TestDownloadFilesMetadataFailureIsNotFatal(t *testing.T) {
+	fake := newFakeDrive()
+	fake.addFile("a", "a.txt", "root", "2025-04-01T00:00:00Z", "hello")
+	d := newTestService(t, fake)
+
+	outputDir := t.TempDir()
+	// A directory in the sidecar's place makes writing it fail
+	if err := os.MkdirAll(filepath.Join(outputDir, "a.txt.meta.json"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	files := []FileInfo{{ID: "a", Name: "a.txt", Path: "a.txt"}}
+	report, err := d.DownloadFiles(files, DownloadOptions{OutputDir: outputDir, WriteMetadata: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(report.Downloaded) != 1 || len(report.Failed) != 0 {
+		t.Errorf("report = %+v, want the file downloaded", report)
+	}
+	if len(report.Warnings) != 1 {
+		t.Errorf("warnings = %v, want one", report.Warnings)
+	}
+}
@@ -0,0 +1,78 @@
+package drive
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// linkStubExtensions names link stubs after the extensions Google Drive for
+// desktop gives each Google Docs editors type
+var linkStubExtensions = map[string]string{
+	"application/vnd.google-apps.document":     ".gdoc",
+	"application/vnd.google-apps.spreadsheet":  ".gsheet",
+	"application/vnd.google-apps.presentation": ".gslides",
+	"application/vnd.google-apps.drawing":      ".gdraw",
+	"application/vnd.google-apps.form":         ".gform",
+	"application/vnd.google-apps.map":          ".gmap",
+	"application/vnd.google-apps.site":         ".gsite",
+	"application/vnd.google-apps.jam":          ".gjam",
+	"application/vnd.google-apps.script":       ".gscript",
+}
+
+// linkStubExtension returns the extension of a Google-native file's link stub
+func linkStubExtension(mimeType string) string {
+	if ext, ok := linkStubExtensions[mimeType]; ok {
+		return ext
+	}
+	return ".glink"
+}
+
+// savesLinkStub reports whether the file is saved as a link stub rather than
+// downloaded
+func (o DownloadOptions) savesLinkStub(fileInfo FileInfo) bool {
+	return o.NativeAsLink && isGoogleNative(fileInfo.MimeType)
+}
+
+// linkStub is the content of a link stub
+type linkStub struct {
+	URL      string `json:"url"`
+	DocID    string `json:"doc_id"`
+	Name     string `json:"name"`
+	MimeType string `json:"mimeType"`
+}
+
+// linkStubContent returns the JSON saved in place of a Google-native file,
+// pointing at the file in Drive
+func linkStubContent(fileInfo FileInfo) ([]byte, error) {
+	url := fileInfo.WebViewLink
+	if url == "" {
+		url = "https://drive.google.com/open?id=" + fileInfo.ID
+	}
+	data, err := json.MarshalIndent(linkStub{
+		URL:      url,
+		DocID:    fileInfo.ID,
+		Name:     fileInfo.Name,
+		MimeType: fileInfo.MimeType,
+	}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode link stub: %v", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// writeLinkStub saves a link stub for a Google-native file at outPath
+func (d *DriveService) writeLinkStub(fileInfo FileInfo, outPath string) error {
+	data, err := linkStubContent(fileInfo)
+	if err != nil {
+		return err
+	}
+	d.log("  %s is a %s, saving a link to it instead",
+		fileInfo.Path, strings.TrimPrefix(fileInfo.MimeType, "application/vnd.google-apps."))
+	if err := d.writeFile(outPath, bytes.NewReader(data), false); err != nil {
+		return err
+	}
+	d.log("✅ Successfully saved link: %s", outPath)
+	return nil
+}
@@ -0,0 +1,51 @@
+package drive
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// OutputDirTemplate computes a per-file base output directory from a Go
+// template evaluated against FileInfo, e.g. "downloads/{{.Owner}}"
+type OutputDirTemplate struct {
+	spec string
+	tmpl *template.Template
+}
+
+// ParseOutputDir parses an -output-dir value. It returns nil for values
+// without "{{", which are used literally.
+func ParseOutputDir(spec string) (*OutputDirTemplate, error) {
+	if !strings.Contains(spec, "{{") {
+		return nil, nil
+	}
+
+	tmpl, err := template.New("output-dir").Option("missingkey=error").Parse(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid output directory template %q: %v", spec, err)
+	}
+
+	// Catch references to unknown fields before any file is downloaded
+	if err := tmpl.Execute(&strings.Builder{}, FileInfo{}); err != nil {
+		return nil, fmt.Errorf("invalid output directory template %q: %v", spec, err)
+	}
+	return &OutputDirTemplate{spec: spec, tmpl: tmpl}, nil
+}
+
+// Render returns the base output directory for a file
+func (t *OutputDirTemplate) Render(fileInfo FileInfo) (string, error) {
+	var b strings.Builder
+	if err := t.tmpl.Execute(&b, fileInfo); err != nil {
+		return "", fmt.Errorf("unable to render output directory %q for %s: %v", t.spec, fileInfo.Path, err)
+	}
+	dir := strings.TrimSpace(b.String())
+	if dir == "" {
+		return "", fmt.Errorf("output directory %q is empty for %s", t.spec, fileInfo.Path)
+	}
+	return filepath.Clean(dir), nil
+}
+
+func (t *OutputDirTemplate) String() string {
+	return t.spec
+}
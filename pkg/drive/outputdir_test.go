@@ -0,0 +1,79 @@
+package drive
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseOutputDir(t *testing.T) {
+	tests := []struct {
+		spec    string
+		file    FileInfo
+		want    string
+		literal bool
+		wantErr bool
+	}{
+		{spec: "downloads", literal: true},
+		{spec: "downloads/{{.Owner}}", file: FileInfo{Owners: []string{"alice@example.com"}}, want: "downloads/alice@example.com"},
+		{spec: "downloads/{{.Owner}}", want: "downloads/unknown"},
+		{spec: "out/{{.MimeType}}/", file: FileInfo{MimeType: "text"}, want: "out/text"},
+		{spec: "{{.ModifiedAt.Format \"2006-01\"}}", file: FileInfo{ModifiedAt: rfc3339("2025-04-01T00:00:00Z")}, want: "2025-04"},
+		{spec: "downloads/{{.Owner", wantErr: true},
+		{spec: "downloads/{{.Team}}", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			tmpl, err := ParseOutputDir(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.literal {
+				if tmpl != nil {
+					t.Errorf("ParseOutputDir(%q) = %v, want nil for a literal directory", tt.spec, tmpl)
+				}
+				return
+			}
+			got, err := tmpl.Render(tt.file)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != filepath.FromSlash(tt.want) {
+				t.Errorf("Render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDownloadFilesOutputDirTemplate(t *testing.T) {
+	fake := newFakeDrive()
+	fake.addFile("a", "a.txt", "root", "2025-04-01T00:00:00Z", "hello")
+	fake.addFile("b", "b.txt", "root", "2025-04-01T00:00:00Z", "world")
+	d := newTestService(t, fake)
+
+	outputDir := t.TempDir()
+	tmpl, err := ParseOutputDir(outputDir + "/{{.Owner}}")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	files := []FileInfo{
+		{ID: "a", Name: "a.txt", Path: "a.txt", Owners: []string{"alice@example.com"}},
+		{ID: "b", Name: "b.txt", Path: "b.txt", Owners: []string{"bob@example.com"}},
+	}
+
+	if _, err := d.DownloadFiles(files, DownloadOptions{OutputDirTemplate: tmpl}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{"alice@example.com/a.txt", "bob@example.com/b.txt"} {
+		if _, err := os.Stat(filepath.Join(outputDir, want)); err != nil {
+			t.Errorf("expected %s to be downloaded: %v", want, err)
+		}
+	}
+}
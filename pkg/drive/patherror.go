@@ -0,0 +1,48 @@
+package drive
+
+import (
+	"errors"
+	"fmt"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// ErrPathUnresolved is returned by ListFiles with the "fail" path error
+// policy when the path of a file couldn't be looked up
+var ErrPathUnresolved = errors.New("unable to resolve path")
+
+// Policies for files whose path can't be looked up, for ListOptions.OnPathError
+const (
+	PathErrorShallow = "shallow"
+	PathErrorSkip    = "skip"
+	PathErrorFail    = "fail"
+)
+
+// ValidatePathErrorPolicy checks that an -on-path-error value is supported
+func ValidatePathErrorPolicy(policy string) error {
+	switch policy {
+	case PathErrorShallow, PathErrorSkip, PathErrorFail:
+		return nil
+	}
+	return fmt.Errorf("invalid path error policy %q (expected shallow, skip or fail)", policy)
+}
+
+// resolvePath returns the path of f as fullPath does. When it can't be
+// looked up, c.opts.OnPathError decides: the file name alone is used, the
+// file is skipped (false is returned), or an error wrapping
+// ErrPathUnresolved stops the crawl.
+func (d *DriveService) resolvePath(c *crawl, f *drive.File, nodes *prefetchedNodes, folderNames map[string]string) (string, bool, error) {
+	path, err := d.fullPath(f, nodes, folderNames)
+	if err == nil {
+		return path, true, nil
+	}
+	switch c.opts.OnPathError {
+	case PathErrorSkip:
+		d.printf("⚠️ Skipping %s: unable to look up its path: %v\n", f.Name, err)
+		return "", false, nil
+	case PathErrorFail:
+		return "", false, fmt.Errorf("%w of %s (ID: %s): %v", ErrPathUnresolved, f.Name, f.Id, err)
+	}
+	d.printf("⚠️ Saving %s without its folders: unable to look up its path: %v\n", f.Name, err)
+	return f.Name, true, nil
+}
@@ -0,0 +1,178 @@
+package drive
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// parentBatchSize is how many folders prefetchParents looks up per query
+const parentBatchSize = 50
+
+// prefetchedNodes are files and their ancestors looked up by
+// prefetchParents, by ID
+type prefetchedNodes struct {
+	files map[string]*drive.File
+	// failed holds the folders that couldn't be looked up, other than those
+	// the account can't see, with the reason
+	failed map[string]error
+}
+
+// prefetchParents looks up every ancestor folder of files with batched
+// queries, one batch of IDs at a time, level by level, so nodePath can
+// compute each path without further calls. Searches don't return the root
+// folder, so folders missing from a batch are looked up on their own; those
+// the account can't see are left out, and those that fail are recorded.
+func (d *DriveService) prefetchParents(c *crawl, files []*drive.File) (*prefetchedNodes, error) {
+	nodes := make(map[string]*drive.File)
+	failed := make(map[string]error)
+	for _, f := range files {
+		nodes[f.Id] = f
+	}
+
+	missing := files
+	looked := make(map[string]bool)
+	for len(missing) > 0 {
+		var ids []string
+		for _, f := range missing {
+			for _, id := range f.Parents {
+				if _, ok := nodes[id]; !ok && !looked[id] {
+					looked[id] = true
+					ids = append(ids, id)
+				}
+			}
+		}
+
+		missing = nil
+		for start := 0; start < len(ids); start += parentBatchSize {
+			batch := ids[start:min(start+parentBatchSize, len(ids))]
+			folders, err := d.listByID(c, batch)
+			if err != nil {
+				return nil, err
+			}
+			for _, folder := range folders {
+				nodes[folder.Id] = folder
+			}
+			missing = append(missing, folders...)
+		}
+		for _, id := range ids {
+			if _, ok := nodes[id]; ok {
+				continue
+			}
+			folder, err := d.getFolder(id)
+			if isNotFound(err) {
+				continue
+			}
+			if err != nil {
+				failed[id] = err
+				continue
+			}
+			nodes[id] = folder
+			missing = append(missing, folder)
+		}
+	}
+	d.log("📂 Prefetched %d parent folders", len(looked))
+	return &prefetchedNodes{files: nodes, failed: failed}, nil
+}
+
+// listByID fetches the ID, name and parents of the given files in one query
+func (d *DriveService) listByID(c *crawl, ids []string) ([]*drive.File, error) {
+	clauses := make([]string, len(ids))
+	for i, id := range ids {
+		clauses[i] = fmt.Sprintf("id = '%s'", escapeQuery(id))
+	}
+	query := strings.Join(clauses, " or ")
+
+	var files []*drive.File
+	pageToken := ""
+	for {
+		call := d.service.Files.List().
+			Q(query).
+			Fields("nextPageToken, files(id, name, parents)").
+			IncludeItemsFromAllDrives(true).
+			SupportsAllDrives(true).
+			PageSize(c.opts.pageSize())
+		if pageToken != "" {
+			call = call.PageToken(pageToken)
+		}
+		var r *drive.FileList
+		err := d.retryDo("Looking up folders", func() (err error) {
+			r, err = call.Context(d.requestContext()).Do()
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to look up folders: %v", err)
+		}
+		files = append(files, r.Files...)
+		if r.NextPageToken == "" {
+			return files, nil
+		}
+		pageToken = r.NextPageToken
+	}
+}
+
+// getFolder fetches the ID, name and parents of a single folder
+func (d *DriveService) getFolder(id string) (*drive.File, error) {
+	var folder *drive.File
+	err := d.retryDo("Looking up a folder", func() (err error) {
+		folder, err = d.service.Files.Get(id).
+			Fields("id, name, parents").
+			SupportsAllDrives(true).
+			Context(d.requestContext()).
+			Do()
+		return err
+	})
+	return folder, err
+}
+
+// nodePath returns the path of a file from nodes prefetched by
+// prefetchParents, up to the highest ancestor the account can see, or the
+// error of an ancestor that couldn't be looked up
+func nodePath(id string, nodes *prefetchedNodes) (string, error) {
+	var names []string
+	seen := make(map[string]bool)
+	for id != "" && !seen[id] {
+		if err, ok := nodes.failed[id]; ok {
+			return "", err
+		}
+		f, ok := nodes.files[id]
+		if !ok {
+			break
+		}
+		seen[id] = true
+		names = append(names, f.Name)
+		id = ""
+		if len(f.Parents) > 0 {
+			id = f.Parents[0]
+		}
+	}
+	for i, j := 0, len(names)-1; i < j; i, j = i+1, j-1 {
+		names[i], names[j] = names[j], names[i]
+	}
+	return joinPath(names...), nil
+}
+
+// prefetchPaths returns the nodes of files and their ancestors when
+// c.opts.PrefetchPaths is set, or nil when it isn't or the lookup failed,
+// in which case paths are looked up one file at a time
+func (d *DriveService) prefetchPaths(c *crawl, files []*drive.File) *prefetchedNodes {
+	if !c.opts.PrefetchPaths || len(files) == 0 {
+		return nil
+	}
+	nodes, err := d.prefetchParents(c, files)
+	if err != nil {
+		d.log("⚠️ Prefetching parent folders failed, looking them up one by one: %v", err)
+		return nil
+	}
+	return nodes
+}
+
+// fullPath returns the path of f, from nodes when they were prefetched and
+// otherwise by looking it up with getFullPath
+func (d *DriveService) fullPath(f *drive.File, nodes *prefetchedNodes, folderNames map[string]string) (string, error) {
+	if nodes != nil {
+		return nodePath(f.Id, nodes)
+	}
+	return d.getFullPath(f.Id, folderNames)
+}
@@ -0,0 +1,54 @@
+package drive
+
+import (
+	"io"
+	"time"
+)
+
+// ProgressFunc is called while a file downloads with the number of bytes
+// written so far and the expected total (0 when unknown)
+type ProgressFunc func(file FileInfo, bytesDone, bytesTotal int64)
+
+// progressInterval is the minimum time between ProgressFunc calls for a file
+const progressInterval = 200 * time.Millisecond
+
+// WithProgress sets the function called to report download progress
+func (d *DriveService) WithProgress(fn ProgressFunc) *DriveService {
+	d.progress = fn
+	return d
+}
+
+// progressReader reports bytes read through a ProgressFunc, at most once per
+// progressInterval
+type progressReader struct {
+	r        io.Reader
+	fn       ProgressFunc
+	file     FileInfo
+	done     int64
+	total    int64
+	lastCall time.Time
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.done += int64(n)
+	if now := time.Now(); now.Sub(p.lastCall) >= progressInterval {
+		p.lastCall = now
+		p.fn(p.file, p.done, p.reportedTotal())
+	}
+	return n, err
+}
+
+// finish reports the final byte count
+func (p *progressReader) finish() {
+	p.fn(p.file, p.done, max(p.total, p.done))
+}
+
+// reportedTotal returns the expected total, raised to the bytes read so far
+// should the file be longer than Drive listed, as when it changed since
+func (p *progressReader) reportedTotal() int64 {
+	if p.total <= 0 {
+		return 0
+	}
+	return max(p.total, p.done)
+}
@@ -0,0 +1,66 @@
+package drive
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// WithRateLimit caps the rate of requests sent to Google at rps per second,
+// across listing, downloads, exports and retries alike, so overlapping them,
+// as with WalkFiles and DownloadStream, stays within quota. Requests wait
+// their turn; 0 or less removes the cap.
+func (d *DriveService) WithRateLimit(rps float64) *DriveService {
+	if d.rate == nil {
+		return d
+	}
+	d.rate.limiter = nil
+	if rps > 0 {
+		d.rate.limiter = &rateLimiter{interval: time.Duration(float64(time.Second) / rps)}
+	}
+	return d
+}
+
+// rateLimiter spaces requests at least interval apart
+type rateLimiter struct {
+	interval time.Duration
+
+	mu sync.Mutex
+	// next is when the next request may be sent
+	next time.Time
+}
+
+// reserve returns when a request may be sent, holding that slot for it
+func (l *rateLimiter) reserve() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	at := l.next
+	if now := time.Now(); at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(l.interval)
+	return at
+}
+
+// rateTransport holds each request sent through it until its limiter lets
+// it go, or the request is cancelled
+type rateTransport struct {
+	next    http.RoundTripper
+	limiter *rateLimiter
+}
+
+func (t *rateTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.limiter == nil {
+		return t.next.RoundTrip(req)
+	}
+	if delay := time.Until(t.limiter.reserve()); delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+	return t.next.RoundTrip(req)
+}
@@ -0,0 +1,97 @@
+package drive
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+)
+
+// stampTransport records when each request was sent
+type stampTransport struct {
+	next  http.RoundTripper
+	mu    sync.Mutex
+	times []time.Time
+}
+
+func (t *stampTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	t.times = append(t.times, time.Now())
+	t.mu.Unlock()
+	return t.next.RoundTrip(req)
+}
+
+func TestRateLimitSharedByListingAndDownloads(t *testing.T) {
+	fake := newFakeDrive()
+	fake.addFolder("f1", "room-1", "root")
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		fake.addFile(id, id+".txt", "f1", "2025-04-01T00:00:00Z", id)
+	}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	fake.url = srv.URL
+
+	stamps := &stampTransport{next: http.DefaultTransport}
+	d := &DriveService{rate: &rateTransport{next: stamps}}
+	d.WithRateLimit(100)
+	client := &http.Client{Transport: d.rate}
+	service, err := drive.NewService(context.Background(), option.WithEndpoint(srv.URL+"/"), option.WithHTTPClient(client))
+	if err != nil {
+		t.Fatalf("failed to create Drive service: %v", err)
+	}
+	d.service, d.client = service, client
+
+	done := make(chan struct{})
+	defer close(done)
+	files, errc := d.WalkFiles(ListOptions{FolderIDs: []string{"f1"}, MaxDepth: -1}, done)
+	report, err := d.DownloadStream(files, DownloadOptions{OutputDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("unexpected walk error: %v", err)
+	}
+	if len(report.Downloaded) != 5 {
+		t.Fatalf("downloaded %d files, want 5", len(report.Downloaded))
+	}
+
+	// Listing and downloads together never beat the limit, allowing a little
+	// for the timer going off early
+	interval := d.rate.limiter.interval
+	times := stamps.times
+	if len(times) < 6 {
+		t.Fatalf("sent %d requests, want at least 6", len(times))
+	}
+	for i := 1; i < len(times); i++ {
+		if gap := times[i].Sub(times[0]); gap < time.Duration(i)*interval-5*time.Millisecond {
+			t.Errorf("request %d sent %v after the first, want at least %v", i, gap, time.Duration(i)*interval)
+		}
+	}
+}
+
+func TestRateTransportCancelled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	d := &DriveService{rate: &rateTransport{next: http.DefaultTransport}}
+	d.WithRateLimit(0.1)
+	client := &http.Client{Transport: d.rate}
+	// The first request goes straight through and holds the next slot
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	if _, err := client.Do(req); err == nil {
+		t.Error("request waiting for the limiter wasn't cancelled with its context")
+	}
+}
@@ -0,0 +1,52 @@
+package drive
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// jsonFieldIndex maps each FileInfo JSON field name to its struct field index
+func jsonFieldIndex() map[string]int {
+	t := reflect.TypeOf(FileInfo{})
+	index := make(map[string]int)
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			index[name] = i
+		}
+	}
+	return index
+}
+
+// ValidateRedactFields checks that every name is a JSON field of FileInfo
+func ValidateRedactFields(fields []string) error {
+	index := jsonFieldIndex()
+	for _, field := range fields {
+		if _, ok := index[field]; !ok {
+			var names []string
+			for name := range index {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return fmt.Errorf("unknown metadata field %q (expected one of: %s)", field, strings.Join(names, ", "))
+		}
+	}
+	return nil
+}
+
+// Redact returns a copy of the file info with the named JSON fields cleared.
+// Optional fields are then left out of the JSON encoding; required ones, such
+// as id, keep their key with an empty value so the record still matches
+// FileInfoSchema.
+func (f FileInfo) Redact(fields []string) FileInfo {
+	index := jsonFieldIndex()
+	v := reflect.ValueOf(&f).Elem()
+	for _, field := range fields {
+		if i, ok := index[field]; ok {
+			v.Field(i).SetZero()
+		}
+	}
+	return f
+}
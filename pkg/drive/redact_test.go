@@ -0,0 +1,43 @@
+package drive
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	file := FileInfo{
+		ID:          "a",
+		Name:        "a.txt",
+		Owners:      []string{"alice@example.com"},
+		Permissions: []Permission{{Type: "user", Role: "reader", EmailAddress: "bob@example.com"}},
+		WebViewLink: "https://drive.google.com/file/d/a/view",
+	}
+
+	redacted := file.Redact([]string{"owners", "permissions", "name"})
+	data, err := json.Marshal(redacted)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, leaked := range []string{"alice@example.com", "bob@example.com", "a.txt", `"owners"`, `"permissions"`} {
+		if strings.Contains(string(data), leaked) {
+			t.Errorf("redacted metadata %s contains %s", data, leaked)
+		}
+	}
+	if !strings.Contains(string(data), `"name":""`) || redacted.WebViewLink != file.WebViewLink {
+		t.Errorf("redacted metadata %s should keep required keys and other fields", data)
+	}
+	if len(file.Owners) != 1 {
+		t.Error("Redact modified the original")
+	}
+}
+
+func TestValidateRedactFields(t *testing.T) {
+	if err := ValidateRedactFields([]string{"owners", "permissions", "webViewLink"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := ValidateRedactFields([]string{"Owners"}); err == nil || !strings.Contains(err.Error(), "owners") {
+		t.Errorf("error = %v, want unknown field error listing owners", err)
+	}
+}
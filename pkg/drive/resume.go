@@ -0,0 +1,282 @@
+package drive
+
+import (
+	"crypto/md5"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// partRecordSuffix is appended to the path of a ".part" file to name the
+// record of the download it holds, which FinishIncomplete resumes from
+const partRecordSuffix = ".json"
+
+// partRecord identifies the download a ".part" file holds
+type partRecord struct {
+	File       FileInfo `json:"file"`
+	Compressed bool     `json:"compressed"`
+}
+
+// writePartRecord records which file is being downloaded to outPath, so the
+// download can be finished should the run crash before it completes. The
+// returned path is removed once the download ends either way.
+func (d *DriveService) writePartRecord(outPath string, fileInfo FileInfo, compressed bool) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(outPath), d.dirPerm()); err != nil {
+		return "", fmt.Errorf("unable to create output directory: %v", err)
+	}
+	data, err := json.Marshal(partRecord{File: fileInfo, Compressed: compressed})
+	if err != nil {
+		return "", fmt.Errorf("unable to encode download record: %v", err)
+	}
+	recordPath := outPath + partSuffix + partRecordSuffix
+	os.Remove(recordPath)
+	if err := os.WriteFile(recordPath, data, d.filePerm()); err != nil {
+		return "", fmt.Errorf("unable to write download record: %v", err)
+	}
+	return recordPath, nil
+}
+
+// IncompleteDownload is a download a crashed run left in a ".part" file
+type IncompleteDownload struct {
+	File FileInfo
+	// OutPath is where the download completes to, and PartPath the ".part"
+	// file holding what was downloaded so far
+	OutPath  string
+	PartPath string
+	// Compressed downloads are gzipped as they are written, so they can't
+	// pick up where they stopped and are downloaded again in full
+	Compressed bool
+}
+
+// FindIncomplete returns the downloads left in ".part" files under dir.
+// Each is identified by the record written when it started or, for files
+// downloaded before, by the -write-metadata sidecar of an earlier copy. The
+// paths of ".part" files that can't be identified are returned apart.
+func FindIncomplete(dir string) ([]IncompleteDownload, []string, error) {
+	var found []IncompleteDownload
+	var unknown []string
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || !strings.HasSuffix(path, partSuffix) {
+			return nil
+		}
+		inc, ok := identifyPart(path)
+		if !ok {
+			unknown = append(unknown, path)
+			return nil
+		}
+		found = append(found, inc)
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, fmt.Errorf("output directory %s does not exist", dir)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to search for incomplete downloads: %v", err)
+	}
+	return found, unknown, nil
+}
+
+// identifyPart finds out which file a ".part" file is a download of
+func identifyPart(partPath string) (IncompleteDownload, bool) {
+	outPath := strings.TrimSuffix(partPath, partSuffix)
+	inc := IncompleteDownload{OutPath: outPath, PartPath: partPath}
+
+	var record partRecord
+	if data, err := os.ReadFile(partPath + partRecordSuffix); err == nil {
+		if json.Unmarshal(data, &record) == nil && record.File.ID != "" {
+			inc.File, inc.Compressed = record.File, record.Compressed
+			return inc, true
+		}
+	}
+
+	// Sidecars are named after the uncompressed path
+	sidecars := []string{outPath + metadataSuffix}
+	if uncompressed, ok := strings.CutSuffix(outPath, ".gz"); ok {
+		sidecars = append(sidecars, uncompressed+metadataSuffix)
+	}
+	for i, sidecar := range sidecars {
+		data, err := os.ReadFile(sidecar)
+		if err != nil {
+			continue
+		}
+		var info FileInfo
+		if json.Unmarshal(data, &info) == nil && info.ID != "" {
+			inc.File, inc.Compressed = info, i == 1
+			return inc, true
+		}
+	}
+	return inc, false
+}
+
+// FinishIncomplete completes downloads left incomplete by a crashed run,
+// without listing Drive. Uncompressed downloads continue where they stopped
+// with a ranged request, unless the file changed since or Drive sends the
+// whole file again, and are then always checked against Drive's checksum.
+// Files that fail are recorded in the report, and their ".part" file is
+// kept unless it turned out to be corrupt. Only opts.VerifyChecksum is used.
+func (d *DriveService) FinishIncomplete(downloads []IncompleteDownload, opts DownloadOptions) (*DownloadReport, error) {
+	report := &DownloadReport{}
+	for _, inc := range downloads {
+		if err := d.requestContext().Err(); err != nil {
+			return report, err
+		}
+		d.printf("Finishing: %s\n", inc.OutPath)
+		if err := d.finishDownload(&inc, opts); err != nil {
+			d.printf("❌ %s: %v\n", inc.OutPath, err)
+			report.Failed = append(report.Failed, FileFailure{File: inc.File, Err: err})
+			continue
+		}
+		os.Remove(inc.PartPath + partRecordSuffix)
+		report.Downloaded = append(report.Downloaded, inc.File)
+	}
+	return report, nil
+}
+
+// finishDownload completes a single incomplete download, updating inc.File
+// should the file have changed since it started
+func (d *DriveService) finishDownload(inc *IncompleteDownload, opts DownloadOptions) error {
+	var offset int64
+	sum := md5.New()
+	if !inc.Compressed {
+		var err error
+		if offset, err = hashPart(inc.PartPath, sum); err != nil {
+			return err
+		}
+	}
+
+	if offset > 0 {
+		// Appending to a part of an earlier revision would mix the two up
+		current, err := d.currentRevision(inc.File)
+		if err != nil {
+			return err
+		}
+		if !sameRevision(inc.File, current) {
+			d.log("  %s changed since its download started", inc.File.Path)
+			inc.File.MD5, inc.File.Size, inc.File.HeadRevisionID = current.Md5Checksum, current.Size, current.HeadRevisionId
+			offset = 0
+			sum.Reset()
+		}
+	}
+
+	if offset > 0 && inc.File.HasSize() && offset >= inc.File.Size {
+		d.log("  %s holds the whole file already", inc.PartPath)
+		return d.completePart(inc, sum, opts)
+	}
+
+	call := d.service.Files.Get(inc.File.ID)
+	if offset > 0 {
+		call.Header().Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	var resp *http.Response
+	err := d.retryDo("Resuming a download", func() (err error) {
+		resp, err = call.Context(d.requestContext()).Download()
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("unable to download file: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		d.log("  Downloading %s again from the start", inc.File.Path)
+		sum.Reset()
+		if err := d.writeFile(inc.OutPath, io.TeeReader(resp.Body, sum), inc.Compressed); err != nil {
+			return err
+		}
+		return d.checkResumed(inc, sum, opts)
+	}
+
+	d.log("  Resuming %s at byte %d", inc.File.Path, offset)
+	part, err := os.OpenFile(inc.PartPath, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return fmt.Errorf("unable to open %s: %v", inc.PartPath, err)
+	}
+	if _, err := io.Copy(part, io.TeeReader(resp.Body, sum)); err != nil {
+		part.Close()
+		return fmt.Errorf("unable to download file: %v", err)
+	}
+	if err := part.Close(); err != nil {
+		return fmt.Errorf("unable to save file: %v", err)
+	}
+	return d.completePart(inc, sum, opts)
+}
+
+// hashPart feeds the content of a ".part" file to sum, returning its size
+func hashPart(partPath string, sum hash.Hash) (int64, error) {
+	f, err := os.Open(partPath)
+	if err != nil {
+		return 0, fmt.Errorf("unable to read %s: %v", partPath, err)
+	}
+	defer f.Close()
+	n, err := io.Copy(sum, f)
+	if err != nil {
+		return 0, fmt.Errorf("unable to read %s: %v", partPath, err)
+	}
+	return n, nil
+}
+
+// currentRevision fetches what identifies the current revision of a file
+func (d *DriveService) currentRevision(info FileInfo) (*drive.File, error) {
+	var f *drive.File
+	err := d.retryDo("Checking a file for changes", func() (err error) {
+		f, err = d.service.Files.Get(info.ID).
+			Fields("md5Checksum, headRevisionId, size").
+			SupportsAllDrives(true).
+			Context(d.requestContext()).
+			Do()
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to check %s for changes: %v", info.Path, err)
+	}
+	return f, nil
+}
+
+// sameRevision reports whether current is still the revision info was
+// recorded from, going by its head revision, checksum and size. A file
+// recorded with neither a revision nor a checksum can't be told apart and
+// counts as changed.
+func sameRevision(info FileInfo, current *drive.File) bool {
+	if info.HeadRevisionID == "" && !info.HasChecksum() {
+		return false
+	}
+	return current.HeadRevisionId == info.HeadRevisionID && current.Md5Checksum == info.MD5 && current.Size == info.Size
+}
+
+// completePart checks a finished ".part" file and moves it into place. Part
+// of it was downloaded by an earlier run, so it is always checked against
+// Drive's checksum when there is one.
+func (d *DriveService) completePart(inc *IncompleteDownload, sum hash.Hash, opts DownloadOptions) error {
+	opts.VerifyChecksum = true
+	if err := d.checkChecksum(inc.File, sum, opts); err != nil {
+		// What was downloaded before can't be trusted, so start over next time
+		os.Remove(inc.PartPath)
+		os.Remove(inc.PartPath + partRecordSuffix)
+		return err
+	}
+	if err := os.Rename(inc.PartPath, inc.OutPath); err != nil {
+		return fmt.Errorf("unable to save file: %v", err)
+	}
+	return nil
+}
+
+// checkResumed checks a download that was written again in full
+func (d *DriveService) checkResumed(inc *IncompleteDownload, sum hash.Hash, opts DownloadOptions) error {
+	if err := d.checkChecksum(inc.File, sum, opts); err != nil {
+		os.Remove(inc.OutPath)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package drive
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFinishIncomplete(t *testing.T) {
+	fake := newFakeDrive()
+	fake.addFile("a", "a.txt", "root", "2025-04-01T00:00:00Z", "hello world")
+	fake.addFile("b", "b.txt", "root", "2025-04-01T00:00:00Z", "bonjour")
+	d := newTestService(t, fake)
+
+	outputDir := t.TempDir()
+	fileA := FileInfo{ID: "a", Name: "a.txt", Path: "a.txt", Size: 11, MD5: md5Hex("hello world")}
+	fileB := FileInfo{ID: "b", Name: "b.txt", Path: "old/b.txt", Size: 7, MD5: md5Hex("bonjour")}
+
+	// a.txt was started by this version, b.txt before part records existed
+	aPath := filepath.Join(outputDir, "a.txt")
+	if _, err := d.writePartRecord(aPath, fileA, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	os.WriteFile(aPath+partSuffix, []byte("hello"), 0644)
+	bPath := filepath.Join(outputDir, "old", "b.txt")
+	os.MkdirAll(filepath.Dir(bPath), 0755)
+	os.WriteFile(bPath+partSuffix, []byte("bon"), 0644)
+	sidecar, _ := json.Marshal(fileB)
+	os.WriteFile(bPath+metadataSuffix, sidecar, 0644)
+	// Nothing tells which file this is
+	os.WriteFile(filepath.Join(outputDir, "c.txt"+partSuffix), []byte("?"), 0644)
+
+	downloads, unknown, err := FindIncomplete(outputDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{filepath.Join(outputDir, "c.txt"+partSuffix)}; !reflect.DeepEqual(unknown, want) {
+		t.Errorf("unknown = %v, want %v", unknown, want)
+	}
+	if len(downloads) != 2 || downloads[0].File.ID != "a" || downloads[1].File.ID != "b" {
+		t.Fatalf("downloads = %+v, want a and b", downloads)
+	}
+
+	report, err := d.FinishIncomplete(downloads, DownloadOptions{VerifyChecksum: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(report.Downloaded) != 2 || len(report.Failed) != 0 {
+		t.Fatalf("downloaded %d, failed %v", len(report.Downloaded), report.Failed)
+	}
+	for path, want := range map[string]string{aPath: "hello world", bPath: "bonjour"} {
+		if got, _ := os.ReadFile(path); string(got) != want {
+			t.Errorf("%s = %q, want %q", path, got, want)
+		}
+		if _, err := os.Stat(path + partSuffix); !os.IsNotExist(err) {
+			t.Errorf("%s was left behind", path+partSuffix)
+		}
+	}
+	if _, err := os.Stat(aPath + partSuffix + partRecordSuffix); !os.IsNotExist(err) {
+		t.Error("part record was left behind")
+	}
+	if want := []string{"bytes=5-", "bytes=3-"}; !reflect.DeepEqual(fake.ranges, want) {
+		t.Errorf("ranges = %v, want %v", fake.ranges, want)
+	}
+}
+
+func TestFinishIncompleteCorruptPart(t *testing.T) {
+	fake := newFakeDrive()
+	fake.addFile("a", "a.txt", "root", "2025-04-01T00:00:00Z", "hello world")
+	d := newTestService(t, fake)
+
+	outputDir := t.TempDir()
+	file := FileInfo{ID: "a", Name: "a.txt", Path: "a.txt", Size: 11, MD5: md5Hex("hello world")}
+	outPath := filepath.Join(outputDir, "a.txt")
+	d.writePartRecord(outPath, file, false)
+	os.WriteFile(outPath+partSuffix, []byte("HELLO"), 0644)
+
+	downloads, _, err := FindIncomplete(outputDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Resumed files are checked even without -verify-checksum
+	report, _ := d.FinishIncomplete(downloads, DownloadOptions{})
+	if len(report.Failed) != 1 {
+		t.Fatalf("failed = %v, want the corrupt part", report.Failed)
+	}
+	// The part is dropped so the next run downloads the file from scratch
+	if _, err := os.Stat(outPath + partSuffix); !os.IsNotExist(err) {
+		t.Error("corrupt part was kept")
+	}
+	if _, err := os.Stat(outPath); !os.IsNotExist(err) {
+		t.Error("corrupt file was moved into place")
+	}
+}
+
+func TestDownloadFileRemovesPartRecord(t *testing.T) {
+	fake := newFakeDrive()
+	fake.addFile("a", "a.txt", "root", "2025-04-01T00:00:00Z", "hello")
+	d := newTestService(t, fake)
+
+	outputDir := t.TempDir()
+	file := FileInfo{ID: "a", Name: "a.txt", Path: "a.txt", Size: 5, MD5: md5Hex("hello")}
+	if err := d.DownloadFile(file, DownloadOptions{OutputDir: outputDir}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	entries, _ := os.ReadDir(outputDir)
+	if len(entries) != 1 || entries[0].Name() != "a.txt" {
+		t.Errorf("output directory holds %v, want only a.txt", entries)
+	}
+}
+
+func TestFinishIncompleteChangedFile(t *testing.T) {
+	fake := newFakeDrive()
+	fake.addFile("a", "a.txt", "root", "2025-04-01T00:00:00Z", "goodbye world")
+	fake.addFile("b", "b.txt", "root", "2025-04-01T00:00:00Z", "au revoir")
+	d := newTestService(t, fake)
+
+	// Both were edited after their download started, b.txt once it had
+	// been downloaded in full under the old size
+	outputDir := t.TempDir()
+	aPath := filepath.Join(outputDir, "a.txt")
+	d.writePartRecord(aPath, FileInfo{ID: "a", Name: "a.txt", Path: "a.txt", Size: 11, MD5: md5Hex("hello world")}, false)
+	os.WriteFile(aPath+partSuffix, []byte("hello"), 0644)
+	bPath := filepath.Join(outputDir, "b.txt")
+	d.writePartRecord(bPath, FileInfo{ID: "b", Name: "b.txt", Path: "b.txt", Size: 7, MD5: md5Hex("bonjour")}, false)
+	os.WriteFile(bPath+partSuffix, []byte("bonjour"), 0644)
+
+	downloads, _, err := FindIncomplete(outputDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	report, err := d.FinishIncomplete(downloads, DownloadOptions{VerifyChecksum: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(report.Downloaded) != 2 || len(report.Failed) != 0 {
+		t.Fatalf("downloaded %d, failed %v", len(report.Downloaded), report.Failed)
+	}
+	if got := report.Downloaded[0].MD5; got != md5Hex("goodbye world") {
+		t.Errorf("reported checksum %s, want the current one", got)
+	}
+	for path, want := range map[string]string{aPath: "goodbye world", bPath: "au revoir"} {
+		if got, _ := os.ReadFile(path); string(got) != want {
+			t.Errorf("%s = %q, want %q", path, got, want)
+		}
+	}
+	if len(fake.ranges) != 0 {
+		t.Errorf("ranges = %v, want changed files downloaded in full", fake.ranges)
+	}
+}
@@ -0,0 +1,145 @@
+package drive
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+// DefaultRetries is the number of times a failed Drive request is retried
+// unless WithRetries says otherwise
+const DefaultRetries = 3
+
+// ErrRetryBudgetExhausted is returned for a transient failure that was not
+// retried because the run used up its retry budget
+var ErrRetryBudgetExhausted = errors.New("retry budget exhausted")
+
+// retryBaseDelay is the wait before the first retry; it doubles each time
+var retryBaseDelay = time.Second
+
+// WithRetries sets how many times a Drive request that fails with a transient
+// error is retried (0 to never retry)
+func (d *DriveService) WithRetries(n int) *DriveService {
+	d.retries = &n
+	return d
+}
+
+// maxRetries returns the retry count set by WithRetries, or DefaultRetries
+func (d *DriveService) maxRetries() int {
+	if d.retries == nil {
+		return DefaultRetries
+	}
+	return *d.retries
+}
+
+// WithRetryBudget caps the total number of retries across all Drive requests
+// made by the service (0 for no cap). Once it is used up, transient failures
+// fail fast with ErrRetryBudgetExhausted instead of being retried.
+func (d *DriveService) WithRetryBudget(n int) *DriveService {
+	d.retryBudget = nil
+	if n > 0 {
+		d.retryBudget = new(atomic.Int64)
+		d.retryBudget.Store(int64(n))
+	}
+	return d
+}
+
+// takeRetry consumes one retry from the budget, reporting false if none are left
+func (d *DriveService) takeRetry() bool {
+	return d.retryBudget == nil || d.retryBudget.Add(-1) >= 0
+}
+
+// retryDo calls fn, calling it again with exponential backoff while it fails
+// with a transient error, up to the configured number of retries and within
+// the retry budget. A Retry-After header sent with the error lengthens the
+// wait.
+func (d *DriveService) retryDo(what string, fn func() error) error {
+	delay := retryBaseDelay
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || !isTransient(err) || attempt >= d.maxRetries() {
+			return err
+		}
+		wait := delay
+		if after, ok := retryAfter(err); ok && after > wait {
+			if after > maxRetryAfter {
+				return fmt.Errorf("%w (the server asked to wait %v before retrying)", err, after)
+			}
+			wait = after
+		}
+		if !d.takeRetry() {
+			return fmt.Errorf("%w (not retried: %w)", err, ErrRetryBudgetExhausted)
+		}
+
+		d.log("⚠️ %s failed: %v; retrying in %v (%d/%d)", what, err, wait, attempt+1, d.maxRetries())
+		d.api.retries.Add(1)
+		select {
+		case <-time.After(wait):
+		case <-d.requestContext().Done():
+			return fmt.Errorf("%v (gave up retrying: %w)", err, d.requestContext().Err())
+		}
+		delay *= 2
+	}
+}
+
+// maxRetryAfter is the longest Retry-After wait honoured; a request the
+// server asks to delay longer fails instead
+var maxRetryAfter = 5 * time.Minute
+
+// retryAfter returns the wait requested by the Retry-After header of a failed
+// request, given in seconds or as an HTTP date
+func retryAfter(err error) (time.Duration, bool) {
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		return 0, false
+	}
+	value := apiErr.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0), true
+	}
+	return 0, false
+}
+
+// isNotFound reports whether a request failed because the file doesn't
+// exist or the account can't see it
+func isNotFound(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound
+}
+
+// isTransient reports whether a failed request is worth retrying: rate
+// limiting, server errors and network errors
+func isTransient(err error) bool {
+	if errors.Is(err, ErrOffline) {
+		return false
+	}
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		switch {
+		case apiErr.Code == http.StatusTooManyRequests, apiErr.Code >= 500:
+			return true
+		case apiErr.Code == http.StatusForbidden:
+			for _, item := range apiErr.Errors {
+				if item.Reason == "rateLimitExceeded" || item.Reason == "userRateLimitExceeded" {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
@@ -0,0 +1,121 @@
+package drive
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+func TestRootLookupRetriesTransientErrors(t *testing.T) {
+	defer func(delay time.Duration) { retryBaseDelay = delay }(retryBaseDelay)
+	retryBaseDelay = time.Millisecond
+
+	fake := newFakeDrive()
+	fake.addFile("a", "a.txt", "root", "2025-04-01T00:00:00Z", "a")
+	fake.failures["files/root"] = []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}
+	d := newTestService(t, fake)
+
+	files, err := d.ListFiles(ListOptions{MaxDepth: -1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(files) != 1 {
+		t.Errorf("found %d files, want 1", len(files))
+	}
+
+	fake.failures["files/root"] = []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable}
+	d.WithRetries(1)
+	if _, err := d.ListFiles(ListOptions{MaxDepth: -1}); err == nil {
+		t.Error("expected error once retries are exhausted")
+	}
+
+	fake.failures["files/root"] = []int{http.StatusBadRequest}
+	if _, err := d.ListFiles(ListOptions{MaxDepth: -1}); err == nil || len(fake.failures["files/root"]) != 0 {
+		t.Errorf("error = %v, want a single failed attempt for a permanent error", err)
+	}
+}
+
+func TestRetryHonoursRetryAfter(t *testing.T) {
+	defer func(delay time.Duration) { retryBaseDelay = delay }(retryBaseDelay)
+	retryBaseDelay = time.Millisecond
+
+	fake := newFakeDrive()
+	fake.addFile("a", "a.txt", "root", "2025-04-01T00:00:00Z", "a")
+	fake.failures["files/root"] = []int{http.StatusTooManyRequests}
+	fake.retryAfter = "1"
+	d := newTestService(t, fake)
+
+	start := time.Now()
+	if _, err := d.ListFiles(ListOptions{MaxDepth: -1}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if waited := time.Since(start); waited < time.Second {
+		t.Errorf("retried after %v, want at least the 1s Retry-After", waited)
+	}
+
+	// A wait longer than maxRetryAfter fails instead of retrying
+	fake.failures["files/root"] = []int{http.StatusTooManyRequests}
+	fake.retryAfter = "3600"
+	if _, err := d.ListFiles(ListOptions{MaxDepth: -1}); err == nil {
+		t.Error("expected error for a Retry-After beyond maxRetryAfter")
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	header := func(value string) error {
+		return &googleapi.Error{Code: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {value}}}
+	}
+
+	if got, ok := retryAfter(header("120")); !ok || got != 2*time.Minute {
+		t.Errorf("retryAfter(120) = %v, %v, want 2m", got, ok)
+	}
+	date := time.Now().Add(90 * time.Second).UTC().Format(http.TimeFormat)
+	if got, ok := retryAfter(header(date)); !ok || got < 85*time.Second || got > 90*time.Second {
+		t.Errorf("retryAfter(%s) = %v, %v, want about 90s", date, got, ok)
+	}
+	past := time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat)
+	if got, ok := retryAfter(header(past)); !ok || got != 0 {
+		t.Errorf("retryAfter(%s) = %v, %v, want 0", past, got, ok)
+	}
+	for _, err := range []error{header("soon"), &googleapi.Error{Code: http.StatusTooManyRequests}, errors.New("other")} {
+		if got, ok := retryAfter(err); ok {
+			t.Errorf("retryAfter(%v) = %v, want none", err, got)
+		}
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	defer func(delay time.Duration) { retryBaseDelay = delay }(retryBaseDelay)
+	retryBaseDelay = time.Millisecond
+
+	fake := newFakeDrive()
+	fake.addFile("a", "a.txt", "root", "2025-04-01T00:00:00Z", "a")
+	d := newTestService(t, fake)
+	d.WithRetryBudget(2)
+
+	fake.failures["files/root"] = []int{http.StatusServiceUnavailable}
+	if _, err := d.ListFiles(ListOptions{MaxDepth: -1}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// One retry is left in the budget, though each request may retry three times
+	fake.failures["files/root"] = []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable}
+	_, err := d.ListFiles(ListOptions{MaxDepth: -1})
+	if !errors.Is(err, ErrRetryBudgetExhausted) {
+		t.Errorf("error = %v, want ErrRetryBudgetExhausted", err)
+	}
+}
+
+func TestRootLookupWithoutMyDrive(t *testing.T) {
+	fake := newFakeDrive()
+	delete(fake.files, "root")
+	d := newTestService(t, fake)
+
+	_, err := d.ListFiles(ListOptions{MaxDepth: -1})
+	if !errors.Is(err, ErrNoRootFolder) {
+		t.Errorf("error = %v, want ErrNoRootFolder", err)
+	}
+}
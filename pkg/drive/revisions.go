@@ -0,0 +1,190 @@
+package drive
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+)
+
+// ParseRevisionLimit parses a -revisions value of "all", "latest" or a
+// positive number N, returning how many of the newest revisions to keep
+// (0 meaning all of them)
+func ParseRevisionLimit(spec string) (int, error) {
+	spec = strings.TrimSpace(spec)
+	switch strings.ToLower(spec) {
+	case "all":
+		return 0, nil
+	case "latest":
+		return 1, nil
+	}
+
+	n, err := strconv.Atoi(spec)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid revisions value %q (expected all, latest or a positive number)", spec)
+	}
+	return n, nil
+}
+
+// isGoogleNative reports whether the MIME type is a Google Docs editors
+// format, which has no binary content and must be exported instead
+func isGoogleNative(mimeType string) bool {
+	return strings.HasPrefix(mimeType, "application/vnd.google-apps.")
+}
+
+// listRevisions returns all revisions of a file, oldest first
+func (d *DriveService) listRevisions(fileID string) ([]*drive.Revision, error) {
+	var revisions []*drive.Revision
+	pageToken := ""
+	for {
+		call := d.service.Revisions.List(fileID).
+			Fields("nextPageToken, revisions(id, mimeType, modifiedTime, size, exportLinks)").
+			PageSize(1000)
+		if pageToken != "" {
+			call = call.PageToken(pageToken)
+		}
+		var r *drive.RevisionList
+		err := d.retryDo("Listing revisions", func() (err error) {
+			r, err = call.Context(d.requestContext()).Do()
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, r.Revisions...)
+		if r.NextPageToken == "" {
+			return revisions, nil
+		}
+		pageToken = r.NextPageToken
+	}
+}
+
+// DownloadRevisions downloads the newest limit revisions of a file (all of
+// them when limit is 0) into <path>.revisions/<revisionId>/ below outputDir.
+// Revisions of Google Docs editors files are exported instead, as PDF when
+// they can be.
+func (d *DriveService) DownloadRevisions(fileInfo FileInfo, outputDir string, limit int) error {
+	if fileInfo.IsFolder {
+		return nil
+	}
+
+	d.log("📜 Listing revisions of: %s", fileInfo.Path)
+	revisions, err := d.listRevisions(fileInfo.ID)
+	if err != nil {
+		return fmt.Errorf("unable to list revisions: %w", err)
+	}
+	if len(revisions) == 0 {
+		d.log("  No revision history available for %s", fileInfo.Path)
+		return nil
+	}
+
+	if limit > 0 && len(revisions) > limit {
+		revisions = revisions[len(revisions)-limit:]
+	}
+
+	revisionsDir := localPath(outputDir, fileInfo.Path+".revisions")
+	for _, rev := range revisions {
+		name := fileInfo.Name
+		var body io.ReadCloser
+		if isGoogleNative(fileInfo.MimeType) {
+			link, ext, ok := revisionExportLink(rev)
+			if !ok {
+				d.printf("⚠️ Skipping revision %s of %s: Drive offers no export of it\n", rev.Id, fileInfo.Path)
+				continue
+			}
+			name += ext
+			d.printf("Exporting revision %s of %s (Modified: %s)\n", rev.Id, fileInfo.Path, rev.ModifiedTime)
+			body, err = d.fetchRevisionExport(link)
+		} else {
+			d.printf("Downloading revision %s of %s (Modified: %s, Size: %d)\n", rev.Id, fileInfo.Path, rev.ModifiedTime, rev.Size)
+			var resp *http.Response
+			err = d.retryDo("Downloading a revision", func() (err error) {
+				resp, err = d.service.Revisions.Get(fileInfo.ID, rev.Id).Context(d.requestContext()).Download()
+				return err
+			})
+			if err == nil {
+				body = resp.Body
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("unable to download revision %s: %w", rev.Id, err)
+		}
+
+		outPath := filepath.Join(revisionsDir, rev.Id, revisionFileName(name, rev))
+		err = d.writeFile(outPath, body, false)
+		body.Close()
+		if err != nil {
+			return fmt.Errorf("unable to save revision %s: %v", rev.Id, err)
+		}
+	}
+
+	d.log("✅ Downloaded %d revisions of: %s", len(revisions), fileInfo.Path)
+	return nil
+}
+
+// revisionExportLink picks the format a revision of a Google Docs editors
+// file is exported to: PDF, as for the pdf-export variant, or else the first
+// format Drive offers. It returns the export URL and the file extension.
+func revisionExportLink(rev *drive.Revision) (string, string, bool) {
+	if link, ok := rev.ExportLinks["application/pdf"]; ok {
+		return link, ".pdf", true
+	}
+	mimeTypes := make([]string, 0, len(rev.ExportLinks))
+	for mimeType := range rev.ExportLinks {
+		mimeTypes = append(mimeTypes, mimeType)
+	}
+	if len(mimeTypes) == 0 {
+		return "", "", false
+	}
+	sort.Strings(mimeTypes)
+	ext := ""
+	if exts, _ := mime.ExtensionsByType(mimeTypes[0]); len(exts) > 0 {
+		ext = exts[0]
+	}
+	return rev.ExportLinks[mimeTypes[0]], ext, true
+}
+
+// fetchRevisionExport fetches an export link of a revision, retrying
+// transient failures as for API calls
+func (d *DriveService) fetchRevisionExport(link string) (io.ReadCloser, error) {
+	var resp *http.Response
+	err := d.retryDo("Exporting a revision", func() error {
+		req, err := http.NewRequestWithContext(d.requestContext(), http.MethodGet, link, nil)
+		if err != nil {
+			return err
+		}
+		if resp, err = d.client.Do(req); err != nil {
+			return err
+		}
+		if resp.StatusCode == http.StatusOK {
+			return nil
+		}
+		defer resp.Body.Close()
+		if err := googleapi.CheckResponse(resp); err != nil {
+			return fmt.Errorf("export failed: %w", err)
+		}
+		return fmt.Errorf("export failed: %s", resp.Status)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// revisionFileName prefixes the file name with the revision's modification time
+// so revisions sort chronologically when listed
+func revisionFileName(name string, rev *drive.Revision) string {
+	modified, err := time.Parse(time.RFC3339, rev.ModifiedTime)
+	if err != nil {
+		return name
+	}
+	return modified.UTC().Format("20060102T150405Z") + "_" + name
+}
@@ -0,0 +1,100 @@
+package drive
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+)
+
+func TestParseRevisionLimit(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    int
+		wantErr bool
+	}{
+		{spec: "all", want: 0},
+		{spec: "latest", want: 1},
+		{spec: "LATEST", want: 1},
+		{spec: "5", want: 5},
+		{spec: " 3", want: 3},
+		{spec: "0", wantErr: true},
+		{spec: "-2", wantErr: true},
+		{spec: "some", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseRevisionLimit(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseRevisionLimit(%q) = %d, want %d", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDownloadRevisionsExportsNativeFiles(t *testing.T) {
+	fake := newFakeDrive()
+	fake.files["doc"] = &drive.File{Id: "doc", Name: "notes", MimeType: "application/vnd.google-apps.document", Parents: []string{"root"}}
+	fake.revisions["doc"] = []string{"r1", "r2"}
+	d := newTestService(t, fake)
+
+	outputDir := t.TempDir()
+	file := FileInfo{ID: "doc", Name: "notes", Path: "notes", MimeType: "application/vnd.google-apps.document"}
+	if err := d.DownloadRevisions(file, outputDir, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, rev := range []string{"r1", "r2"} {
+		path := filepath.Join(outputDir, "notes.revisions", rev, "notes.pdf")
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("revision %s was not exported: %v", rev, err)
+		}
+		if want := "exported doc/" + rev; string(got) != want {
+			t.Errorf("%s = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestDownloadRevisionsRetries(t *testing.T) {
+	defer func(delay time.Duration) { retryBaseDelay = delay }(retryBaseDelay)
+	retryBaseDelay = time.Millisecond
+
+	fake := newFakeDrive()
+	fake.files["doc"] = &drive.File{Id: "doc", Name: "notes", MimeType: "application/vnd.google-apps.document", Parents: []string{"root"}}
+	fake.revisions["doc"] = []string{"r1", "r2"}
+	fake.failures["files/doc/revisions"] = []int{http.StatusServiceUnavailable}
+	fake.failures["revexports/doc/r1"] = []int{http.StatusTooManyRequests, http.StatusInternalServerError}
+	d := newTestService(t, fake)
+
+	outputDir := t.TempDir()
+	file := FileInfo{ID: "doc", Name: "notes", Path: "notes", MimeType: "application/vnd.google-apps.document"}
+	if err := d.DownloadRevisions(file, outputDir, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(outputDir, "notes.revisions", "r1", "notes.pdf")); string(got) != "exported doc/r1" {
+		t.Errorf("r1 = %q after retries, want its export", got)
+	}
+
+	// A failure that isn't transient is returned as the API error
+	fake.failures["revexports/doc/r2"] = []int{http.StatusNotFound}
+	err := d.DownloadRevisions(file, t.TempDir(), 0)
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != http.StatusNotFound {
+		t.Fatalf("error = %v, want the 404 from Drive", err)
+	}
+}
@@ -0,0 +1,230 @@
+package drive
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	pathpkg "path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// s3MaxObjectSize is the largest object a single S3 PUT can create
+const s3MaxObjectSize = 5 << 30
+
+// S3Sink uploads files as objects of an Amazon S3 bucket, or of a service
+// compatible with it, named after their path below a prefix. Requests are
+// signed with AWS Signature Version 4. Each file is spooled to a temporary
+// file as it downloads, since a PUT needs its size up front, and uploaded
+// once complete, so files of up to 5 GiB can be saved.
+type S3Sink struct {
+	ctx    context.Context
+	client *http.Client
+	bucket string
+	prefix string
+	region string
+	// endpoint, if set, is used with path-style URLs instead of the
+	// virtual-hosted AWS endpoint of the bucket
+	endpoint string
+
+	accessKey    string
+	secretKey    string
+	sessionToken string
+}
+
+// NewS3Sink returns a sink uploading to bucket with the AWS credentials of
+// the environment: AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and, for
+// temporary credentials, AWS_SESSION_TOKEN. The region is taken from
+// AWS_REGION or AWS_DEFAULT_REGION, us-east-1 by default, and
+// AWS_ENDPOINT_URL_S3 or AWS_ENDPOINT_URL names a compatible service such as
+// MinIO in place of AWS.
+func NewS3Sink(ctx context.Context, bucket, prefix string) (*S3Sink, error) {
+	s := &S3Sink{
+		ctx:          ctx,
+		client:       http.DefaultClient,
+		bucket:       bucket,
+		prefix:       strings.Trim(prefix, "/"),
+		region:       firstEnv("AWS_REGION", "AWS_DEFAULT_REGION"),
+		endpoint:     strings.TrimSuffix(firstEnv("AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL"), "/"),
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if s.accessKey == "" || s.secretKey == "" {
+		return nil, fmt.Errorf("%w: an S3 sink needs AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY to be set", ErrInvalidCredentials)
+	}
+	if s.region == "" {
+		s.region = "us-east-1"
+	}
+	return s, nil
+}
+
+// firstEnv returns the first of the environment variables that is set
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// objectURL returns the URL of the object with the given key
+func (s *S3Sink) objectURL(key string) string {
+	if s.endpoint != "" {
+		return s.endpoint + "/" + s.bucket + "/" + key
+	}
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", s.bucket, s.region, key)
+}
+
+// Create spools the file to a temporary file, uploaded when closed
+func (s *S3Sink) Create(path string) (SinkWriter, error) {
+	spool, err := os.CreateTemp("", "gdrive-s3-*")
+	if err != nil {
+		return nil, fmt.Errorf("unable to create temporary file: %v", err)
+	}
+	return &s3Writer{sink: s, key: pathpkg.Join(s.prefix, path), spool: spool, sum: sha256.New()}, nil
+}
+
+// s3Writer spools an object for S3Sink, which Close uploads
+type s3Writer struct {
+	sink  *S3Sink
+	key   string
+	spool *os.File
+	sum   hash.Hash
+	size  int64
+}
+
+func (w *s3Writer) Write(p []byte) (int, error) {
+	n, err := w.spool.Write(p)
+	w.sum.Write(p[:n])
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *s3Writer) Abort(error) {
+	w.spool.Close()
+	os.Remove(w.spool.Name())
+}
+
+func (w *s3Writer) Close() error {
+	defer w.Abort(nil)
+	if w.size > s3MaxObjectSize {
+		return fmt.Errorf("unable to upload %s: S3 takes at most 5 GiB in a single upload", w.key)
+	}
+	if _, err := w.spool.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("unable to read temporary file: %v", err)
+	}
+
+	s := w.sink
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodPut, s.objectURL(s3EscapePath(w.key)), io.NopCloser(w.spool))
+	if err != nil {
+		return fmt.Errorf("unable to upload %s: %v", w.key, err)
+	}
+	req.ContentLength = w.size
+	if s.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.sessionToken)
+	}
+	signV4(req, hex.EncodeToString(w.sum.Sum(nil)), s.accessKey, s.secretKey, s.region, time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to upload %s: %v", w.key, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unable to upload %s: %s: %s", w.key, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// s3EscapePath escapes an object key for use in a URL path, as Signature
+// Version 4 expects for S3: every byte but unreserved characters and "/"
+func s3EscapePath(key string) string {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-_.~/", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// signV4 signs a request to S3 with AWS Signature Version 4, covering the
+// host and every header already set. payloadHash is the hex SHA-256 of the
+// body.
+func signV4(req *http.Request, payloadHash, accessKey, secretKey, region string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := amzDate[:8] + "/" + region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := []byte("AWS4" + secretKey)
+	for _, part := range []string{amzDate[:8], region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+}
+
+// canonicalQuery returns query parameters sorted and escaped as Signature
+// Version 4 expects
+func canonicalQuery(query url.Values) string {
+	var pairs []string
+	for name, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, s3EscapePath(name)+"="+strings.ReplaceAll(s3EscapePath(value), "/", "%2F"))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
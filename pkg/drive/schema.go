@@ -0,0 +1,83 @@
+package drive
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// schemaURI is the JSON Schema dialect emitted by FileInfoSchema
+const schemaURI = "https://json-schema.org/draft/2020-12/schema"
+
+// FileInfoSchema returns a JSON Schema document describing how FileInfo is
+// encoded as JSON. It is generated from the struct's json tags, so fields
+// added to FileInfo appear automatically.
+func FileInfoSchema() ([]byte, error) {
+	schema := schemaFor(reflect.TypeOf(FileInfo{}))
+	schema["$schema"] = schemaURI
+	schema["title"] = "FileInfo"
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaFor returns the JSON Schema for values of type t
+func schemaFor(t reflect.Type) map[string]interface{} {
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Ptr:
+		return schemaFor(t.Elem())
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaFor(t.Elem())}
+	case reflect.Struct:
+		return structSchema(t)
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// structSchema describes a struct's exported fields under their json names.
+// Fields without omitempty are listed as required.
+func structSchema(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	required := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = schemaFor(field.Type)
+		if !strings.Contains(opts, "omitempty") {
+			required = append(required, name)
+		}
+	}
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
@@ -0,0 +1,53 @@
+package drive
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestFileInfoSchema(t *testing.T) {
+	data, err := FileInfoSchema()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var schema struct {
+		Schema     string                            `json:"$schema"`
+		Type       string                            `json:"type"`
+		Properties map[string]map[string]interface{} `json:"properties"`
+		Required   []string                          `json:"required"`
+	}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("schema is not valid JSON: %v", err)
+	}
+	if schema.Schema != schemaURI || schema.Type != "object" {
+		t.Errorf("$schema = %q, type = %q", schema.Schema, schema.Type)
+	}
+
+	// Every field of an encoded FileInfo must be described
+	encoded, err := json.Marshal(FileInfo{MD5: "x", Owners: []string{"a"}, Permissions: []Permission{{}}, ThumbnailLink: "x", ModifiedTime: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		t.Fatal(err)
+	}
+	for name := range fields {
+		if _, ok := schema.Properties[name]; !ok {
+			t.Errorf("field %q missing from schema", name)
+		}
+	}
+
+	if got := schema.Properties["modifiedAt"]["format"]; got != "date-time" {
+		t.Errorf("modifiedAt format = %v, want date-time", got)
+	}
+	if got := schema.Properties["size"]["type"]; got != "integer" {
+		t.Errorf("size type = %v, want integer", got)
+	}
+	wantRequired := []string{"id", "name", "path", "mimeType", "modifiedAt", "createdAt", "size", "isFolder"}
+	if !reflect.DeepEqual(schema.Required, wantRequired) {
+		t.Errorf("required = %v, want %v", schema.Required, wantRequired)
+	}
+}
@@ -0,0 +1,90 @@
+package drive
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// OAuth scopes for NewDriveServiceWithScope
+const (
+	// ReadonlyScope allows listing and downloading files
+	ReadonlyScope = drive.DriveReadonlyScope
+
+	// FullScope also allows modifying files, such as moving them to the trash
+	FullScope = drive.DriveScope
+)
+
+// tokenInfoURL is Google's endpoint describing an access token
+var tokenInfoURL = "https://oauth2.googleapis.com/tokeninfo"
+
+// readOnlyScopes are the Drive scopes that cannot modify files
+var readOnlyScopes = map[string]bool{
+	drive.DriveReadonlyScope:         true,
+	drive.DriveMetadataReadonlyScope: true,
+	drive.DrivePhotosReadonlyScope:   true,
+}
+
+// GrantedScopes returns the OAuth scopes actually granted to the access
+// token the service authenticates with: those the token endpoint sent with
+// it or, if it sent none, those reported by Google's tokeninfo endpoint.
+// User credentials get the scopes the user authorized, which may be wider
+// than the scope the service asks for. Service accounts get the scope asked
+// for, whatever the files shared with them allow.
+func (d *DriveService) GrantedScopes() ([]string, error) {
+	if d.tokens == nil {
+		return nil, fmt.Errorf("no token source to inspect")
+	}
+	token, err := d.tokens.Token()
+	if err != nil {
+		return nil, fmt.Errorf("unable to obtain an access token: %v", err)
+	}
+	if scope, ok := token.Extra("scope").(string); ok && scope != "" {
+		scopes := strings.Fields(scope)
+		sort.Strings(scopes)
+		return scopes, nil
+	}
+
+	req, err := http.NewRequestWithContext(d.requestContext(), http.MethodGet,
+		tokenInfoURL+"?access_token="+url.QueryEscape(token.AccessToken), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to inspect access token: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to inspect access token: %s", resp.Status)
+	}
+
+	var info struct {
+		Scope string `json:"scope"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, fmt.Errorf("unable to decode token info: %v", err)
+	}
+	scopes := strings.Fields(info.Scope)
+	sort.Strings(scopes)
+	return scopes, nil
+}
+
+// WriteScopes returns the scopes that allow modifying Drive files: every
+// Drive scope except the read-only ones, and the all-encompassing
+// cloud-platform scope
+func WriteScopes(scopes []string) []string {
+	var write []string
+	for _, scope := range scopes {
+		isDrive := scope == drive.DriveScope || strings.HasPrefix(scope, drive.DriveScope+".")
+		if (isDrive && !readOnlyScopes[scope]) || scope == "https://www.googleapis.com/auth/cloud-platform" {
+			write = append(write, scope)
+		}
+	}
+	return write
+}
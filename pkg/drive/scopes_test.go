@@ -0,0 +1,73 @@
+package drive
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+func TestGrantedScopes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("access_token") != "secret" {
+			http.Error(w, `{"error": "invalid_token"}`, http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `{"scope": "https://www.googleapis.com/auth/drive.readonly openid", "expires_in": "3599"}`)
+	}))
+	defer srv.Close()
+	defer func(u string) { tokenInfoURL = u }(tokenInfoURL)
+	tokenInfoURL = srv.URL
+
+	d := &DriveService{tokens: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "secret"})}
+	scopes, err := d.GrantedScopes()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"https://www.googleapis.com/auth/drive.readonly", "openid"}
+	if !reflect.DeepEqual(scopes, want) {
+		t.Errorf("GrantedScopes() = %v, want %v", scopes, want)
+	}
+
+	d = &DriveService{tokens: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "wrong"})}
+	if _, err := d.GrantedScopes(); err == nil {
+		t.Error("expected error for rejected token")
+	}
+
+	// The scopes sent with the token are used without asking tokeninfo
+	token := (&oauth2.Token{AccessToken: "wrong"}).WithExtra(map[string]interface{}{
+		"scope": "https://www.googleapis.com/auth/drive openid",
+	})
+	d = &DriveService{tokens: oauth2.StaticTokenSource(token)}
+	scopes, err = d.GrantedScopes()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want = []string{"https://www.googleapis.com/auth/drive", "openid"}
+	if !reflect.DeepEqual(scopes, want) {
+		t.Errorf("GrantedScopes() = %v, want %v", scopes, want)
+	}
+}
+
+func TestWriteScopes(t *testing.T) {
+	scopes := []string{
+		"https://www.googleapis.com/auth/drive",
+		"https://www.googleapis.com/auth/drive.readonly",
+		"https://www.googleapis.com/auth/drive.file",
+		"https://www.googleapis.com/auth/drive.metadata.readonly",
+		"https://www.googleapis.com/auth/drivers",
+		"https://www.googleapis.com/auth/cloud-platform",
+		"openid",
+	}
+	want := []string{
+		"https://www.googleapis.com/auth/drive",
+		"https://www.googleapis.com/auth/drive.file",
+		"https://www.googleapis.com/auth/cloud-platform",
+	}
+	if got := WriteScopes(scopes); !reflect.DeepEqual(got, want) {
+		t.Errorf("WriteScopes() = %v, want %v", got, want)
+	}
+}
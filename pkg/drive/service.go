@@ -2,91 +2,635 @@ package drive
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
+	pathpkg "path"
 	"path/filepath"
 	"regexp"
-	"sort"
 	"strings"
+	"sync/atomic"
+	"time"
 
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
 	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/option"
+	htransport "google.golang.org/api/transport/http"
 )
 
 type DriveService struct {
-	service *drive.Service
-	verbose bool
+	service  *drive.Service
+	client   *http.Client
+	verbose  bool
+	progress ProgressFunc
+
+	// out receives the messages printed while listing and downloading; see
+	// WithOutput
+	out io.Writer
+
+	// driveNames caches shared drive names by ID
+	driveNames map[string]string
+
+	// ctx bounds every Drive request; see WithContext
+	ctx context.Context
+
+	// retries overrides DefaultRetries; see WithRetries
+	retries *int
+
+	// retryBudget, when set, is the number of retries left for the whole
+	// run; see WithRetryBudget
+	retryBudget *atomic.Int64
+
+	// tokens issues the access tokens GrantedScopes inspects
+	tokens oauth2.TokenSource
+
+	// transport carries the authenticated client's requests; see WithConnLimits
+	transport *http.Transport
+
+	// tracer logs the requests sent over transport; see WithHTTPTrace
+	tracer *traceTransport
+
+	// rate holds requests back to a set rate; see WithRateLimit
+	rate *rateTransport
+
+	// hooks are called while crawling; see WithCrawlHooks
+	hooks CrawlHooks
+
+	// fileMode and dirMode override the permissions downloads are created
+	// with; see WithFileModes
+	fileMode os.FileMode
+	dirMode  os.FileMode
+
+	// counters track crawl progress; see CrawlStats
+	counters crawlCounters
+
+	// api counts the requests sent over transport; see APIStats
+	api apiCounters
+}
+
+// ListOptions controls which files ListFiles returns
+type ListOptions struct {
+	// FolderIDs are the folders to crawl; the root folder is used when empty
+	FolderIDs  []string
+	Pattern    string
+	Extensions []string
+	MaxDepth   int
+	MaxResults int
+	OrderBy    SortOrder
+
+	// MaxPerExtension caps the results kept for each file extension (0 for unlimited)
+	MaxPerExtension int
+
+	// MaxPerFolder caps the results kept for each folder, grouped by the
+	// folder of their path (0 for unlimited)
+	MaxPerFolder int
+
+	// MaxFolders caps the folders listed, roots included (0 for unlimited).
+	// Once reached, no further folders are listed and the files already
+	// found are returned.
+	MaxFolders int
+
+	// MatchFolders includes folders whose names match in the results
+	MatchFolders bool
+
+	// LeafOnly restricts results to files in folders without subfolders of
+	// their own, trashed ones aside. Folders matched with MatchFolders and
+	// files outside any crawled folder, such as those shared directly with
+	// the account, are kept.
+	LeafOnly bool
+
+	// MatchTarget is what Pattern and Extensions are matched against:
+	// MatchName (the default when empty) or MatchPath
+	MatchTarget string
+
+	// FolderPattern, when set, restricts results to files inside a folder
+	// whose name matches this regex, directly or further down. Only the
+	// folders below those crawled are checked. It filters the files found
+	// rather than pruning the crawl, as a matching folder may sit below one
+	// that doesn't match.
+	FolderPattern string
+
+	// Owners restricts results to files owned by one of these email addresses
+	Owners []string
+
+	// OwnedByMe restricts results to files the account owns, filtered
+	// server-side with 'me' in owners. Unlike SharedWithMe, which adds the
+	// items shared with the account, it removes every file the account
+	// doesn't own, wherever it is found. Files in shared drives have no
+	// owner and never match. With a verbose service, the filter is applied
+	// after listing instead, so the files it excludes can be counted.
+	OwnedByMe bool
+
+	// Exclude, if set, holds the IDs of files to leave out of the results,
+	// such as those downloaded by earlier runs. Their files are still
+	// listed, but checked against it before any matching work.
+	Exclude *BloomFilter
+
+	// LastModifiedBy restricts results to files last modified by one of these
+	// email addresses. Files Drive reports no last modifier for never match.
+	LastModifiedBy []string
+
+	// Query is a Drive query ANDed with the generated one, evaluated
+	// server-side before the name pattern is applied
+	Query string
+
+	// CreatedAfter and CreatedBefore, when non-zero, restrict results to
+	// files created within those bounds (exclusive)
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+
+	// ModifiedAfter, when non-zero, restricts results to files modified
+	// after it (exclusive)
+	ModifiedAfter time.Time
+
+	// Categories restricts results to files in one of these categories
+	Categories []Category
+
+	// Types restricts results to files of one of these types, such as
+	// "video" or "pdf" (see FileTypeNames), filtered server-side by MIME type
+	Types []string
+
+	// SharedWithMe also crawls the files and folders shared directly with
+	// the account, which are outside its My Drive. The root folder is not
+	// crawled unless listed in FolderIDs.
+	SharedWithMe bool
+
+	// PrefetchPaths looks up the parent folders of the files found by
+	// searches outside the crawled tree, such as SharedWithMe, in batched
+	// queries up front, instead of one request per folder of each file
+	PrefetchPaths bool
+
+	// OnPathError is what to do with a file found outside the crawled tree
+	// whose path can't be looked up: one of the PathError policies, with ""
+	// for PathErrorShallow
+	OnPathError string
+
+	// PageSize is the number of files requested per page of a folder
+	// listing, from 1 to MaxPageSize (0 for MaxPageSize)
+	PageSize int
+
+	// Labels restricts results to files matching every one of these Drive
+	// label filters
+	Labels []LabelFilter
 }
 
 type FileInfo struct {
-	ID           string
-	Name         string
-	Path         string
-	MimeType     string
-	ModifiedTime string
+	ID             string       `json:"id"`
+	Name           string       `json:"name"`
+	Path           string       `json:"path"`
+	MimeType       string       `json:"mimeType"`
+	ModifiedTime   string       `json:"modifiedTime,omitempty"`
+	ModifiedAt     time.Time    `json:"modifiedAt"`
+	CreatedTime    string       `json:"createdTime,omitempty"`
+	CreatedAt      time.Time    `json:"createdAt"`
+	Size           int64        `json:"size"`
+	MD5            string       `json:"md5,omitempty"`
+	HeadRevisionID string       `json:"headRevisionId,omitempty"`
+	Owners         []string     `json:"owners,omitempty"`
+	LastModifiedBy string       `json:"lastModifiedBy,omitempty"`
+	Labels         []FileLabel  `json:"labels,omitempty"`
+	Permissions    []Permission `json:"permissions,omitempty"`
+	ThumbnailLink  string       `json:"thumbnailLink,omitempty"`
+	IsFolder       bool         `json:"isFolder"`
+	ParentID       string       `json:"parentId,omitempty"`
+	DriveID        string       `json:"driveId,omitempty"`
+	WebViewLink    string       `json:"webViewLink,omitempty"`
+	WebContentLink string       `json:"webContentLink,omitempty"`
+}
+
+// Owner returns the email address of the file's first owner, or "unknown"
+// for files without one, such as those in shared drives
+func (f FileInfo) Owner() string {
+	if len(f.Owners) == 0 {
+		return "unknown"
+	}
+	return f.Owners[0]
+}
+
+// HasSize reports whether Drive reported the size of the file's content.
+// Google-native files, shortcuts and folders may have none, which Drive
+// leaves out rather than reporting as 0; an empty uploaded file still has a
+// checksum, so its size of 0 counts as known.
+func (f FileInfo) HasSize() bool {
+	return f.Size > 0 || f.MD5 != ""
+}
+
+// HasChecksum reports whether Drive reported an MD5 checksum of the file's
+// content, which it only does for content stored in Drive, not for
+// Google-native files
+func (f FileInfo) HasChecksum() bool {
+	return f.MD5 != ""
+}
+
+// ErrNoRootFolder is returned by ListFiles when no folder IDs are given and
+// the credentials have no My Drive to start from
+var ErrNoRootFolder = errors.New("no root folder")
+
+const folderMimeType = "application/vnd.google-apps.folder"
+
+// fileDetailFields lists the fields requested for every file found
+const fileDetailFields = "id, name, mimeType, trashed, driveId, owners, ownedByMe, lastModifyingUser(emailAddress), permissions(type, role, emailAddress, domain), parents, modifiedTime, createdTime, size, md5Checksum, headRevisionId, thumbnailLink, webViewLink, webContentLink, labelInfo"
+
+// fileFields lists the fields requested for every file in a listing
+const fileFields = "nextPageToken, files(" + fileDetailFields + ")"
+
+// crawl holds the state shared by a single ListFiles or WalkFiles traversal
+type crawl struct {
+	opts    ListOptions
+	pattern matcher
+	files   []FileInfo
+	seen    map[string]bool // IDs already found, as roots may overlap
+	found   int
+
+	// folderPattern is the compiled FolderPattern, if any
+	folderPattern *regexp.Regexp
+
+	// folders counts the folders listed, for MaxFolders
+	folders atomic.Int64
+
+	// out, when set, receives files as they are found instead of files;
+	// the crawl stops once done is closed
+	out          chan<- FileInfo
+	done         <-chan struct{}
+	extCounts    map[string]int
+	folderCounts map[string]int
+
+	// countNotOwned applies OwnedByMe after listing, counting the files it
+	// excludes in notOwned
+	countNotOwned bool
+	notOwned      int
+
+	// excluded counts the files left out by ListOptions.Exclude
+	excluded int
 }
 
 func NewDriveService(credentialsFile string, verbose bool) (*DriveService, error) {
+	return NewDriveServiceWithScope(credentialsFile, verbose, FullScope)
+}
+
+// NewDriveServiceWithScope is like NewDriveService but requests the given
+// OAuth scope, such as ReadonlyScope, instead of full Drive access. An empty
+// credentialsFile uses Application Default Credentials; see defaultCredentials.
+func NewDriveServiceWithScope(credentialsFile string, verbose bool, scope string) (*DriveService, error) {
 	ctx := context.Background()
-	srv, err := drive.NewService(ctx, option.WithCredentialsFile(credentialsFile))
+	var creds *google.Credentials
+	if credentialsFile == "" {
+		var err error
+		if creds, err = defaultCredentials(ctx, scope); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := validateCredentialsFile(credentialsFile); err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(credentialsFile)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+		}
+		creds, err = google.CredentialsFromJSON(ctx, data, scope)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to load credentials: %v", ErrInvalidCredentials, err)
+		}
+	}
+
+	d := &DriveService{
+		verbose:   verbose,
+		tokens:    creds.TokenSource,
+		transport: newBaseTransport(),
+	}
+	d.tracer = &traceTransport{next: &countingTransport{next: d.transport, counters: &d.api}}
+	d.rate = &rateTransport{next: d.tracer}
+	authenticated, err := htransport.NewTransport(ctx, d.rate, option.WithCredentials(creds))
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to create HTTP client: %v", ErrInvalidCredentials, err)
+	}
+	// Keep the authenticated client for requests the Drive API library
+	// doesn't wrap, such as fetching thumbnail links
+	d.client = &http.Client{Transport: &authTransport{next: authenticated, counters: &d.api}}
+
+	d.service, err = drive.NewService(ctx, option.WithHTTPClient(d.client))
 	if err != nil {
-		return nil, fmt.Errorf("unable to create Drive service: %v", err)
+		return nil, fmt.Errorf("%w: unable to create Drive service: %v", ErrInvalidCredentials, err)
 	}
+	return d, nil
+}
+
+// WithContext sets the context every Drive request is made with. Cancelling
+// it aborts listing and downloads in progress.
+func (d *DriveService) WithContext(ctx context.Context) *DriveService {
+	d.ctx = ctx
+	return d
+}
+
+// requestContext returns the context set by WithContext, or a background
+// context if none was set
+func (d *DriveService) requestContext() context.Context {
+	if d.ctx == nil {
+		return context.Background()
+	}
+	return d.ctx
+}
 
-	return &DriveService{service: srv, verbose: verbose}, nil
+// WithOutput sets where messages about listing and downloads are printed,
+// standard output by default. Verbose logging goes there too.
+func (d *DriveService) WithOutput(w io.Writer) *DriveService {
+	d.out = w
+	return d
+}
+
+// printf prints a message to the writer set by WithOutput
+func (d *DriveService) printf(format string, args ...interface{}) {
+	out := d.out
+	if out == nil {
+		out = os.Stdout
+	}
+	fmt.Fprintf(out, format, args...)
 }
 
 func (d *DriveService) log(format string, args ...interface{}) {
 	if d.verbose {
-		fmt.Printf(format+"\n", args...)
+		d.printf(format+"\n", args...)
 	}
 }
 
-func (d *DriveService) ListFiles(folderID string, pattern string, maxDepth int, maxResults int) ([]FileInfo, error) {
-	var files []FileInfo
-	regex, err := regexp.Compile(pattern)
+func (d *DriveService) ListFiles(opts ListOptions) ([]FileInfo, error) {
+	c, folderIDs, err := d.newCrawl(opts)
 	if err != nil {
-		return nil, fmt.Errorf("invalid regex pattern: %v", err)
+		return nil, err
+	}
+
+	if err := d.crawlRoots(c, folderIDs); err != nil {
+		return nil, err
 	}
+	files := d.sortAndLimit(c.files, opts)
 
-	d.log("Starting search with pattern: %s", pattern)
+	d.log("\nSearch completed. Found %d matching files (showing %d).", len(files), len(files))
+	return files, nil
+}
+
+// sortAndLimit orders the files found by a search and applies its
+// MaxPerExtension, MaxPerFolder and MaxResults limits
+func (d *DriveService) sortAndLimit(files []FileInfo, opts ListOptions) []FileInfo {
+	order := opts.OrderBy
+	if order.Field == "" {
+		order = DefaultSortOrder
+	}
+	SortFiles(files, order)
+
+	if opts.MaxPerExtension > 0 {
+		files = LimitPerExtension(files, opts.MaxPerExtension)
+		d.log("Kept %d files after limiting to %d per extension", len(files), opts.MaxPerExtension)
+	}
+	if opts.MaxPerFolder > 0 {
+		files = LimitPerFolder(files, opts.MaxPerFolder)
+		d.log("Kept %d files after limiting to %d per folder", len(files), opts.MaxPerFolder)
+	}
+
+	// Limit results if maxResults is specified
+	if opts.MaxResults > 0 && len(files) > opts.MaxResults {
+		files = files[:opts.MaxResults]
+	}
+	return files
+}
+
+// newCrawl compiles the matchers for opts and resolves the folders to crawl
+func (d *DriveService) newCrawl(opts ListOptions) (*crawl, []string, error) {
+	if err := ValidateMatchTarget(opts.MatchTarget); err != nil {
+		return nil, nil, err
+	}
+	m, err := d.compileMatcher(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	folderPattern, err := d.compileFolderPattern(opts.FolderPattern)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := ValidateQuery(opts.Query); err != nil {
+		return nil, nil, err
+	}
+	if err := ValidateFileTypes(opts.Types); err != nil {
+		return nil, nil, err
+	}
+	if err := d.checkLabels(opts); err != nil {
+		return nil, nil, err
+	}
+	if err := d.checkQuery(opts); err != nil {
+		return nil, nil, err
+	}
 
 	// First, get the root folder if no folder ID is provided
-	if folderID == "" {
+	folderIDs := opts.FolderIDs
+	if len(folderIDs) == 0 && !opts.SharedWithMe {
 		d.log("No folder ID provided, getting root folder...")
-		root, err := d.service.Files.Get("root").Fields("id").Do()
+		var root *drive.File
+		err := d.retryDo("Looking up the root folder", func() (err error) {
+			root, err = d.service.Files.Get("root").Fields("id").Context(d.requestContext()).Do()
+			return err
+		})
+		var apiErr *googleapi.Error
+		if errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+			return nil, nil, fmt.Errorf("%w: the credentials have no My Drive root folder, as is usual for service accounts; pass -folder-id with a folder shared with the account, or -shared-with-me", ErrNoRootFolder)
+		}
 		if err != nil {
-			return nil, fmt.Errorf("unable to get root folder: %v", err)
+			return nil, nil, fmt.Errorf("unable to get root folder: %w", err)
 		}
-		folderID = root.Id
-		d.log("Using root folder ID: %s", folderID)
+		folderIDs = []string{root.Id}
+		d.log("Using root folder ID: %s", root.Id)
 	}
 
-	err = d.listFilesRecursive(folderID, "", regex, maxDepth, 0, maxResults, &files)
-	if err != nil {
-		return nil, err
+	c := &crawl{opts: opts, pattern: m, folderPattern: folderPattern, seen: make(map[string]bool)}
+	c.countNotOwned = opts.OwnedByMe && d.verbose
+	return c, folderIDs, nil
+}
+
+// fileQuery returns c.opts.fileQuery, without the OwnedByMe clause when
+// ownership is checked after listing
+func (c *crawl) fileQuery() string {
+	opts := c.opts
+	if c.countNotOwned {
+		opts.OwnedByMe = false
+	}
+	return opts.fileQuery()
+}
+
+// crawlRoots crawls each folder, then the items shared with the account if
+// requested. All roots share one crawl so maxResults applies to the combined
+// results.
+func (d *DriveService) crawlRoots(c *crawl, folderIDs []string) error {
+	for _, folderID := range folderIDs {
+		if err := d.listFilesRecursive(c, folderID, "", 0); err != nil {
+			return err
+		}
+	}
+	if c.opts.SharedWithMe {
+		if err := d.listSharedWithMe(c); err != nil {
+			return err
+		}
 	}
+	if c.countNotOwned {
+		d.log("Skipped %d files not owned by the account", c.notOwned)
+	}
+	if c.excluded > 0 {
+		d.log("Skipped %d files already downloaded before matching them", c.excluded)
+	}
+	return nil
+}
 
-	// Sort files by modification time (newest first)
-	sort.Slice(files, func(i, j int) bool {
-		return files[i].ModifiedTime > files[j].ModifiedTime
-	})
+// WalkFiles streams matching files as they are found, so they can be
+// processed while the crawl continues. Files arrive in crawl order: OrderBy
+// is ignored, MaxResults keeps the first files found and MaxPerExtension and
+// MaxPerFolder the first files found for each extension and folder. The crawl blocks until each file is
+// received, and stops early once done is closed. The error channel receives
+// the crawl's result after the file channel is closed.
+func (d *DriveService) WalkFiles(opts ListOptions, done <-chan struct{}) (<-chan FileInfo, <-chan error) {
+	out := make(chan FileInfo)
+	errc := make(chan error, 1)
+
+	go func() {
+		defer close(errc)
+		defer close(out)
+
+		c, folderIDs, err := d.newCrawl(opts)
+		if err != nil {
+			errc <- err
+			return
+		}
+		c.out, c.done = out, done
+		if opts.MaxPerExtension > 0 {
+			c.extCounts = make(map[string]int)
+		}
+		if opts.MaxPerFolder > 0 {
+			c.folderCounts = make(map[string]int)
+		}
 
-	// Limit results if maxResults is specified
-	if maxResults > 0 && len(files) > maxResults {
-		files = files[:maxResults]
+		if err := d.crawlRoots(c, folderIDs); err != nil {
+			errc <- err
+			return
+		}
+		d.log("\nSearch completed. Streamed %d matching files.", c.found)
+		errc <- nil
+	}()
+
+	return out, errc
+}
+
+// stopped reports whether the crawl has found enough files or its consumer
+// has gone away
+func (c *crawl) stopped() bool {
+	if c.opts.MaxResults > 0 && c.found >= c.opts.MaxResults && c.stopsAtMax() {
+		return true
 	}
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
 
-	d.log("\nSearch completed. Found %d matching files (showing %d).", len(files), len(files))
-	return files, nil
+// stopsAtMax reports whether the crawl can stop once it has found
+// MaxResults files. Only a streaming crawl keeps the first files found: a
+// listing keeps the first in sort order, and caps the files per extension
+// and per folder, once every file has been found.
+func (c *crawl) stopsAtMax() bool {
+	return c.out != nil
 }
 
+// add appends a file to the results unless it was already found, reporting
+// whether it was kept
+func (c *crawl) add(info FileInfo) bool {
+	if c.seen[info.ID] {
+		return false
+	}
+	c.seen[info.ID] = true
+
+	if c.out == nil {
+		c.files = append(c.files, info)
+		c.found++
+		return true
+	}
+
+	ext := strings.ToLower(filepath.Ext(info.Name))
+	dir := pathpkg.Dir(info.Path)
+	if c.extCounts != nil && c.extCounts[ext] >= c.opts.MaxPerExtension {
+		return false
+	}
+	if c.folderCounts != nil && c.folderCounts[dir] >= c.opts.MaxPerFolder {
+		return false
+	}
+	if c.extCounts != nil {
+		c.extCounts[ext]++
+	}
+	if c.folderCounts != nil {
+		c.folderCounts[dir]++
+	}
+	if c.stopped() {
+		return false
+	}
+	select {
+	case c.out <- info:
+		c.found++
+		return true
+	case <-c.done:
+		return false
+	}
+}
+
+// newFileInfo converts a Drive file found at path into a FileInfo
+func (d *DriveService) newFileInfo(f *drive.File, path string) FileInfo {
+	info := FileInfo{
+		ID:             f.Id,
+		Name:           f.Name,
+		Path:           path,
+		MimeType:       f.MimeType,
+		ModifiedTime:   f.ModifiedTime,
+		CreatedTime:    f.CreatedTime,
+		Size:           f.Size,
+		MD5:            f.Md5Checksum,
+		HeadRevisionID: f.HeadRevisionId,
+		Owners:         ownerEmails(f.Owners),
+		LastModifiedBy: modifierEmail(f.LastModifyingUser),
+		Labels:         newFileLabels(f.LabelInfo),
+		Permissions:    newPermissions(f.Permissions),
+		ThumbnailLink:  f.ThumbnailLink,
+		IsFolder:       f.MimeType == folderMimeType,
+		DriveID:        f.DriveId,
+		WebViewLink:    f.WebViewLink,
+		WebContentLink: f.WebContentLink,
+	}
+	if len(f.Parents) > 0 {
+		info.ParentID = f.Parents[0]
+	}
+
+	if f.ModifiedTime != "" {
+		modifiedAt, err := time.Parse(time.RFC3339, f.ModifiedTime)
+		if err != nil {
+			d.log("⚠️ Unable to parse modified time %q of %s: %v", f.ModifiedTime, path, err)
+		} else {
+			info.ModifiedAt = modifiedAt
+		}
+	}
+	if f.CreatedTime != "" {
+		createdAt, err := time.Parse(time.RFC3339, f.CreatedTime)
+		if err != nil {
+			d.log("⚠️ Unable to parse created time %q of %s: %v", f.CreatedTime, path, err)
+		} else {
+			info.CreatedAt = createdAt
+		}
+	}
+	return info
+}
+
+// getFullPath looks up the path of a file by following its first parent,
+// retrying transient failures. A parent the account can't see ends the
+// path; any other failure is returned.
 func (d *DriveService) getFullPath(fileID string, folderNames map[string]string) (string, error) {
-	file, err := d.service.Files.Get(fileID).
-		Fields("id, name, parents").
-		SupportsAllDrives(true).
-		Do()
+	file, err := d.getFolder(fileID)
 	if err != nil {
 		return "", err
 	}
@@ -94,14 +638,77 @@ func (d *DriveService) getFullPath(fileID string, folderNames map[string]string)
 	path := file.Name
 	if len(file.Parents) > 0 {
 		parentPath, err := d.getFullPath(file.Parents[0], folderNames)
+		if isNotFound(err) {
+			// The path starts at the highest folder the account can see
+			return path, nil
+		}
 		if err != nil {
-			return path, nil // Return just the file name if we can't get parent path
+			return path, err
 		}
-		path = filepath.Join(parentPath, path)
+		path = joinPath(parentPath, path)
 	}
 	return path, nil
 }
 
+// ParentLink is a file or folder in the chain of parents of a file
+type ParentLink struct {
+	ID      string
+	Name    string
+	DriveID string
+	// Parents lists every parent Drive reports; the chain follows the first
+	Parents []string
+	// Err is set for a parent that couldn't be fetched, which ends the chain
+	Err error
+}
+
+// ParentChain returns a file and its ancestors, from the file up, as
+// getFullPath walks them: following the first parent until a folder has
+// none or can't be fetched. Unlike the paths of ListFiles, nothing is
+// rewritten, so it shows why a path came out as it did.
+func (d *DriveService) ParentChain(fileID string) ([]ParentLink, error) {
+	var chain []ParentLink
+	for id := fileID; id != ""; {
+		var file *drive.File
+		err := d.retryDo("Looking up a parent", func() (err error) {
+			file, err = d.service.Files.Get(id).
+				Fields("id, name, parents, driveId").
+				SupportsAllDrives(true).
+				Context(d.requestContext()).
+				Do()
+			return err
+		})
+		if err != nil && len(chain) == 0 {
+			return nil, fmt.Errorf("unable to get file %s: %w", fileID, err)
+		}
+		if err != nil {
+			return append(chain, ParentLink{ID: id, Err: err}), nil
+		}
+		chain = append(chain, ParentLink{ID: file.Id, Name: file.Name, DriveID: file.DriveId, Parents: file.Parents})
+		id = ""
+		if len(file.Parents) > 0 {
+			id = file.Parents[0]
+		}
+	}
+	return chain, nil
+}
+
+// ChainPath returns the path getFullPath computes from a parent chain
+func ChainPath(chain []ParentLink) string {
+	var names []string
+	for i := len(chain) - 1; i >= 0; i-- {
+		if chain[i].Err == nil {
+			names = append(names, chain[i].Name)
+		}
+	}
+	return joinPath(names...)
+}
+
+// joinPath joins the elements of a Drive path. FileInfo.Path always uses "/"
+// as separator, whatever the OS; see localPath for the conversion.
+func joinPath(elem ...string) string {
+	return pathpkg.Join(elem...)
+}
+
 func (d *DriveService) cleanPath(path string) string {
 	// Remove redundant "Drive/zoom-recordings" prefix if it appears after "Zoom Recordings"
 	if strings.Contains(path, "Zoom Recordings/Drive/zoom-recordings/") {
@@ -110,64 +717,135 @@ func (d *DriveService) cleanPath(path string) string {
 	return path
 }
 
-func (d *DriveService) listFilesRecursive(folderID, parentPath string, pattern *regexp.Regexp, maxDepth, currentDepth, maxResults int, files *[]FileInfo) error {
+// MaxPageSize is the largest page of files the Drive API returns
+const MaxPageSize = 1000
+
+// ValidatePageSize checks that a -page-size value is accepted by the Drive API
+func ValidatePageSize(n int) error {
+	if n < 1 || n > MaxPageSize {
+		return fmt.Errorf("invalid page size %d (expected 1 to %d)", n, MaxPageSize)
+	}
+	return nil
+}
+
+// pageSize returns the page size set in the options, or MaxPageSize
+func (o ListOptions) pageSize() int64 {
+	if o.PageSize <= 0 {
+		return MaxPageSize
+	}
+	return int64(o.PageSize)
+}
+
+// listAll returns every file matching query, following nextPageToken
+// through all pages of results. It stops early, returning the files listed
+// so far, once the crawl is stopped.
+func (d *DriveService) listAll(c *crawl, query string) (*drive.FileList, error) {
+	all := &drive.FileList{}
+	pageToken := ""
+	for {
+		call := d.service.Files.List().
+			Q(query).
+			Fields(fileFields).
+			OrderBy("modifiedTime desc").
+			IncludeItemsFromAllDrives(true).
+			SupportsAllDrives(true).
+			PageSize(c.opts.pageSize())
+		if len(c.opts.Labels) > 0 {
+			call = call.IncludeLabels(labelIDs(c.opts.Labels))
+		}
+		if pageToken != "" {
+			call = call.PageToken(pageToken)
+		}
+		var r *drive.FileList
+		err := d.retryDo("Listing files", func() (err error) {
+			r, err = call.Context(d.requestContext()).Do()
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		all.Files = append(all.Files, r.Files...)
+		if r.NextPageToken == "" || c.stopped() {
+			return all, nil
+		}
+		pageToken = r.NextPageToken
+	}
+}
+
+func (d *DriveService) listFilesRecursive(c *crawl, folderID, parentPath string, currentDepth int) error {
+	maxDepth, maxResults := c.opts.MaxDepth, c.opts.MaxResults
 	if maxDepth != -1 && currentDepth > maxDepth {
 		d.log("Reached max depth (%d) at path: %s", maxDepth, parentPath)
 		return nil
 	}
 
 	// Early return if we've reached maxResults
-	if maxResults > 0 && len(*files) >= maxResults {
+	if c.stopped() {
 		d.log("Reached max results (%d), stopping search", maxResults)
 		return nil
 	}
 
+	if c.opts.MaxFolders > 0 {
+		if n := c.folders.Add(1); n > int64(c.opts.MaxFolders) {
+			if n == int64(c.opts.MaxFolders)+1 {
+				d.log("Reached max folders (%d), not searching any more folders", c.opts.MaxFolders)
+				d.counters.folderLimit.Store(true)
+			}
+			return nil
+		}
+	}
+
 	indent := strings.Repeat("  ", currentDepth)
-	d.log("%s📂 Entering directory: %s (depth: %d)", indent, parentPath, currentDepth)
+	d.counters.folders.Add(1)
+	d.enterFolder(parentPath, currentDepth)
+	defer d.leaveFolder(parentPath, currentDepth)
 
 	// Try both search methods
 	query := fmt.Sprintf("'%s' in parents", folderID)
+	fileQuery := c.fileQuery()
+	if fileQuery != "" {
+		// Folders must still be listed so the crawl can descend into them
+		query += fmt.Sprintf(" and (mimeType = '%s' or (%s))", folderMimeType, fileQuery)
+	}
 	d.log("%s🔍 Querying files with: %s", indent, query)
 
-	r, err := d.service.Files.List().
-		Q(query).
-		Fields("files(id, name, mimeType, trashed, driveId, owners, permissions, parents, modifiedTime)").
-		OrderBy("modifiedTime desc").
-		IncludeItemsFromAllDrives(true).
-		SupportsAllDrives(true).
-		PageSize(1000).
-		Do()
+	r, err := d.listAll(c, query)
 	if err != nil {
-		return fmt.Errorf("unable to list files in folder %s: %v", folderID, err)
+		return fmt.Errorf("unable to list files in folder %s: %w", folderID, err)
 	}
 
 	// If no files found, try a broader search
 	if len(r.Files) == 0 && currentDepth == 1 { // Only do this for the first level to avoid too many API calls
 		d.log("%s📂 Folder appears empty, trying broader search...", indent)
 		query = fmt.Sprintf("fullText contains 'TRANSCRIPT' and name contains '.TRANSCRIPT'")
-		r, err = d.service.Files.List().
-			Q(query).
-			Fields("files(id, name, mimeType, trashed, driveId, owners, permissions, parents, modifiedTime)").
-			OrderBy("modifiedTime desc").
-			IncludeItemsFromAllDrives(true).
-			SupportsAllDrives(true).
-			PageSize(1000).
-			Do()
+		if len(c.opts.Owners) > 0 {
+			// Nothing constrains this search to the start folder, so narrow it by owner server-side
+			query += " and " + ownersQuery(c.opts.Owners)
+		}
+		if fileQuery != "" {
+			query += " and (" + fileQuery + ")"
+		}
+		broad, err := d.listAll(c, query)
 		if err != nil {
 			d.log("%s⚠️ Broader search failed: %v", indent, err)
+		} else {
+			r = broad
 		}
 
 		// If we found files, get their full paths
 		if err == nil && len(r.Files) > 0 {
 			// Create a map to store folder names for caching
 			folderNames := make(map[string]string)
+			nodes := d.prefetchPaths(c, r.Files)
 
 			// Create a new file list with proper paths
 			var newFiles []*drive.File
 			for _, f := range r.Files {
-				fullPath, err := d.getFullPath(f.Id, folderNames)
+				fullPath, ok, err := d.resolvePath(c, f, nodes, folderNames)
 				if err != nil {
-					d.log("%s⚠️ Error getting full path for %s: %v", indent, f.Name, err)
+					return err
+				}
+				if !ok {
 					continue
 				}
 				f.Name = d.cleanPath(fullPath)
@@ -178,22 +856,26 @@ func (d *DriveService) listFilesRecursive(folderID, parentPath string, pattern *
 	}
 
 	d.log("%s📋 Found %d items in current directory", indent, len(r.Files))
+	skipFiles := c.opts.LeafOnly && hasSubfolders(r.Files)
+	if skipFiles {
+		d.log("%s⏭️ Skipping the files of folder %s, which has subfolders", indent, folderID)
+	}
 
 	// First list all items to see what we're dealing with
 	for _, f := range r.Files {
-		if f.MimeType == "application/vnd.google-apps.folder" {
+		if f.MimeType == folderMimeType {
 			d.log("%s  📂 Found subfolder: %s (ID: %s, Trashed: %v, DriveId: %s)",
 				indent, f.Name, f.Id, f.Trashed, f.DriveId)
 		} else {
-			d.log("%s  📄 Found file: %s (Type: %s, Trashed: %v, DriveId: %s, Modified: %s)",
-				indent, f.Name, f.MimeType, f.Trashed, f.DriveId, f.ModifiedTime)
+			d.log("%s  📄 Found file: %s (Type: %s, Trashed: %v, DriveId: %s, Modified: %s by %s)",
+				indent, f.Name, f.MimeType, f.Trashed, f.DriveId, f.ModifiedTime, orUnknown(modifierEmail(f.LastModifyingUser)))
 		}
 	}
 
 	// Now process them
 	for _, f := range r.Files {
 		// Early return if we've reached maxResults
-		if maxResults > 0 && len(*files) >= maxResults {
+		if c.stopped() {
 			d.log("%s  🛑 Reached max results (%d), stopping search", indent, maxResults)
 			return nil
 		}
@@ -204,83 +886,93 @@ func (d *DriveService) listFilesRecursive(folderID, parentPath string, pattern *
 			continue
 		}
 
-		currentPath := filepath.Join(parentPath, f.Name)
+		currentPath := joinPath(parentPath, f.Name)
 		currentPath = d.cleanPath(currentPath)
 
-		if f.MimeType == "application/vnd.google-apps.folder" {
+		if f.MimeType == folderMimeType {
+			if c.opts.MatchFolders && c.pattern.MatchString(c.opts.matchSubject(f.Name, currentPath)) {
+				d.match(c, d.newFileInfo(f, currentPath), currentDepth)
+			}
+
 			d.log("%s  🔍 Exploring subfolder: %s (ID: %s)", indent, f.Name, f.Id)
-			err = d.listFilesRecursive(f.Id, currentPath, pattern, maxDepth, currentDepth+1, maxResults, files)
+			err = d.listFilesRecursive(c, f.Id, currentPath, currentDepth+1)
 			if err != nil {
 				return err
 			}
 			continue
 		}
 
-		if pattern.MatchString(f.Name) {
-			d.log("%s  ✅ Found matching file: %s (Modified: %s)", indent, currentPath, f.ModifiedTime)
-			*files = append(*files, FileInfo{
-				ID:           f.Id,
-				Name:         f.Name,
-				Path:         currentPath,
-				MimeType:     f.MimeType,
-				ModifiedTime: f.ModifiedTime,
-			})
+		d.counters.files.Add(1)
+		if skipFiles {
+			continue
 		}
+		d.matchFile(c, f, currentPath, currentDepth)
 	}
-
-	d.log("%s📂 Leaving directory: %s", indent, parentPath)
 	return nil
 }
 
-func (d *DriveService) DownloadFile(fileInfo FileInfo, outputDir string) error {
-	d.log("📥 Starting download of: %s", fileInfo.Path)
-
-	// If the path contains a directory separator, use filepath.Join
-	// Otherwise, just use the path as is
-	var outPath string
-	if strings.Contains(fileInfo.Path, string(os.PathSeparator)) {
-		outPath = filepath.Join(outputDir, fileInfo.Path)
-	} else {
-		outPath = filepath.Join(outputDir, fileInfo.Path)
+// hasSubfolders reports whether files, listed from one folder, include a
+// folder that isn't trashed
+func hasSubfolders(files []*drive.File) bool {
+	for _, f := range files {
+		if f.MimeType == folderMimeType && !f.Trashed {
+			return true
+		}
 	}
+	return false
+}
 
-	d.log("  Creating directory: %s", filepath.Dir(outPath))
-	if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
-		return fmt.Errorf("unable to create output directory: %v", err)
+// matchFile adds a file, found at currentPath in a folder at depth, to the
+// results if it passes every filter of the crawl
+func (d *DriveService) matchFile(c *crawl, f *drive.File, currentPath string, depth int) {
+	if c.opts.Exclude != nil && c.opts.Exclude.Test(f.Id) {
+		c.excluded++
+		return
 	}
-
-	d.log("  Downloading file from Drive...")
-	resp, err := d.service.Files.Get(fileInfo.ID).Download()
-	if err != nil {
-		return fmt.Errorf("unable to download file: %v", err)
+	indent := strings.Repeat("  ", depth)
+	if !c.pattern.MatchString(c.opts.matchSubject(f.Name, currentPath)) {
+		return
 	}
-	defer resp.Body.Close()
-
-	d.log("  Creating output file: %s", outPath)
-	outFile, err := os.Create(outPath)
-	if err != nil {
-		return fmt.Errorf("unable to create output file: %v", err)
+	if !underMatchingFolder(c.folderPattern, currentPath) {
+		d.log("%s  ⏭️ Skipping file outside folders matching %s: %s", indent, c.opts.FolderPattern, currentPath)
+		return
 	}
-	defer outFile.Close()
-
-	d.log("  Copying file contents...")
-	_, err = io.Copy(outFile, resp.Body)
-	if err != nil {
-		return fmt.Errorf("unable to save file: %v", err)
+	if len(c.opts.Owners) > 0 && !ownedByAny(f.Owners, c.opts.Owners) {
+		d.log("%s  ⏭️ Skipping file not owned by %s: %s", indent, strings.Join(c.opts.Owners, ", "), currentPath)
+		return
+	}
+	if len(c.opts.LastModifiedBy) > 0 && !modifiedByAny(f.LastModifyingUser, c.opts.LastModifiedBy) {
+		d.log("%s  ⏭️ Skipping file not last modified by %s: %s (Last modified by: %s)",
+			indent, strings.Join(c.opts.LastModifiedBy, ", "), currentPath, orUnknown(modifierEmail(f.LastModifyingUser)))
+		return
 	}
 
-	d.log("✅ Successfully downloaded: %s", fileInfo.Path)
-	return nil
-}
-
-func (d *DriveService) DownloadFiles(files []FileInfo, outputDir string) error {
-	d.log("\n📥 Starting download of %d files...", len(files))
-	for _, file := range files {
-		fmt.Printf("Downloading: %s\n", file.Path) // Always show this regardless of verbose mode
-		if err := d.DownloadFile(file, outputDir); err != nil {
-			return fmt.Errorf("error downloading %s: %v", file.Path, err)
-		}
+	info := d.newFileInfo(f, currentPath)
+	if !inAnyCategory(info, c.opts.Categories) {
+		d.log("%s  ⏭️ Skipping file outside the requested categories: %s (Type: %s)", indent, currentPath, f.MimeType)
+		return
 	}
-	d.log("✅ All files downloaded successfully!")
-	return nil
+	if !c.opts.createdInRange(info.CreatedAt) {
+		d.log("%s  ⏭️ Skipping file created outside the requested range: %s (Created: %s)", indent, currentPath, f.CreatedTime)
+		return
+	}
+	if !c.opts.modifiedInRange(info.ModifiedAt) {
+		d.log("%s  ⏭️ Skipping file not modified since %s: %s (Modified: %s)",
+			indent, c.opts.ModifiedAfter.Format(time.RFC3339), currentPath, f.ModifiedTime)
+		return
+	}
+	if len(info.Labels) > 0 {
+		d.log("%s  🏷️ Labels of %s: %s", indent, currentPath, formatLabels(info.Labels))
+	}
+	if !hasLabels(info.Labels, c.opts.Labels) {
+		d.log("%s  ⏭️ Skipping file without the requested labels: %s", indent, currentPath)
+		return
+	}
+	if c.opts.OwnedByMe && !f.OwnedByMe {
+		d.log("%s  ⏭️ Skipping file not owned by the account: %s (Owner: %s)", indent, currentPath, info.Owner())
+		c.notOwned++
+		return
+	}
+
+	d.match(c, info, depth)
 }
@@ -0,0 +1,801 @@
+package drive
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+)
+
+// fakeDrive serves a minimal subset of the Drive v3 API from an in-memory tree
+type fakeDrive struct {
+	// mu serializes requests, which export workers make concurrently
+	mu sync.Mutex
+
+	files    map[string]*drive.File
+	contents map[string]string
+	url      string
+
+	// corrupt is the number of upcoming downloads of each file to serve
+	// with altered content
+	corrupt map[string]int
+
+	// truncated is the number of upcoming downloads of each file to cut
+	// off halfway, as a dropped connection would
+	truncated map[string]int
+
+	// drives holds shared drive names by ID, and driveGets counts lookups
+	drives    map[string]string
+	driveGets int
+
+	// failures holds HTTP status codes to fail upcoming requests for a path
+	// with, one request per code
+	failures map[string][]int
+
+	// retryAfter, if set, is sent as the Retry-After header of injected failures
+	retryAfter string
+
+	// changes holds the IDs of changed files in order; page tokens are
+	// offsets into it
+	changes []string
+
+	// revisions holds the revision IDs of files, oldest first
+	revisions map[string][]string
+
+	// fileGets counts requests for single files
+	fileGets int
+
+	// queries holds the query of every file listing, in order
+	queries []string
+
+	// ranges holds the Range header of every ranged download, in order
+	ranges []string
+}
+
+var (
+	parentQuery = regexp.MustCompile(`'([^']+)' in parents`)
+	idQuery     = regexp.MustCompile(`id = '([^']+)'`)
+)
+
+func newFakeDrive() *fakeDrive {
+	return &fakeDrive{
+		files: map[string]*drive.File{
+			"root": {Id: "root", Name: "My Drive", MimeType: folderMimeType},
+		},
+		contents:  make(map[string]string),
+		corrupt:   make(map[string]int),
+		truncated: make(map[string]int),
+		drives:    make(map[string]string),
+		failures:  make(map[string][]int),
+		revisions: make(map[string][]string),
+	}
+}
+
+// addFolder adds a folder under parentID
+func (f *fakeDrive) addFolder(id, name, parentID string) {
+	f.files[id] = &drive.File{Id: id, Name: name, MimeType: folderMimeType, Parents: []string{parentID}}
+}
+
+// addFile adds a file under parentID with the given content
+func (f *fakeDrive) addFile(id, name, parentID, modifiedTime, content string) {
+	f.files[id] = &drive.File{
+		Id:           id,
+		Name:         name,
+		MimeType:     "text/plain",
+		Parents:      []string{parentID},
+		ModifiedTime: modifiedTime,
+		Size:         int64(len(content)),
+		Md5Checksum:  md5Hex(content),
+	}
+	f.contents[id] = content
+}
+
+func (f *fakeDrive) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	if codes := f.failures[path]; len(codes) > 0 {
+		f.failures[path] = codes[1:]
+		if f.retryAfter != "" {
+			w.Header().Set("Retry-After", f.retryAfter)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(codes[0])
+		fmt.Fprintf(w, `{"error": {"code": %d, "message": "injected failure"}}`, codes[0])
+		return
+	}
+	switch {
+	case path == "files":
+		f.serveList(w, r)
+	case strings.HasPrefix(path, "files/") && strings.HasSuffix(path, "/export"):
+		id := strings.TrimSuffix(strings.TrimPrefix(path, "files/"), "/export")
+		w.Write([]byte("exported " + r.URL.Query().Get("mimeType") + ": " + f.contents[id]))
+	case strings.HasPrefix(path, "files/") && strings.HasSuffix(path, "/revisions"):
+		id := strings.TrimSuffix(strings.TrimPrefix(path, "files/"), "/revisions")
+		var list drive.RevisionList
+		for _, rev := range f.revisions[id] {
+			revision := &drive.Revision{Id: rev}
+			if isGoogleNative(f.files[id].MimeType) {
+				revision.ExportLinks = map[string]string{
+					"application/pdf": f.url + "/revexports/" + id + "/" + rev,
+				}
+			}
+			list.Revisions = append(list.Revisions, revision)
+		}
+		writeJSON(w, &list)
+	case strings.HasPrefix(path, "revexports/"):
+		w.Write([]byte("exported " + strings.TrimPrefix(path, "revexports/")))
+	case strings.HasPrefix(path, "thumbnails/"):
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("thumbnail of " + strings.TrimPrefix(path, "thumbnails/")))
+	case strings.HasPrefix(path, "files/"):
+		f.fileGets++
+		f.serveGet(w, r, strings.TrimPrefix(path, "files/"))
+	case path == "changes/startPageToken":
+		writeJSON(w, &drive.StartPageToken{StartPageToken: strconv.Itoa(len(f.changes))})
+	case path == "changes":
+		f.serveChanges(w, r)
+	case path == "about":
+		writeJSON(w, &drive.About{ExportFormats: map[string][]string{
+			"application/vnd.google-apps.spreadsheet": {"text/csv", "application/pdf"},
+			"application/vnd.google-apps.document":    {"text/plain", "application/pdf"},
+		}, ImportFormats: map[string][]string{
+			"text/plain": {"application/vnd.google-apps.document"},
+			"text/csv":   {"application/vnd.google-apps.spreadsheet"},
+		}})
+	case strings.HasPrefix(path, "drives/"):
+		f.driveGets++
+		id := strings.TrimPrefix(path, "drives/")
+		name, ok := f.drives[id]
+		if !ok {
+			http.Error(w, `{"error": {"code": 404, "message": "Shared drive not found"}}`, http.StatusNotFound)
+			return
+		}
+		writeJSON(w, &drive.Drive{Id: id, Name: name})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeDrive) serveList(w http.ResponseWriter, r *http.Request) {
+	f.queries = append(f.queries, r.URL.Query().Get("q"))
+	if strings.HasPrefix(r.URL.Query().Get("q"), "sharedWithMe = true") {
+		var shared []*drive.File
+		for _, file := range f.files {
+			if file.SharedWithMeTime != "" {
+				shared = append(shared, file)
+			}
+		}
+		sort.Slice(shared, func(i, j int) bool { return shared[i].Id < shared[j].Id })
+		writeJSON(w, &drive.FileList{Files: shared})
+		return
+	}
+
+	if strings.HasPrefix(r.URL.Query().Get("q"), "id = ") {
+		// Like Drive, searches never return the root folder
+		var found []*drive.File
+		for _, m := range idQuery.FindAllStringSubmatch(r.URL.Query().Get("q"), -1) {
+			if file, ok := f.files[m[1]]; ok && m[1] != "root" {
+				found = append(found, file)
+			}
+		}
+		writeJSON(w, &drive.FileList{Files: found})
+		return
+	}
+
+	m := parentQuery.FindStringSubmatch(r.URL.Query().Get("q"))
+	if m == nil {
+		writeJSON(w, &drive.FileList{})
+		return
+	}
+
+	var children []*drive.File
+	for _, file := range f.files {
+		for _, parent := range file.Parents {
+			if parent == m[1] {
+				children = append(children, file)
+			}
+		}
+	}
+	sort.Slice(children, func(i, j int) bool { return children[i].Id < children[j].Id })
+
+	// Page tokens are offsets into the sorted children
+	start, _ := strconv.Atoi(r.URL.Query().Get("pageToken"))
+	list := &drive.FileList{Files: children[start:]}
+	if size, _ := strconv.Atoi(r.URL.Query().Get("pageSize")); size > 0 && len(list.Files) > size {
+		list.Files = list.Files[:size]
+		list.NextPageToken = strconv.Itoa(start + size)
+	}
+	writeJSON(w, list)
+}
+
+func (f *fakeDrive) serveChanges(w http.ResponseWriter, r *http.Request) {
+	start, _ := strconv.Atoi(r.URL.Query().Get("pageToken"))
+	end := len(f.changes)
+	if size, _ := strconv.Atoi(r.URL.Query().Get("pageSize")); size > 0 && start+size < end {
+		end = start + size
+	}
+
+	list := &drive.ChangeList{}
+	for _, id := range f.changes[start:end] {
+		file, ok := f.files[id]
+		list.Changes = append(list.Changes, &drive.Change{FileId: id, File: file, Removed: !ok})
+	}
+	if end < len(f.changes) {
+		list.NextPageToken = strconv.Itoa(end)
+	} else {
+		list.NewStartPageToken = strconv.Itoa(end)
+	}
+	writeJSON(w, list)
+}
+
+func (f *fakeDrive) serveGet(w http.ResponseWriter, r *http.Request, id string) {
+	file, ok := f.files[id]
+	if !ok {
+		http.Error(w, `{"error": {"code": 404, "message": "File not found"}}`, http.StatusNotFound)
+		return
+	}
+	if r.Method == http.MethodPatch {
+		var update drive.File
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		file.Trashed = update.Trashed
+	}
+	if r.URL.Query().Get("alt") == "media" {
+		content := f.contents[id]
+		if f.corrupt[id] > 0 {
+			f.corrupt[id]--
+			content = strings.ToUpper(content)
+		}
+		if f.truncated[id] > 0 {
+			f.truncated[id]--
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			w.Write([]byte(content[:len(content)/2]))
+			return
+		}
+		if rng := r.Header.Get("Range"); rng != "" {
+			f.ranges = append(f.ranges, rng)
+			var offset int
+			if _, err := fmt.Sscanf(rng, "bytes=%d-", &offset); err == nil && offset <= len(content) {
+				w.WriteHeader(http.StatusPartialContent)
+				content = content[offset:]
+			}
+		}
+		w.Write([]byte(content))
+		return
+	}
+	writeJSON(w, file)
+}
+
+func md5Hex(content string) string {
+	sum := md5.Sum([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// newTestService returns a DriveService backed by the fake Drive
+func newTestService(t *testing.T, fake *fakeDrive) *DriveService {
+	t.Helper()
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	fake.url = srv.URL
+
+	service, err := drive.NewService(context.Background(),
+		option.WithEndpoint(srv.URL+"/"),
+		option.WithoutAuthentication(),
+	)
+	if err != nil {
+		t.Fatalf("failed to create Drive service: %v", err)
+	}
+	return &DriveService{service: service, client: srv.Client()}
+}
+
+func paths(files []FileInfo) []string {
+	var result []string
+	for _, f := range files {
+		result = append(result, f.Path)
+	}
+	return result
+}
+
+func TestListFilesMatchFolders(t *testing.T) {
+	fake := newFakeDrive()
+	fake.addFolder("f1", "meeting-1", "root")
+	fake.addFolder("f2", "notes", "root")
+	fake.addFile("a", "meeting.TRANSCRIPT", "f1", "2025-04-02T00:00:00Z", "a")
+	fake.addFile("b", "todo.txt", "f2", "2025-04-01T00:00:00Z", "b")
+
+	d := newTestService(t, fake)
+
+	files, err := d.ListFiles(ListOptions{Pattern: "meeting", MaxDepth: -1, OrderBy: SortOrder{Field: "path"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := strings.Join(paths(files), ","); got != "meeting-1/meeting.TRANSCRIPT" {
+		t.Errorf("without MatchFolders got %v", got)
+	}
+
+	files, err = d.ListFiles(ListOptions{Pattern: "meeting", MaxDepth: -1, OrderBy: SortOrder{Field: "path"}, MatchFolders: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := strings.Join(paths(files), ","); got != "meeting-1,meeting-1/meeting.TRANSCRIPT" {
+		t.Errorf("with MatchFolders got %v", got)
+	}
+	if !files[0].IsFolder || files[1].IsFolder {
+		t.Errorf("IsFolder = %v, %v, want true, false", files[0].IsFolder, files[1].IsFolder)
+	}
+
+	if err := d.DownloadFile(files[0], DownloadOptions{OutputDir: t.TempDir()}); err == nil || !strings.Contains(err.Error(), "is a folder") {
+		t.Errorf("DownloadFile(folder) error = %v, want folder error", err)
+	}
+}
+
+func TestListFilesMaxFolders(t *testing.T) {
+	fake := newFakeDrive()
+	fake.addFile("a", "top.txt", "root", "2025-04-01T00:00:00Z", "a")
+	fake.addFolder("f1", "one", "root")
+	fake.addFolder("f2", "two", "root")
+	fake.addFile("b", "b.txt", "f1", "2025-04-01T00:00:00Z", "b")
+	fake.addFile("c", "c.txt", "f2", "2025-04-01T00:00:00Z", "c")
+
+	d := newTestService(t, fake)
+
+	files, err := d.ListFiles(ListOptions{MaxDepth: -1, MaxFolders: 2, OrderBy: SortOrder{Field: "path"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := strings.Join(paths(files), ","); got != "one/b.txt,top.txt" {
+		t.Errorf("got %v, want the files of the root and the first folder", got)
+	}
+	if !d.CrawlStats().FolderLimitReached {
+		t.Error("expected the crawl stats to report the folder limit reached")
+	}
+}
+
+func TestListFilesMaxPerFolder(t *testing.T) {
+	fake := newFakeDrive()
+	fake.addFolder("r1", "Room 1", "root")
+	fake.addFolder("r2", "Room 2", "root")
+	fake.addFile("a", "a.mp4", "r1", "2025-04-01T00:00:00Z", "a")
+	fake.addFile("b", "b.mp4", "r1", "2025-04-03T00:00:00Z", "b")
+	fake.addFile("c", "c.mp4", "r1", "2025-04-02T00:00:00Z", "c")
+	fake.addFile("d", "d.mp4", "r2", "2025-04-01T00:00:00Z", "d")
+	fake.addFile("e", "e.mp4", "root", "2025-04-01T00:00:00Z", "e")
+
+	d := newTestService(t, fake)
+
+	files, err := d.ListFiles(ListOptions{Extensions: []string{"mp4"}, MaxDepth: -1, MaxPerFolder: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Newest first within each folder
+	got := paths(files)
+	sort.Strings(got)
+	if got, want := strings.Join(got, ","), "Room 1/b.mp4,Room 1/c.mp4,Room 2/d.mp4,e.mp4"; got != want {
+		t.Errorf("ListFiles() = %v, want %v", got, want)
+	}
+}
+
+func TestListFilesMaxKeepsSortOrder(t *testing.T) {
+	fake := newFakeDrive()
+	fake.addFile("a", "a.bin", "root", "2025-04-01T00:00:00Z", "a")
+	fake.addFile("b", "b.bin", "root", "2025-04-01T00:00:00Z", "bb")
+	fake.addFile("c", "c.bin", "root", "2025-04-01T00:00:00Z", "ccc")
+	fake.addFile("z", "z.bin", "root", "2025-04-01T00:00:00Z", "the largest, found last")
+	d := newTestService(t, fake)
+
+	files, err := d.ListFiles(ListOptions{MaxDepth: -1, MaxResults: 2, OrderBy: SortOrder{Field: "size", Desc: true}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := strings.Join(paths(files), ","), "z.bin,c.bin"; got != want {
+		t.Errorf("ListFiles() = %v, want the two largest files %v", got, want)
+	}
+}
+
+func TestListFilesMaxPerFolderWithMax(t *testing.T) {
+	fake := newFakeDrive()
+	for _, room := range []string{"r1", "r2", "r3"} {
+		fake.addFolder(room, "Room "+room[1:], "root")
+		for _, name := range []string{"a", "b", "c"} {
+			fake.addFile(room+name, name+".mp4", room, "2025-04-01T00:00:00Z", name)
+		}
+	}
+	d := newTestService(t, fake)
+
+	// The first room alone holds three files, but every room is sampled
+	files, err := d.ListFiles(ListOptions{MaxDepth: -1, MaxResults: 3, MaxPerFolder: 1, OrderBy: SortOrder{Field: "path"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := strings.Join(paths(files), ","), "Room 1/a.mp4,Room 2/a.mp4,Room 3/a.mp4"; got != want {
+		t.Errorf("ListFiles() = %v, want %v", got, want)
+	}
+}
+
+func TestListFilesMaxPerExtensionWithMax(t *testing.T) {
+	fake := newFakeDrive()
+	fake.addFile("a", "a.mp4", "root", "2025-04-05T00:00:00Z", "a")
+	fake.addFile("b", "b.mp4", "root", "2025-04-04T00:00:00Z", "b")
+	fake.addFile("c", "c.mp4", "root", "2025-04-03T00:00:00Z", "c")
+	fake.addFile("d", "d.pdf", "root", "2025-04-02T00:00:00Z", "d")
+	fake.addFile("e", "e.txt", "root", "2025-04-01T00:00:00Z", "e")
+	d := newTestService(t, fake)
+
+	// The crawl goes on past the first three files, all .mp4
+	files, err := d.ListFiles(ListOptions{MaxDepth: -1, MaxResults: 3, MaxPerExtension: 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := strings.Join(paths(files), ","), "a.mp4,d.pdf,e.txt"; got != want {
+		t.Errorf("ListFiles() = %v, want %v", got, want)
+	}
+}
+
+func TestListFilesFolderPattern(t *testing.T) {
+	fake := newFakeDrive()
+	fake.addFolder("m", "Meetings", "root")
+	fake.addFolder("m1", "2025-04-01", "m")
+	fake.addFolder("o", "Other", "root")
+	fake.addFile("a", "call.TRANSCRIPT", "m1", "2025-04-01T00:00:00Z", "a")
+	fake.addFile("b", "call.TRANSCRIPT", "o", "2025-04-01T00:00:00Z", "b")
+	fake.addFile("c", "call.TRANSCRIPT", "root", "2025-04-01T00:00:00Z", "c")
+	fake.addFile("d", "notes.txt", "m", "2025-04-01T00:00:00Z", "d")
+
+	d := newTestService(t, fake)
+
+	opts := ListOptions{Pattern: `\.TRANSCRIPT$`, FolderPattern: "^Meet", MaxDepth: -1, OrderBy: SortOrder{Field: "path"}}
+	files, err := d.ListFiles(opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := strings.Join(paths(files), ","); got != "Meetings/2025-04-01/call.TRANSCRIPT" {
+		t.Errorf("got %v, want only the transcript under Meetings", got)
+	}
+
+	opts.FolderPattern = "("
+	if _, err := d.ListFiles(opts); err == nil {
+		t.Error("expected error for invalid folder pattern")
+	}
+}
+
+func TestListFilesMatchTargetPath(t *testing.T) {
+	fake := newFakeDrive()
+	fake.addFolder("z", "Zoom Recordings", "root")
+	fake.addFolder("d", "2025-04-01", "z")
+	fake.addFolder("o", "Other", "root")
+	fake.addFile("a", "call.TRANSCRIPT", "d", "2025-04-02T00:00:00Z", "a")
+	fake.addFile("b", "call.TRANSCRIPT", "o", "2025-04-01T00:00:00Z", "b")
+
+	d := newTestService(t, fake)
+
+	opts := ListOptions{Pattern: `^Zoom Recordings/.*\.TRANSCRIPT$`, MaxDepth: -1, OrderBy: SortOrder{Field: "path"}}
+	files, err := d.ListFiles(opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(files) != 0 {
+		t.Errorf("matching names got %v, want nothing", paths(files))
+	}
+
+	opts.MatchTarget = MatchPath
+	files, err = d.ListFiles(opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := strings.Join(paths(files), ","); got != "Zoom Recordings/2025-04-01/call.TRANSCRIPT" {
+		t.Errorf("matching paths got %v", got)
+	}
+
+	opts.Pattern = "^Zoom Recordings/[0-9-]+$"
+	opts.MatchFolders = true
+	files, err = d.ListFiles(opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := strings.Join(paths(files), ","); got != "Zoom Recordings/2025-04-01" {
+		t.Errorf("matching folder paths got %v", got)
+	}
+
+	opts.MatchTarget = "basename"
+	if _, err := d.ListFiles(opts); err == nil {
+		t.Error("expected an error for an invalid match target")
+	}
+}
+
+func TestListFilesLastModifiedBy(t *testing.T) {
+	fake := newFakeDrive()
+	fake.addFile("a", "a.txt", "root", "2025-04-01T00:00:00Z", "a")
+	fake.addFile("b", "b.txt", "root", "2025-04-01T00:00:00Z", "b")
+	fake.addFile("c", "c.txt", "root", "2025-04-01T00:00:00Z", "c")
+	fake.files["a"].LastModifyingUser = &drive.User{EmailAddress: "alice@example.com"}
+	fake.files["b"].LastModifyingUser = &drive.User{EmailAddress: "bob@example.com"}
+	d := newTestService(t, fake)
+
+	files, err := d.ListFiles(ListOptions{MaxDepth: -1, LastModifiedBy: []string{"Alice@example.com"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := strings.Join(paths(files), ","); got != "a.txt" {
+		t.Fatalf("ListFiles() = %v, want a.txt", got)
+	}
+	if files[0].LastModifiedBy != "alice@example.com" {
+		t.Errorf("LastModifiedBy = %q, want alice@example.com", files[0].LastModifiedBy)
+	}
+}
+
+func TestListFilesOwnedByMe(t *testing.T) {
+	fake := newFakeDrive()
+	fake.addFolder("f1", "shared", "root")
+	fake.addFile("a", "a.txt", "root", "2025-04-03T00:00:00Z", "a")
+	fake.addFile("b", "b.txt", "f1", "2025-04-02T00:00:00Z", "b")
+	fake.addFile("c", "c.txt", "f1", "2025-04-01T00:00:00Z", "c")
+	fake.files["a"].OwnedByMe = true
+	fake.files["c"].OwnedByMe = true
+	d := newTestService(t, fake)
+
+	for _, verbose := range []bool{false, true} {
+		d.verbose = verbose
+		fake.queries = nil
+		files, err := d.ListFiles(ListOptions{MaxDepth: -1, OwnedByMe: true, OrderBy: SortOrder{Field: "path"}})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := strings.Join(paths(files), ","); got != "a.txt,shared/c.txt" {
+			t.Errorf("verbose %v: ListFiles() = %v, want a.txt,shared/c.txt", verbose, got)
+		}
+		// Verbose runs filter after listing so the excluded files are counted
+		filtered := strings.Contains(strings.Join(fake.queries, "\n"), "'me' in owners")
+		if filtered == verbose {
+			t.Errorf("verbose %v: listing queries %q, want 'me' in owners only when not verbose", verbose, fake.queries)
+		}
+	}
+}
+
+func TestListFilesLeafOnly(t *testing.T) {
+	fake := newFakeDrive()
+	fake.addFolder("y24", "2024", "root")
+	fake.addFolder("m01", "01", "y24")
+	fake.addFolder("m02", "02", "y24")
+	fake.addFolder("y25", "2025", "root")
+	fake.addFile("r", "readme.txt", "root", "2025-04-06T00:00:00Z", "r")
+	fake.addFile("i", "index.txt", "y24", "2025-04-05T00:00:00Z", "i")
+	fake.addFile("a", "a.txt", "m01", "2025-04-04T00:00:00Z", "a")
+	fake.addFile("b", "b.txt", "m01", "2025-04-03T00:00:00Z", "b")
+	fake.addFile("c", "c.txt", "m02", "2025-04-02T00:00:00Z", "c")
+	fake.addFile("d", "d.txt", "y25", "2025-04-01T00:00:00Z", "d")
+	d := newTestService(t, fake)
+
+	files, err := d.ListFiles(ListOptions{MaxDepth: -1, LeafOnly: true, OrderBy: SortOrder{Field: "path"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The root and 2024 have subfolders, so their files are skipped
+	if got := strings.Join(paths(files), ","); got != "2024/01/a.txt,2024/01/b.txt,2024/02/c.txt,2025/d.txt" {
+		t.Errorf("ListFiles() = %v", got)
+	}
+
+	// A folder with subfolders isn't a leaf even when they're beyond MaxDepth
+	files, err = d.ListFiles(ListOptions{MaxDepth: 1, LeafOnly: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := strings.Join(paths(files), ","); got != "2025/d.txt" {
+		t.Errorf("ListFiles() with MaxDepth 1 = %v, want 2025/d.txt", got)
+	}
+}
+
+func TestFileInfoSizeAndChecksum(t *testing.T) {
+	tests := []struct {
+		name                 string
+		file                 FileInfo
+		hasSize, hasChecksum bool
+	}{
+		{"uploaded file", FileInfo{Size: 12, MD5: "abc"}, true, true},
+		{"empty uploaded file", FileInfo{MD5: "d41d8cd98f00b204e9800998ecf8427e"}, true, true},
+		{"native doc", FileInfo{MimeType: "application/vnd.google-apps.document"}, false, false},
+		{"native doc with a size", FileInfo{MimeType: "application/vnd.google-apps.document", Size: 1024}, true, false},
+		{"folder", FileInfo{IsFolder: true}, false, false},
+	}
+	for _, tt := range tests {
+		if got := tt.file.HasSize(); got != tt.hasSize {
+			t.Errorf("%s: HasSize() = %v, want %v", tt.name, got, tt.hasSize)
+		}
+		if got := tt.file.HasChecksum(); got != tt.hasChecksum {
+			t.Errorf("%s: HasChecksum() = %v, want %v", tt.name, got, tt.hasChecksum)
+		}
+	}
+}
+
+func TestNewFileInfoParsesModifiedTime(t *testing.T) {
+	d := &DriveService{}
+
+	info := d.newFileInfo(&drive.File{Id: "a", Name: "a.txt", ModifiedTime: "2025-04-10T17:27:28.123Z"}, "a.txt")
+	want := time.Date(2025, 4, 10, 17, 27, 28, 123000000, time.UTC)
+	if !info.ModifiedAt.Equal(want) {
+		t.Errorf("ModifiedAt = %v, want %v", info.ModifiedAt, want)
+	}
+	if info.ModifiedTime != "2025-04-10T17:27:28.123Z" {
+		t.Errorf("ModifiedTime = %q, want raw value kept", info.ModifiedTime)
+	}
+
+	info = d.newFileInfo(&drive.File{Id: "b", Name: "b.txt", ModifiedTime: "yesterday"}, "b.txt")
+	if !info.ModifiedAt.IsZero() {
+		t.Errorf("ModifiedAt = %v, want zero time for unparsable value", info.ModifiedAt)
+	}
+}
+
+func TestListFilesMultipleRoots(t *testing.T) {
+	fake := newFakeDrive()
+	fake.addFolder("f1", "room-1", "root")
+	fake.addFolder("f1a", "apr", "f1")
+	fake.addFolder("f2", "room-2", "root")
+	fake.addFile("a", "a.TRANSCRIPT", "f1a", "2025-04-03T00:00:00Z", "a")
+	fake.addFile("b", "b.TRANSCRIPT", "f2", "2025-04-02T00:00:00Z", "b")
+	fake.addFile("c", "c.TRANSCRIPT", "f2", "2025-04-01T00:00:00Z", "c")
+	d := newTestService(t, fake)
+
+	// f1a is also reachable through f1, but its file must only be listed once
+	files, err := d.ListFiles(ListOptions{FolderIDs: []string{"f1", "f1a", "f2"}, Pattern: "TRANSCRIPT", MaxDepth: -1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := strings.Join(paths(files), ","); got != "apr/a.TRANSCRIPT,b.TRANSCRIPT,c.TRANSCRIPT" {
+		t.Errorf("ListFiles() = %v", got)
+	}
+
+	files, err = d.ListFiles(ListOptions{FolderIDs: []string{"f1", "f2"}, Pattern: "TRANSCRIPT", MaxDepth: -1, MaxResults: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(files) != 2 {
+		t.Errorf("MaxResults across roots returned %d files, want 2", len(files))
+	}
+}
+
+func TestWalkFilesStreamsToDownloader(t *testing.T) {
+	fake := newFakeDrive()
+	fake.addFolder("f1", "room-1", "root")
+	fake.addFile("a", "a.TRANSCRIPT", "f1", "2025-04-03T00:00:00Z", "a")
+	fake.addFile("b", "b.TRANSCRIPT", "f1", "2025-04-02T00:00:00Z", "b")
+	fake.addFile("c", "c.mp4", "f1", "2025-04-01T00:00:00Z", "c")
+	d := newTestService(t, fake)
+
+	done := make(chan struct{})
+	defer close(done)
+	files, errc := d.WalkFiles(ListOptions{FolderIDs: []string{"f1"}, Pattern: "TRANSCRIPT", MaxDepth: -1}, done)
+
+	outputDir := t.TempDir()
+	report, err := d.DownloadStream(files, DownloadOptions{OutputDir: outputDir})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("unexpected walk error: %v", err)
+	}
+	if got := strings.Join(paths(report.Downloaded), ","); got != "a.TRANSCRIPT,b.TRANSCRIPT" {
+		t.Errorf("downloaded %v", got)
+	}
+}
+
+func TestWalkFilesLimits(t *testing.T) {
+	fake := newFakeDrive()
+	fake.addFile("a", "a.txt", "root", "2025-04-03T00:00:00Z", "a")
+	fake.addFile("b", "b.txt", "root", "2025-04-02T00:00:00Z", "b")
+	fake.addFile("c", "c.mp4", "root", "2025-04-01T00:00:00Z", "c")
+	fake.addFile("d", "d.mp4", "root", "2025-04-01T00:00:00Z", "d")
+	d := newTestService(t, fake)
+
+	collect := func(opts ListOptions) []string {
+		done := make(chan struct{})
+		defer close(done)
+		files, errc := d.WalkFiles(opts, done)
+		var ids []string
+		for f := range files {
+			ids = append(ids, f.ID)
+		}
+		if err := <-errc; err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return ids
+	}
+
+	if got := collect(ListOptions{FolderIDs: []string{"root"}, MaxDepth: -1, MaxResults: 3}); len(got) != 3 {
+		t.Errorf("MaxResults 3 streamed %v", got)
+	}
+	if got := strings.Join(collect(ListOptions{FolderIDs: []string{"root"}, MaxDepth: -1, MaxPerExtension: 1}), ","); got != "a,c" {
+		t.Errorf("MaxPerExtension 1 streamed %v, want a,c", got)
+	}
+
+	// Closing done stops a crawl nobody is reading from
+	done := make(chan struct{})
+	_, errc := d.WalkFiles(ListOptions{FolderIDs: []string{"root"}, MaxDepth: -1}, done)
+	close(done)
+	select {
+	case <-errc:
+	case <-time.After(5 * time.Second):
+		t.Fatal("WalkFiles did not stop after done was closed")
+	}
+}
+
+func TestListFilesFollowsPages(t *testing.T) {
+	fake := newFakeDrive()
+	fake.addFolder("f1", "sub", "root")
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		fake.addFile(id, id+".txt", "root", "2025-04-01T00:00:00Z", id)
+	}
+	fake.addFile("z", "z.txt", "f1", "2025-04-01T00:00:00Z", "z")
+	d := newTestService(t, fake)
+
+	files, err := d.ListFiles(ListOptions{FolderIDs: []string{"root"}, MaxDepth: -1, OrderBy: SortOrder{Field: "path"}, PageSize: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := strings.Join(paths(files), ","); got != "a.txt,b.txt,c.txt,d.txt,e.txt,sub/z.txt" {
+		t.Errorf("ListFiles() = %v", got)
+	}
+}
+
+func TestParentChain(t *testing.T) {
+	fake := newFakeDrive()
+	fake.addFolder("proj", "Project", "root")
+	fake.addFolder("sub", "Notes", "proj")
+	fake.addFile("a", "a.txt", "sub", "2025-04-01T00:00:00Z", "a")
+	fake.files["a"].Parents = []string{"sub", "proj"}
+	fake.addFile("b", "b.txt", "gone", "2025-04-01T00:00:00Z", "b")
+	d := newTestService(t, fake)
+
+	chain, err := d.ParentChain("a")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var ids []string
+	for _, link := range chain {
+		ids = append(ids, link.ID)
+	}
+	if got := strings.Join(ids, ","); got != "a,sub,proj,root" {
+		t.Errorf("chain = %s, want a,sub,proj,root", got)
+	}
+	if len(chain[0].Parents) != 2 {
+		t.Errorf("parents of a = %v, want both", chain[0].Parents)
+	}
+	if got := ChainPath(chain); got != "My Drive/Project/Notes/a.txt" {
+		t.Errorf("ChainPath() = %q", got)
+	}
+
+	// A parent that can't be fetched ends the chain
+	chain, err = d.ParentChain("b")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(chain) != 2 || chain[1].ID != "gone" || chain[1].Err == nil {
+		t.Errorf("chain = %+v, want b and the missing parent", chain)
+	}
+	if got := ChainPath(chain); got != "b.txt" {
+		t.Errorf("ChainPath() = %q, want b.txt", got)
+	}
+
+	if _, err := d.ParentChain("missing"); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
@@ -0,0 +1,66 @@
+package drive
+
+import (
+	"fmt"
+)
+
+// listSharedWithMe crawls the files and folders shared directly with the
+// account. They sit outside its My Drive, so each is placed under the path of
+// its parent folders as far as the account can see them, usually just its
+// own name. Shared folders are crawled like subfolders of the root.
+func (d *DriveService) listSharedWithMe(c *crawl) error {
+	if c.stopped() {
+		return nil
+	}
+	d.log("📂 Listing items shared with the account...")
+
+	query := "sharedWithMe = true"
+	if fileQuery := c.fileQuery(); fileQuery != "" {
+		query += fmt.Sprintf(" and (mimeType = '%s' or (%s))", folderMimeType, fileQuery)
+	}
+	d.log("🔍 Querying files with: %s", query)
+
+	r, err := d.listAll(c, query)
+	if err != nil {
+		return fmt.Errorf("unable to list files shared with the account: %w", err)
+	}
+	d.log("📋 Found %d items shared with the account", len(r.Files))
+
+	folderNames := make(map[string]string)
+	nodes := d.prefetchPaths(c, r.Files)
+	for _, f := range r.Files {
+		if c.stopped() {
+			d.log("  🛑 Reached max results (%d), stopping search", c.opts.MaxResults)
+			return nil
+		}
+		if f.Trashed {
+			d.log("  ⚠️ Skipping trashed item: %s", f.Name)
+			continue
+		}
+
+		currentPath, ok, err := d.resolvePath(c, f, nodes, folderNames)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		currentPath = d.cleanPath(currentPath)
+
+		if f.MimeType == folderMimeType {
+			if c.opts.MatchFolders && c.pattern.MatchString(c.opts.matchSubject(f.Name, currentPath)) {
+				d.match(c, d.newFileInfo(f, currentPath), 0)
+			}
+
+			d.log("  🔍 Exploring shared folder: %s (ID: %s)", f.Name, f.Id)
+			if err := d.listFilesRecursive(c, f.Id, currentPath, 1); err != nil {
+				return err
+			}
+			continue
+		}
+
+		d.counters.files.Add(1)
+		d.matchFile(c, f, currentPath, 0)
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package drive
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestListFilesSharedWithMe(t *testing.T) {
+	fake := newFakeDrive()
+	fake.addFile("mine", "mine.txt", "root", "2025-04-01T00:00:00Z", "mine")
+	// Shared items live under folders the account can't see
+	fake.addFolder("s1", "Team", "someone-elses-folder")
+	fake.addFile("a", "a.txt", "s1", "2025-04-01T00:00:00Z", "a")
+	fake.addFile("b", "b.txt", "someone-elses-folder", "2025-04-01T00:00:00Z", "b")
+	fake.addFile("c", "c.pdf", "someone-elses-folder", "2025-04-01T00:00:00Z", "c")
+	for _, id := range []string{"s1", "b", "c"} {
+		fake.files[id].SharedWithMeTime = "2025-04-02T00:00:00Z"
+	}
+	d := newTestService(t, fake)
+
+	files, err := d.ListFiles(ListOptions{Pattern: `\.txt$`, MaxDepth: -1, SharedWithMe: true, OrderBy: SortOrder{Field: "path"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := paths(files), []string{"Team/a.txt", "b.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ListFiles() = %v, want %v", got, want)
+	}
+
+	// Folders passed explicitly are still crawled
+	files, err = d.ListFiles(ListOptions{Pattern: `\.txt$`, FolderIDs: []string{"root"}, MaxDepth: -1, SharedWithMe: true, OrderBy: SortOrder{Field: "path"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := paths(files), []string{"Team/a.txt", "b.txt", "mine.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ListFiles() = %v, want %v", got, want)
+	}
+}
+
+func TestListFilesSharedWithMePrefetchPaths(t *testing.T) {
+	fake := newFakeDrive()
+	fake.addFolder("p1", "Projects", "hidden")
+	fake.addFolder("p2", "Q3", "p1")
+	fake.addFile("d", "d.txt", "p2", "2025-04-01T00:00:00Z", "d")
+	fake.addFile("b", "b.txt", "someone-elses-folder", "2025-04-01T00:00:00Z", "b")
+	for _, id := range []string{"d", "b"} {
+		fake.files[id].SharedWithMeTime = "2025-04-02T00:00:00Z"
+	}
+	d := newTestService(t, fake)
+
+	for _, prefetch := range []bool{false, true} {
+		fake.fileGets = 0
+		files, err := d.ListFiles(ListOptions{Pattern: `\.txt$`, MaxDepth: -1, SharedWithMe: true, PrefetchPaths: prefetch, OrderBy: SortOrder{Field: "path"}})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got, want := paths(files), []string{"Projects/Q3/d.txt", "b.txt"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("prefetch %v: ListFiles() = %v, want %v", prefetch, got, want)
+		}
+
+		// Only the folders searches can't find are fetched one at a time
+		want := 6
+		if prefetch {
+			want = 2
+		}
+		if fake.fileGets != want {
+			t.Errorf("prefetch %v: %d single file requests, want %d", prefetch, fake.fileGets, want)
+		}
+	}
+}
+
+func TestListFilesSharedWithMePathError(t *testing.T) {
+	defer func(delay time.Duration) { retryBaseDelay = delay }(retryBaseDelay)
+	retryBaseDelay = time.Millisecond
+
+	fake := newFakeDrive()
+	fake.addFolder("p1", "Projects", "hidden")
+	fake.addFile("d", "d.txt", "p1", "2025-04-01T00:00:00Z", "d")
+	fake.addFile("b", "b.txt", "someone-elses-folder", "2025-04-01T00:00:00Z", "b")
+	for _, id := range []string{"d", "b"} {
+		fake.files[id].SharedWithMeTime = "2025-04-02T00:00:00Z"
+	}
+	d := newTestService(t, fake).WithRetries(1)
+	opts := ListOptions{Pattern: `\.txt$`, MaxDepth: -1, SharedWithMe: true, OrderBy: SortOrder{Field: "path"}}
+
+	// A transient failure is retried
+	fake.failures["files/p1"] = []int{http.StatusServiceUnavailable}
+	files, err := d.ListFiles(opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := paths(files), []string{"Projects/d.txt", "b.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after a retry: ListFiles() = %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		policy string
+		want   []string
+	}{
+		{policy: "", want: []string{"b.txt", "d.txt"}},
+		{policy: PathErrorShallow, want: []string{"b.txt", "d.txt"}},
+		{policy: PathErrorSkip, want: []string{"b.txt"}},
+	}
+	for _, tt := range tests {
+		fake.failures["files/p1"] = []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable}
+		opts.OnPathError = tt.policy
+		files, err := d.ListFiles(opts)
+		if err != nil {
+			t.Fatalf("policy %q: unexpected error: %v", tt.policy, err)
+		}
+		if got := paths(files); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("policy %q: ListFiles() = %v, want %v", tt.policy, got, tt.want)
+		}
+	}
+
+	fake.failures["files/p1"] = []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable}
+	opts.OnPathError = PathErrorFail
+	if _, err := d.ListFiles(opts); !errors.Is(err, ErrPathUnresolved) {
+		t.Errorf("policy fail: ListFiles() error = %v, want ErrPathUnresolved", err)
+	}
+}
+
+func TestListFilesSharedWithMePrefetchPathError(t *testing.T) {
+	defer func(delay time.Duration) { retryBaseDelay = delay }(retryBaseDelay)
+	retryBaseDelay = time.Millisecond
+
+	// Searches don't return the root folder, so it is fetched on its own
+	fake := newFakeDrive()
+	fake.addFolder("p1", "Projects", "root")
+	fake.addFile("d", "d.txt", "p1", "2025-04-01T00:00:00Z", "d")
+	fake.addFile("b", "b.txt", "someone-elses-folder", "2025-04-01T00:00:00Z", "b")
+	for _, id := range []string{"d", "b"} {
+		fake.files[id].SharedWithMeTime = "2025-04-02T00:00:00Z"
+	}
+	d := newTestService(t, fake).WithRetries(1)
+	opts := ListOptions{Pattern: `\.txt$`, MaxDepth: -1, SharedWithMe: true, PrefetchPaths: true, OrderBy: SortOrder{Field: "path"}}
+
+	files, err := d.ListFiles(opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := paths(files), []string{"My Drive/Projects/d.txt", "b.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ListFiles() = %v, want %v", got, want)
+	}
+
+	fake.failures["files/root"] = []int{http.StatusInternalServerError, http.StatusInternalServerError}
+	opts.OnPathError = PathErrorFail
+	if _, err := d.ListFiles(opts); !errors.Is(err, ErrPathUnresolved) {
+		t.Errorf("policy fail: ListFiles() error = %v, want ErrPathUnresolved", err)
+	}
+
+	fake.failures["files/root"] = []int{http.StatusInternalServerError, http.StatusInternalServerError}
+	opts.OnPathError = PathErrorSkip
+	files, err = d.ListFiles(opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := paths(files), []string{"b.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("policy skip: ListFiles() = %v, want %v", got, want)
+	}
+}
+
+func TestValidatePathErrorPolicy(t *testing.T) {
+	for _, policy := range []string{PathErrorShallow, PathErrorSkip, PathErrorFail} {
+		if err := ValidatePathErrorPolicy(policy); err != nil {
+			t.Errorf("ValidatePathErrorPolicy(%q) unexpected error: %v", policy, err)
+		}
+	}
+	if err := ValidatePathErrorPolicy("ignore"); err == nil {
+		t.Error("ValidatePathErrorPolicy(\"ignore\") expected error, got nil")
+	}
+}
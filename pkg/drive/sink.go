@@ -0,0 +1,267 @@
+package drive
+
+import (
+	"context"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"os"
+	pathpkg "path"
+	"path/filepath"
+	"strings"
+
+	"google.golang.org/api/option"
+	"google.golang.org/api/storage/v1"
+)
+
+// Sink stores downloaded files somewhere other than the output directory,
+// such as a bucket in object storage
+type Sink interface {
+	// Create returns a writer for the file at path, "/"-separated and
+	// relative to the sink. The file is only complete once the writer has
+	// been closed without error.
+	Create(path string) (SinkWriter, error)
+}
+
+// SinkWriter receives the content of a file saved to a Sink
+type SinkWriter interface {
+	io.WriteCloser
+	// Abort discards the file instead of completing it, so nothing is left
+	// at its path. It is called in place of Close, with the reason.
+	Abort(err error)
+}
+
+// ParseSink returns the sink a -sink value names: gs://bucket/prefix for a
+// Google Cloud Storage bucket, using the given credentials file or, when it
+// is empty, Application Default Credentials, s3://bucket/prefix for an
+// Amazon S3 bucket, using the AWS credentials of the environment (see
+// NewS3Sink), or file:///dir for a local directory
+func ParseSink(ctx context.Context, spec, credentialsFile string) (Sink, error) {
+	scheme, rest, ok := strings.Cut(spec, "://")
+	if !ok {
+		return nil, fmt.Errorf("invalid sink %q (expected gs://bucket/prefix or file:///dir)", spec)
+	}
+	switch scheme {
+	case "gs":
+		bucket, prefix, _ := strings.Cut(rest, "/")
+		if bucket == "" {
+			return nil, fmt.Errorf("invalid sink %q: no bucket", spec)
+		}
+		return NewGCSSink(ctx, credentialsFile, bucket, prefix)
+	case "file":
+		if rest == "" {
+			return nil, fmt.Errorf("invalid sink %q: no directory", spec)
+		}
+		return LocalSink{Dir: filepath.FromSlash(rest)}, nil
+	case "s3":
+		bucket, prefix, _ := strings.Cut(rest, "/")
+		if bucket == "" {
+			return nil, fmt.Errorf("invalid sink %q: no bucket", spec)
+		}
+		return NewS3Sink(ctx, bucket, prefix)
+	}
+	return nil, fmt.Errorf("invalid sink %q: unknown scheme %q", spec, scheme)
+}
+
+// LocalSink saves files under a local directory. Each file is written next
+// to its final path and only moved there once complete.
+type LocalSink struct {
+	Dir string
+	// FileMode and DirMode are the permissions files and directories are
+	// created with, 0 for the defaults of WithFileModes. Downloads set them
+	// from the service when they are 0.
+	FileMode os.FileMode
+	DirMode  os.FileMode
+}
+
+func (s LocalSink) Create(path string) (SinkWriter, error) {
+	outPath := localPath(s.Dir, path)
+	dirMode, fileMode := s.DirMode, s.FileMode
+	if dirMode == 0 {
+		dirMode = DefaultDirMode
+	}
+	if fileMode == 0 {
+		fileMode = DefaultFileMode
+	}
+	if err := os.MkdirAll(filepath.Dir(outPath), dirMode); err != nil {
+		return nil, fmt.Errorf("unable to create directory: %v", err)
+	}
+	// A part left by a crash would keep its permissions if truncated
+	partPath := outPath + partSuffix
+	os.Remove(partPath)
+	f, err := os.OpenFile(partPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fileMode)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create file: %v", err)
+	}
+	return &localSinkFile{File: f, path: outPath}, nil
+}
+
+// localSinkFile moves a file written by LocalSink into place when closed
+type localSinkFile struct {
+	*os.File
+	path string
+}
+
+func (f *localSinkFile) Abort(error) {
+	f.File.Close()
+	os.Remove(f.Name())
+}
+
+func (f *localSinkFile) Close() error {
+	if err := f.File.Close(); err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("unable to write file: %v", err)
+	}
+	if err := os.Rename(f.Name(), f.path); err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("unable to write file: %v", err)
+	}
+	return nil
+}
+
+// GCSSink uploads files as objects of a Google Cloud Storage bucket, named
+// after their path below a prefix
+type GCSSink struct {
+	ctx     context.Context
+	service *storage.Service
+	bucket  string
+	prefix  string
+}
+
+// NewGCSSink returns a sink uploading to bucket with the credentials file
+// used for Drive, which must also be allowed to create objects in the
+// bucket. An empty credentialsFile uses Application Default Credentials.
+func NewGCSSink(ctx context.Context, credentialsFile, bucket, prefix string) (*GCSSink, error) {
+	opts := []option.ClientOption{option.WithScopes(storage.DevstorageReadWriteScope)}
+	if credentialsFile != "" {
+		data, err := os.ReadFile(credentialsFile)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+		}
+		opts = append(opts, option.WithCredentialsJSON(data))
+	}
+	srv, err := storage.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create Cloud Storage service: %v", err)
+	}
+	return &GCSSink{ctx: ctx, service: srv, bucket: bucket, prefix: strings.Trim(prefix, "/")}, nil
+}
+
+// Create starts uploading an object, fed by the writer as it is written
+func (s *GCSSink) Create(path string) (SinkWriter, error) {
+	name := pathpkg.Join(s.prefix, path)
+	pr, pw := io.Pipe()
+	w := &gcsWriter{PipeWriter: pw, name: name, done: make(chan error, 1)}
+	go func() {
+		_, err := s.service.Objects.Insert(s.bucket, &storage.Object{Name: name}).
+			Media(pr).
+			Context(s.ctx).
+			Do()
+		// Unblock the writer if the upload stopped reading early
+		pr.CloseWithError(fmt.Errorf("upload failed: %v", err))
+		w.done <- err
+	}()
+	return w, nil
+}
+
+// gcsWriter feeds an object upload, which Close waits for
+type gcsWriter struct {
+	*io.PipeWriter
+	name string
+	done chan error
+}
+
+func (w *gcsWriter) Close() error {
+	w.PipeWriter.Close()
+	if err := <-w.done; err != nil {
+		return fmt.Errorf("unable to upload %s: %v", w.name, err)
+	}
+	return nil
+}
+
+// Abort fails the upload, so the object is never created
+func (w *gcsWriter) Abort(err error) {
+	w.PipeWriter.CloseWithError(err)
+	<-w.done
+}
+
+// sinkModes gives a LocalSink the file modes of the service, unless it has
+// its own
+func (d *DriveService) sinkModes(sink Sink) Sink {
+	if local, ok := sink.(LocalSink); ok {
+		if local.FileMode == 0 {
+			local.FileMode = d.filePerm()
+		}
+		if local.DirMode == 0 {
+			local.DirMode = d.dirPerm()
+		}
+		return local
+	}
+	return sink
+}
+
+// sinkFile downloads a file straight into the sink, verifying it against
+// Drive's checksum as it is written
+func (d *DriveService) sinkFile(fileInfo FileInfo, sink Sink, opts DownloadOptions) error {
+	if fileInfo.IsFolder {
+		return fmt.Errorf("%s is a folder; folder entries from -match-folders can be listed but not downloaded", fileInfo.Path)
+	}
+
+	d.log("📥 Saving to sink: %s", fileInfo.Path)
+	sink = d.sinkModes(sink)
+	if opts.savesLinkStub(fileInfo) {
+		data, err := linkStubContent(fileInfo)
+		if err != nil {
+			return err
+		}
+		w, err := sink.Create(fileInfo.Path + linkStubExtension(fileInfo.MimeType))
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(data); err != nil {
+			err = fmt.Errorf("unable to write link: %v", err)
+			w.Abort(err)
+			return err
+		}
+		return w.Close()
+	}
+
+	resp, err := d.service.Files.Get(fileInfo.ID).Context(d.requestContext()).Download()
+	if err != nil {
+		return fmt.Errorf("unable to download file: %v", err)
+	}
+	defer resp.Body.Close()
+
+	hash := md5.New()
+	var body io.Reader = io.TeeReader(resp.Body, hash)
+	var progress *progressReader
+	if d.progress != nil {
+		progress = &progressReader{r: body, fn: d.progress, file: fileInfo, total: resp.ContentLength}
+		body = progress
+	}
+
+	w, err := sink.Create(fileInfo.Path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, body); err != nil {
+		err = fmt.Errorf("unable to download file: %v", err)
+		w.Abort(err)
+		return err
+	}
+	if progress != nil {
+		progress.finish()
+	}
+
+	// Checked before the file is completed, so a corrupt one never lands
+	if err := d.checkChecksum(fileInfo, hash, opts); err != nil {
+		w.Abort(err)
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	d.log("✅ Successfully saved: %s", fileInfo.Path)
+	return nil
+}
@@ -0,0 +1,116 @@
+package drive
+
+import (
+	"cmp"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SortOrder describes how ListFiles orders its results
+type SortOrder struct {
+	Field string
+	Desc  bool
+}
+
+// DefaultSortOrder sorts files by modification time, newest first
+var DefaultSortOrder = SortOrder{Field: "modified", Desc: true}
+
+// defaultDescending holds the direction used when a field is given without a suffix
+var defaultDescending = map[string]bool{
+	"modified": true,
+	"created":  true,
+	"name":     false,
+	"size":     true,
+	"path":     false,
+}
+
+// ParseSortOrder parses a "field[:asc|:desc]" specification
+func ParseSortOrder(spec string) (SortOrder, error) {
+	field, direction, hasDirection := strings.Cut(strings.TrimSpace(spec), ":")
+	field = strings.ToLower(field)
+
+	desc, ok := defaultDescending[field]
+	if !ok {
+		return SortOrder{}, fmt.Errorf("invalid sort field %q (expected modified, created, name, size or path)", field)
+	}
+
+	if hasDirection {
+		switch strings.ToLower(direction) {
+		case "asc":
+			desc = false
+		case "desc":
+			desc = true
+		default:
+			return SortOrder{}, fmt.Errorf("invalid sort direction %q (expected asc or desc)", direction)
+		}
+	}
+
+	return SortOrder{Field: field, Desc: desc}, nil
+}
+
+func (o SortOrder) String() string {
+	if o.Desc {
+		return o.Field + ":desc"
+	}
+	return o.Field + ":asc"
+}
+
+// SortFiles sorts files in place according to the given order. Files that
+// compare equal are ordered by Path and then ID, both ascending, so the
+// result is deterministic regardless of the order Drive returned them in.
+// Sorted by size, files without one (see FileInfo.HasSize) come last.
+func SortFiles(files []FileInfo, order SortOrder) {
+	compare := func(a, b FileInfo) int {
+		switch order.Field {
+		case "name":
+			return strings.Compare(a.Name, b.Name)
+		case "size":
+			return cmp.Compare(a.Size, b.Size)
+		case "path":
+			return strings.Compare(a.Path, b.Path)
+		case "created":
+			return a.CreatedAt.Compare(b.CreatedAt)
+		default:
+			return a.ModifiedAt.Compare(b.ModifiedAt)
+		}
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		a, b := files[i], files[j]
+		if order.Field == "size" && a.HasSize() != b.HasSize() {
+			// Files without a size sort last either way
+			return a.HasSize()
+		}
+		c := compare(a, b)
+		if order.Desc {
+			c = -c
+		}
+		if c == 0 {
+			c = strings.Compare(a.Path, b.Path)
+		}
+		if c == 0 {
+			c = strings.Compare(a.ID, b.ID)
+		}
+		return c < 0
+	})
+}
+
+// downloadOrders maps each -download-order name to the order it sorts by
+var downloadOrders = map[string]SortOrder{
+	"smallest": {Field: "size"},
+	"largest":  {Field: "size", Desc: true},
+	"oldest":   {Field: "modified"},
+	"newest":   {Field: "modified", Desc: true},
+	"path":     {Field: "path"},
+}
+
+// ParseDownloadOrder parses a -download-order name: smallest, largest,
+// oldest, newest or path
+func ParseDownloadOrder(name string) (SortOrder, error) {
+	order, ok := downloadOrders[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return SortOrder{}, fmt.Errorf("invalid download order %q (expected smallest, largest, oldest, newest or path)", name)
+	}
+	return order, nil
+}
@@ -0,0 +1,167 @@
+package drive
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseSortOrder(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    SortOrder
+		wantErr bool
+	}{
+		{spec: "modified", want: SortOrder{Field: "modified", Desc: true}},
+		{spec: "modified:asc", want: SortOrder{Field: "modified", Desc: false}},
+		{spec: "name", want: SortOrder{Field: "name", Desc: false}},
+		{spec: "Name:DESC", want: SortOrder{Field: "name", Desc: true}},
+		{spec: "size", want: SortOrder{Field: "size", Desc: true}},
+		{spec: "path:asc", want: SortOrder{Field: "path", Desc: false}},
+		{spec: "created", want: SortOrder{Field: "created", Desc: true}},
+		{spec: "owner", wantErr: true},
+		{spec: "size:up", wantErr: true},
+		{spec: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseSortOrder(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseSortOrder(%q) = %+v, want %+v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSortFiles(t *testing.T) {
+	files := []FileInfo{
+		{ID: "1", Name: "b.txt", Path: "x/b.txt", Size: 30, ModifiedAt: rfc3339("2025-04-02T00:00:00Z"), CreatedAt: rfc3339("2025-01-01T00:00:00Z")},
+		{ID: "2", Name: "c.txt", Path: "a/c.txt", Size: 10, ModifiedAt: rfc3339("2025-04-03T00:00:00Z"), CreatedAt: rfc3339("2025-03-01T00:00:00Z")},
+		{ID: "3", Name: "a.txt", Path: "m/a.txt", Size: 20, ModifiedAt: rfc3339("2025-04-01T00:00:00Z"), CreatedAt: rfc3339("2025-02-01T00:00:00Z")},
+	}
+
+	tests := []struct {
+		order SortOrder
+		want  []string
+	}{
+		{order: DefaultSortOrder, want: []string{"2", "1", "3"}},
+		{order: SortOrder{Field: "modified"}, want: []string{"3", "1", "2"}},
+		{order: SortOrder{Field: "name"}, want: []string{"3", "1", "2"}},
+		{order: SortOrder{Field: "size", Desc: true}, want: []string{"1", "3", "2"}},
+		{order: SortOrder{Field: "path"}, want: []string{"2", "3", "1"}},
+		{order: SortOrder{Field: "created", Desc: true}, want: []string{"2", "3", "1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.order.String(), func(t *testing.T) {
+			sorted := append([]FileInfo(nil), files...)
+			SortFiles(sorted, tt.order)
+
+			var got []string
+			for _, f := range sorted {
+				got = append(got, f.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SortFiles(%v) = %v, want %v", tt.order, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSortFilesUnsizedLast(t *testing.T) {
+	files := []FileInfo{
+		{ID: "doc", Path: "doc", MimeType: "application/vnd.google-apps.document"},
+		{ID: "big", Path: "big", Size: 30, MD5: "b"},
+		{ID: "empty", Path: "empty", MD5: "d41d8cd98f00b204e9800998ecf8427e"},
+		{ID: "small", Path: "small", Size: 10, MD5: "s"},
+	}
+	for order, want := range map[SortOrder][]string{
+		{Field: "size"}:             {"empty", "small", "big", "doc"},
+		{Field: "size", Desc: true}: {"big", "small", "empty", "doc"},
+	} {
+		sorted := append([]FileInfo(nil), files...)
+		SortFiles(sorted, order)
+		var got []string
+		for _, f := range sorted {
+			got = append(got, f.ID)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("SortFiles(%v) = %v, want %v", order, got, want)
+		}
+	}
+}
+
+func TestParseDownloadOrder(t *testing.T) {
+	tests := map[string]SortOrder{
+		"smallest": {Field: "size"},
+		"Largest":  {Field: "size", Desc: true},
+		"oldest":   {Field: "modified"},
+		"newest":   {Field: "modified", Desc: true},
+		"path":     {Field: "path"},
+	}
+	for name, want := range tests {
+		got, err := ParseDownloadOrder(name)
+		if err != nil || got != want {
+			t.Errorf("ParseDownloadOrder(%q) = %v, %v, want %v", name, got, err, want)
+		}
+	}
+	if _, err := ParseDownloadOrder("size"); err == nil {
+		t.Error("expected error for unknown download order")
+	}
+}
+
+func TestSortFilesBreaksTiesDeterministically(t *testing.T) {
+	files := []FileInfo{
+		{ID: "4", Path: "b/x.txt", ModifiedAt: rfc3339("2025-04-01T00:00:00Z")},
+		{ID: "2", Path: "a/x.txt", ModifiedAt: rfc3339("2025-04-01T00:00:00Z")},
+		{ID: "5", Path: "c/x.txt", ModifiedAt: rfc3339("2025-04-02T00:00:00Z")},
+		{ID: "3", Path: "a/x.txt", ModifiedAt: rfc3339("2025-04-01T00:00:00Z")},
+		{ID: "1", Path: "b/x.txt", ModifiedAt: rfc3339("2025-04-01T00:00:00Z")},
+	}
+	want := []string{"5", "2", "3", "1", "4"}
+
+	// Every starting permutation must produce the same order
+	for shift := 0; shift < len(files); shift++ {
+		shuffled := append(append([]FileInfo(nil), files[shift:]...), files[:shift]...)
+		SortFiles(shuffled, DefaultSortOrder)
+
+		var got []string
+		for _, f := range shuffled {
+			got = append(got, f.ID)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("shift %d: SortFiles() = %v, want %v", shift, got, want)
+		}
+	}
+}
+
+func rfc3339(value string) time.Time {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestSortFilesComparesParsedTimes(t *testing.T) {
+	// Lexically "2025-04-01T10:00:00+02:00" sorts after "2025-04-01T09:00:00Z",
+	// but it is an hour earlier
+	files := []FileInfo{
+		{ID: "utc", ModifiedAt: rfc3339("2025-04-01T09:00:00Z")},
+		{ID: "offset", ModifiedAt: rfc3339("2025-04-01T10:00:00+02:00")},
+	}
+	SortFiles(files, DefaultSortOrder)
+	if files[0].ID != "utc" {
+		t.Errorf("newest file = %s, want utc", files[0].ID)
+	}
+}
@@ -0,0 +1,183 @@
+package drive
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxTracedBody is the most of each request or response body -http-trace-body
+// logs; file downloads in particular are cut short
+const maxTracedBody = 64 << 10
+
+// redactedHeaders are left out of traces, as they carry credentials
+var redactedHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+	"X-Goog-Api-Key":      true,
+}
+
+// redactedParams are query parameters left out of traced URLs
+var redactedParams = []string{"access_token", "key"}
+
+// traceTransport logs each request it sends when tracing is enabled with
+// WithHTTPTrace, and otherwise just passes requests on
+type traceTransport struct {
+	next http.RoundTripper
+
+	mu     sync.Mutex
+	w      io.Writer
+	bodies bool
+}
+
+// WithHTTPTrace logs the method, URL, headers, status and latency of every
+// Drive API request to w, with credentials redacted. With bodies, up to
+// 64 KiB of each request and response body is logged too. It must be called
+// before any request is made.
+func (d *DriveService) WithHTTPTrace(w io.Writer, bodies bool) *DriveService {
+	if d.tracer != nil {
+		d.tracer.w = w
+		d.tracer.bodies = bodies
+	}
+	return d
+}
+
+func (t *traceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.w == nil {
+		return t.next.RoundTrip(req)
+	}
+
+	var reqBody []byte
+	if t.bodies && req.Body != nil {
+		var err error
+		if reqBody, req.Body, err = peekBody(req.Body); err != nil {
+			return nil, err
+		}
+	}
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	elapsed := time.Since(start).Round(time.Millisecond)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "→ %s %s\n", req.Method, redactURL(req.URL))
+	writeHeaders(&b, req.Header)
+	if len(reqBody) > 0 {
+		writeBody(&b, reqBody, req.Header.Get("Content-Type"))
+	}
+	if err != nil {
+		fmt.Fprintf(&b, "← error after %v: %v\n\n", elapsed, err)
+		t.write(b.String())
+		return nil, err
+	}
+
+	fmt.Fprintf(&b, "← %s (%v)\n", resp.Status, elapsed)
+	writeHeaders(&b, resp.Header)
+	if t.bodies && resp.Body != nil {
+		var respBody []byte
+		if respBody, resp.Body, err = peekBody(resp.Body); err != nil {
+			return nil, err
+		}
+		writeBody(&b, respBody, resp.Header.Get("Content-Type"))
+	}
+	b.WriteString("\n")
+	t.write(b.String())
+	return resp, nil
+}
+
+// write logs one request; requests may be traced from several goroutines
+func (t *traceTransport) write(entry string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	io.WriteString(t.w, entry)
+}
+
+// peekBody reads up to maxTracedBody bytes of body, returning them along with
+// a body that still yields the whole content
+func peekBody(body io.ReadCloser) ([]byte, io.ReadCloser, error) {
+	head := make([]byte, maxTracedBody+1)
+	n, err := io.ReadFull(body, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		body.Close()
+		return nil, nil, err
+	}
+	head = head[:n]
+	rest := struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(head), body), body}
+	return head, rest, nil
+}
+
+// redactURL returns u with credentials in its query replaced
+func redactURL(u *url.URL) string {
+	query := u.Query()
+	redacted := false
+	for _, param := range redactedParams {
+		if query.Has(param) {
+			query.Set(param, "REDACTED")
+			redacted = true
+		}
+	}
+	if !redacted {
+		return u.String()
+	}
+	clean := *u
+	clean.RawQuery = query.Encode()
+	return clean.String()
+}
+
+// writeHeaders logs headers in a stable order, redacting credentials
+func writeHeaders(b *strings.Builder, header http.Header) {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := strings.Join(header[name], ", ")
+		if redactedHeaders[http.CanonicalHeaderKey(name)] {
+			value = "REDACTED"
+		}
+		fmt.Fprintf(b, "  %s: %s\n", name, value)
+	}
+}
+
+// writeBody logs a body peeked by peekBody, noting when it was cut short.
+// Only the size of binary content, such as downloaded files, is logged.
+func writeBody(b *strings.Builder, body []byte, contentType string) {
+	if !isTextual(contentType) {
+		size := fmt.Sprintf("%d", len(body))
+		if len(body) > maxTracedBody {
+			size = fmt.Sprintf("over %d", maxTracedBody)
+		}
+		fmt.Fprintf(b, "  [%s bytes of %s]\n", size, contentType)
+		return
+	}
+	truncated := len(body) > maxTracedBody
+	if truncated {
+		body = body[:maxTracedBody]
+	}
+	b.WriteString("  ")
+	b.Write(bytes.ReplaceAll(body, []byte("\n"), []byte("\n  ")))
+	b.WriteString("\n")
+	if truncated {
+		fmt.Fprintf(b, "  [body truncated after %d bytes]\n", maxTracedBody)
+	}
+}
+
+// isTextual reports whether content of the given type is readable in a trace
+func isTextual(contentType string) bool {
+	return strings.HasPrefix(contentType, "text/") ||
+		strings.Contains(contentType, "json") ||
+		strings.Contains(contentType, "xml") ||
+		strings.Contains(contentType, "x-www-form-urlencoded")
+}
@@ -0,0 +1,67 @@
+package drive
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTraceTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTeapot)
+		io.WriteString(w, `{"files": []}`)
+	}))
+	defer srv.Close()
+
+	get := func(bodies bool) (string, string) {
+		var log bytes.Buffer
+		d := &DriveService{tracer: &traceTransport{next: http.DefaultTransport}}
+		d.WithHTTPTrace(&log, bodies)
+
+		req, _ := http.NewRequest("GET", srv.URL+"/files?q=x&access_token=secret", nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := (&http.Client{Transport: d.tracer}).Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return log.String(), string(body)
+	}
+
+	trace, body := get(false)
+	if body != `{"files": []}` {
+		t.Errorf("body = %q, want it passed through", body)
+	}
+	if strings.Contains(trace, "secret") {
+		t.Errorf("trace leaks credentials:\n%s", trace)
+	}
+	for _, want := range []string{"→ GET " + srv.URL + "/files?", "access_token=REDACTED", "Authorization: REDACTED", "← 418 I'm a teapot ("} {
+		if !strings.Contains(trace, want) {
+			t.Errorf("trace is missing %q:\n%s", want, trace)
+		}
+	}
+	if strings.Contains(trace, `"files"`) {
+		t.Errorf("trace includes the body without bodies:\n%s", trace)
+	}
+
+	trace, body = get(true)
+	if body != `{"files": []}` {
+		t.Errorf("body = %q, want it passed through", body)
+	}
+	if !strings.Contains(trace, `  {"files": []}`) {
+		t.Errorf("trace is missing the body:\n%s", trace)
+	}
+}
+
+func TestWriteBodyBinary(t *testing.T) {
+	var b strings.Builder
+	writeBody(&b, make([]byte, maxTracedBody+1), "video/mp4")
+	if got, want := b.String(), "  [over 65536 bytes of video/mp4]\n"; got != want {
+		t.Errorf("writeBody() = %q, want %q", got, want)
+	}
+}
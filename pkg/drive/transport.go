@@ -0,0 +1,33 @@
+package drive
+
+import (
+	"net/http"
+)
+
+// Connection limits applied to Drive API hosts unless WithConnLimits says
+// otherwise. Google throttles clients that open many connections at once, and
+// requests are made one at a time, so a handful of connections is plenty.
+const (
+	DefaultMaxConnsPerHost     = 8
+	DefaultMaxIdleConnsPerHost = 4
+)
+
+// newBaseTransport returns the transport Drive requests are sent over, with
+// the default connection limits
+func newBaseTransport() *http.Transport {
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	base.MaxConnsPerHost = DefaultMaxConnsPerHost
+	base.MaxIdleConnsPerHost = DefaultMaxIdleConnsPerHost
+	return base
+}
+
+// WithConnLimits caps the connections open to each host at once (0 for no
+// limit) and those kept idle for reuse. It must be called before any request
+// is made.
+func (d *DriveService) WithConnLimits(maxConns, maxIdle int) *DriveService {
+	if d.transport != nil {
+		d.transport.MaxConnsPerHost = maxConns
+		d.transport.MaxIdleConnsPerHost = maxIdle
+	}
+	return d
+}
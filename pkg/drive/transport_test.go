@@ -0,0 +1,29 @@
+package drive
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWithConnLimits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	creds := `{"type": "authorized_user", "client_id": "id", "client_secret": "secret", "refresh_token": "token"}`
+	if err := os.WriteFile(path, []byte(creds), 0600); err != nil {
+		t.Fatalf("failed to write credentials: %v", err)
+	}
+
+	d, err := NewDriveServiceWithScope(path, false, ReadonlyScope)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d.transport.MaxConnsPerHost != DefaultMaxConnsPerHost || d.transport.MaxIdleConnsPerHost != DefaultMaxIdleConnsPerHost {
+		t.Errorf("default limits = %d/%d, want %d/%d", d.transport.MaxConnsPerHost, d.transport.MaxIdleConnsPerHost,
+			DefaultMaxConnsPerHost, DefaultMaxIdleConnsPerHost)
+	}
+
+	d.WithConnLimits(2, 1)
+	if d.transport.MaxConnsPerHost != 2 || d.transport.MaxIdleConnsPerHost != 1 {
+		t.Errorf("limits = %d/%d, want 2/1", d.transport.MaxConnsPerHost, d.transport.MaxIdleConnsPerHost)
+	}
+}
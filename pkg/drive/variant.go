@@ -0,0 +1,215 @@
+package drive
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// OriginalVariant is the variant name for a file's own content
+const OriginalVariant = "original"
+
+// errVariantUnavailable is returned by a variant that cannot be produced for a file
+var errVariantUnavailable = errors.New("variant not available")
+
+// Variant is a named output produced for a matched file alongside, or instead
+// of, its original content
+type Variant struct {
+	Name        string
+	Description string
+
+	// fetch returns the variant's content and the suffix appended to the
+	// file's output path
+	fetch func(d *DriveService, fileInfo FileInfo) (io.ReadCloser, string, error)
+
+	// export is the suffix of a variant exported from the file's content,
+	// which only changes along with the file's modification time
+	export string
+}
+
+// variants holds every supported variant by name, except the original
+// content which DownloadFile handles
+var variants = map[string]Variant{
+	"thumbnail": {
+		Name:        "thumbnail",
+		Description: "the thumbnail image Drive generated for the file",
+		fetch:       fetchThumbnail,
+	},
+	"pdf-export": {
+		Name:        "pdf-export",
+		Description: "a PDF export of a Google Docs editors file",
+		fetch:       fetchPDFExport,
+		export:      ".pdf",
+	},
+}
+
+// ValidateVariants checks that every name is a supported variant
+func ValidateVariants(names []string) error {
+	for _, name := range names {
+		if _, ok := variants[name]; !ok && name != OriginalVariant {
+			return fmt.Errorf("unknown variant %q (expected one of: %s)", name, strings.Join(VariantNames(), ", "))
+		}
+	}
+	return nil
+}
+
+// VariantNames returns the names of all supported variants
+func VariantNames() []string {
+	names := []string{OriginalVariant}
+	for name := range variants {
+		names = append(names, name)
+	}
+	sort.Strings(names[1:])
+	return names
+}
+
+// includesOriginal reports whether the file's own content is downloaded
+func (o DownloadOptions) includesOriginal() bool {
+	if len(o.Variants) == 0 {
+		return true
+	}
+	for _, name := range o.Variants {
+		if name == OriginalVariant {
+			return true
+		}
+	}
+	return false
+}
+
+// DownloadVariant saves the named variant of a file next to its original
+// output path. Variants that do not apply to the file are skipped.
+func (d *DriveService) DownloadVariant(fileInfo FileInfo, name string, opts DownloadOptions) error {
+	variant, ok := variants[name]
+	if !ok {
+		return fmt.Errorf("unknown variant %q", name)
+	}
+
+	baseDir, err := opts.BaseDir(fileInfo)
+	if err != nil {
+		return err
+	}
+	suffix := variant.export
+	if variant.export != "" && opts.TagRevision && isGoogleNative(fileInfo.MimeType) {
+		revision, err := d.headRevision(fileInfo)
+		if err != nil {
+			return err
+		}
+		suffix = revisionTag(revision) + variant.export
+	}
+	if variant.export != "" && opts.SkipUnchangedExports {
+		if exportUpToDate(localPath(baseDir, fileInfo.Path)+suffix, fileInfo.ModifiedAt, opts.ModTimeTolerance) {
+			d.printf("Skipping %s of %s: unchanged since it was last exported\n", name, fileInfo.Path)
+			return nil
+		}
+	}
+
+	d.log("📥 Fetching %s of: %s", name, fileInfo.Path)
+	body, fetchedSuffix, err := variant.fetch(d, fileInfo)
+	if errors.Is(err, errVariantUnavailable) {
+		d.printf("Skipping %s of %s: %v\n", name, fileInfo.Path, err)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	if variant.export == "" {
+		suffix = fetchedSuffix
+	}
+
+	outPath := localPath(baseDir, fileInfo.Path) + suffix
+	if err := d.writeFile(outPath, body, false); err != nil {
+		return err
+	}
+	if variant.export != "" && !fileInfo.ModifiedAt.IsZero() {
+		// Record which version of the file was exported for
+		// SkipUnchangedExports
+		if err := os.Chtimes(outPath, time.Now(), fileInfo.ModifiedAt); err != nil {
+			return fmt.Errorf("unable to record the export time of %s: %v", outPath, err)
+		}
+	}
+	return nil
+}
+
+// headRevision returns the ID of the file's current revision. Drive doesn't
+// report one for Google Docs editors files, whose newest revision is used.
+func (d *DriveService) headRevision(fileInfo FileInfo) (string, error) {
+	if fileInfo.HeadRevisionID != "" {
+		return fileInfo.HeadRevisionID, nil
+	}
+	var revisions []*drive.Revision
+	err := d.retryDo("Listing revisions", func() (err error) {
+		revisions, err = d.listRevisions(fileInfo.ID)
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("unable to look up the head revision: %v", err)
+	}
+	if len(revisions) == 0 {
+		return "", fmt.Errorf("unable to look up the head revision: Drive reports no revisions")
+	}
+	return revisions[len(revisions)-1].Id, nil
+}
+
+// revisionTag returns the part of an export's name identifying its revision
+func revisionTag(revision string) string {
+	return "@rev" + strings.NewReplacer("/", "_", "\\", "_").Replace(revision)
+}
+
+// exportUpToDate reports whether the export at path was made from the
+// version of a file last modified at modifiedAt or later: DownloadVariant
+// sets an export's modification time to that of the file it came from.
+func exportUpToDate(path string, modifiedAt time.Time, tolerance time.Duration) bool {
+	info, err := os.Stat(path)
+	if err != nil || info.Size() == 0 || modifiedAt.IsZero() {
+		return false
+	}
+	return !info.ModTime().Before(modifiedAt.Add(-tolerance))
+}
+
+func fetchThumbnail(d *DriveService, fileInfo FileInfo) (io.ReadCloser, string, error) {
+	if fileInfo.ThumbnailLink == "" {
+		return nil, "", fmt.Errorf("%w: Drive has no thumbnail for this file", errVariantUnavailable)
+	}
+
+	req, err := http.NewRequestWithContext(d.requestContext(), http.MethodGet, fileInfo.ThumbnailLink, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to fetch thumbnail: %v", err)
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to fetch thumbnail: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, "", fmt.Errorf("unable to fetch thumbnail: %s", resp.Status)
+	}
+
+	ext := ".img"
+	switch resp.Header.Get("Content-Type") {
+	case "image/png":
+		ext = ".png"
+	case "image/jpeg":
+		ext = ".jpg"
+	}
+	return resp.Body, ".thumbnail" + ext, nil
+}
+
+func fetchPDFExport(d *DriveService, fileInfo FileInfo) (io.ReadCloser, string, error) {
+	if !isGoogleNative(fileInfo.MimeType) {
+		return nil, "", fmt.Errorf("%w: only Google Docs editors files can be exported", errVariantUnavailable)
+	}
+
+	resp, err := d.service.Files.Export(fileInfo.ID, "application/pdf").Context(d.requestContext()).Download()
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to export PDF: %v", err)
+	}
+	return resp.Body, ".pdf", nil
+}
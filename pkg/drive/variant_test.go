@@ -0,0 +1,222 @@
+package drive
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDownloadFilesVariants(t *testing.T) {
+	fake := newFakeDrive()
+	fake.addFile("doc", "notes", "root", "2025-04-01T00:00:00Z", "meeting notes")
+	fake.files["doc"].MimeType = "application/vnd.google-apps.document"
+	fake.addFile("vid", "call.mp4", "root", "2025-04-01T00:00:00Z", "video bytes")
+	d := newTestService(t, fake)
+
+	files := []FileInfo{
+		{ID: "doc", Name: "notes", Path: "notes", MimeType: "application/vnd.google-apps.document"},
+		{ID: "vid", Name: "call.mp4", Path: "call.mp4", MimeType: "video/mp4", ThumbnailLink: fake.url + "/thumbnails/vid"},
+	}
+	outputDir := t.TempDir()
+
+	report, err := d.DownloadFiles(files, DownloadOptions{OutputDir: outputDir, Variants: []string{"thumbnail", "pdf-export"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(report.Downloaded) != 0 {
+		t.Errorf("downloaded %d originals, want 0 without the original variant", len(report.Downloaded))
+	}
+
+	want := map[string]string{
+		"notes.pdf":              "exported application/pdf: meeting notes",
+		"call.mp4.thumbnail.png": "thumbnail of vid",
+	}
+	for name, content := range want {
+		got, err := os.ReadFile(filepath.Join(outputDir, name))
+		if err != nil {
+			t.Errorf("expected %s: %v", name, err)
+			continue
+		}
+		if string(got) != content {
+			t.Errorf("%s = %q, want %q", name, got, content)
+		}
+	}
+
+	for _, name := range []string{"notes", "call.mp4", "call.mp4.pdf"} {
+		if _, err := os.Stat(filepath.Join(outputDir, name)); err == nil {
+			t.Errorf("unexpected output %s", name)
+		}
+	}
+}
+
+func TestValidateVariants(t *testing.T) {
+	if err := ValidateVariants([]string{"original", "thumbnail", "pdf-export"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := ValidateVariants([]string{"lowres"}); err == nil {
+		t.Error("expected error for unknown variant")
+	}
+}
+
+func TestSkipUnchangedExports(t *testing.T) {
+	fake := newFakeDrive()
+	fake.addFile("doc", "notes", "root", "2025-04-01T00:00:00Z", "v1")
+	fake.files["doc"].MimeType = "application/vnd.google-apps.document"
+	d := newTestService(t, fake)
+
+	file := FileInfo{ID: "doc", Name: "notes", Path: "notes", MimeType: "application/vnd.google-apps.document", ModifiedAt: rfc3339("2025-04-01T00:00:00Z")}
+	outputDir := t.TempDir()
+	opts := DownloadOptions{OutputDir: outputDir, Variants: []string{"pdf-export"}, SkipUnchangedExports: true}
+	export := func(file FileInfo) string {
+		t.Helper()
+		if _, err := d.DownloadFiles([]FileInfo{file}, opts); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got, err := os.ReadFile(filepath.Join(outputDir, "notes.pdf"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(got)
+	}
+
+	if got := export(file); got != "exported application/pdf: v1" {
+		t.Fatalf("first export = %q", got)
+	}
+
+	// Drive would only serve different content for a newer version
+	fake.contents["doc"] = "v2"
+	if got := export(file); got != "exported application/pdf: v1" {
+		t.Errorf("export of an unchanged file = %q, want it skipped", got)
+	}
+
+	file.ModifiedAt = file.ModifiedAt.Add(time.Hour)
+	if got := export(file); got != "exported application/pdf: v2" {
+		t.Errorf("export of a modified file = %q, want it exported again", got)
+	}
+}
+
+func TestTagRevision(t *testing.T) {
+	fake := newFakeDrive()
+	fake.addFile("doc", "notes", "root", "2025-04-01T00:00:00Z", "v1")
+	fake.files["doc"].MimeType = "application/vnd.google-apps.document"
+	fake.revisions["doc"] = []string{"1", "2"}
+	d := newTestService(t, fake)
+
+	file := FileInfo{ID: "doc", Name: "notes", Path: "notes", MimeType: "application/vnd.google-apps.document", ModifiedAt: rfc3339("2025-04-01T00:00:00Z")}
+	outputDir := t.TempDir()
+	opts := DownloadOptions{OutputDir: outputDir, Variants: []string{"pdf-export"}, TagRevision: true, SkipUnchangedExports: true}
+	if _, err := d.DownloadFiles([]FileInfo{file}, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "notes@rev2.pdf")); err != nil {
+		t.Errorf("expected the export named after the newest revision: %v", err)
+	}
+
+	// A new revision gets an export of its own even though the file's
+	// modification time is unchanged
+	fake.revisions["doc"] = append(fake.revisions["doc"], "3")
+	if _, err := d.DownloadFiles([]FileInfo{file}, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, name := range []string{"notes@rev2.pdf", "notes@rev3.pdf"} {
+		if _, err := os.Stat(filepath.Join(outputDir, name)); err != nil {
+			t.Errorf("expected %s: %v", name, err)
+		}
+	}
+
+	file.HeadRevisionID = "abc/def"
+	if _, err := d.DownloadFiles([]FileInfo{file}, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "notes@revabc_def.pdf")); err != nil {
+		t.Errorf("expected the export named after the reported head revision: %v", err)
+	}
+}
+
+func TestDownloadFilesExportWorkers(t *testing.T) {
+	fake := newFakeDrive()
+	for _, id := range []string{"doc1", "doc2", "doc3"} {
+		fake.addFile(id, id, "root", "2025-04-01T00:00:00Z", "text of "+id)
+		fake.files[id].MimeType = "application/vnd.google-apps.document"
+	}
+	fake.addFile("vid", "call.mp4", "root", "2025-04-01T00:00:00Z", "video bytes")
+	d := newTestService(t, fake)
+
+	files := []FileInfo{
+		{ID: "doc1", Name: "doc1", Path: "doc1", MimeType: "application/vnd.google-apps.document"},
+		{ID: "vid", Name: "call.mp4", Path: "call.mp4", MimeType: "video/mp4"},
+		{ID: "doc2", Name: "doc2", Path: "doc2", MimeType: "application/vnd.google-apps.document"},
+		{ID: "doc3", Name: "doc3", Path: "doc3", MimeType: "application/vnd.google-apps.document"},
+	}
+	outputDir := t.TempDir()
+	opts := DownloadOptions{OutputDir: outputDir, Variants: []string{"pdf-export"}, ExportWorkers: 2}
+
+	report, err := d.DownloadFiles(files, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, id := range []string{"doc1", "doc2", "doc3"} {
+		got, err := os.ReadFile(filepath.Join(outputDir, id+".pdf"))
+		if err != nil {
+			t.Errorf("expected %s.pdf: %v", id, err)
+		} else if want := "exported application/pdf: text of " + id; string(got) != want {
+			t.Errorf("%s.pdf = %q, want %q", id, got, want)
+		}
+	}
+	if report.Downloads.Workers != 1 || report.Downloads.Tasks != 4 {
+		t.Errorf("download stats = %+v, want 4 tasks on 1 worker", report.Downloads)
+	}
+	// The video can't be exported, but a worker still finds that out
+	if report.Exports.Workers != 2 || report.Exports.Tasks != 4 {
+		t.Errorf("export stats = %+v, want 4 tasks on 2 workers", report.Exports)
+	}
+
+	fake.failures["files/doc2/export"] = []int{http.StatusNotFound}
+	opts.OutputDir = t.TempDir()
+	report, err = d.DownloadFiles(files, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(report.Failed) != 1 || !strings.Contains(report.Failed[0].Err.Error(), "pdf-export of doc2") {
+		t.Errorf("failed = %v, want the failed export of doc2", report.Failed)
+	}
+	// The other exports still ran
+	for _, id := range []string{"doc1", "doc3"} {
+		if _, err := os.Stat(filepath.Join(opts.OutputDir, id+".pdf")); err != nil {
+			t.Errorf("expected %s.pdf after doc2 failed: %v", id, err)
+		}
+	}
+}
+
+func TestDownloadFilesSeenAfterExports(t *testing.T) {
+	fake := newFakeDrive()
+	for _, id := range []string{"doc1", "doc2"} {
+		fake.addFile(id, id, "root", "2025-04-01T00:00:00Z", "text of "+id)
+		fake.files[id].MimeType = "application/vnd.google-apps.document"
+	}
+	d := newTestService(t, fake)
+
+	files := []FileInfo{
+		{ID: "doc1", Name: "doc1", Path: "doc1", MimeType: "application/vnd.google-apps.document"},
+		{ID: "doc2", Name: "doc2", Path: "doc2", MimeType: "application/vnd.google-apps.document"},
+	}
+	fake.failures["files/doc2/export"] = []int{http.StatusNotFound}
+	seen := NewBloomFilter(10, 0.01)
+	opts := DownloadOptions{OutputDir: t.TempDir(), Variants: []string{"pdf-export"}, ExportWorkers: 2, Seen: seen}
+	report, err := d.DownloadFiles(files, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(report.Failed) != 1 || report.Failed[0].File.ID != "doc2" {
+		t.Fatalf("failed = %v, want doc2", report.Failed)
+	}
+	if !seen.Test("doc1") {
+		t.Error("doc1 was not added to the seen filter once exported")
+	}
+	if seen.Test("doc2") {
+		t.Error("doc2 was added to the seen filter although its export failed")
+	}
+}
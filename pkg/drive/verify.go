@@ -0,0 +1,211 @@
+package drive
+
+import (
+	"compress/gzip"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+)
+
+// VerifyReport records the outcome of VerifyLocal
+type VerifyReport struct {
+	Verified   []FileInfo
+	Missing    []FileInfo
+	Mismatched []FileFailure
+
+	// Unchecked lists files Drive reports no checksum for, such as Google
+	// Docs editors files
+	Unchecked []FileInfo
+
+	// Bytes is the amount of local content hashed, in Elapsed
+	Bytes   int64
+	Elapsed time.Duration
+}
+
+// Throughput returns the hashing rate in bytes per second
+func (r *VerifyReport) Throughput() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Bytes) / r.Elapsed.Seconds()
+}
+
+// verifyResult is the outcome of hashing one local copy
+type verifyResult struct {
+	sum   string
+	bytes int64
+	err   error
+}
+
+// VerifyLocal checks previously downloaded copies of files against the
+// checksums reported by Drive, without downloading anything. Local paths are
+// computed with opts exactly as DownloadFiles would. Up to opts.VerifyWorkers
+// files are hashed at once; the report lists files in their original order.
+func (d *DriveService) VerifyLocal(files []FileInfo, opts DownloadOptions) (*VerifyReport, error) {
+	report := &VerifyReport{}
+	var checked []FileInfo
+	var paths []string
+	for _, file := range files {
+		if file.IsFolder {
+			continue
+		}
+		if !file.HasChecksum() {
+			report.Unchecked = append(report.Unchecked, file)
+			continue
+		}
+		localPath, err := opts.OutputPath(file)
+		if err != nil {
+			return report, err
+		}
+		checked = append(checked, file)
+		paths = append(paths, localPath)
+	}
+
+	start := time.Now()
+	results := make([]verifyResult, len(checked))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < max(opts.VerifyWorkers, 1); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				d.log("🔍 Verifying %s against %s", paths[i], checked[i].MD5)
+				sum, n, err := hashFile(paths[i], opts.compresses(checked[i]))
+				results[i] = verifyResult{sum: sum, bytes: n, err: err}
+			}
+		}()
+	}
+	for i := range checked {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	report.Elapsed = time.Since(start)
+
+	for i, file := range checked {
+		result := results[i]
+		report.Bytes += result.bytes
+		switch {
+		case errors.Is(result.err, fs.ErrNotExist):
+			report.Missing = append(report.Missing, file)
+		case result.err != nil:
+			report.Mismatched = append(report.Mismatched, FileFailure{File: file, Err: result.err})
+		case result.sum != file.MD5:
+			report.Mismatched = append(report.Mismatched, FileFailure{
+				File: file,
+				Err:  fmt.Errorf("%w: got %s, Drive reports %s", ErrChecksumMismatch, result.sum, file.MD5),
+			})
+		default:
+			report.Verified = append(report.Verified, file)
+		}
+	}
+	return report, nil
+}
+
+// hashFile returns the hex MD5 of a local file's content, decompressing it
+// first when compressed is set, along with the number of bytes hashed
+func hashFile(path string, compressed bool) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if compressed {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return "", 0, fmt.Errorf("unable to read %s: %v", path, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	hash := md5.New()
+	n, err := io.Copy(hash, r)
+	if err != nil {
+		return "", n, fmt.Errorf("unable to read %s: %v", path, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), n, nil
+}
+
+// LocalState describes how a Drive file compares with its local copy
+type LocalState string
+
+const (
+	StateNew       LocalState = "NEW"
+	StateUpdate    LocalState = "UPDATE"
+	StateUnchanged LocalState = "UNCHANGED"
+)
+
+// DefaultModTimeTolerance absorbs the difference between Drive's
+// millisecond modification times and local filesystems that round them,
+// such as FAT with its 2 second resolution
+const DefaultModTimeTolerance = 2 * time.Second
+
+// LocalDiff is the state of one file found by DiffLocal
+type LocalDiff struct {
+	File      FileInfo
+	LocalPath string
+	State     LocalState
+}
+
+// DiffLocal reports, without downloading anything, whether each file would
+// be new, would overwrite a different local copy, or already matches it.
+// Local paths are computed with opts exactly as DownloadFiles would.
+func (d *DriveService) DiffLocal(files []FileInfo, opts DownloadOptions) ([]LocalDiff, error) {
+	var diffs []LocalDiff
+	for _, file := range files {
+		if file.IsFolder {
+			continue
+		}
+		localPath, err := opts.OutputPath(file)
+		if err != nil {
+			return diffs, err
+		}
+		state, err := localState(file, localPath, opts.compresses(file), opts.ModTimeTolerance)
+		if err != nil {
+			return diffs, err
+		}
+		d.log("🔍 %s: %s", localPath, state)
+		diffs = append(diffs, LocalDiff{File: file, LocalPath: localPath, State: state})
+	}
+	return diffs, nil
+}
+
+// localState compares a file with its local copy. The MD5 is compared when
+// Drive reports one. Otherwise the copy is unchanged when it is the same
+// size and no older than the Drive file, give or take tolerance; compressed
+// copies can't be compared by size and count as updates.
+func localState(file FileInfo, localPath string, compressed bool, tolerance time.Duration) (LocalState, error) {
+	info, err := os.Stat(localPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return StateNew, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("unable to inspect %s: %v", localPath, err)
+	}
+
+	if file.HasChecksum() {
+		sum, _, err := hashFile(localPath, compressed)
+		if err != nil {
+			return StateUpdate, nil
+		}
+		if sum == file.MD5 {
+			return StateUnchanged, nil
+		}
+		return StateUpdate, nil
+	}
+
+	if !compressed && file.HasSize() && info.Size() == file.Size && !info.ModTime().Before(file.ModifiedAt.Add(-tolerance)) {
+		return StateUnchanged, nil
+	}
+	return StateUpdate, nil
+}
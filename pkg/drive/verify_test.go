@@ -0,0 +1,172 @@
+package drive
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyLocal(t *testing.T) {
+	outputDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outputDir, "good.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(outputDir, "bad.txt"), []byte("HELLO"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	files := []FileInfo{
+		{ID: "1", Name: "good.txt", Path: "good.txt", MD5: md5Hex("hello")},
+		{ID: "2", Name: "bad.txt", Path: "bad.txt", MD5: md5Hex("hello")},
+		{ID: "3", Name: "gone.txt", Path: "gone.txt", MD5: md5Hex("hello")},
+		{ID: "4", Name: "doc", Path: "doc", MimeType: "application/vnd.google-apps.document"},
+		{ID: "5", Name: "dir", Path: "dir", IsFolder: true},
+	}
+
+	d := &DriveService{}
+	report, err := d.VerifyLocal(files, DownloadOptions{OutputDir: outputDir})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(report.Verified) != 1 || report.Verified[0].ID != "1" {
+		t.Errorf("Verified = %v, want only 1", report.Verified)
+	}
+	if len(report.Mismatched) != 1 || report.Mismatched[0].File.ID != "2" || !errors.Is(report.Mismatched[0].Err, ErrChecksumMismatch) {
+		t.Errorf("Mismatched = %v, want only 2 with ErrChecksumMismatch", report.Mismatched)
+	}
+	if len(report.Missing) != 1 || report.Missing[0].ID != "3" {
+		t.Errorf("Missing = %v, want only 3", report.Missing)
+	}
+	if len(report.Unchecked) != 1 || report.Unchecked[0].ID != "4" {
+		t.Errorf("Unchecked = %v, want only 4", report.Unchecked)
+	}
+}
+
+func TestVerifyLocalParallel(t *testing.T) {
+	outputDir := t.TempDir()
+	var files []FileInfo
+	for i := 0; i < 20; i++ {
+		name := fmt.Sprintf("f%02d.txt", i)
+		content := strings.Repeat(name, 100)
+		if i%5 == 0 {
+			content = "corrupted"
+		}
+		if err := os.WriteFile(filepath.Join(outputDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, FileInfo{ID: name, Name: name, Path: name, MD5: md5Hex(strings.Repeat(name, 100))})
+	}
+
+	d := &DriveService{}
+	report, err := d.VerifyLocal(files, DownloadOptions{OutputDir: outputDir, VerifyWorkers: 4})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(report.Verified) != 16 || len(report.Mismatched) != 4 {
+		t.Fatalf("verified %d and mismatched %d files, want 16 and 4", len(report.Verified), len(report.Mismatched))
+	}
+	for i, failure := range report.Mismatched {
+		if want := fmt.Sprintf("f%02d.txt", i*5); failure.File.ID != want {
+			t.Errorf("Mismatched[%d] = %s, want %s in listing order", i, failure.File.ID, want)
+		}
+	}
+	if want := int64(16*700 + 4*len("corrupted")); report.Bytes != want {
+		t.Errorf("Bytes = %d, want %d", report.Bytes, want)
+	}
+}
+
+func TestVerifyLocalCompressed(t *testing.T) {
+	fake := newFakeDrive()
+	fake.addFile("a", "a.txt", "root", "2025-04-01T00:00:00Z", "hello")
+	d := newTestService(t, fake)
+
+	opts := DownloadOptions{OutputDir: t.TempDir(), Compress: "gzip"}
+	files := []FileInfo{{ID: "a", Name: "a.txt", Path: "a.txt", MD5: md5Hex("hello")}}
+	if _, err := d.DownloadFiles(files, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	report, err := d.VerifyLocal(files, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(report.Verified) != 1 {
+		t.Errorf("report = %+v, want the gzipped copy verified", report)
+	}
+}
+
+func TestDiffLocal(t *testing.T) {
+	outputDir := t.TempDir()
+	for name, content := range map[string]string{"same.txt": "hello", "changed.txt": "HELLO", "doc": "exported"} {
+		if err := os.WriteFile(filepath.Join(outputDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files := []FileInfo{
+		{ID: "1", Name: "same.txt", Path: "same.txt", MD5: md5Hex("hello")},
+		{ID: "2", Name: "changed.txt", Path: "changed.txt", MD5: md5Hex("hello")},
+		{ID: "3", Name: "new.txt", Path: "new.txt", MD5: md5Hex("hello")},
+		{ID: "4", Name: "doc", Path: "doc", Size: 8, ModifiedAt: rfc3339("2020-01-01T00:00:00Z")},
+		{ID: "5", Name: "doc", Path: "doc", Size: 8, ModifiedAt: time.Now().Add(time.Hour)},
+		{ID: "6", Name: "dir", Path: "dir", IsFolder: true},
+	}
+
+	d := &DriveService{}
+	diffs, err := d.DiffLocal(files, DownloadOptions{OutputDir: outputDir})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []LocalState{StateUnchanged, StateUpdate, StateNew, StateUnchanged, StateUpdate}
+	if len(diffs) != len(want) {
+		t.Fatalf("got %d diffs, want %d", len(diffs), len(want))
+	}
+	for i, diff := range diffs {
+		if diff.State != want[i] {
+			t.Errorf("%s (ID %s): state = %s, want %s", diff.File.Path, diff.File.ID, diff.State, want[i])
+		}
+	}
+}
+
+func TestLocalStateModTimeTolerance(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "doc")
+	if err := os.WriteFile(path, []byte("exported"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	local := rfc3339("2025-01-01T12:00:00Z")
+	if err := os.Chtimes(path, local, local); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		remote    time.Time
+		tolerance time.Duration
+		want      LocalState
+	}{
+		{"same time", local, 0, StateUnchanged},
+		{"local newer", local.Add(-time.Hour), 0, StateUnchanged},
+		{"sub-second older without tolerance", local.Add(500 * time.Millisecond), 0, StateUpdate},
+		{"sub-second older", local.Add(500 * time.Millisecond), 2 * time.Second, StateUnchanged},
+		{"at the tolerance", local.Add(2 * time.Second), 2 * time.Second, StateUnchanged},
+		{"just past the tolerance", local.Add(2*time.Second + time.Millisecond), 2 * time.Second, StateUpdate},
+		{"much older", local.Add(time.Hour), 2 * time.Second, StateUpdate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := FileInfo{Name: "doc", Path: "doc", Size: 8, ModifiedAt: tt.remote}
+			state, err := localState(file, path, false, tt.tolerance)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if state != tt.want {
+				t.Errorf("state = %s, want %s", state, tt.want)
+			}
+		})
+	}
+}
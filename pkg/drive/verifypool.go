@@ -0,0 +1,150 @@
+package drive
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// verifyPool checks the checksums of downloads in the background, so hashing
+// large files doesn't hold up the next download. Each download is queued as
+// its ".part" file, hashed on up to DownloadOptions.VerifyWorkers at once
+// and moved into place only once it matches; a part that doesn't match is
+// removed. Outcomes are collected by the download loop, which owns the
+// run's state and downloads mismatched files again.
+type verifyPool struct {
+	d       *DriveService
+	opts    DownloadOptions
+	workers int
+	wg      sync.WaitGroup
+
+	mu    sync.Mutex
+	ready *sync.Cond
+	queue []verifyJob
+	// running is how many workers are taking parts off the queue
+	running int
+	// pending is how many queued parts have not been collected yet
+	pending int
+	done    []verifyJob
+	stopped bool
+	stats   PoolStats
+}
+
+// verifyJob is a downloaded ".part" file waiting to be checked
+type verifyJob struct {
+	file    FileInfo
+	outPath string
+	// recordPath is the part record left for FinishIncomplete, if any
+	recordPath string
+	// attempt counts the downloads of the file after a checksum mismatch
+	attempt int
+	err     error
+}
+
+func newVerifyPool(d *DriveService, opts DownloadOptions) *verifyPool {
+	p := &verifyPool{
+		d:       d,
+		opts:    opts,
+		workers: opts.VerifyWorkers,
+		stats:   PoolStats{Workers: opts.VerifyWorkers},
+	}
+	p.ready = sync.NewCond(&p.mu)
+	return p
+}
+
+// add queues a downloaded part for checking, starting a worker if fewer
+// than the limit are running
+func (p *verifyPool) add(job verifyJob) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.queue = append(p.queue, job)
+	p.pending++
+	if p.running < p.workers {
+		p.running++
+		p.wg.Add(1)
+		go p.work()
+	}
+}
+
+// work checks queued parts until the queue is empty or the pool is stopped
+func (p *verifyPool) work() {
+	defer p.wg.Done()
+	for {
+		p.mu.Lock()
+		if len(p.queue) == 0 || p.stopped {
+			p.running--
+			p.mu.Unlock()
+			return
+		}
+		job := p.queue[0]
+		p.queue = p.queue[1:]
+		p.mu.Unlock()
+
+		start := time.Now()
+		n := p.check(&job)
+		p.mu.Lock()
+		p.stats.Tasks++
+		p.stats.Busy += time.Since(start)
+		p.stats.Bytes += n
+		p.done = append(p.done, job)
+		p.ready.Broadcast()
+		p.mu.Unlock()
+	}
+}
+
+// check hashes a part and moves it into place if it matches Drive's
+// checksum, recording the outcome in job. It returns the bytes hashed.
+func (p *verifyPool) check(job *verifyJob) int64 {
+	d, file := p.d, job.file
+	partPath := job.outPath + partSuffix
+	d.log("🔍 Verifying %s against %s", partPath, file.MD5)
+	sum, n, err := hashFile(partPath, p.opts.compresses(file))
+	if err == nil && sum != file.MD5 {
+		err = fmt.Errorf("%w: got %s, Drive reports %s", ErrChecksumMismatch, sum, file.MD5)
+	}
+	if err == nil {
+		d.log("  Checksum verified: %s", file.MD5)
+		err = d.movePart(job.outPath)
+	}
+	if err != nil {
+		os.Remove(partPath)
+	}
+	if job.recordPath != "" {
+		os.Remove(job.recordPath)
+	}
+	job.err = err
+	return n
+}
+
+// collect takes the parts checked so far off the pool. With wait set, it
+// first waits for at least one to be checked, unless none is pending.
+func (p *verifyPool) collect(wait bool) []verifyJob {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for wait && len(p.done) == 0 && p.pending > 0 {
+		p.ready.Wait()
+	}
+	done := p.done
+	p.done = nil
+	p.pending -= len(done)
+	return done
+}
+
+// busy reports whether any part has yet to be collected
+func (p *verifyPool) busy() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.pending > 0
+}
+
+// stop drops the parts still queued, leaving them with their record for
+// FinishIncomplete, and waits for those being checked
+func (p *verifyPool) stop() PoolStats {
+	p.mu.Lock()
+	p.stopped = true
+	p.queue = nil
+	p.mu.Unlock()
+	p.wg.Wait()
+	return p.stats
+}
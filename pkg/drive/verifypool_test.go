@@ -0,0 +1,73 @@
+package drive
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDownloadFilesVerifyPool(t *testing.T) {
+	fake := newFakeDrive()
+	fake.addFile("a", "a.txt", "root", "2025-04-01T00:00:00Z", "hello world")
+	fake.addFile("b", "b.txt", "root", "2025-04-01T00:00:00Z", "bonjour")
+	fake.corrupt["a"] = 1
+	d := newTestService(t, fake)
+
+	outputDir := t.TempDir()
+	files := []FileInfo{
+		{ID: "a", Name: "a.txt", Path: "a.txt", Size: 11, MD5: md5Hex("hello world")},
+		{ID: "b", Name: "b.txt", Path: "b.txt", Size: 7, MD5: md5Hex("bonjour")},
+	}
+	seen := NewBloomFilter(10, 0.01)
+	opts := DownloadOptions{OutputDir: outputDir, VerifyChecksum: true, VerifyWorkers: 2, ChecksumRetries: 1, Seen: seen}
+	report, err := d.DownloadFiles(files, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(report.Downloaded) != 2 || len(report.Failed) != 0 {
+		t.Fatalf("downloaded %d, failed %v", len(report.Downloaded), report.Failed)
+	}
+	for name, want := range map[string]string{"a.txt": "hello world", "b.txt": "bonjour"} {
+		if got, _ := os.ReadFile(filepath.Join(outputDir, name)); string(got) != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	// The corrupt download of a.txt was checked and downloaded again
+	stats := report.Verification
+	if stats.Workers != 2 || stats.Tasks != 3 || stats.Bytes != 29 {
+		t.Errorf("verification stats = %+v, want 3 parts and 29 bytes checked on 2 workers", stats)
+	}
+	if !seen.Test("a") || !seen.Test("b") {
+		t.Error("verified downloads were not added to the seen filter")
+	}
+	entries, _ := os.ReadDir(outputDir)
+	if len(entries) != 2 {
+		t.Errorf("output directory holds %v, want only the two files", entries)
+	}
+}
+
+func TestDownloadFilesVerifyPoolMismatch(t *testing.T) {
+	fake := newFakeDrive()
+	fake.addFile("a", "a.txt", "root", "2025-04-01T00:00:00Z", "hello world")
+	fake.corrupt["a"] = 1
+	d := newTestService(t, fake)
+
+	outputDir := t.TempDir()
+	seen := NewBloomFilter(10, 0.01)
+	files := []FileInfo{{ID: "a", Name: "a.txt", Path: "a.txt", Size: 11, MD5: md5Hex("hello world")}}
+	report, err := d.DownloadFiles(files, DownloadOptions{OutputDir: outputDir, VerifyChecksum: true, VerifyWorkers: 2, Seen: seen})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(report.Failed) != 1 || !errors.Is(report.Failed[0].Err, ErrChecksumMismatch) {
+		t.Fatalf("failed = %v, want a.txt with ErrChecksumMismatch", report.Failed)
+	}
+	// Nothing is moved into place nor left behind
+	if entries, _ := os.ReadDir(outputDir); len(entries) != 0 {
+		t.Errorf("output directory holds %v, want nothing", entries)
+	}
+	if seen.Test("a") {
+		t.Error("a download that failed its checksum was added to the seen filter")
+	}
+}
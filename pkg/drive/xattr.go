@@ -0,0 +1,41 @@
+package drive
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Extended attributes recording where a download came from
+const (
+	XattrID  = "user.drive.id"
+	XattrMD5 = "user.drive.md5"
+)
+
+// ErrXattrUnsupported is returned when extended attributes can't be set,
+// because of the operating system or the filesystem of the output directory
+var ErrXattrUnsupported = errors.New("extended attributes not supported")
+
+// writeXattrs records the file's Drive ID, and MD5 when Drive reports one,
+// as extended attributes of its local copy
+func (d *DriveService) writeXattrs(fileInfo FileInfo, opts DownloadOptions) error {
+	outPath, err := opts.OutputPath(fileInfo)
+	if err != nil {
+		return err
+	}
+
+	d.log("  Setting extended attributes: %s", outPath)
+	if err := setXattr(outPath, XattrID, fileInfo.ID); err != nil {
+		return err
+	}
+	if fileInfo.HasChecksum() {
+		if err := setXattr(outPath, XattrMD5, fileInfo.MD5); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// xattrError wraps an error from setting the extended attribute name
+func xattrError(name string, err error) error {
+	return fmt.Errorf("unable to set %s: %w", name, err)
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+
+package drive
+
+// setXattr fails, as extended attributes are only set on Linux and macOS
+func setXattr(path, name, value string) error {
+	return xattrError(name, ErrXattrUnsupported)
+}
@@ -0,0 +1,21 @@
+//go:build linux || darwin
+
+package drive
+
+import (
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// setXattr sets the extended attribute name of the file at path
+func setXattr(path, name, value string) error {
+	err := unix.Setxattr(path, name, []byte(value), 0)
+	if errors.Is(err, unix.ENOTSUP) || errors.Is(err, unix.EOPNOTSUPP) {
+		err = ErrXattrUnsupported
+	}
+	if err != nil {
+		return xattrError(name, err)
+	}
+	return nil
+}
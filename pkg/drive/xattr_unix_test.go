@@ -0,0 +1,47 @@
+//go:build linux || darwin
+
+package drive
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func getXattr(t *testing.T, path, name string) string {
+	t.Helper()
+	buf := make([]byte, 256)
+	n, err := unix.Getxattr(path, name, buf)
+	if err != nil {
+		t.Fatalf("unable to read %s: %v", name, err)
+	}
+	return string(buf[:n])
+}
+
+func TestWriteXattrs(t *testing.T) {
+	outputDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outputDir, "a.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	d := &DriveService{}
+	file := FileInfo{ID: "id-a", Name: "a.txt", Path: "a.txt", MD5: md5Hex("hello")}
+	err := d.writeXattrs(file, DownloadOptions{OutputDir: outputDir})
+	if errors.Is(err, ErrXattrUnsupported) {
+		t.Skipf("the temporary directory doesn't support extended attributes: %v", err)
+	}
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	path := filepath.Join(outputDir, "a.txt")
+	if got := getXattr(t, path, XattrID); got != "id-a" {
+		t.Errorf("%s = %q, want %q", XattrID, got, "id-a")
+	}
+	if got := getXattr(t, path, XattrMD5); got != file.MD5 {
+		t.Errorf("%s = %q, want %q", XattrMD5, got, file.MD5)
+	}
+}
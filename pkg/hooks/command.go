@@ -0,0 +1,141 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/kubenoops-ai/google-drive-downloader/pkg/drive"
+)
+
+// CommandData is the data a command template is rendered with. It exposes
+// every FileInfo field, except that Path is the local path of the download;
+// the path in Drive is available as DrivePath.
+type CommandData struct {
+	drive.FileInfo
+	Path      string
+	DrivePath string
+}
+
+// CommandHook runs a templated command for each downloaded file.
+//
+// The command line is split into arguments before the template is rendered,
+// and the command is executed directly rather than through a shell, so values
+// substituted from file metadata always stay within a single argument and
+// cannot inject further commands.
+type CommandHook struct {
+	args    []*template.Template
+	timeout time.Duration
+}
+
+// NewCommandHook parses a command line such as 'ffmpeg -i {{.Path}} out.mp3'.
+// Arguments may be quoted with single or double quotes. A timeout of 0 means
+// the command may run indefinitely.
+func NewCommandHook(command string, timeout time.Duration) (*CommandHook, error) {
+	words, err := splitCommand(command)
+	if err != nil {
+		return nil, err
+	}
+	if len(words) == 0 {
+		return nil, fmt.Errorf("empty command")
+	}
+
+	hook := &CommandHook{timeout: timeout}
+	for i, word := range words {
+		tmpl, err := template.New(fmt.Sprintf("arg%d", i)).Option("missingkey=error").Parse(word)
+		if err != nil {
+			return nil, fmt.Errorf("invalid command template: %v", err)
+		}
+		hook.args = append(hook.args, tmpl)
+	}
+	return hook, nil
+}
+
+// Args renders the command's arguments for a downloaded file
+func (h *CommandHook) Args(file drive.FileInfo, localPath string) ([]string, error) {
+	data := CommandData{FileInfo: file, Path: localPath, DrivePath: file.Path}
+
+	var args []string
+	for _, tmpl := range h.args {
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return nil, fmt.Errorf("unable to render command: %v", err)
+		}
+		args = append(args, buf.String())
+	}
+	return args, nil
+}
+
+// Run executes the command for a downloaded file and returns its combined
+// stdout and stderr. A non-zero exit status, timeout or cancellation of ctx is
+// returned as an error.
+func (h *CommandHook) Run(ctx context.Context, file drive.FileInfo, localPath string) ([]byte, error) {
+	args, err := h.Args(file, localPath)
+	if err != nil {
+		return nil, err
+	}
+
+	cmdCtx := ctx
+	if h.timeout > 0 {
+		var cancel context.CancelFunc
+		cmdCtx, cancel = context.WithTimeout(ctx, h.timeout)
+		defer cancel()
+	}
+
+	output, err := exec.CommandContext(cmdCtx, args[0], args[1:]...).CombinedOutput()
+	if ctx.Err() != nil {
+		return output, fmt.Errorf("command cancelled: %v", ctx.Err())
+	}
+	if errors.Is(cmdCtx.Err(), context.DeadlineExceeded) {
+		return output, fmt.Errorf("command timed out after %v", h.timeout)
+	}
+	if err != nil {
+		return output, fmt.Errorf("command failed: %v", err)
+	}
+	return output, nil
+}
+
+// splitCommand splits a command line into words, honouring single and
+// double quotes
+func splitCommand(command string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+
+	for _, r := range command {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote in command", quote)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
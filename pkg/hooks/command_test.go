@@ -0,0 +1,100 @@
+package hooks
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kubenoops-ai/google-drive-downloader/pkg/drive"
+)
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		command string
+		want    []string
+		wantErr bool
+	}{
+		{command: "echo {{.Path}}", want: []string{"echo", "{{.Path}}"}},
+		{command: `ffmpeg -i "{{.Path}}" 'out file.mp3'`, want: []string{"ffmpeg", "-i", "{{.Path}}", "out file.mp3"}},
+		{command: `  spaced   out  `, want: []string{"spaced", "out"}},
+		{command: `echo ""`, want: []string{"echo", ""}},
+		{command: `echo "unterminated`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			got, err := splitCommand(tt.command)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitCommand(%q) = %q, want %q", tt.command, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandHookArgsKeepsValuesInOneArgument(t *testing.T) {
+	hook, err := NewCommandHook("index --id {{.ID}} {{.Path}} {{.DrivePath}}", 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	file := drive.FileInfo{ID: "abc", Path: "notes; rm -rf ~.txt"}
+	args, err := hook.Args(file, "/tmp/out/notes; rm -rf ~.txt")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"index", "--id", "abc", "/tmp/out/notes; rm -rf ~.txt", "notes; rm -rf ~.txt"}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("Args() = %q, want %q", args, want)
+	}
+}
+
+func TestCommandHookRun(t *testing.T) {
+	hook, err := NewCommandHook("echo downloaded {{.Name}}", time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	output, err := hook.Run(context.Background(), drive.FileInfo{Name: "a.txt"}, "/tmp/a.txt")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.TrimSpace(string(output)) != "downloaded a.txt" {
+		t.Errorf("output = %q", output)
+	}
+
+	hook, _ = NewCommandHook("false", time.Second)
+	if _, err := hook.Run(context.Background(), drive.FileInfo{}, "/tmp/a.txt"); err == nil {
+		t.Error("expected error for non-zero exit")
+	}
+
+	hook, _ = NewCommandHook("sleep 5", 50*time.Millisecond)
+	if _, err := hook.Run(context.Background(), drive.FileInfo{}, "/tmp/a.txt"); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("error = %v, want timeout", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	hook, _ = NewCommandHook("sleep 5", 0)
+	if _, err := hook.Run(ctx, drive.FileInfo{}, "/tmp/a.txt"); err == nil || !strings.Contains(err.Error(), "cancelled") {
+		t.Errorf("error = %v, want cancellation", err)
+	}
+}
+
+func TestNewCommandHookInvalidTemplate(t *testing.T) {
+	if _, err := NewCommandHook("echo {{.Path", 0); err == nil {
+		t.Error("expected error for invalid template")
+	}
+	if _, err := NewCommandHook("   ", 0); err == nil {
+		t.Error("expected error for empty command")
+	}
+}
@@ -0,0 +1,190 @@
+package transform
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// RulePair is a single pattern/format rule of a ChainTransformer
+type RulePair struct {
+	Pattern string
+	Format  string
+}
+
+func (r RulePair) String() string {
+	return r.Pattern + "=>" + r.Format
+}
+
+// ChainTransformer transforms paths with the first of several rules whose
+// pattern matches
+type ChainTransformer struct {
+	rules        []RulePair
+	transformers []*PathTransformer
+
+	// unmatchedDir, when set, holds paths matching no rule; see SetUnmatchedDir
+	unmatchedDir string
+}
+
+// NewChainTransformer creates a ChainTransformer from rules, tried in order
+func NewChainTransformer(rules []RulePair) (*ChainTransformer, error) {
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("at least one rule is required")
+	}
+
+	chain := &ChainTransformer{rules: rules}
+	for i, rule := range rules {
+		t, err := NewPathTransformer(rule.Pattern, rule.Format)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d (%s): %v", i+1, rule, err)
+		}
+		chain.transformers = append(chain.transformers, t)
+	}
+	return chain, nil
+}
+
+// Rules returns the rules of the chain in the order they are tried
+func (c *ChainTransformer) Rules() []RulePair {
+	return c.rules
+}
+
+// SetUnmatchedDir makes Transform place paths that match no rule under dir,
+// a directory relative to the output directory, keeping their original path
+// beneath it, instead of failing. Paths matching a rule whose format fails
+// still fail.
+func (c *ChainTransformer) SetUnmatchedDir(dir string) error {
+	dir, ok := cleanSubdir(dir)
+	if !ok {
+		return fmt.Errorf("unmatched directory must be a relative directory inside the output directory")
+	}
+	c.unmatchedDir = dir
+	return nil
+}
+
+// UnmatchedDir returns the directory set by SetUnmatchedDir, or "" if none
+func (c *ChainTransformer) UnmatchedDir() string {
+	return c.unmatchedDir
+}
+
+// Transform applies the first rule whose pattern matches the path
+func (c *ChainTransformer) Transform(path string) (string, error) {
+	for i, t := range c.transformers {
+		if !c.matches(i, path) {
+			continue
+		}
+		return t.Transform(path)
+	}
+	if c.unmatchedDir != "" {
+		return c.unmatchedDir + "/" + path, nil
+	}
+	return "", fmt.Errorf("path does not match any of %d rules: %s", len(c.rules), path)
+}
+
+// matches reports whether rule i of the chain applies to path
+func (c *ChainTransformer) matches(i int, path string) bool {
+	return c.transformers[i].pattern.MatchString(path)
+}
+
+// RuleMatch is a rule of a chain that matches a path, with the path it
+// produces or the error it fails with
+type RuleMatch struct {
+	// Index is the rule's position in the chain, from 0
+	Index  int
+	Rule   RulePair
+	Result string
+	Err    error
+}
+
+// MatchAll applies every rule whose pattern matches the path, in the order
+// they are tried. Transform uses only the first.
+func (c *ChainTransformer) MatchAll(path string) []RuleMatch {
+	var matches []RuleMatch
+	for i, t := range c.transformers {
+		if !c.matches(i, path) {
+			continue
+		}
+		result, err := t.Transform(path)
+		matches = append(matches, RuleMatch{Index: i, Rule: c.rules[i], Result: result, Err: err})
+	}
+	return matches
+}
+
+// Ambiguous reports whether rules matching the same path disagree: they
+// produce different paths, or only some of them fail. Overlapping rules that
+// agree are harmless, as it doesn't matter which one is used.
+func Ambiguous(matches []RuleMatch) bool {
+	for i := 1; i < len(matches); i++ {
+		if matches[i].Result != matches[0].Result || (matches[i].Err == nil) != (matches[0].Err == nil) {
+			return true
+		}
+	}
+	return false
+}
+
+// Coverage counts how the paths of a result set fare with a chain
+type Coverage struct {
+	Total       int
+	Transformed int
+	// Failed paths match a rule whose format can't be applied
+	Failed int
+	// Unmatched paths match no rule and keep their original path, under
+	// the unmatched directory when one is set
+	Unmatched int
+	// PerRule is how many paths each rule transformed, by position
+	PerRule []int
+	// Samples holds the first unmatched paths, up to the sample size
+	Samples []string
+}
+
+// Coverage applies the chain to every path as Transform would, keeping up
+// to sample unmatched paths
+func (c *ChainTransformer) Coverage(paths []string, sample int) Coverage {
+	cov := Coverage{Total: len(paths), PerRule: make([]int, len(c.rules))}
+	for _, path := range paths {
+		i := 0
+		for i < len(c.transformers) && !c.matches(i, path) {
+			i++
+		}
+		if i == len(c.transformers) {
+			cov.Unmatched++
+			if len(cov.Samples) < sample {
+				cov.Samples = append(cov.Samples, path)
+			}
+			continue
+		}
+		if _, err := c.transformers[i].Transform(path); err != nil {
+			cov.Failed++
+			continue
+		}
+		cov.Transformed++
+		cov.PerRule[i]++
+	}
+	return cov
+}
+
+// ParseRules reads one "pattern=>format" rule per line. Blank lines and lines
+// starting with "#" are ignored.
+func ParseRules(r io.Reader) ([]RulePair, error) {
+	var rules []RulePair
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		pattern, format, ok := strings.Cut(line, "=>")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected pattern=>format, got %q", lineNum, line)
+		}
+		rules = append(rules, RulePair{
+			Pattern: strings.TrimSpace(pattern),
+			Format:  strings.TrimSpace(format),
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read rules: %v", err)
+	}
+	return rules, nil
+}
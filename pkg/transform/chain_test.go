@@ -0,0 +1,157 @@
+package transform
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRules(t *testing.T) {
+	input := `
+# Zoom transcripts
+(?P<room>[^/]+)/(?P<date>\d{4}-\d{2}-\d{2})/.*\.TRANSCRIPT => ${room}/${date}.TRANSCRIPT
+
+(?P<name>[^/]+)\.mp4=>videos/${name}.mp4
+`
+	rules, err := ParseRules(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []RulePair{
+		{Pattern: `(?P<room>[^/]+)/(?P<date>\d{4}-\d{2}-\d{2})/.*\.TRANSCRIPT`, Format: "${room}/${date}.TRANSCRIPT"},
+		{Pattern: `(?P<name>[^/]+)\.mp4`, Format: "videos/${name}.mp4"},
+	}
+	if !reflect.DeepEqual(rules, want) {
+		t.Errorf("ParseRules() = %v, want %v", rules, want)
+	}
+
+	if _, err := ParseRules(strings.NewReader("# ok\nno separator\n")); err == nil || !contains(err.Error(), "line 2") {
+		t.Errorf("ParseRules() error = %v, want line 2 error", err)
+	}
+}
+
+func TestChainTransformerMatchAll(t *testing.T) {
+	chain, err := NewChainTransformer([]RulePair{
+		{Pattern: `(?P<name>[^/]+)\.TRANSCRIPT$`, Format: "transcripts/${name}.txt"},
+		{Pattern: `^Zoom/(?P<name>[^/]+)$`, Format: "zoom/${name}"},
+		{Pattern: `^(?P<dir>[^/]+)/(?P<name>[^/]+)\.TRANSCRIPT$`, Format: "transcripts/${name}.txt"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	matches := chain.MatchAll("Zoom/a.TRANSCRIPT")
+	var results []string
+	for _, m := range matches {
+		results = append(results, m.Result)
+	}
+	if want := []string{"transcripts/a.txt", "zoom/a.TRANSCRIPT", "transcripts/a.txt"}; !reflect.DeepEqual(results, want) {
+		t.Errorf("MatchAll() results = %v, want %v", results, want)
+	}
+	if matches[1].Index != 1 || matches[1].Rule.Format != "zoom/${name}" {
+		t.Errorf("MatchAll()[1] = %+v, want rule 1", matches[1])
+	}
+	if !Ambiguous(matches) {
+		t.Error("expected rules producing different paths to be ambiguous")
+	}
+
+	// Rules 0 and 2 overlap but agree
+	if matches := chain.MatchAll("Meet/b.TRANSCRIPT"); len(matches) != 2 || Ambiguous(matches) {
+		t.Errorf("MatchAll(Meet/b.TRANSCRIPT) = %+v, want two agreeing matches", matches)
+	}
+	if matches := chain.MatchAll("Meet/b.mp4"); len(matches) != 0 || Ambiguous(matches) {
+		t.Errorf("MatchAll(Meet/b.mp4) = %+v, want no matches", matches)
+	}
+}
+
+func TestChainTransformer(t *testing.T) {
+	chain, err := NewChainTransformer([]RulePair{
+		{Pattern: `(?P<name>[^/]+)\.TRANSCRIPT$`, Format: "transcripts/${name}.txt"},
+		{Pattern: `(?P<name>[^/]+)\.(?P<ext>mp4|m4a)$`, Format: "media/${name}.${ext}"},
+		{Pattern: `(?P<name>[^/]+)$`, Format: "other/${name}"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := map[string]string{
+		"Zoom/a.TRANSCRIPT": "transcripts/a.txt",
+		"Zoom/a.mp4":        "media/a.mp4",
+		"Zoom/notes.doc":    "other/notes.doc",
+	}
+	for path, want := range tests {
+		got, err := chain.Transform(path)
+		if err != nil {
+			t.Errorf("Transform(%q) unexpected error: %v", path, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("Transform(%q) = %q, want %q", path, got, want)
+		}
+	}
+
+	if _, err := NewChainTransformer([]RulePair{{Pattern: "(?P<a>x)", Format: "${a}"}, {Pattern: "[", Format: "x"}}); err == nil || !contains(err.Error(), "rule 2") {
+		t.Errorf("NewChainTransformer() error = %v, want rule 2 error", err)
+	}
+}
+
+func TestChainTransformerCoverage(t *testing.T) {
+	chain, err := NewChainTransformer([]RulePair{
+		{Pattern: `^(?P<name>[^/]+)\.TRANSCRIPT$`, Format: "transcripts/${name}.txt"},
+		{Pattern: `^Zoom/(?P<name>[^/]+)$`, Format: "zoom/${name}"},
+		{Pattern: `^Notes/(?P<name>[^/]+)$`, Format: "${name}/${missing}"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cov := chain.Coverage([]string{
+		"a.TRANSCRIPT", "Zoom/b.mp4", "Zoom/c.mp4", "Notes/d.txt",
+		"Other/e.txt", "Other/f.txt", "g.pdf",
+	}, 2)
+	want := Coverage{
+		Total:       7,
+		Transformed: 3,
+		Failed:      1,
+		Unmatched:   3,
+		PerRule:     []int{1, 2, 0},
+		Samples:     []string{"Other/e.txt", "Other/f.txt"},
+	}
+	if !reflect.DeepEqual(cov, want) {
+		t.Errorf("Coverage() = %+v, want %+v", cov, want)
+	}
+}
+
+func TestChainTransformerUnmatchedDir(t *testing.T) {
+	chain, err := NewChainTransformer([]RulePair{
+		{Pattern: `^Zoom/(?P<name>[^/]+)$`, Format: "zoom/${name}"},
+		{Pattern: `^Notes/(?P<name>[^/]+)$`, Format: "${name}/${missing}"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := chain.SetUnmatchedDir("quarantine/"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := map[string]string{
+		"Zoom/a.mp4":      "zoom/a.mp4",
+		"Other/sub/b.txt": "quarantine/Other/sub/b.txt",
+		"c.TRANSCRIPT":    "quarantine/c.TRANSCRIPT",
+	}
+	for path, want := range tests {
+		if got, err := chain.Transform(path); err != nil || got != want {
+			t.Errorf("Transform(%q) = %q, %v, want %q", path, got, err, want)
+		}
+	}
+	// A rule that matches but fails isn't quarantined
+	if _, err := chain.Transform("Notes/d.txt"); err == nil {
+		t.Error("Transform(Notes/d.txt): expected the failing rule's error")
+	}
+
+	for _, dir := range []string{"", ".", "/tmp/q", "../q", "a/../.."} {
+		if err := chain.SetUnmatchedDir(dir); err == nil {
+			t.Errorf("SetUnmatchedDir(%q): expected an error", dir)
+		}
+	}
+}
@@ -1,12 +1,16 @@
 package transform
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
 	"fmt"
+	pathpkg "path"
 	"regexp"
 	"strings"
 )
 
-// PathTransformer handles path transformation using regex patterns and format strings
+// PathTransformer handles path transformation using regex patterns and format
+// strings. Paths use "/" as separator on every OS, so patterns can too.
 type PathTransformer struct {
 	pattern *regexp.Regexp
 	format  string
@@ -75,3 +79,74 @@ func (t *PathTransformer) Transform(path string) (string, error) {
 
 	return result, nil
 }
+
+// CollapsePath limits the number of directories in path to maxDirs by joining
+// the directories beyond the limit with "_" into a single directory name.
+// Paths use "/" as separator on every OS. A maxDirs of 0 or less leaves the
+// path unchanged.
+func CollapsePath(path string, maxDirs int) string {
+	parts := strings.Split(path, "/")
+	dirs, name := parts[:len(parts)-1], parts[len(parts)-1]
+	if maxDirs <= 0 || len(dirs) <= maxDirs {
+		return path
+	}
+
+	collapsed := append(dirs[:maxDirs-1:maxDirs-1], strings.Join(dirs[maxDirs-1:], "_"))
+	return pathpkg.Join(append(collapsed, name)...)
+}
+
+// ValidateShards checks that a shard count can be expressed as a whole number
+// of hex digits: 16, 256, 4096 and so on. 0 disables sharding.
+func ValidateShards(shards int) error {
+	if shards == 0 {
+		return nil
+	}
+	_, err := shardDigits(shards)
+	return err
+}
+
+func shardDigits(shards int) (int, error) {
+	digits := 0
+	for n := shards; n > 1; n /= 16 {
+		if n%16 != 0 {
+			return 0, fmt.Errorf("invalid shard count %d (expected a power of 16 such as 16, 256 or 4096)", shards)
+		}
+		digits++
+	}
+	if digits == 0 || digits > sha1.Size*2 {
+		return 0, fmt.Errorf("invalid shard count %d (expected a power of 16 such as 16, 256 or 4096)", shards)
+	}
+	return digits, nil
+}
+
+// ShardPath places the file into one of shards subdirectories, inserted just
+// above the file name and named after the leading hex digits of the SHA-1 of
+// the file name, as in git's object store. The shard depends only on the
+// name, so a file always lands in the same shard. A shards of 0 leaves the
+// path unchanged.
+func ShardPath(path string, shards int) string {
+	digits, err := shardDigits(shards)
+	if err != nil {
+		return path
+	}
+
+	dir, name := pathpkg.Split(path)
+	sum := sha1.Sum([]byte(name))
+	return pathpkg.Join(dir, hex.EncodeToString(sum[:])[:digits], name)
+}
+
+// NoExtensionDir holds the files ExtensionPath finds no extension for
+const NoExtensionDir = "no-ext"
+
+// ExtensionPath places the file under a top-level directory named after
+// the extension of its name, as written and without the dot: "mp4" for
+// "a/talk.mp4" and "gz" for "b.tar.gz". Names without an extension, and
+// hidden files such as ".bashrc", go under NoExtensionDir.
+func ExtensionPath(path string) string {
+	name := strings.TrimLeft(pathpkg.Base(path), ".")
+	dir := NoExtensionDir
+	if ext := pathpkg.Ext(name); len(ext) > 1 {
+		dir = ext[1:]
+	}
+	return dir + "/" + path
+}
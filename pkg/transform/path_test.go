@@ -153,6 +153,10 @@ func TestPathTransformer_Transform(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			transformer, err := NewPathTransformer(tt.pattern, tt.format)
 			if err != nil {
+				// Some formats are rejected when the transformer is created
+				if tt.wantErr && contains(err.Error(), tt.errContains) {
+					return
+				}
 				t.Fatalf("failed to create transformer: %v", err)
 			}
 
@@ -175,6 +179,117 @@ func TestPathTransformer_Transform(t *testing.T) {
 	}
 }
 
+func TestCollapsePath(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		maxDirs int
+		want    string
+	}{
+		{
+			name:    "shallow path unchanged",
+			path:    "a/b/file.txt",
+			maxDirs: 2,
+			want:    "a/b/file.txt",
+		},
+		{
+			name:    "overflow joined into last directory",
+			path:    "a/b/c/d/file.txt",
+			maxDirs: 2,
+			want:    "a/b_c_d/file.txt",
+		},
+		{
+			name:    "single directory",
+			path:    "Zoom Recordings/2025/apr/file.TRANSCRIPT",
+			maxDirs: 1,
+			want:    "Zoom Recordings_2025_apr/file.TRANSCRIPT",
+		},
+		{
+			name:    "disabled",
+			path:    "a/b/c/file.txt",
+			maxDirs: 0,
+			want:    "a/b/c/file.txt",
+		},
+		{
+			name:    "file without directories",
+			path:    "file.txt",
+			maxDirs: 1,
+			want:    "file.txt",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CollapsePath(tt.path, tt.maxDirs); got != tt.want {
+				t.Errorf("CollapsePath(%q, %d) = %q, want %q", tt.path, tt.maxDirs, got, tt.want)
+			}
+		})
+	}
+}
+
 func contains(s, substr string) bool {
 	return strings.Contains(s, substr)
 }
+
+func TestShardPath(t *testing.T) {
+	// sha1("a.txt") = cfc7b4885384957ae445bc14914d4588f607651c
+	tests := []struct {
+		name   string
+		path   string
+		shards int
+		want   string
+	}{
+		{name: "disabled", path: "x/a.txt", shards: 0, want: "x/a.txt"},
+		{name: "16 shards", path: "a.txt", shards: 16, want: "c/a.txt"},
+		{name: "256 shards", path: "x/a.txt", shards: 256, want: "x/cf/a.txt"},
+		{name: "4096 shards", path: "x/y/a.txt", shards: 4096, want: "x/y/cfc/a.txt"},
+		{name: "same name, same shard", path: "other/a.txt", shards: 256, want: "other/cf/a.txt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ShardPath(tt.path, tt.shards); got != tt.want {
+				t.Errorf("ShardPath(%q, %d) = %q, want %q", tt.path, tt.shards, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateShards(t *testing.T) {
+	for _, shards := range []int{0, 16, 256, 4096, 65536} {
+		if err := ValidateShards(shards); err != nil {
+			t.Errorf("ValidateShards(%d) unexpected error: %v", shards, err)
+		}
+	}
+	for _, shards := range []int{-16, 1, 10, 100, 512} {
+		if err := ValidateShards(shards); err == nil {
+			t.Errorf("ValidateShards(%d) expected error, got nil", shards)
+		}
+	}
+}
+
+func TestExtensionPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "a/talk.mp4", want: "mp4/a/talk.mp4"},
+		{path: "2025/notes.TRANSCRIPT", want: "TRANSCRIPT/2025/notes.TRANSCRIPT"},
+		{path: "backup.tar.gz", want: "gz/backup.tar.gz"},
+		{path: "v1.2/release.notes.md", want: "md/v1.2/release.notes.md"},
+		{path: "v1.2/README", want: "no-ext/v1.2/README"},
+		{path: "home/.bashrc", want: "no-ext/home/.bashrc"},
+		{path: "home/.config.json", want: "json/home/.config.json"},
+		{path: "trailing.", want: "no-ext/trailing."},
+	}
+
+	for _, tt := range tests {
+		if got := ExtensionPath(tt.path); got != tt.want {
+			t.Errorf("ExtensionPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+	// Composes with collapsing, which runs first
+	if got := ExtensionPath(CollapsePath("a/b/c/talk.mp4", 1)); got != "mp4/a_b_c/talk.mp4" {
+		t.Errorf("collapsed and routed by extension = %q", got)
+	}
+}
@@ -0,0 +1,73 @@
+package transform
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// replacementRef matches the group references of a regexp replacement
+// string: $$, ${name} and $name
+var replacementRef = regexp.MustCompile(`\$(?:\$|\{(\w+)\}|(\w+))`)
+
+// RegexReplacer rewrites every match of a pattern within a path, sed-style,
+// leaving the rest of the path untouched
+type RegexReplacer struct {
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+// NewRegexReplacer creates a RegexReplacer. The replacement uses
+// regexp.ReplaceAllString syntax: $1 or ${1} for numbered groups and
+// ${name} for named ones.
+func NewRegexReplacer(pattern, replacement string) (*RegexReplacer, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("pattern must be non-empty")
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex pattern: %v", err)
+	}
+
+	// regexp expands unknown groups to nothing; catch typos instead
+	for _, m := range replacementRef.FindAllStringSubmatch(replacement, -1) {
+		name := m[1] + m[2]
+		if name == "" {
+			continue // $$
+		}
+		if n, err := strconv.Atoi(name); err == nil {
+			if n > re.NumSubexp() {
+				return nil, fmt.Errorf("replacement refers to group %d, but the pattern has %d", n, re.NumSubexp())
+			}
+		} else if re.SubexpIndex(name) < 0 {
+			return nil, fmt.Errorf("replacement refers to unknown group %q (write ${1}x rather than $1x before letters)", name)
+		}
+	}
+
+	return &RegexReplacer{pattern: re, replacement: replacement}, nil
+}
+
+// ParseReplacement parses a "pattern=>replacement" rule into a RegexReplacer
+func ParseReplacement(rule string) (*RegexReplacer, error) {
+	pattern, replacement, ok := strings.Cut(rule, "=>")
+	if !ok {
+		return nil, fmt.Errorf("expected pattern=>replacement, got %q", rule)
+	}
+	return NewRegexReplacer(pattern, replacement)
+}
+
+// Transform replaces every match of the pattern in path. A path the pattern
+// doesn't match is returned unchanged.
+func (r *RegexReplacer) Transform(path string) (string, error) {
+	result := r.pattern.ReplaceAllString(path, r.replacement)
+	if result == "" {
+		return "", fmt.Errorf("replacement leaves nothing of path %s", path)
+	}
+	return result, nil
+}
+
+func (r *RegexReplacer) String() string {
+	return r.pattern.String() + "=>" + r.replacement
+}
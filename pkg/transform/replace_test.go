@@ -0,0 +1,52 @@
+package transform
+
+import "testing"
+
+func TestRegexReplacer(t *testing.T) {
+	tests := []struct {
+		rule    string
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{rule: ` =>`, path: "Zoom Recordings/a b.mp4", want: "ZoomRecordings/ab.mp4"},
+		{rule: `^Zoom Recordings/=>`, path: "Zoom Recordings/2025/a.mp4", want: "2025/a.mp4"},
+		{rule: `^Zoom Recordings/=>`, path: "Other/a.mp4", want: "Other/a.mp4"},
+		{rule: `(\d{2})-(\d{2})-(\d{4})=>$3-$1-$2`, path: "calls/04-01-2025/a.mp4", want: "calls/2025-04-01/a.mp4"},
+		{rule: `(?P<name>[^/]+)\.TRANSCRIPT$=>${name}.txt`, path: "calls/a.TRANSCRIPT", want: "calls/a.txt"},
+		{rule: `\.mp4$=>$$.mp4`, path: "a.mp4", want: "a$.mp4"},
+		{rule: `.*=>`, path: "a.mp4", wantErr: true},
+	}
+	for _, tt := range tests {
+		r, err := ParseReplacement(tt.rule)
+		if err != nil {
+			t.Errorf("ParseReplacement(%q) unexpected error: %v", tt.rule, err)
+			continue
+		}
+		got, err := r.Transform(tt.path)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: Transform(%q) = %q, want error", tt.rule, tt.path, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%s: Transform(%q) = %q, %v, want %q", tt.rule, tt.path, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseReplacementErrors(t *testing.T) {
+	for _, rule := range []string{
+		"no separator",
+		"=>x",
+		"[=>x",
+		"(a)=>$2",
+		"(?P<name>a)=>${nmae}",
+		"(a)=>$1x",
+	} {
+		if _, err := ParseReplacement(rule); err == nil {
+			t.Errorf("ParseReplacement(%q) expected error", rule)
+		}
+	}
+}
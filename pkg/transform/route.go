@@ -0,0 +1,71 @@
+package transform
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Route places files whose path matches Glob under the directory Dir
+type Route struct {
+	Glob string
+	Dir  string
+}
+
+// ParseRoute parses a "glob=>subdir" route. The glob uses path.Match syntax;
+// one without a "/" is matched against the file name alone, so "*.mp4"
+// matches mp4 files in any folder.
+func ParseRoute(rule string) (Route, error) {
+	glob, dir, ok := strings.Cut(rule, "=>")
+	if !ok {
+		return Route{}, fmt.Errorf("expected glob=>subdir, got %q", rule)
+	}
+	glob, dir = strings.TrimSpace(glob), strings.TrimSpace(dir)
+	if glob == "" {
+		return Route{}, fmt.Errorf("route %q: glob must be non-empty", rule)
+	}
+	if _, err := path.Match(glob, ""); err != nil {
+		return Route{}, fmt.Errorf("route %q: invalid glob: %v", rule, err)
+	}
+
+	dir, ok = cleanSubdir(dir)
+	if !ok {
+		return Route{}, fmt.Errorf("route %q: subdir must be a relative directory inside the output directory", rule)
+	}
+	return Route{Glob: glob, Dir: dir}, nil
+}
+
+// cleanSubdir cleans a directory that must stay inside the output
+// directory, reporting whether it does
+func cleanSubdir(dir string) (string, bool) {
+	dir = path.Clean(dir)
+	if dir == "." || path.IsAbs(dir) || dir == ".." || strings.HasPrefix(dir, "../") {
+		return "", false
+	}
+	return dir, true
+}
+
+func (r Route) String() string {
+	return r.Glob + "=>" + r.Dir
+}
+
+// Matches reports whether the route applies to a path. Paths use "/" as
+// separator on every OS.
+func (r Route) Matches(p string) bool {
+	if !strings.Contains(r.Glob, "/") {
+		p = path.Base(p)
+	}
+	ok, _ := path.Match(r.Glob, p)
+	return ok
+}
+
+// RouteDir returns the directory of the first route matching a path, or ""
+// when none does
+func RouteDir(routes []Route, p string) string {
+	for _, r := range routes {
+		if r.Matches(p) {
+			return r.Dir
+		}
+	}
+	return ""
+}
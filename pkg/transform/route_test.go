@@ -0,0 +1,47 @@
+package transform
+
+import (
+	"testing"
+)
+
+func TestParseRoute(t *testing.T) {
+	route, err := ParseRoute(" *.mp4 => videos/ ")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if route != (Route{Glob: "*.mp4", Dir: "videos"}) {
+		t.Errorf("route = %+v", route)
+	}
+
+	for _, rule := range []string{"*.mp4", "=>videos", "[=>videos", "*.mp4=>", "*.mp4=>/videos", "*.mp4=>../videos", "*.mp4=>a/../.."} {
+		if _, err := ParseRoute(rule); err == nil {
+			t.Errorf("ParseRoute(%q): expected an error", rule)
+		}
+	}
+}
+
+func TestRouteDir(t *testing.T) {
+	routes := []Route{
+		{Glob: "*.TRANSCRIPT", Dir: "transcripts"},
+		{Glob: "Zoom Recordings/*/*.mp4", Dir: "zoom"},
+		{Glob: "*.mp4", Dir: "videos"},
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"Zoom Recordings/2025-04-01/call.TRANSCRIPT", "transcripts"},
+		{"Zoom Recordings/2025-04-01/call.mp4", "zoom"},
+		{"Other/deep/clip.mp4", "videos"},
+		{"clip.mp4", "videos"},
+		{"notes.txt", ""},
+		// A glob with a "/" must match the whole path
+		{"Zoom Recordings/call.TRANSCRIPT.bak", ""},
+	}
+	for _, tt := range tests {
+		if got := RouteDir(routes, tt.path); got != tt.want {
+			t.Errorf("RouteDir(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
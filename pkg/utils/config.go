@@ -1,8 +1,12 @@
 package utils
 
+import "github.com/kubenoops-ai/google-drive-downloader/pkg/drive"
+
 type Config struct {
-	FolderID    string
+	FolderIDs   []string
 	Pattern     string
+	Extensions  []string
+	OrderBy     drive.SortOrder
 	MaxDepth    int
 	DryRun      bool
 	OutputDir   string
@@ -15,6 +19,7 @@ type Config struct {
 func NewDefaultConfig() *Config {
 	return &Config{
 		MaxDepth:    -1, // -1 means unlimited depth
+		OrderBy:     drive.DefaultSortOrder,
 		DryRun:      true,
 		OutputDir:   "downloads",
 		Credentials: "credentials.json",
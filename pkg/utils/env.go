@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ExpandEnv replaces $VAR and ${VAR} in value with the values of those
+// environment variables. Unlike os.ExpandEnv, it fails when a variable is
+// not set, rather than silently dropping it from a path.
+func ExpandEnv(value string) (string, error) {
+	var missing []string
+	expanded := os.Expand(value, func(name string) string {
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+		}
+		return v
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("environment variable %s is not set", strings.Join(missing, ", "))
+	}
+	return expanded, nil
+}
+
+// ExpandEnvFlags applies ExpandEnv to the values of the named flags of fs
+func ExpandEnvFlags(fs *flag.FlagSet, names ...string) error {
+	for _, name := range names {
+		f := fs.Lookup(name)
+		if f == nil {
+			return fmt.Errorf("no flag -%s", name)
+		}
+		expanded, err := ExpandEnv(f.Value.String())
+		if err != nil {
+			return fmt.Errorf("invalid -%s: %v", name, err)
+		}
+		if err := f.Value.Set(expanded); err != nil {
+			return fmt.Errorf("invalid -%s: %v", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"flag"
+	"strings"
+	"testing"
+)
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("ARCHIVE_ROOT", "/mnt/archive")
+
+	tests := map[string]string{
+		"$ARCHIVE_ROOT/drive":           "/mnt/archive/drive",
+		"${ARCHIVE_ROOT}-2025":          "/mnt/archive-2025",
+		"downloads/{{.Owner}}":          "downloads/{{.Owner}}",
+		"$ARCHIVE_ROOT/{{.Owner}}/data": "/mnt/archive/{{.Owner}}/data",
+	}
+	for value, want := range tests {
+		got, err := ExpandEnv(value)
+		if err != nil || got != want {
+			t.Errorf("ExpandEnv(%q) = %q, %v, want %q", value, got, err, want)
+		}
+	}
+
+	if _, err := ExpandEnv("$GDD_TEST_UNSET/archive"); err == nil || !strings.Contains(err.Error(), "GDD_TEST_UNSET") {
+		t.Errorf("ExpandEnv(unset) error = %v, want it to name the variable", err)
+	}
+}
+
+func TestExpandEnvFlags(t *testing.T) {
+	t.Setenv("ARCHIVE_ROOT", "/mnt/archive")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	outputDir := fs.String("output-dir", "", "")
+	pattern := fs.String("pattern", "", "")
+	if err := fs.Parse([]string{"-output-dir", "$ARCHIVE_ROOT/drive", "-pattern", `\.mp4$`}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ExpandEnvFlags(fs, "output-dir"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *outputDir != "/mnt/archive/drive" {
+		t.Errorf("output-dir = %q, want it expanded", *outputDir)
+	}
+	if *pattern != `\.mp4$` {
+		t.Errorf("pattern = %q, want it left alone", *pattern)
+	}
+
+	fs.Set("output-dir", "$GDD_TEST_UNSET")
+	if err := ExpandEnvFlags(fs, "output-dir"); err == nil || !strings.Contains(err.Error(), "-output-dir") {
+		t.Errorf("ExpandEnvFlags(unset) error = %v, want it to name the flag", err)
+	}
+}